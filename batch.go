@@ -0,0 +1,153 @@
+package tesei
+
+import "time"
+
+// BatchJob is a job that groups messages into batches of up to Size messages.
+// Each batch is emitted as one new message with zero Data and the grouped messages in "batch" metadata,
+// the last batch is emitted when the input is closed, even if it is not full.
+// Downstream jobs get the messages of a batch with BatchItems, UnbatchJob emits them again one by one.
+// Messages added to a batch release their WithMaxInFlight slots, the batch message doesn't hold one.
+type BatchJob[T any] struct {
+	// Size is the maximal number of messages in a batch. Defaults to 1.
+	Size int
+}
+
+func (b BatchJob[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+
+	size := max(b.Size, 1)
+	var batch []*Message[T]
+
+	flush := func() bool {
+		msg := batchMessage(batch)
+		batch = nil
+
+		select {
+		case out <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				if len(batch) > 0 {
+					flush()
+				}
+				return
+			}
+
+			batch = append(batch, msg)
+			ctx.Release(msg.ID)
+			if len(batch) >= size && !flush() {
+				return
+			}
+		}
+	}
+}
+
+// batchMessage creates a message holding the batch.
+func batchMessage[T any](batch []*Message[T]) *Message[T] {
+	var zero T
+	msg := NewMessage(zero)
+	msg.Metadata["batch"] = batch
+	return msg
+}
+
+// BatchItems returns the messages grouped into a batch message by BatchJob or WindowJob, or nil for other messages.
+func BatchItems[T any](msg *Message[T]) []*Message[T] {
+	items, _ := msg.Metadata["batch"].([]*Message[T])
+	return items
+}
+
+// UnbatchJob is a job that emits the messages of batches created by BatchJob or WindowJob one by one.
+// Other messages are passed through.
+type UnbatchJob[T any] struct{}
+
+func (u UnbatchJob[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+
+			items := BatchItems(msg)
+			if items == nil {
+				items = []*Message[T]{msg}
+			}
+			for _, item := range items {
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// WindowJob is a job that groups messages by time. The first buffered message opens a window,
+// and Duration after it the buffered messages are emitted as one batch message, like the ones of BatchJob.
+// The last window is emitted when the input is closed, empty windows are never emitted.
+// Like with BatchJob, buffered messages release their WithMaxInFlight slots.
+type WindowJob[T any] struct {
+	// Duration is the length of a window.
+	Duration time.Duration
+}
+
+func (w WindowJob[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+
+	// the timer runs only while a window is open
+	timer := time.NewTimer(w.Duration)
+	timer.Stop()
+	defer timer.Stop()
+
+	var batch []*Message[T]
+	flush := func() bool {
+		timer.Stop()
+		msg := batchMessage(batch)
+		batch = nil
+
+		select {
+		case out <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if len(batch) > 0 && !flush() {
+				return
+			}
+		case msg, ok := <-in:
+			if !ok {
+				if len(batch) > 0 {
+					flush()
+				}
+				return
+			}
+
+			if len(batch) == 0 {
+				timer.Reset(w.Duration)
+			}
+			batch = append(batch, msg)
+			ctx.Release(msg.ID)
+		}
+	}
+}
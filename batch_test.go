@@ -0,0 +1,212 @@
+package tesei
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []string
+		size     int
+		expected []string
+	}{
+		{"Exact multiple", []string{"a", "b", "c", "d", "e", "f"}, 3, []string{"abc", "def"}},
+		{"Remainder", []string{"a", "b", "c", "d", "e", "f", "g"}, 3, []string{"abc", "def", "g"}},
+		{"Smaller than size", []string{"a", "b"}, 5, []string{"ab"}},
+		{"Empty", nil, 3, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batches []string
+			var unbatched []*Message[string]
+			_, err := NewPipeline[string]().
+				Sequential(Slice[string]{Items: tt.items}).
+				Batch(tt.size).
+				Sequential(TransformJob[string]{
+					Transform: func(msg *Message[string]) (*Message[string], error) {
+						var sb strings.Builder
+						for _, item := range BatchItems(msg) {
+							sb.WriteString(item.Data)
+						}
+						batches = append(batches, sb.String())
+						return msg, nil
+					},
+				}).
+				Sequential(UnbatchJob[string]{}).
+				Sequential(Collect[string]{Items: &unbatched}).
+				Sequential(End[string]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(batches, tt.expected) {
+				t.Errorf("batches = %v, want %v", batches, tt.expected)
+			}
+			if len(unbatched) != len(tt.items) {
+				t.Fatalf("got %d unbatched messages, want %d", len(unbatched), len(tt.items))
+			}
+			for i, msg := range unbatched {
+				if msg.Data != tt.items[i] {
+					t.Errorf("message %d = %q, want %q", i, msg.Data, tt.items[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBatchWithMaxInFlight(t *testing.T) {
+	items := make([]int, 20)
+	for i := range items {
+		items[i] = i
+	}
+
+	tests := []struct {
+		name  string
+		stage func(*Pipeline[int]) *Pipeline[int]
+	}{
+		{"Batch", func(p *Pipeline[int]) *Pipeline[int] { return p.Batch(5) }},
+		{"Window", func(p *Pipeline[int]) *Pipeline[int] { return p.Sequential(WindowJob[int]{Duration: time.Hour}) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batches []*Message[int]
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			p := NewPipeline[int]().
+				WithMaxInFlight(3).
+				Sequential(Slice[int]{Items: items})
+			_, err := tt.stage(p).
+				Sequential(Collect[int]{Items: &batches}).
+				Sequential(End[int]{}).
+				Build().
+				Start(ctx)
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			count := 0
+			for _, batch := range batches {
+				count += len(BatchItems(batch))
+			}
+			if count != len(items) {
+				t.Errorf("got %d batched messages, want %d", count, len(items))
+			}
+		})
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	base, cancel := context.WithCancel(context.Background())
+	ctx := NewThread(base, 1)
+
+	in := make(chan *Message[string])
+	out := make(chan *Message[string], 1)
+	done := make(chan struct{})
+	go func() {
+		BatchJob[string]{Size: 3}.Run(ctx, in, out)
+		close(done)
+	}()
+
+	// the input stays open, the job is buffering a partial batch
+	in <- NewMessage("a")
+	in <- NewMessage("b")
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("BatchJob didn't stop on cancellation")
+	}
+	if msg, ok := <-out; ok {
+		t.Errorf("Expected no batch after cancellation, got %v", BatchItems(msg))
+	}
+}
+
+func TestWindow(t *testing.T) {
+	ctx := NewThread(context.Background(), 1)
+	in := make(chan *Message[string])
+	out := make(chan *Message[string], 10)
+	done := make(chan struct{})
+	go func() {
+		WindowJob[string]{Duration: 50 * time.Millisecond}.Run(ctx, in, out)
+		close(done)
+	}()
+
+	receive := func(timeout time.Duration) []string {
+		select {
+		case msg := <-out:
+			var items []string
+			for _, item := range BatchItems(msg) {
+				items = append(items, item.Data)
+			}
+			return items
+		case <-time.After(timeout):
+			return nil
+		}
+	}
+
+	// the window opens with the first message and is flushed Duration after it
+	start := time.Now()
+	in <- NewMessage("a")
+	time.Sleep(20 * time.Millisecond)
+	in <- NewMessage("b")
+	if items := receive(time.Second); !reflect.DeepEqual(items, []string{"a", "b"}) {
+		t.Fatalf("first window = %v, want [a b]", items)
+	}
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("Expected the window to be flushed after 50ms, got %v", elapsed)
+	}
+
+	// no message, no window
+	if items := receive(120 * time.Millisecond); items != nil {
+		t.Errorf("Expected no empty window, got %v", items)
+	}
+
+	// the next window starts with the next message, the partial one is flushed on close
+	in <- NewMessage("c")
+	close(in)
+	if items := receive(20 * time.Millisecond); !reflect.DeepEqual(items, []string{"c"}) {
+		t.Errorf("last window = %v, want [c]", items)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("WindowJob didn't stop after the input was closed")
+	}
+	if _, ok := <-out; ok {
+		t.Error("Expected the output to be closed")
+	}
+}
+
+func TestWindowCancel(t *testing.T) {
+	base, cancel := context.WithCancel(context.Background())
+	ctx := NewThread(base, 1)
+
+	in := make(chan *Message[string])
+	out := make(chan *Message[string], 1)
+	done := make(chan struct{})
+	go func() {
+		WindowJob[string]{Duration: time.Hour}.Run(ctx, in, out)
+		close(done)
+	}()
+
+	in <- NewMessage("a")
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("WindowJob didn't stop on cancellation")
+	}
+}
@@ -0,0 +1,136 @@
+package tesei
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Bridge runs two pipelines of different message types as one, converting the output
+// of the upstream pipeline into the input of the downstream one.
+// The upstream pipeline must start with a source job, the downstream one usually ends with End.
+type Bridge[In, Out any] struct {
+	Upstream   Executor[In]
+	Downstream Executor[Out]
+	// Convert builds a downstream message from an upstream one, see ConvertMessage.
+	// If it returns nil, nil, the message is filtered out. A returned error is stored in the message.
+	// Messages with errors are not converted, they are passed on with zero Data.
+	Convert func(*Message[In]) (*Message[Out], error)
+}
+
+// ConvertMessage creates a message with new data, keeping the ID, metadata and error state of the source message.
+func ConvertMessage[In, Out any](msg *Message[In], data Out) *Message[Out] {
+	n := &Message[Out]{
+		ID:         msg.ID,
+		Data:       data,
+		Metadata:   make(map[string]any, len(msg.Metadata)),
+		Error:      msg.Error,
+		ErrorStage: msg.ErrorStage,
+	}
+	for k, v := range msg.Metadata {
+		n.Metadata[k] = v
+	}
+	return n
+}
+
+// Start runs both pipelines until the downstream one completes.
+// It returns the duration of execution and the first critical error of either pipeline.
+func (b Bridge[In, Out]) Start(baseCtx context.Context) (time.Duration, error) {
+	start := time.Now()
+	base, cancel := context.WithCancel(baseCtx)
+	defer cancel()
+	ctx := NewThread(base, 1)
+
+	input := make(chan *Message[In])
+	close(input)
+	upOut := make(chan *Message[In], defaultBufferSize)
+	downIn := make(chan *Message[Out], defaultBufferSize)
+	downOut := make(chan *Message[Out], defaultBufferSize)
+
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	go func() {
+		b.Upstream.Run(ctx, input, upOut)
+		wg.Done()
+	}()
+	go func() {
+		b.convert(ctx, upOut, downIn)
+		wg.Done()
+	}()
+	go func() {
+		b.Downstream.Run(ctx, downIn, downOut)
+		wg.Done()
+	}()
+	go func() {
+		// downstream pipelines ending with End emit nothing, others are drained here
+		for range downOut {
+		}
+		wg.Done()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case err := <-ctx.Error():
+		cancel()
+		return time.Since(start), fmt.Errorf("Bridge error: %w", err)
+	case <-ctx.Done():
+		wg.Wait()
+		return time.Since(start), ctx.Context.Err()
+	case <-done:
+		// an error may be reported by a job right before it exits
+		if err := ctx.GetError(); err != nil {
+			return time.Since(start), fmt.Errorf("Bridge error: %w", err)
+		}
+	}
+
+	return time.Since(start), nil
+}
+
+func (b Bridge[In, Out]) convert(ctx *Thread, in <-chan *Message[In], out chan<- *Message[Out]) {
+	defer close(out)
+	for {
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+
+			var next *Message[Out]
+			if msg.Error != nil {
+				var zero Out
+				next = ConvertMessage(msg, zero)
+			} else {
+				var err error
+				next, err = b.Convert(msg)
+				if err != nil {
+					if next == nil {
+						var zero Out
+						next = ConvertMessage(msg, zero)
+					}
+					if next.ErrorStage == "" {
+						next.WithError(err, "Bridge")
+					} else {
+						next.Error = err
+					}
+				}
+				if next == nil {
+					continue
+				}
+			}
+
+			select {
+			case out <- next:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
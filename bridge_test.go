@@ -0,0 +1,117 @@
+package tesei_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestBridge(t *testing.T) {
+	upstream := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{
+			{Name: "a.txt", Content: "first"},
+			{Name: "b.txt", Content: "second"},
+			{Name: "skip.txt", Content: ""},
+		}}).
+		Sequential(files.HashContent{}).
+		Build()
+
+	var results []*tesei.Message[string]
+	downstream := tesei.NewPipeline[string]().
+		Sequential(&tesei.TransformJob[string]{
+			Transform: func(msg *tesei.Message[string]) (*tesei.Message[string], error) {
+				msg.Data = strings.ToUpper(msg.Data)
+				return msg, nil
+			},
+		}).
+		Sequential(tesei.Collect[string]{Items: &results}).
+		Sequential(tesei.End[string]{}).
+		Build()
+
+	_, err := tesei.Bridge[files.TextFile, string]{
+		Upstream:   upstream,
+		Downstream: downstream,
+		Convert: func(msg *tesei.Message[files.TextFile]) (*tesei.Message[string], error) {
+			if msg.Data.Content == "" {
+				return nil, nil
+			}
+			return tesei.ConvertMessage(msg, msg.Data.Content), nil
+		},
+	}.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Bridge failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(results))
+	}
+	for i, expected := range []string{"FIRST", "SECOND"} {
+		msg := results[i]
+		if msg.Data != expected {
+			t.Errorf("Expected %q, got %q", expected, msg.Data)
+		}
+		if msg.ID != []string{"a.txt", "b.txt"}[i] || msg.Metadata["hash"] == nil {
+			t.Errorf("Expected ID and metadata to be kept, got %s %v", msg.ID, msg.Metadata)
+		}
+	}
+}
+
+func TestBridgeErrors(t *testing.T) {
+	upstream := tesei.NewPipeline[int]().
+		Sequential(tesei.Slice[int]{Items: []int{1, 2}}).
+		Build()
+
+	var results []*tesei.Message[string]
+	downstream := tesei.NewPipeline[string]().
+		Sequential(tesei.Collect[string]{Items: &results}).
+		Sequential(tesei.End[string]{}).
+		Build()
+
+	_, err := tesei.Bridge[int, string]{
+		Upstream:   upstream,
+		Downstream: downstream,
+		Convert: func(msg *tesei.Message[int]) (*tesei.Message[string], error) {
+			if msg.Data == 2 {
+				return nil, errors.New("bad input")
+			}
+			return tesei.ConvertMessage(msg, "one"), nil
+		},
+	}.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Bridge failed: %v", err)
+	}
+	if len(results) != 2 || results[1].Error == nil || results[1].ErrorStage != "Bridge" {
+		t.Fatalf("Expected the second message to fail in convert, got %v", results)
+	}
+
+	// a critical error of the downstream pipeline stops both
+	critical := tesei.NewPipeline[string]().
+		Sequential(criticalJob{}).
+		Sequential(tesei.End[string]{}).
+		Build()
+	_, err = tesei.Bridge[int, string]{
+		Upstream:   tesei.NewPipeline[int]().Sequential(tesei.Slice[int]{Items: []int{1}}).Build(),
+		Downstream: critical,
+		Convert: func(msg *tesei.Message[int]) (*tesei.Message[string], error) {
+			return tesei.ConvertMessage(msg, "x"), nil
+		},
+	}.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "critical") {
+		t.Errorf("Expected the critical error, got %v", err)
+	}
+}
+
+// criticalJob reports a critical error for the first message.
+type criticalJob struct{}
+
+func (c criticalJob) Run(ctx *tesei.Thread, in <-chan *tesei.Message[string], out chan<- *tesei.Message[string]) {
+	defer close(out)
+	for range in {
+		ctx.SetError(errors.New("critical"))
+		return
+	}
+}
@@ -1,17 +1,58 @@
 package tesei
 
-import "context"
+import (
+	"context"
+	"math/rand"
+	"sync"
+)
 
 // Thread is a wrapper around context.Context that also carries pipeline errors.
 // It allows propagating critical errors from any stage to the executor.
 type Thread struct {
 	context.Context
 	errorChan chan error
+	rng       *rand.Rand
+	overflow  *errorOverflow
+	release   func(id string)
+}
+
+// errorOverflow keeps the errors reported while the error buffer was full.
+type errorOverflow struct {
+	mu   sync.Mutex
+	errs []error
 }
 
 // SetError reports a critical error that should stop the pipeline.
+// It never blocks: when the error buffer is full, the pipeline is already stopping
+// on an earlier error, and the error is kept aside for GetError.
 func (t *Thread) SetError(err error) {
-	t.errorChan <- err
+	select {
+	case t.errorChan <- err:
+		return
+	default:
+	}
+
+	if t.overflow != nil {
+		t.overflow.mu.Lock()
+		t.overflow.errs = append(t.overflow.errs, err)
+		t.overflow.mu.Unlock()
+	}
+}
+
+// Release frees the WithMaxInFlight slot of a message which leaves the pipeline before its last stage,
+// like a message dropped, merged or split by a job. Transform and Filter call it for the messages they drop.
+// It does nothing when the pipeline has no in-flight limit.
+func (t *Thread) Release(id string) {
+	if t.release != nil {
+		t.release(id)
+	}
+}
+
+// derive returns a copy of the thread for a nested run, stage or worker.
+// The copy shares all fields with the thread, the caller replaces the ones it owns.
+func (t *Thread) derive() *Thread {
+	own := *t
+	return &own
 }
 
 // Done returns a channel that's closed when the thread is cancelled.
@@ -25,19 +66,33 @@ func (t *Thread) Error() chan error {
 }
 
 // GetError returns the first error reported to the thread, or nil if none.
+// Errors which didn't fit into the buffer are returned after the buffered ones.
 func (t *Thread) GetError() error {
 	select {
 	case err := <-t.errorChan:
 		return err
 	default:
+	}
+
+	if t.overflow == nil {
+		return nil
+	}
+	t.overflow.mu.Lock()
+	defer t.overflow.mu.Unlock()
+	if len(t.overflow.errs) == 0 {
 		return nil
 	}
+	err := t.overflow.errs[0]
+	t.overflow.errs = t.overflow.errs[1:]
+	return err
 }
 
 // NewThread creates a new Thread with the given context and error buffer size.
+// The buffer holds at least one error, so the first reported error is never lost.
 func NewThread(ctx context.Context, errorBufferSize int) *Thread {
 	return &Thread{
 		Context:   ctx,
-		errorChan: make(chan error, errorBufferSize),
+		errorChan: make(chan error, max(errorBufferSize, 1)),
+		overflow:  &errorOverflow{},
 	}
 }
@@ -0,0 +1,84 @@
+package tesei
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThreadSetErrorNonBlocking(t *testing.T) {
+	ctx := NewThread(context.Background(), 1)
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx.SetError(fmt.Errorf("error %d", i))
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SetError blocked with a full error buffer")
+	}
+
+	// no error is lost, the overflow is returned after the buffered one
+	count := 0
+	for ctx.GetError() != nil {
+		count++
+	}
+	if count != n {
+		t.Errorf("Expected %d errors, got %d", n, count)
+	}
+}
+
+func TestThreadSetErrorWideFanOut(t *testing.T) {
+	src := make([]string, 20)
+	for i := range src {
+		src[i] = fmt.Sprint(i)
+	}
+
+	// every worker reports an error while the executor is already stopping on the first one
+	job := JobFunc[string](func(ctx *Thread, in <-chan *Message[string], out chan<- *Message[string]) {
+		defer close(out)
+		for range in {
+			ctx.SetError(errors.New("critical"))
+		}
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := NewPipeline[string]().
+			Sequential(JobFunc[string](func(ctx *Thread, in <-chan *Message[string], out chan<- *Message[string]) {
+				defer close(out)
+				for _, s := range src {
+					out <- NewMessage(s)
+				}
+			})).
+			FanOut(job, 10).
+			Sequential(End[string]{}).
+			Build().
+			Start(context.Background())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected the critical error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pipeline blocked on concurrent errors")
+	}
+}
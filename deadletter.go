@@ -0,0 +1,31 @@
+package tesei
+
+// DeadLetter is a job that takes the messages with errors out of the stream, so the next stages
+// get only clean messages. Failed messages go to Handler and then to Errors, when they are set,
+// and are dropped otherwise.
+type DeadLetter[T any] struct {
+	// Handler is called for each failed message, like logging or collecting the failures.
+	Handler func(msg *Message[T])
+	// Errors receives the failed messages. It must be read while the pipeline runs, as sending blocks,
+	// and it is not closed by the job.
+	Errors chan<- *Message[T]
+}
+
+func (d DeadLetter[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	Filter(ctx, in, out, func(msg *Message[T]) bool {
+		if msg.Error == nil {
+			return true
+		}
+
+		if d.Handler != nil {
+			d.Handler(msg)
+		}
+		if d.Errors != nil {
+			select {
+			case d.Errors <- msg:
+			case <-ctx.Done():
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,85 @@
+package tesei
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDeadLetter(t *testing.T) {
+	failOdd := TransformJob[int]{
+		Transform: func(msg *Message[int]) (*Message[int], error) {
+			if msg.Data%2 == 1 {
+				return msg, errors.New("odd")
+			}
+			return msg, nil
+		},
+	}
+	items := []int{1, 2, 3, 4, 5, 6, 7}
+
+	t.Run("Handler", func(t *testing.T) {
+		var clean []*Message[int]
+		var failed []int
+		var stats Stats
+		_, err := NewPipeline[int]().
+			Sequential(Slice[int]{Items: items}).
+			Sequential(failOdd).
+			Sequential(DeadLetter[int]{Handler: func(msg *Message[int]) { failed = append(failed, msg.Data) }}).
+			Sequential(Collect[int]{Items: &clean}).
+			Sequential(End[int]{Stats: &stats}).
+			Build().
+			Start(context.Background())
+		if err != nil {
+			t.Fatalf("pipeline failed: %v", err)
+		}
+
+		var got []int
+		for _, msg := range clean {
+			got = append(got, msg.Data)
+		}
+		if !reflect.DeepEqual(got, []int{2, 4, 6}) {
+			t.Errorf("clean = %v, want [2 4 6]", got)
+		}
+		if !reflect.DeepEqual(failed, []int{1, 3, 5, 7}) {
+			t.Errorf("failed = %v, want [1 3 5 7]", failed)
+		}
+		if stats.Errors != 0 {
+			t.Errorf("expected no errors after the dead letter stage, got %d", stats.Errors)
+		}
+	})
+
+	t.Run("Channel", func(t *testing.T) {
+		// unbuffered, so the pipeline completes only if every failed message is received
+		errs := make(chan *Message[int])
+		done := make(chan []int)
+		go func() {
+			var failed []int
+			for msg := range errs {
+				failed = append(failed, msg.Data)
+			}
+			done <- failed
+		}()
+
+		var clean []*Message[int]
+		_, err := NewPipeline[int]().
+			Sequential(Slice[int]{Items: items}).
+			Sequential(failOdd).
+			Sequential(DeadLetter[int]{Errors: errs}).
+			Sequential(Collect[int]{Items: &clean}).
+			Sequential(End[int]{}).
+			Build().
+			Start(context.Background())
+		close(errs)
+		if err != nil {
+			t.Fatalf("pipeline failed: %v", err)
+		}
+
+		if failed := <-done; !reflect.DeepEqual(failed, []int{1, 3, 5, 7}) {
+			t.Errorf("failed = %v, want [1 3 5 7]", failed)
+		}
+		if len(clean) != 3 {
+			t.Errorf("expected 3 clean messages, got %d", len(clean))
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package tesei
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DropConsecutiveDuplicates is a job that drops a message when its key equals the key of the previous message,
+// like repeated events of a noisy source. Only the last key is kept, so it is cheap, but a key
+// which reappears after a different one is passed again. Messages with errors are passed through.
+type DropConsecutiveDuplicates[T any] struct {
+	// Key computes the key of a message.
+	Key func(msg *Message[T]) string
+}
+
+func (d DropConsecutiveDuplicates[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	last, seen := "", false
+	Filter(ctx, in, out, func(msg *Message[T]) bool {
+		if msg.Error != nil {
+			return true
+		}
+
+		key := d.Key(msg)
+		if seen && key == last {
+			return false
+		}
+		last, seen = key, true
+		return true
+	})
+}
+
+// Dedup is a job that drops a message when its key was already passed, the first message with a key wins.
+// Every passed key is kept until the end of the run, so memory grows with the number of distinct keys.
+// Messages with errors or an empty key are passed through.
+// Build it with NewDedup or DedupByMetadata, a Dedup without Seen fails the run with ErrInvalidPipeline.
+type Dedup[T any] struct {
+	// Key computes the key of a message.
+	Key func(msg *Message[T]) string
+	// Seen holds the keys passed so far. The job value carries the pointer, so the workers of
+	// a FanOut stage check the same keys and a duplicate passes only once in total.
+	Seen *SeenKeys
+}
+
+// NewDedup returns a Dedup keyed by the key function, with empty SeenKeys.
+// The keys are kept between runs of the job, so build a new one for each run.
+func NewDedup[T any](key func(msg *Message[T]) string) Dedup[T] {
+	return Dedup[T]{Key: key, Seen: &SeenKeys{}}
+}
+
+// SeenKeys holds the keys passed by a Dedup. It is safe for concurrent use.
+// The zero value is ready to use.
+type SeenKeys struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+// add records the key and reports whether it is new.
+func (s *SeenKeys) add(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.keys == nil {
+		s.keys = make(map[string]struct{})
+	}
+	if _, ok := s.keys[key]; ok {
+		return false
+	}
+	s.keys[key] = struct{}{}
+	return true
+}
+
+// Len returns the number of seen keys.
+func (s *SeenKeys) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys)
+}
+
+// DedupByMetadata returns a Dedup keyed by the value of a metadata key, like the "hash" set by files.HashContent.
+// Messages without the key are passed through.
+func DedupByMetadata[T any](key string) Dedup[T] {
+	return NewDedup(func(msg *Message[T]) string {
+		value, ok := msg.Metadata[key]
+		if !ok || value == nil {
+			return ""
+		}
+		return fmt.Sprint(value)
+	})
+}
+
+func (d Dedup[T]) check() error {
+	if d.Seen == nil {
+		return errors.New("Dedup without Seen, build it with NewDedup")
+	}
+	return nil
+}
+
+func (d Dedup[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	if err := d.check(); err != nil {
+		close(out)
+		ctx.SetError(fmt.Errorf("%w: %w", ErrInvalidPipeline, err))
+		return
+	}
+
+	Filter(ctx, in, out, func(msg *Message[T]) bool {
+		if msg.Error != nil {
+			return true
+		}
+		key := d.Key(msg)
+		return key == "" || d.Seen.add(key)
+	})
+}
@@ -0,0 +1,166 @@
+package tesei
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDropConsecutiveDuplicates(t *testing.T) {
+	var results []*Message[string]
+	_, err := NewPipeline[string]().
+		Sequential(Slice[string]{Items: []string{"A", "A", "B", "A", "", "", "b", "B"}}).
+		Sequential(TransformJob[string]{
+			Transform: func(msg *Message[string]) (*Message[string], error) {
+				if msg.Data == "b" {
+					return msg, errors.New("failed")
+				}
+				return msg, nil
+			},
+		}).
+		Sequential(DropConsecutiveDuplicates[string]{
+			Key: func(msg *Message[string]) string { return msg.Data },
+		}).
+		Sequential(Collect[string]{Items: &results}).
+		Sequential(End[string]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	var got []string
+	for _, msg := range results {
+		got = append(got, msg.Data)
+	}
+	// the failed message passes and doesn't reset the last key
+	expected := []string{"A", "B", "A", "", "b", "B"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, want %q", got, expected)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	// items are "key:source", the first source of each key must win
+	items := []string{"a:1", "b:1", "a:2", "c:1", "b:2", "a:3", ":1", ":2"}
+	key := func(msg *Message[string]) string {
+		k, _, _ := strings.Cut(msg.Data, ":")
+		return k
+	}
+
+	tests := []struct {
+		name string
+		job  Job[string]
+	}{
+		{"Key", NewDedup(key)},
+		{"Metadata", DedupByMetadata[string]("key")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*Message[string]
+			_, err := NewPipeline[string]().
+				Sequential(Slice[string]{Items: items}).
+				Sequential(TransformJob[string]{
+					Transform: func(msg *Message[string]) (*Message[string], error) {
+						if k := key(msg); k != "" {
+							msg.Metadata["key"] = k
+						}
+						return msg, nil
+					},
+				}).
+				Sequential(tt.job).
+				Sequential(Collect[string]{Items: &results}).
+				Sequential(End[string]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			var got []string
+			for _, msg := range results {
+				got = append(got, msg.Data)
+			}
+			// messages without a key pass
+			expected := []string{"a:1", "b:1", "c:1", ":1", ":2"}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("got %q, want %q", got, expected)
+			}
+		})
+	}
+}
+
+func TestDedupFanOut(t *testing.T) {
+	items := make([]int, 200)
+	for i := range items {
+		items[i] = i % 20
+	}
+
+	job := NewDedup(func(msg *Message[int]) string { return strconv.Itoa(msg.Data) })
+	var results []*Message[int]
+	_, err := NewPipeline[int]().
+		Sequential(Slice[int]{Items: items}).
+		FanOut(job, 4).
+		Sequential(Collect[int]{Items: &results}).
+		Sequential(End[int]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	counts := make(map[int]int)
+	for _, msg := range results {
+		counts[msg.Data]++
+	}
+	for key := 0; key < 20; key++ {
+		if counts[key] != 1 {
+			t.Errorf("key %d passed %d times, want once", key, counts[key])
+		}
+	}
+	if job.Seen.Len() != 20 {
+		t.Errorf("expected 20 seen keys, got %d", job.Seen.Len())
+	}
+}
+
+func TestDedupWithoutSeen(t *testing.T) {
+	p := NewPipeline[int]().
+		Sequential(Slice[int]{Items: []int{1, 1}}).
+		FanOut(Dedup[int]{Key: func(msg *Message[int]) string { return strconv.Itoa(msg.Data) }}, 4).
+		Sequential(End[int]{})
+
+	if err := p.Validate(); !errors.Is(err, ErrInvalidPipeline) {
+		t.Errorf("Validate() = %v, want ErrInvalidPipeline", err)
+	}
+	if _, err := p.Build().Start(context.Background()); !errors.Is(err, ErrInvalidPipeline) {
+		t.Errorf("Start() = %v, want ErrInvalidPipeline", err)
+	}
+}
+
+func TestDedupByMetadataFanOut(t *testing.T) {
+	items := make([]int, 200)
+	for i := range items {
+		items[i] = i % 20
+	}
+
+	var results []*Message[int]
+	_, err := NewPipeline[int]().
+		Sequential(Slice[int]{Items: items}).
+		Sequential(SetMetaData[int]{Key: "key", Handler: func(msg *Message[int]) any { return msg.Data }}).
+		FanOut(DedupByMetadata[int]("key"), 4).
+		Sequential(Collect[int]{Items: &results}).
+		Sequential(End[int]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	if len(results) != 20 {
+		t.Errorf("expected 20 unique messages, got %d", len(results))
+	}
+}
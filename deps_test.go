@@ -0,0 +1,32 @@
+package tesei
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// format parsers are only needed by the text jobs, the core packages must not pull them in
+var textOnlyDeps = []string{
+	"gopkg.in/yaml.v3",
+	"github.com/BurntSushi/toml",
+}
+
+func TestCoreDependencies(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	out, err := exec.Command("go", "list", "-deps", ".", "./files").Output()
+	if err != nil {
+		t.Fatalf("go list: %v", err)
+	}
+
+	for _, pkg := range strings.Fields(string(out)) {
+		for _, dep := range textOnlyDeps {
+			if pkg == dep || strings.HasPrefix(pkg, dep+"/") {
+				t.Errorf("core packages import %s", pkg)
+			}
+		}
+	}
+}
@@ -22,30 +22,65 @@ type Executor[T any] interface {
 }
 
 type executor[T any] struct {
-	stages     []stage[T]
-	bufferSize int
+	stages       []stage[T]
+	bufferSize   int
+	outputBuffer int
+	maxInFlight  int
+	failFast     bool
+	invalid      *validation
+	seeded       bool
+	seed         int64
+	errorHandler ErrorHandler[T]
 
 	input  chan *Message[T]
 	output chan *Message[T]
 	cancel context.CancelFunc
 }
 
+// validation holds the errors found by the pipeline validation.
+type validation struct {
+	// stages are the problems of the stages, all include the missing End of a top-level pipeline.
+	stages error
+	all    error
+}
+
 func (e *executor[T]) Start(baseCtx context.Context) (time.Duration, error) {
 	start := time.Now()
+	if e.invalid != nil && e.invalid.all != nil {
+		return time.Since(start), e.invalid.all
+	}
 	base, cancel := context.WithCancel(baseCtx)
 	ctx := NewThread(base, 1)
+	if e.seeded {
+		ctx.rng = newRand(e.seed)
+	}
 	e.cancel = cancel
 
 	e.input = make(chan *Message[T], e.bufferSize)
-	e.output = make(chan *Message[T], e.bufferSize)
+	outputBuffer := e.bufferSize
+	if e.outputBuffer > 0 {
+		outputBuffer = e.outputBuffer
+	}
+	e.output = make(chan *Message[T], outputBuffer)
 
 	wg := sync.WaitGroup{}
 	done := make(chan struct{})
-	e.innerRun(ctx, &wg, done, e.input, e.output)
+	group := e.innerRun(ctx, &wg, done, e.input, e.output)
+
+	if group != nil {
+		// stages are cancelled by the group or the base context, wait for all of them
+		<-done
+		if err := group.wait(); err != nil {
+			e.handleError(err, nil)
+			return time.Since(start), fmt.Errorf("Executor error: %w", err)
+		}
+		return time.Since(start), ctx.Context.Err()
+	}
 
 	select {
 	case err := <-ctx.Error():
 		e.cancel()
+		e.handleError(err, nil)
 		return time.Since(start), fmt.Errorf("Executor error: %w", err)
 	case <-ctx.Done():
 		wg.Wait()
@@ -59,9 +94,42 @@ func (e *executor[T]) Start(baseCtx context.Context) (time.Duration, error) {
 }
 
 func (e *executor[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	if e.invalid != nil && e.invalid.stages != nil {
+		close(out)
+		select {
+		case ctx.Error() <- e.invalid.stages:
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	if e.seeded {
+		// errors still go to the outer thread
+		ctx = ctx.derive()
+		ctx.rng = newRand(e.seed)
+	}
+	if e.errorHandler != nil {
+		// critical errors pass the handler on their way to the outer thread
+		outer := ctx
+		ctx = outer.derive()
+		ctx.errorChan = make(chan error, 1)
+		defer e.forwardErrors(ctx, outer)()
+	}
+
 	wg := sync.WaitGroup{}
 	done := make(chan struct{})
-	e.innerRun(ctx, &wg, done, in, out)
+	group := e.innerRun(ctx, &wg, done, in, out)
+
+	if group != nil {
+		<-done
+		if err := group.wait(); err != nil {
+			select {
+			case ctx.Error() <- err:
+			case <-ctx.Done():
+			}
+		}
+		return
+	}
 
 	select {
 	case <-ctx.Done():
@@ -73,43 +141,89 @@ func (e *executor[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Messag
 	}
 }
 
-func (e *executor[T]) innerRun(ctx *Thread, wg *sync.WaitGroup, done chan struct{}, globalIn <-chan *Message[T], globalOut chan<- *Message[T]) {
+// innerRun starts the stages, done is closed when all of them have exited.
+// In fail-fast mode the stages run in a group, which is returned.
+func (e *executor[T]) innerRun(ctx *Thread, wg *sync.WaitGroup, done chan struct{}, globalIn <-chan *Message[T], globalOut chan<- *Message[T]) *stageGroup {
 	if len(e.stages) == 0 {
+		// a pipeline without stages passes the messages through
 		go func() {
-			for range e.input {
+			defer close(globalOut)
+			for msg := range globalIn {
+				select {
+				case globalOut <- msg:
+				case <-ctx.Done():
+					return
+				}
 			}
-			close(e.output)
 		}()
 	}
 
 	channels := e.wireChannels()
+	ins := make([]<-chan *Message[T], len(e.stages))
+	outs := make([]chan<- *Message[T], len(e.stages))
 
-	for i, stg := range e.stages {
-		wg.Add(1)
-		var in <-chan *Message[T]
+	for i := range e.stages {
 		if i == 0 {
-			in = globalIn
+			ins[i] = globalIn
 		} else {
-			in = channels[i]
+			ins[i] = channels[i]
 		}
 
-		var out chan<- *Message[T]
 		if i == len(e.stages)-1 {
-			out = globalOut
+			outs[i] = globalOut
 		} else {
-			out = channels[i+1]
+			outs[i] = channels[i+1]
+		}
+	}
+
+	if e.maxInFlight > 0 && len(e.stages) > 1 {
+		limiter := e.limitInFlight(ctx, ins, outs)
+
+		// messages leaving the pipeline early release their slots here and in the outer pipelines
+		outer := ctx.release
+		ctx = ctx.derive()
+		ctx.release = func(id string) {
+			limiter.release(id)
+			if outer != nil {
+				outer(id)
+			}
+		}
+	}
+	if e.errorHandler != nil {
+		e.observeErrors(ctx, ins, outs)
+	}
+
+	var group *stageGroup
+	if e.failFast {
+		group = newStageGroup(ctx)
+	}
+
+	for i, stg := range e.stages {
+		thread, finish := ctx, func() {}
+		if group != nil {
+			thread, finish = group.thread(ctx, i, describeStage(stg))
+		} else if ctx.rng != nil {
+			thread = ctx.derive()
+		}
+		if ctx.rng != nil {
+			// stages run concurrently, each one draws from its own generator, seeded in stage order
+			thread.rng = newRand(ctx.rng.Int63())
 		}
 
+		wg.Add(1)
 		go func(s stage[T], input <-chan *Message[T], output chan<- *Message[T]) {
-			s.run(ctx, input, output)
+			s.run(thread, input, output)
+			finish()
 			wg.Done()
-		}(stg, in, out)
+		}(stg, ins[i], outs[i])
 	}
 
 	go func() {
 		wg.Wait()
 		close(done)
 	}()
+
+	return group
 }
 
 func (e *executor[T]) Input() chan<- *Message[T] {
@@ -120,6 +234,94 @@ func (e *executor[T]) Output() <-chan *Message[T] {
 	return e.output
 }
 
+// limitInFlight inserts taps after the first stage and before the last one.
+// The first tap takes a slot before passing a message on, the second releases it
+// once the last stage has received the message. Jobs release the slots of dropped messages with Thread.Release.
+func (e *executor[T]) limitInFlight(ctx *Thread, ins []<-chan *Message[T], outs []chan<- *Message[T]) *inFlightLimiter {
+	limiter := newInFlightLimiter(e.maxInFlight)
+	last := len(e.stages) - 1
+
+	source := make(chan *Message[T], e.bufferSize)
+	go tap(ctx, source, outs[0], func(ctx *Thread, msg *Message[T]) bool { return limiter.acquire(ctx, msg.ID) }, nil)
+	outs[0] = source
+
+	// unbuffered, so a message is released only when the last stage takes it
+	sink := make(chan *Message[T])
+	go tap(ctx, ins[last], sink, nil, limiter.release)
+	ins[last] = sink
+	return limiter
+}
+
+// observeErrors inserts a tap calling the error handler for failed messages at the end of the pipeline,
+// before the last stage if it is a sink, after it otherwise.
+func (e *executor[T]) observeErrors(ctx *Thread, ins []<-chan *Message[T], outs []chan<- *Message[T]) {
+	last := len(e.stages) - 1
+	observe := func(_ *Thread, msg *Message[T]) bool {
+		if msg.Error != nil {
+			e.errorHandler(msg.Error, msg)
+		}
+		return true
+	}
+
+	if endsWithSink(e.stages[last]) {
+		// unbuffered, so the in-flight limit still releases a message when the last stage takes it
+		observed := make(chan *Message[T])
+		go tap(ctx, ins[last], observed, observe, nil)
+		ins[last] = observed
+		return
+	}
+
+	observed := make(chan *Message[T], e.bufferSize)
+	go tap(ctx, observed, outs[last], observe, nil)
+	outs[last] = observed
+}
+
+// forwardErrors calls the error handler for the critical errors reported to the thread of a nested run
+// and passes them to the outer thread. The returned function stops it, once the stages have exited.
+func (e *executor[T]) forwardErrors(inner, outer *Thread) func() {
+	quit := make(chan struct{})
+	finished := make(chan struct{})
+
+	forward := func(err error) {
+		e.handleError(err, nil)
+		select {
+		case outer.errorChan <- err:
+		case <-outer.Done():
+		}
+	}
+
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case err := <-inner.errorChan:
+				forward(err)
+			case <-quit:
+				for {
+					select {
+					case err := <-inner.errorChan:
+						forward(err)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(quit)
+		<-finished
+	}
+}
+
+// handleError calls the error handler, if there is one.
+func (e *executor[T]) handleError(err error, msg *Message[T]) {
+	if e.errorHandler != nil {
+		e.errorHandler(err, msg)
+	}
+}
+
 func (e *executor[T]) wireChannels() []chan *Message[T] {
 	channels := make([]chan *Message[T], len(e.stages)+1)
 
@@ -129,3 +331,74 @@ func (e *executor[T]) wireChannels() []chan *Message[T] {
 
 	return channels
 }
+
+type inFlightLimiter struct {
+	slots chan struct{}
+
+	mu   sync.Mutex
+	held map[string]int
+}
+
+func newInFlightLimiter(n int) *inFlightLimiter {
+	return &inFlightLimiter{
+		slots: make(chan struct{}, n),
+		held:  make(map[string]int),
+	}
+}
+
+func (l *inFlightLimiter) acquire(ctx *Thread, id string) bool {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+
+	l.mu.Lock()
+	l.held[id]++
+	l.mu.Unlock()
+	return true
+}
+
+func (l *inFlightLimiter) release(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// messages created inside the pipeline (e.g. split chunks) do not hold a slot
+	if l.held[id] == 0 {
+		return
+	}
+
+	l.held[id]--
+	if l.held[id] == 0 {
+		delete(l.held, id)
+	}
+	<-l.slots
+}
+
+// tap forwards messages from in to out.
+// The before hook is called prior to sending and stops the tap when it returns false,
+// the after hook is called once the message has been sent.
+func tap[T any](ctx *Thread, in <-chan *Message[T], out chan<- *Message[T], before func(*Thread, *Message[T]) bool, after func(string)) {
+	defer close(out)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+			if before != nil && !before(ctx, msg) {
+				return
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+			if after != nil {
+				after(msg.ID)
+			}
+		}
+	}
+}
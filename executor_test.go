@@ -5,6 +5,7 @@ import (
 	"errors"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -315,3 +316,347 @@ func TestExecutorSequentialPipelines(t *testing.T) {
 		t.Errorf("Expected count to be 2, got %d", count)
 	}
 }
+
+func TestExecutorEmptySubPipeline(t *testing.T) {
+	empty := tesei.NewPipeline[int]().Build()
+
+	// the messages are collected after the empty sub-pipeline
+	var results []*tesei.Message[int]
+	p := tesei.NewPipeline[int]().
+		Sequential(tesei.Slice[int]{Items: []int{1, 2, 3}}).
+		Sequential(empty).
+		Sequential(tesei.Collect[int]{Items: &results}).
+		Sequential(tesei.End[int]{}).
+		Build()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Start(context.Background())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pipeline with empty sub-pipeline deadlocked")
+	}
+	if len(results) != 3 {
+		t.Errorf("Expected 3 messages out of the pipeline, got %d", len(results))
+	}
+}
+
+// endlessSource emits messages until the pipeline is cancelled.
+type endlessSource struct {
+	exited *sync.WaitGroup
+}
+
+func (s endlessSource) Run(ctx *tesei.Thread, in <-chan *tesei.Message[int], out chan<- *tesei.Message[int]) {
+	defer s.exited.Done()
+	defer close(out)
+	for i := 0; ; i++ {
+		select {
+		case out <- tesei.NewMessage(i):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// failingStage reports a critical error on the given message.
+type failingStage struct {
+	at     int
+	exited *sync.WaitGroup
+}
+
+func (s failingStage) Run(ctx *tesei.Thread, in <-chan *tesei.Message[int], out chan<- *tesei.Message[int]) {
+	defer s.exited.Done()
+	tesei.TransformStage(ctx, in, out, "failingStage", func(msg *tesei.Message[int]) (*tesei.Message[int], error) {
+		if msg.Data == s.at {
+			ctx.SetError(errors.New("stage failed"))
+		}
+		return msg, nil
+	})
+}
+
+// drain consumes messages until the pipeline is cancelled.
+type drain struct {
+	exited *sync.WaitGroup
+}
+
+func (d drain) Run(ctx *tesei.Thread, in <-chan *tesei.Message[int], out chan<- *tesei.Message[int]) {
+	defer d.exited.Done()
+	defer close(out)
+	for {
+		select {
+		case _, ok := <-in:
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func TestExecutorFailFast(t *testing.T) {
+	exited := &sync.WaitGroup{}
+	exited.Add(3)
+
+	_, err := tesei.NewPipeline[int]().
+		Sequential(endlessSource{exited: exited}).
+		Sequential(failingStage{at: 5, exited: exited}).
+		Sequential(drain{exited: exited}).
+		WithFailFast().
+		Build().
+		Start(context.Background())
+
+	var stageErr *tesei.StageError
+	if !errors.As(err, &stageErr) {
+		t.Fatalf("Expected a StageError, got %v", err)
+	}
+	if stageErr.Index != 1 || stageErr.Stage != "tesei_test.failingStage" {
+		t.Errorf("Expected error of stage 1 (tesei_test.failingStage), got %d (%s)", stageErr.Index, stageErr.Stage)
+	}
+	if stageErr.Err.Error() != "stage failed" {
+		t.Errorf("Expected 'stage failed', got %v", stageErr.Err)
+	}
+
+	// all stages must have exited when Start returns
+	done := make(chan struct{})
+	go func() {
+		exited.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Expected all stages to exit before Start returns")
+	}
+}
+
+func TestExecutorFailFastNoError(t *testing.T) {
+	var mu sync.Mutex
+	var results []string
+
+	_, err := tesei.NewPipeline[string]().
+		Sequential(tesei.Slice[string]{Items: []string{"a", "b"}}).
+		Sequential(&tesei.TransformJob[string]{
+			Transform: func(msg *tesei.Message[string]) (*tesei.Message[string], error) {
+				mu.Lock()
+				results = append(results, msg.Data)
+				mu.Unlock()
+				return msg, nil
+			},
+		}).
+		Sequential(tesei.End[string]{}).
+		WithFailFast().
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(results))
+	}
+}
+
+func TestExecutorOutputBuffer(t *testing.T) {
+	run := func(build func(p *tesei.Pipeline[int]) *tesei.Pipeline[int]) (tesei.Executor[int], *atomic.Int32, chan error) {
+		var emitted atomic.Int32
+		p := tesei.NewPipeline[int]().
+			Sequential(tesei.Slice[int]{Items: []int{1, 2, 3, 4, 5}}).
+			Sequential(&tesei.TransformJob[int]{
+				Transform: func(msg *tesei.Message[int]) (*tesei.Message[int], error) {
+					emitted.Add(1)
+					return msg, nil
+				},
+			})
+		exec := build(p).Build()
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := exec.Start(context.Background())
+			done <- err
+		}()
+		return exec, &emitted, done
+	}
+
+	// the last stage emits all messages before anything is read
+	exec, emitted, done := run(func(p *tesei.Pipeline[int]) *tesei.Pipeline[int] {
+		return p.WithOutputBuffer(5)
+	})
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Pipeline failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the pipeline to complete without a consumer")
+	}
+	if n := emitted.Load(); n != 5 {
+		t.Errorf("Expected 5 emitted messages, got %d", n)
+	}
+	for i := 1; i <= 5; i++ {
+		if msg := <-exec.Output(); msg.Data != i {
+			t.Errorf("Expected %d, got %d", i, msg.Data)
+		}
+	}
+
+	// with the default buffer the last stage waits for the consumer
+	exec, emitted, done = run(func(p *tesei.Pipeline[int]) *tesei.Pipeline[int] { return p })
+	time.Sleep(50 * time.Millisecond)
+	if n := emitted.Load(); n >= 5 {
+		t.Errorf("Expected the last stage to be blocked, got %d emitted messages", n)
+	}
+	for range 5 {
+		<-exec.Output()
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	failOdd := tesei.TransformJob[int]{
+		Transform: func(msg *tesei.Message[int]) (*tesei.Message[int], error) {
+			if msg.Data%2 == 1 {
+				return msg, errors.New("odd")
+			}
+			return msg, nil
+		},
+	}
+
+	t.Run("Messages reaching End", func(t *testing.T) {
+		var handled []int
+		var stats tesei.Stats
+		_, err := tesei.NewPipeline[int]().
+			Sequential(tesei.Slice[int]{Items: []int{1, 2, 3, 4, 5}}).
+			Sequential(failOdd).
+			Sequential(tesei.End[int]{Stats: &stats}).
+			WithErrorHandler(func(err error, msg *tesei.Message[int]) {
+				handled = append(handled, msg.Data)
+			}).
+			Build().
+			Start(context.Background())
+		if err != nil {
+			t.Fatalf("Pipeline failed: %v", err)
+		}
+
+		if len(handled) != 3 || handled[0] != 1 || handled[1] != 3 || handled[2] != 5 {
+			t.Errorf("Expected the handler to get 1, 3, 5, got %v", handled)
+		}
+		// the messages are not swallowed
+		if stats.Total != 5 || stats.Errors != 3 {
+			t.Errorf("Expected End to get 5 messages, 3 failed, got %+v", stats)
+		}
+	})
+
+	t.Run("Messages reaching Output", func(t *testing.T) {
+		// the stages start after Start has created the input and output channels
+		started := make(chan struct{})
+		signal := tesei.JobFunc[int](func(ctx *tesei.Thread, in <-chan *tesei.Message[int], out chan<- *tesei.Message[int]) {
+			close(started)
+			defer close(out)
+			for msg := range in {
+				out <- msg
+			}
+		})
+
+		var handled atomic.Int32
+		exec := tesei.NewPipeline[int]().
+			Sequential(signal).
+			Sequential(failOdd).
+			WithErrorHandler(func(err error, msg *tesei.Message[int]) {
+				handled.Add(1)
+			}).
+			Build()
+		go exec.Start(context.Background())
+		<-started
+
+		exec.Input() <- tesei.NewMessage(1)
+		exec.Input() <- tesei.NewMessage(2)
+		close(exec.Input())
+
+		var failed int
+		for msg := range exec.Output() {
+			if msg.Error != nil {
+				failed++
+			}
+		}
+		if failed != 1 || handled.Load() != 1 {
+			t.Errorf("Expected one failed message in the output and the handler, got %d and %d", failed, handled.Load())
+		}
+	})
+
+	t.Run("Critical errors", func(t *testing.T) {
+		var mu sync.Mutex
+		var critical []error
+		_, err := tesei.NewPipeline[int]().
+			Sequential(tesei.Slice[int]{Items: []int{1}}).
+			Sequential(tesei.JobFunc[int](func(ctx *tesei.Thread, in <-chan *tesei.Message[int], out chan<- *tesei.Message[int]) {
+				defer close(out)
+				for range in {
+					ctx.SetError(errors.New("critical"))
+				}
+			})).
+			Sequential(tesei.End[int]{}).
+			WithErrorHandler(func(err error, msg *tesei.Message[int]) {
+				if msg == nil {
+					mu.Lock()
+					critical = append(critical, err)
+					mu.Unlock()
+				}
+			}).
+			Build().
+			Start(context.Background())
+		if err == nil {
+			t.Fatal("Expected the critical error")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(critical) != 1 || critical[0].Error() != "critical" {
+			t.Errorf("Expected the handler to get the critical error, got %v", critical)
+		}
+	})
+
+	t.Run("Critical errors of a nested pipeline", func(t *testing.T) {
+		var mu sync.Mutex
+		var critical []error
+		nested := tesei.NewPipeline[int]().
+			Sequential(tesei.JobFunc[int](func(ctx *tesei.Thread, in <-chan *tesei.Message[int], out chan<- *tesei.Message[int]) {
+				defer close(out)
+				for range in {
+					ctx.SetError(errors.New("critical"))
+				}
+			})).
+			WithErrorHandler(func(err error, msg *tesei.Message[int]) {
+				if msg == nil {
+					mu.Lock()
+					critical = append(critical, err)
+					mu.Unlock()
+				}
+			}).
+			Build()
+
+		_, err := tesei.NewPipeline[int]().
+			Sequential(tesei.Slice[int]{Items: []int{1}}).
+			Sequential(nested).
+			Sequential(tesei.End[int]{}).
+			Build().
+			Start(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "critical") {
+			t.Fatalf("Expected the critical error to reach the outer pipeline, got %v", err)
+		}
+
+		// the handler is called before the error is passed to the outer thread
+		mu.Lock()
+		defer mu.Unlock()
+		if len(critical) != 1 || critical[0].Error() != "critical" {
+			t.Errorf("Expected the nested handler to get the critical error, got %v", critical)
+		}
+	})
+}
@@ -14,7 +14,7 @@ type Replace struct {
 }
 
 func (c Replace) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+	tesei.TransformStage(ctx, in, out, "Replace", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
 		for k, v := range c.Matches {
 			v := ResolveString(v, msg)
 			msg.Data.Content = strings.ReplaceAll(msg.Data.Content, k, v)
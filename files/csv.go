@@ -0,0 +1,138 @@
+package files
+
+import (
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// GroupCSV is a job that splits CSV files into one file per value of a grouping column.
+// Rows are buffered until the input is closed, then a file is emitted for each group of each input file,
+// with the header and the rows of the group in their original order.
+// Group files are children of the input file, in its folder; the group value is stored in metadata.
+type GroupCSV struct {
+	// Column is the header of the grouping column.
+	Column string
+	// Name is the name template of group files, with {{group}} and metadata placeholders.
+	// Defaults to the input name with the group value appended, "sales-north.csv".
+	Name string
+	// Key is the metadata key to store the group value in. Defaults to "group".
+	Key string
+	// Comma is the field delimiter. Defaults to ','.
+	Comma rune
+}
+
+type csvGroups struct {
+	msg    *tesei.Message[TextFile]
+	header []string
+	order  []string
+	rows   map[string][][]string
+}
+
+func (g GroupCSV) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	defer close(out)
+
+	key := g.Key
+	if key == "" {
+		key = "group"
+	}
+
+	var files []*csvGroups
+	for msg := range in {
+		if msg.Error == nil {
+			groups, err := g.parse(msg)
+			if err == nil {
+				// the groups are emitted when the input is closed, they don't hold the slot of the file
+				files = append(files, groups)
+				ctx.Release(msg.ID)
+				continue
+			}
+			msg.WithError(err, "GroupCSV")
+		}
+
+		select {
+		case out <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	for _, groups := range files {
+		for _, group := range groups.order {
+			select {
+			case out <- g.group(groups, group, key):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// parse reads the rows of the file and groups them by the column value.
+func (g GroupCSV) parse(msg *tesei.Message[TextFile]) (*csvGroups, error) {
+	reader := csv.NewReader(strings.NewReader(msg.Data.Content))
+	reader.Comma = g.comma()
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCSV, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: no header", ErrCSV)
+	}
+
+	column := -1
+	for i, name := range records[0] {
+		if strings.TrimSpace(name) == g.Column {
+			column = i
+			break
+		}
+	}
+	if column == -1 {
+		return nil, fmt.Errorf("%w: no column %q", ErrCSV, g.Column)
+	}
+
+	groups := &csvGroups{msg: msg, header: records[0], rows: make(map[string][][]string)}
+	for _, row := range records[1:] {
+		value := row[column]
+		if _, ok := groups.rows[value]; !ok {
+			groups.order = append(groups.order, value)
+		}
+		groups.rows[value] = append(groups.rows[value], row)
+	}
+	return groups, nil
+}
+
+// group creates the file of a group.
+func (g GroupCSV) group(groups *csvGroups, group, key string) *tesei.Message[TextFile] {
+	// the delimiter was validated by the reader, so writing to memory doesn't fail
+	var sb strings.Builder
+	writer := csv.NewWriter(&sb)
+	writer.Comma = g.comma()
+	writer.Write(groups.header)
+	writer.WriteAll(groups.rows[group])
+
+	child := groups.msg.Child()
+	child.ID = groups.msg.ID + "_" + group
+	child.Metadata[key] = group
+	child.Data.Content = sb.String()
+
+	// group values are used in file names, path separators are replaced
+	safe := strings.NewReplacer("/", "_", "\\", "_").Replace(group)
+	if g.Name != "" {
+		child.Data.Name = ResolveString(strings.ReplaceAll(g.Name, "{{group}}", safe), child)
+	} else {
+		ext := filepath.Ext(child.Data.Name)
+		child.Data.Name = strings.TrimSuffix(child.Data.Name, ext) + "-" + safe + ext
+	}
+	return child
+}
+
+func (g GroupCSV) comma() rune {
+	if g.Comma == 0 {
+		return ','
+	}
+	return g.Comma
+}
@@ -0,0 +1,64 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestGroupCSV(t *testing.T) {
+	input := TextFile{
+		Name:   "sales.csv",
+		Folder: "data",
+		Content: "id,region,amount\n" +
+			"1,north,10\n" +
+			"2,south,20\n" +
+			"3,north,\"1,5\"\n" +
+			"4,south,40\n",
+	}
+
+	var results []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{input, {Name: "bad.csv", Content: "id,amount\n1,2\n"}}}).
+		Sequential(GroupCSV{Column: "region"}).
+		Sequential(tesei.Collect[TextFile]{Items: &results}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("got %d messages, want 3", len(results))
+	}
+	if results[0].Data.Name != "bad.csv" || !errors.Is(results[0].Error, ErrCSV) {
+		t.Errorf("file without the column: %s, error %v", results[0].Data.Name, results[0].Error)
+	}
+
+	expected := []struct {
+		name    string
+		group   string
+		content string
+	}{
+		{"sales-north.csv", "north", "id,region,amount\n1,north,10\n3,north,\"1,5\"\n"},
+		{"sales-south.csv", "south", "id,region,amount\n2,south,20\n4,south,40\n"},
+	}
+	for i, want := range expected {
+		msg := results[i+1]
+		if msg.Data.Name != want.name || msg.Data.Folder != "data" {
+			t.Errorf("group %d: file %s/%s, want data/%s", i, msg.Data.Folder, msg.Data.Name, want.name)
+		}
+		if msg.Metadata["group"] != want.group {
+			t.Errorf("group %d: group = %v, want %s", i, msg.Metadata["group"], want.group)
+		}
+		if msg.Data.Content != want.content {
+			t.Errorf("group %d: content = %q, want %q", i, msg.Data.Content, want.content)
+		}
+		if msg.Metadata["parent_id"] == nil {
+			t.Errorf("group %d: no parent_id", i)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package files
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"regexp"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// FindDuplicates is a job that finds files with identical or near-identical content.
+// It buffers all messages until the input is closed, then marks the duplicates and passes all messages on.
+// Exact duplicates are found by the HashContent hash, which is reused if already present in metadata.
+type FindDuplicates struct {
+	// HashKey is the metadata key of the content hash. Defaults to "hash".
+	HashKey string
+	// Key is the metadata key set on each duplicate to the ID of the first file of its group.
+	// Defaults to "duplicate_of".
+	Key string
+	// Similarity enables near-duplicate detection, files with SimHash similarity (0..1)
+	// not lower than the value are grouped. 0 means exact duplicates only.
+	Similarity float64
+	// Report is called with the groups of message IDs sharing the same content.
+	Report func(groups [][]string)
+	// Log prints the groups of duplicates.
+	Log bool
+}
+
+type duplicateGroup struct {
+	hash     string
+	simhash  uint64
+	messages []*tesei.Message[TextFile]
+}
+
+func (d FindDuplicates) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	defer close(out)
+
+	hashKey := d.HashKey
+	if hashKey == "" {
+		hashKey = "hash"
+	}
+	key := d.Key
+	if key == "" {
+		key = "duplicate_of"
+	}
+
+	var all []*tesei.Message[TextFile]
+	var groups []*duplicateGroup
+	exact := make(map[string]*duplicateGroup)
+
+	for {
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				d.report(groups, key)
+				for _, msg := range all {
+					select {
+					case out <- msg:
+					case <-ctx.Done():
+						return
+					}
+				}
+				return
+			}
+
+			all = append(all, msg)
+			if msg.Error != nil {
+				continue
+			}
+
+			hash, ok := msg.Metadata[hashKey].(string)
+			if !ok {
+				hash = hashBase62(msg.Data.Content, 0)
+				msg.Metadata[hashKey] = hash
+			}
+
+			group := exact[hash]
+			var sum uint64
+			if group == nil && d.Similarity > 0 {
+				sum = simHash(msg.Data.Content)
+				for _, g := range groups {
+					if similarity(sum, g.simhash) >= d.Similarity {
+						group = g
+						break
+					}
+				}
+			}
+			if group == nil {
+				group = &duplicateGroup{hash: hash, simhash: sum}
+				groups = append(groups, group)
+				exact[hash] = group
+			}
+			group.messages = append(group.messages, msg)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d FindDuplicates) report(groups []*duplicateGroup, key string) {
+	var ids [][]string
+	for _, g := range groups {
+		if len(g.messages) < 2 {
+			continue
+		}
+
+		group := make([]string, len(g.messages))
+		for i, msg := range g.messages {
+			group[i] = msg.ID
+			if i > 0 {
+				msg.Metadata[key] = g.messages[0].ID
+			}
+		}
+		ids = append(ids, group)
+
+		if d.Log {
+			fmt.Println("duplicates:", strings.Join(group, ", "))
+		}
+	}
+
+	if d.Report != nil {
+		d.Report(ids)
+	}
+}
+
+var shingleWordPattern = regexp.MustCompile(`\w+`)
+
+// shingleSize is the number of words in a shingle used by SimHash.
+const shingleSize = 3
+
+// simHash calculates a 64 bit SimHash of the word shingles of the text.
+func simHash(text string) uint64 {
+	words := shingleWordPattern.FindAllString(strings.ToLower(text), -1)
+	size := shingleSize
+	if len(words) < size {
+		size = len(words)
+	}
+
+	var weights [64]int
+	for i := 0; i+size <= len(words) && size > 0; i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+size], " ")))
+		sum := h.Sum64()
+		for b := 0; b < 64; b++ {
+			if sum&(1<<b) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+
+	var result uint64
+	for b := 0; b < 64; b++ {
+		if weights[b] > 0 {
+			result |= 1 << b
+		}
+	}
+	return result
+}
+
+// similarity returns the share of equal bits of two hashes.
+func similarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
@@ -0,0 +1,82 @@
+package files
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func findDuplicates(t *testing.T, job FindDuplicates, files []TextFile) ([][]string, []*tesei.Message[TextFile]) {
+	t.Helper()
+
+	var groups [][]string
+	job.Report = func(g [][]string) { groups = g }
+
+	var result []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: files}).
+		Sequential(job).
+		Sequential(tesei.TransformJob[TextFile]{Transform: func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+			result = append(result, msg)
+			return msg, nil
+		}}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	return groups, result
+}
+
+func TestFindDuplicates(t *testing.T) {
+	groups, result := findDuplicates(t, FindDuplicates{}, []TextFile{
+		{Name: "a.md", Content: "# Install\n\nRun the installer."},
+		{Name: "b.md", Content: "# Usage\n\nCall the API."},
+		{Name: "c.md", Content: "# Install\n\nRun the installer."},
+	})
+
+	if expected := [][]string{{"a.md", "c.md"}}; !reflect.DeepEqual(groups, expected) {
+		t.Errorf("groups = %v, want %v", groups, expected)
+	}
+	if len(result) != 3 {
+		t.Fatalf("got %d messages, want 3", len(result))
+	}
+	for _, msg := range result {
+		dup, _ := msg.Metadata["duplicate_of"].(string)
+		if msg.ID == "c.md" && dup != "a.md" || msg.ID != "c.md" && dup != "" {
+			t.Errorf("%s: duplicate_of = %q", msg.ID, dup)
+		}
+		if msg.Metadata["hash"] == nil {
+			t.Errorf("%s: hash is not set", msg.ID)
+		}
+	}
+}
+
+func TestFindDuplicatesSimilar(t *testing.T) {
+	text := "The pipeline reads every file in the folder, converts the markdown to plain text, " +
+		"asks the model for a short summary and writes the result next to the original file, " +
+		"so the summaries can be reviewed before they are published on the documentation site."
+	edited := "The pipeline reads every file in the folder, converts the markdown to plain text, " +
+		"asks the model for a brief summary and writes the result next to the original file, " +
+		"so the summaries can be reviewed before they are published on the documentation site."
+	other := "Tokens are counted per request and the limiter pauses all workers after a rate-limit error."
+
+	files := []TextFile{
+		{Name: "a.md", Content: text},
+		{Name: "b.md", Content: edited},
+		{Name: "c.md", Content: other},
+	}
+
+	groups, _ := findDuplicates(t, FindDuplicates{}, files)
+	if len(groups) != 0 {
+		t.Errorf("exact mode: groups = %v, want none", groups)
+	}
+
+	groups, _ = findDuplicates(t, FindDuplicates{Similarity: 0.8}, files)
+	if expected := [][]string{{"a.md", "b.md"}}; !reflect.DeepEqual(groups, expected) {
+		t.Errorf("similar mode: groups = %v, want %v", groups, expected)
+	}
+}
@@ -0,0 +1,32 @@
+package files
+
+import "errors"
+
+var (
+	// ErrReadDir is reported when a directory can't be listed.
+	ErrReadDir = errors.New("read dir")
+	// ErrReadFile is reported when a file can't be read.
+	ErrReadFile = errors.New("read file")
+	// ErrCreateDir is reported when a target directory can't be created.
+	ErrCreateDir = errors.New("create directory")
+	// ErrWriteFile is reported when a file can't be written.
+	ErrWriteFile = errors.New("write file")
+	// ErrCollision is reported when a target path was already written in the run.
+	ErrCollision = errors.New("path already written")
+	// ErrTooLong is reported when the content exceeds the MaxLength limit.
+	ErrTooLong = errors.New("content too long")
+	// ErrExec is reported when an external command fails.
+	ErrExec = errors.New("exec")
+	// ErrPatch is reported when a patch can't be applied.
+	ErrPatch = errors.New("apply patch")
+	// ErrExpand is reported when a variable is undefined or an include can't be resolved.
+	ErrExpand = errors.New("expand")
+	// ErrCompile is reported when Go code doesn't compile.
+	ErrCompile = errors.New("compile")
+	// ErrCSV is reported when the content is not valid CSV.
+	ErrCSV = errors.New("invalid csv")
+	// ErrDuplicateExtension is reported when ByExtension has several jobs for the same extension.
+	ErrDuplicateExtension = errors.New("duplicate extension")
+	// ErrVector is reported when an embedding is not a vector of numbers, or its size differs from the others.
+	ErrVector = errors.New("invalid vector")
+)
@@ -0,0 +1,81 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestErrReadDir(t *testing.T) {
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(ListDir{Path: "../testdata/missing"}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+
+	if !errors.Is(err, ErrReadDir) {
+		t.Errorf("Expected ErrReadDir, got %v", err)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected wrapped os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestErrCreateDir(t *testing.T) {
+	// a file in place of the target folder makes both MkdirAll and WriteFile fail
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "a.txt", Content: "a"}}}).
+		Sequential(WriteFile{Folder: filepath.Join(blocker, "out")}).
+		Sequential(tesei.Collect[TextFile]{Items: &results}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if !errors.Is(results[0].Error, ErrCreateDir) {
+		t.Errorf("Expected ErrCreateDir, got %v", results[0].Error)
+	}
+}
+
+func TestErrWriteFile(t *testing.T) {
+	// a folder in place of the target file makes WriteFile fail
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "a.txt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "a.txt", Content: "a"}}}).
+		Sequential(WriteFile{Folder: dir}).
+		Sequential(tesei.Collect[TextFile]{Items: &results}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if !errors.Is(results[0].Error, ErrWriteFile) {
+		t.Errorf("Expected ErrWriteFile, got %v", results[0].Error)
+	}
+}
@@ -0,0 +1,71 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// Exec is a job that pipes the file content through an external command, like a formatter.
+// The content is written to the command stdin and replaced with its stdout.
+// A command failing or exiting with a non-zero code marks the message with ErrExec, including its stderr.
+type Exec struct {
+	// Command is the program to run.
+	Command string
+	// Args are the command arguments. {{path}} is replaced with the file path,
+	// other {{key}} placeholders with metadata values.
+	Args []string
+	// PathArg passes the file path as the last argument instead of writing the content to stdin,
+	// for tools which read the file themselves.
+	PathArg bool
+	// Timeout limits the run time of each command, 0 means no limit.
+	Timeout time.Duration
+}
+
+func (e Exec) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	tesei.TransformStage(ctx, in, out, "Exec", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		path := filepath.Join(msg.Data.Folder, msg.Data.Name)
+
+		args := make([]string, 0, len(e.Args)+1)
+		for _, arg := range e.Args {
+			args = append(args, ResolveString(strings.ReplaceAll(arg, "{{path}}", path), msg))
+		}
+		if e.PathArg {
+			args = append(args, path)
+		}
+
+		var runCtx context.Context = ctx
+		if e.Timeout > 0 {
+			var cancel context.CancelFunc
+			runCtx, cancel = context.WithTimeout(ctx, e.Timeout)
+			defer cancel()
+		}
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(runCtx, e.Command, args...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if !e.PathArg {
+			cmd.Stdin = strings.NewReader(msg.Data.Content)
+		}
+
+		if err := cmd.Run(); err != nil {
+			if runCtx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("timeout after %s", e.Timeout)
+			}
+			if text := strings.TrimSpace(stderr.String()); text != "" {
+				return msg, fmt.Errorf("%w: %s: %w: %s", ErrExec, e.Command, err, text)
+			}
+			return msg, fmt.Errorf("%w: %s: %w", ErrExec, e.Command, err)
+		}
+
+		msg.Data.Content = stdout.String()
+		return msg, nil
+	})
+}
@@ -0,0 +1,60 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkozhukh/tesei/testutil"
+)
+
+func TestExec(t *testing.T) {
+	for _, name := range []string{"tr", "cat", "sh", "sleep"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s is not available", name)
+		}
+	}
+
+	t.Run("Stdin", func(t *testing.T) {
+		msg := testutil.RunOne(t, Source{Files: []TextFile{{Name: "a.txt", Content: "hello\n"}}}, Exec{Command: "tr", Args: []string{"a-z", "A-Z"}})
+		if msg.Error != nil || msg.Data.Content != "HELLO\n" {
+			t.Errorf("got %q, %v", msg.Data.Content, msg.Error)
+		}
+	})
+
+	t.Run("PathArg", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("from disk"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		msg := testutil.RunOne(t, Source{Files: []TextFile{{Name: "a.txt", Folder: dir, Content: "in memory"}}}, Exec{Command: "cat", PathArg: true})
+		if msg.Error != nil || msg.Data.Content != "from disk" {
+			t.Errorf("got %q, %v", msg.Data.Content, msg.Error)
+		}
+	})
+
+	t.Run("Stderr", func(t *testing.T) {
+		msg := testutil.RunOne(t, Source{Files: []TextFile{{Name: "a.txt", Content: "x"}}}, Exec{Command: "sh", Args: []string{"-c", "echo broken {{path}} >&2; exit 3"}})
+		if !errors.Is(msg.Error, ErrExec) || !strings.Contains(msg.Error.Error(), "broken a.txt") {
+			t.Errorf("got error %v", msg.Error)
+		}
+		if msg.Data.Content != "x" {
+			t.Errorf("content changed to %q", msg.Data.Content)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		start := time.Now()
+		msg := testutil.RunOne(t, Source{Files: []TextFile{{Name: "a.txt"}}}, Exec{Command: "sleep", Args: []string{"5"}, Timeout: 50 * time.Millisecond})
+		if !errors.Is(msg.Error, ErrExec) || !strings.Contains(msg.Error.Error(), "timeout") {
+			t.Errorf("got error %v", msg.Error)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("the command was not stopped by the timeout")
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// Expand is a job that expands ${VAR} variables in the content, and inlines files referenced
+// by "@include path" lines when Includes is set. Includes are resolved first, relative to the folder
+// of the including file, so included files can use variables and include other files.
+type Expand struct {
+	// Vars are the variable values. If nil, the environment is used.
+	Vars map[string]string
+	// Includes enables "@include path" directives.
+	Includes bool
+	// Limiter caps the number of open files. Defaults to the global one set by SetFileLimiter.
+	Limiter *FileLimiter
+}
+
+var (
+	// ${VAR} or ${VAR:-default}
+	expandVarPattern     = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+	expandIncludePattern = regexp.MustCompile(`(?m)^[ \t]*@include[ \t]+(.+?)[ \t]*$`)
+)
+
+func (e Expand) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	limiter := LimiterOrDefault(e.Limiter)
+	lookup := os.LookupEnv
+	if e.Vars != nil {
+		lookup = func(name string) (string, bool) {
+			value, ok := e.Vars[name]
+			return value, ok
+		}
+	}
+
+	tesei.TransformStage(ctx, in, out, "Expand", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		content := msg.Data.Content
+		if e.Includes {
+			self, _ := filepath.Abs(filepath.Join(msg.Data.Folder, msg.Data.Name))
+			var err error
+			content, err = e.include(ctx, limiter, content, msg.Data.Folder, []string{self})
+			if err != nil {
+				return msg, err
+			}
+		}
+
+		var undefined []string
+		content = expandVarPattern.ReplaceAllStringFunc(content, func(match string) string {
+			parts := expandVarPattern.FindStringSubmatch(match)
+			if value, ok := lookup(parts[1]); ok {
+				return value
+			}
+			if strings.Contains(match, ":-") {
+				return parts[2]
+			}
+			undefined = append(undefined, parts[1])
+			return match
+		})
+		if len(undefined) > 0 {
+			return msg, fmt.Errorf("%w: undefined variables %s", ErrExpand, strings.Join(undefined, ", "))
+		}
+
+		msg.Data.Content = content
+		return msg, nil
+	})
+}
+
+// include replaces the include lines of the content with the files they reference.
+// The stack holds the absolute paths of the files being included, to detect cycles.
+func (e Expand) include(ctx *tesei.Thread, limiter *FileLimiter, content, folder string, stack []string) (string, error) {
+	var failed error
+	result := expandIncludePattern.ReplaceAllStringFunc(content, func(line string) string {
+		if failed != nil {
+			return line
+		}
+
+		target := strings.TrimSpace(strings.TrimSpace(line)[len("@include"):])
+		path := target
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(folder, path)
+		}
+		abs, _ := filepath.Abs(path)
+
+		for i, p := range stack {
+			if p == abs {
+				chain := append(stack[i:len(stack):len(stack)], abs)
+				failed = fmt.Errorf("%w: include cycle %s", ErrExpand, strings.Join(chain, " -> "))
+				return line
+			}
+		}
+
+		data, err := limitedReadFile(ctx, limiter, path)
+		if err != nil {
+			failed = fmt.Errorf("%w: include %s: %w", ErrExpand, target, err)
+			return line
+		}
+
+		// the line break of the include line is kept, the one at the end of the file is not needed
+		text := strings.TrimSuffix(string(data), "\n")
+		text, failed = e.include(ctx, limiter, text, filepath.Dir(path), append(stack, abs))
+		return text
+	})
+	if failed != nil {
+		return content, failed
+	}
+	return result, nil
+}
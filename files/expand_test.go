@@ -0,0 +1,135 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("parts/db.conf", "db = ${DB_HOST}\n@include pool.conf\n")
+	write("parts/pool.conf", "pool = ${POOL:-10}\n")
+	write("loop/a.conf", "a\n@include b.conf\n")
+	write("loop/b.conf", "b\n@include a.conf\n")
+
+	vars := map[string]string{"NAME": "api", "DB_HOST": "localhost"}
+
+	tests := []struct {
+		name     string
+		job      Expand
+		file     TextFile
+		expected string
+		err      string
+	}{
+		{
+			name:     "Variables",
+			job:      Expand{Vars: vars},
+			file:     TextFile{Content: "name = ${NAME}\nhost = ${DB_HOST}\ncost = $5 {x}"},
+			expected: "name = api\nhost = localhost\ncost = $5 {x}",
+		},
+		{
+			name:     "Default",
+			job:      Expand{Vars: vars},
+			file:     TextFile{Content: "port = ${PORT:-8080}\nname = ${NAME:-web}\nempty = ${EMPTY:-}"},
+			expected: "port = 8080\nname = api\nempty = ",
+		},
+		{
+			name: "Undefined",
+			job:  Expand{Vars: vars},
+			file: TextFile{Content: "${NAME} ${PORT} ${USER}"},
+			err:  "undefined variables PORT, USER",
+		},
+		{
+			name:     "Include",
+			job:      Expand{Vars: vars, Includes: true},
+			file:     TextFile{Folder: dir, Name: "app.conf", Content: "[app]\n  @include parts/db.conf\nname = ${NAME}\n"},
+			expected: "[app]\ndb = localhost\npool = 10\nname = api\n",
+		},
+		{
+			name:     "Includes disabled",
+			job:      Expand{Vars: vars},
+			file:     TextFile{Folder: dir, Content: "@include parts/db.conf"},
+			expected: "@include parts/db.conf",
+		},
+		{
+			name: "Include cycle",
+			job:  Expand{Vars: vars, Includes: true},
+			file: TextFile{Folder: filepath.Join(dir, "loop"), Name: "main.conf", Content: "@include a.conf"},
+			err:  "include cycle",
+		},
+		{
+			name: "Missing include",
+			job:  Expand{Vars: vars, Includes: true},
+			file: TextFile{Folder: dir, Content: "@include nope.conf"},
+			err:  "include nope.conf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []*tesei.Message[TextFile]
+			_, err := tesei.NewPipeline[TextFile]().
+				Sequential(Source{Files: []TextFile{tt.file}}).
+				Sequential(tt.job).
+				Sequential(tesei.Collect[TextFile]{Items: &result}).
+				Sequential(tesei.End[TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			msg := result[0]
+			if tt.err != "" {
+				if !errors.Is(msg.Error, ErrExpand) || !strings.Contains(msg.Error.Error(), tt.err) {
+					t.Fatalf("error = %v, want ErrExpand with %q", msg.Error, tt.err)
+				}
+				if msg.Data.Content != tt.file.Content {
+					t.Errorf("content changed on error: %q", msg.Data.Content)
+				}
+				return
+			}
+			if msg.Error != nil {
+				t.Fatalf("unexpected error: %v", msg.Error)
+			}
+			if msg.Data.Content != tt.expected {
+				t.Errorf("content = %q, want %q", msg.Data.Content, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandEnvironment(t *testing.T) {
+	t.Setenv("TESEI_EXPAND_TEST", "from env")
+
+	var result []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Content: "value: ${TESEI_EXPAND_TEST}"}}}).
+		Sequential(Expand{}).
+		Sequential(tesei.Collect[TextFile]{Items: &result}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if result[0].Data.Content != "value: from env" {
+		t.Errorf("content = %q", result[0].Data.Content)
+	}
+}
@@ -0,0 +1,71 @@
+package files
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// ByExtension is a job that sends each file to the job registered for its extension.
+// Files with other extensions go to Default, or are passed through unchanged without it.
+// The jobs run concurrently and may emit any number of messages, so the output order is not kept.
+// A built pipeline can be used as a job, to run several jobs for an extension.
+type ByExtension struct {
+	// Jobs maps extensions, like ".md", to jobs. Extensions match case-insensitively, the dot is optional.
+	// Two keys of the same extension, like ".md" and "MD", are a critical ErrDuplicateExtension error.
+	Jobs map[string]tesei.Job[TextFile]
+	// Default processes the files without a matching job.
+	Default tesei.Job[TextFile]
+}
+
+func (b ByExtension) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	exts := make([]string, 0, len(b.Jobs))
+	for ext := range b.Jobs {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+
+	// branch index by normalized extension, Default is the last branch
+	branches := make([]tesei.Job[TextFile], 0, len(exts)+1)
+	index := make(map[string]int, len(exts))
+	for _, ext := range exts {
+		key := extensionKey(ext)
+		if i, ok := index[key]; ok {
+			close(out)
+			select {
+			case ctx.Error() <- fmt.Errorf("%w: %q and %q", ErrDuplicateExtension, exts[i], ext):
+			case <-ctx.Done():
+			}
+			return
+		}
+		index[key] = len(branches)
+		branches = append(branches, b.Jobs[ext])
+	}
+	fallback := -1
+	if b.Default != nil {
+		fallback = len(branches)
+		branches = append(branches, b.Default)
+	}
+
+	tesei.RouteJob[TextFile]{
+		Selector: func(msg *tesei.Message[TextFile]) int {
+			if i, ok := index[extensionKey(filepath.Ext(msg.Data.Name))]; ok {
+				return i
+			}
+			return fallback
+		},
+		Branches:      branches,
+		PassUnmatched: true,
+	}.Run(ctx, in, out)
+}
+
+// extensionKey normalizes an extension to a lowercase one with the leading dot.
+func extensionKey(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	return "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+}
@@ -0,0 +1,102 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestByExtension(t *testing.T) {
+	upper := tesei.TransformJob[TextFile]{
+		Transform: func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+			msg.Data.Content = strings.ToUpper(msg.Data.Content)
+			return msg, nil
+		},
+	}
+	tag := func(tag string) tesei.Job[TextFile] {
+		return tesei.TransformJob[TextFile]{
+			Transform: func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+				msg.Data.Content = tag + msg.Data.Content
+				return msg, nil
+			},
+		}
+	}
+
+	src := []TextFile{
+		{Name: "a.md", Content: "doc"},
+		{Name: "b.txt", Content: "text"},
+		{Name: "c.MD", Content: "upper"},
+		{Name: "d.go", Content: "code"},
+		{Name: "e", Content: "plain"},
+	}
+
+	tests := []struct {
+		name     string
+		job      ByExtension
+		expected map[string]string
+	}{
+		{
+			name: "Pass through",
+			job:  ByExtension{Jobs: map[string]tesei.Job[TextFile]{".md": upper, "go": tag("// ")}},
+			expected: map[string]string{
+				"a.md": "DOC", "b.txt": "text", "c.MD": "UPPER", "d.go": "// code", "e": "plain",
+			},
+		},
+		{
+			name: "Default and sub-pipeline",
+			job: ByExtension{
+				Jobs: map[string]tesei.Job[TextFile]{
+					".md": tesei.NewPipeline[TextFile]().Sequential(upper).Sequential(tag("# ")).Build(),
+				},
+				Default: tag("> "),
+			},
+			expected: map[string]string{
+				"a.md": "# DOC", "b.txt": "> text", "c.MD": "# UPPER", "d.go": "> code", "e": "> plain",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*tesei.Message[TextFile]
+			_, err := tesei.NewPipeline[TextFile]().
+				Sequential(Source{Files: src}).
+				Sequential(tt.job).
+				Sequential(tesei.Collect[TextFile]{Items: &results}).
+				Sequential(tesei.End[TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			if len(results) != len(tt.expected) {
+				t.Fatalf("got %d messages, want %d", len(results), len(tt.expected))
+			}
+			for _, msg := range results {
+				if want := tt.expected[msg.Data.Name]; msg.Data.Content != want {
+					t.Errorf("%s: content = %q, want %q", msg.Data.Name, msg.Data.Content, want)
+				}
+			}
+		})
+	}
+}
+
+func TestByExtensionDuplicate(t *testing.T) {
+	pass := tesei.TransformJob[TextFile]{
+		Transform: func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) { return msg, nil },
+	}
+
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "a.md"}, {Name: "b.md"}}}).
+		Sequential(ByExtension{Jobs: map[string]tesei.Job[TextFile]{".md": pass, "MD": pass}}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if !errors.Is(err, ErrDuplicateExtension) {
+		t.Errorf("Expected ErrDuplicateExtension, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package files
+
+import (
+	"regexp"
+	"strings"
+)
+
+var fencePattern = regexp.MustCompile("^( *)(`{3,}|~{3,})")
+
+// FenceLine is the place of a line relative to the fenced code blocks of a markdown text.
+type FenceLine int
+
+const (
+	OutsideFence FenceLine = iota
+	OpeningFence
+	InsideFence
+	ClosingFence
+)
+
+// FenceScanner follows the fenced code blocks of a markdown text line by line,
+// for the jobs which leave code untouched, like SplitByHeading and the text jobs.
+// A block is closed by a line of at least as many fence characters of the same kind, and nothing else.
+type FenceScanner struct {
+	fence string
+	// Match holds the indentation and the fence of the opening line of the current block,
+	// as the full match and the two submatches of "^( *)(`{3,}|~{3,})"
+	Match []string
+}
+
+// Next returns the place of the line, which follows the previous one passed to Next.
+func (f *FenceScanner) Next(line string) FenceLine {
+	if f.fence != "" {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, f.fence) && strings.Trim(trimmed, f.fence[:1]) == "" {
+			f.fence = ""
+			return ClosingFence
+		}
+		return InsideFence
+	}
+
+	if match := fencePattern.FindStringSubmatch(line); match != nil {
+		f.fence = match[2]
+		f.Match = match
+		return OpeningFence
+	}
+	return OutsideFence
+}
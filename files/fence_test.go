@@ -0,0 +1,35 @@
+package files
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFenceScanner(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []FenceLine
+	}{
+		{"backticks", "text\n```go\nx := 1\n```\ntext", []FenceLine{OutsideFence, OpeningFence, InsideFence, ClosingFence, OutsideFence}},
+		{"tildes", "~~~\n```\n~~~~", []FenceLine{OpeningFence, InsideFence, ClosingFence}},
+		{"info string doesn't close", "```\n```go\n```", []FenceLine{OpeningFence, InsideFence, ClosingFence}},
+		{"shorter fence doesn't close", "````\n```\n  ````", []FenceLine{OpeningFence, InsideFence, ClosingFence}},
+		{"indented", "  ```\ncode\n   ```", []FenceLine{OpeningFence, InsideFence, ClosingFence}},
+		{"unclosed", "```\ncode", []FenceLine{OpeningFence, InsideFence}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fences FenceScanner
+			var got []FenceLine
+			for _, line := range strings.Split(tt.content, "\n") {
+				got = append(got, fences.Next(line))
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("lines = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// Fingerprint is a job that stores a fingerprint of the file in metadata, for change detection across runs.
+// The fingerprint combines the hash and length of the content with the modification time of the file.
+// The time is taken from "file_mod_time" metadata, as set by ReadFile, without touching the disk;
+// otherwise the file at the message folder and name is checked, and its size is used instead of the length.
+// Files which have neither get only the content hash and length.
+type Fingerprint struct {
+	// Key is the metadata key to store the fingerprint in. Defaults to "fingerprint".
+	Key string
+}
+
+func (f Fingerprint) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	key := fingerprintKey(f.Key)
+
+	tesei.TransformStage(ctx, in, out, "Fingerprint", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		sum := sha256.Sum256([]byte(msg.Data.Content))
+		size, mtime := int64(len(msg.Data.Content)), int64(0)
+		if t, ok := msg.Metadata["file_mod_time"].(time.Time); ok {
+			mtime = t.UnixNano()
+		} else if info, err := os.Stat(filepath.Join(msg.Data.Folder, msg.Data.Name)); err == nil {
+			size, mtime = info.Size(), info.ModTime().UnixNano()
+		}
+
+		msg.Metadata[key] = fmt.Sprintf("%s:%d:%d", hex.EncodeToString(sum[:8]), size, mtime)
+		return msg, nil
+	})
+}
+
+// LoadFingerprints is a job that compares fingerprints with the ones stored by SaveFingerprints in a previous run.
+// It sets "changed" metadata to false for files with the same fingerprint, and to true for new and modified ones.
+// A missing manifest means all files are changed.
+type LoadFingerprints struct {
+	// Path is the manifest file.
+	Path string
+	// Key is the metadata key of the fingerprint. Defaults to "fingerprint".
+	Key string
+}
+
+func (l LoadFingerprints) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	key := fingerprintKey(l.Key)
+	manifest, err := loadManifest(l.Path)
+	if err != nil {
+		close(out)
+		select {
+		case ctx.Error() <- fmt.Errorf("%w: %w", ErrReadFile, err):
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	tesei.TransformStage(ctx, in, out, "LoadFingerprints", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		stored, ok := manifest[manifestPath(msg)]
+		msg.Metadata["changed"] = !ok || stored != msg.Metadata[key]
+		return msg, nil
+	})
+}
+
+// SaveFingerprints is a job that records the fingerprints of passing files and writes the manifest
+// once the input is closed. Entries of files not seen in this run are kept, so it can be placed
+// after SkipUnchanged. Files with errors are not recorded, so they are processed again in the next run.
+type SaveFingerprints struct {
+	// Path is the manifest file.
+	Path string
+	// Key is the metadata key of the fingerprint. Defaults to "fingerprint".
+	Key string
+}
+
+func (s SaveFingerprints) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	defer close(out)
+	key := fingerprintKey(s.Key)
+
+	manifest, err := loadManifest(s.Path)
+	if err != nil {
+		select {
+		case ctx.Error() <- fmt.Errorf("%w: %w", ErrReadFile, err):
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				if err := saveManifest(s.Path, manifest); err != nil {
+					select {
+					case ctx.Error() <- fmt.Errorf("%w: %w", ErrWriteFile, err):
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+
+			if fingerprint, ok := msg.Metadata[key].(string); ok && msg.Error == nil {
+				manifest[manifestPath(msg)] = fingerprint
+			}
+
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// SkipUnchanged is a job that drops files marked as unchanged by LoadFingerprints.
+type SkipUnchanged struct{}
+
+func (s SkipUnchanged) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	tesei.Filter(ctx, in, out, func(msg *tesei.Message[TextFile]) bool {
+		changed, ok := msg.Metadata["changed"].(bool)
+		return !ok || changed || msg.Error != nil
+	})
+}
+
+func fingerprintKey(key string) string {
+	if key == "" {
+		return "fingerprint"
+	}
+	return key
+}
+
+// manifestPath is the key of the file in the manifest.
+func manifestPath(msg *tesei.Message[TextFile]) string {
+	return filepath.ToSlash(filepath.Join(msg.Data.Folder, msg.Data.Name))
+}
+
+func loadManifest(path string) (map[string]string, error) {
+	manifest := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+func saveManifest(path string, manifest map[string]string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,138 @@
+package files
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestFingerprints(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "cache", "fingerprints.json")
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("content of "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func() (map[string]bool, []string) {
+		t.Helper()
+		var all, processed []*tesei.Message[TextFile]
+		_, err := tesei.NewPipeline[TextFile]().
+			Sequential(ListDir{Path: dir, Ext: ".txt"}).
+			Sequential(ReadFile{}).
+			Sequential(Fingerprint{}).
+			Sequential(LoadFingerprints{Path: manifest}).
+			Sequential(tesei.Collect[TextFile]{Items: &all}).
+			Sequential(SkipUnchanged{}).
+			Sequential(tesei.Collect[TextFile]{Items: &processed}).
+			Sequential(SaveFingerprints{Path: manifest}).
+			Sequential(tesei.End[TextFile]{}).
+			Build().
+			Start(context.Background())
+		if err != nil {
+			t.Fatalf("pipeline failed: %v", err)
+		}
+
+		changed := make(map[string]bool)
+		for _, msg := range all {
+			changed[msg.Data.Name] = msg.Metadata["changed"].(bool)
+		}
+		var names []string
+		for _, msg := range processed {
+			names = append(names, msg.Data.Name)
+		}
+		return changed, names
+	}
+
+	changed, processed := run()
+	if !changed["a.txt"] || !changed["b.txt"] || !changed["c.txt"] || len(processed) != 3 {
+		t.Fatalf("first run: all files should be changed, got %v, processed %v", changed, processed)
+	}
+
+	// same size, new content
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("CONTENT OF b.txt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// same content, touched
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "c.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, processed = run()
+	expected := map[string]bool{"a.txt": false, "b.txt": true, "c.txt": true}
+	for name, want := range expected {
+		if changed[name] != want {
+			t.Errorf("%s: changed = %v, want %v", name, changed[name], want)
+		}
+	}
+	if len(processed) != 2 || processed[0] != "b.txt" || processed[1] != "c.txt" {
+		t.Errorf("processed = %v, want [b.txt c.txt]", processed)
+	}
+
+	// the skipped file stays in the manifest
+	changed, processed = run()
+	if len(processed) != 0 {
+		t.Errorf("third run: processed = %v, changed = %v", processed, changed)
+	}
+
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("manifest has %d entries, want 3", len(entries))
+	}
+}
+
+func TestFingerprintModTimeMetadata(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var results []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "missing.txt", Folder: t.TempDir(), Content: "abc"}}}).
+		Sequential(tesei.SetMetaData[TextFile]{Key: "file_mod_time", Value: mtime}).
+		Sequential(Fingerprint{}).
+		Sequential(tesei.Collect[TextFile]{Items: &results}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	// the file isn't on disk, the time comes from metadata
+	expected := fmt.Sprintf("ba7816bf8f01cfea:3:%d", mtime.UnixNano())
+	if got := results[0].Metadata["fingerprint"]; got != expected {
+		t.Errorf("fingerprint = %v, want %s", got, expected)
+	}
+}
+
+func TestLoadFingerprintsInvalidManifest(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "fingerprints.json")
+	if err := os.WriteFile(manifest, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "a.txt", Content: "a"}}}).
+		Sequential(Fingerprint{}).
+		Sequential(LoadFingerprints{Path: manifest}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for an invalid manifest")
+	}
+}
@@ -1,9 +1,11 @@
 package files
 
 import (
+	"bytes"
 	"crypto/md5"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/mkozhukh/tesei"
 )
@@ -34,6 +36,26 @@ func hashBase62(input string, size int) string {
 	return string(chars)
 }
 
+// maxPooledBuffer is the capacity above which buffers are dropped instead of pooled,
+// so a single huge file doesn't pin its memory for the rest of the run.
+const maxPooledBuffer = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
 // ResolveString replaces template variables in the format {{key}} with values from metadata.
 // It supports string, int, float64, and bool metadata values.
 func ResolveString(input string, msg *tesei.Message[TextFile]) string {
@@ -42,8 +64,9 @@ func ResolveString(input string, msg *tesei.Message[TextFile]) string {
 		return input
 	}
 
-	var result strings.Builder
-	result.Grow(len(input)) // Pre-allocate to avoid reallocations
+	// the pooled buffer keeps its capacity between calls, so the result string is the only allocation
+	result := getBuffer()
+	defer putBuffer(result)
 
 	i := 0
 	for i < len(input) {
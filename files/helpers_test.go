@@ -0,0 +1,51 @@
+package files
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestResolveString(t *testing.T) {
+	msg := tesei.NewMessage(TextFile{Name: "a.md"})
+	msg.Metadata["name"] = "intro"
+	msg.Metadata["count"] = 3
+	msg.Metadata["ratio"] = 0.5
+	msg.Metadata["draft"] = true
+	msg.Metadata["long"] = strings.Repeat("x", 100)
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"plain text", "plain text"},
+		{"{{name}}.md", "intro.md"},
+		{"{{count}}-{{ratio}}-{{draft}}", "3-0.5-true"},
+		{"a{{missing}}b", "ab"},
+		{"a{{}}b", "ab"},
+		{"open {{name", "open {{name"},
+		{"{{long}}", strings.Repeat("x", 100)},
+	}
+
+	for _, tt := range tests {
+		// run twice, the second call gets a used buffer from the pool
+		for i := 0; i < 2; i++ {
+			if got := ResolveString(tt.input, msg); got != tt.want {
+				t.Errorf("ResolveString(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		}
+	}
+}
+
+func BenchmarkResolveString(b *testing.B) {
+	msg := tesei.NewMessage(TextFile{Name: "a.md"})
+	msg.Metadata["title"] = strings.Repeat("A long title ", 20)
+	msg.Metadata["hash"] = "a1b2c3d4"
+	input := "{{title}} - {{hash}} - {{title}}"
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ResolveString(input, msg)
+	}
+}
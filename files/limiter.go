@@ -0,0 +1,88 @@
+package files
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+var fileLimiter *FileLimiter
+
+// SetFileLimiter sets the global file limiter shared by ReadFile and WriteFile jobs.
+func SetFileLimiter(l *FileLimiter) {
+	fileLimiter = l
+}
+
+// FileLimiter caps the number of files open at the same time by all workers of file jobs,
+// to stay under the OS file descriptor limit regardless of the fan-out width.
+type FileLimiter struct {
+	// Max is the maximal number of open files, 0 means no limit.
+	Max int
+
+	once  sync.Once
+	slots chan struct{}
+}
+
+// Acquire blocks until a file can be opened. Each successful call must be paired with Release.
+func (l *FileLimiter) Acquire(ctx context.Context) error {
+	if l.Max <= 0 {
+		return nil
+	}
+
+	l.once.Do(func() {
+		l.slots = make(chan struct{}, l.Max)
+	})
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees the slot taken by Acquire.
+func (l *FileLimiter) Release() {
+	if l.Max <= 0 {
+		return
+	}
+	<-l.slots
+}
+
+// file operations, replaced in tests
+var (
+	readFile  = os.ReadFile
+	writeFile = os.WriteFile
+)
+
+// LimiterOrDefault returns l, or the global limiter set by SetFileLimiter when l is nil,
+// so jobs of other packages share the budget of the file jobs.
+func LimiterOrDefault(l *FileLimiter) *FileLimiter {
+	if l != nil {
+		return l
+	}
+	if fileLimiter != nil {
+		return fileLimiter
+	}
+	return &FileLimiter{}
+}
+
+// limitedReadFile reads the file within the limiter budget.
+func limitedReadFile(ctx context.Context, l *FileLimiter, path string) ([]byte, error) {
+	if err := l.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	return readFile(path)
+}
+
+// limitedWriteFile writes the file within the limiter budget.
+func limitedWriteFile(ctx context.Context, l *FileLimiter, path string, data []byte, perm os.FileMode) error {
+	if err := l.Acquire(ctx); err != nil {
+		return err
+	}
+	defer l.Release()
+
+	return writeFile(path, data, perm)
+}
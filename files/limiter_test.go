@@ -0,0 +1,76 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// countingFS counts concurrently open files in place of the os calls.
+type countingFS struct {
+	mu   sync.Mutex
+	open int
+	peak int
+}
+
+func (c *countingFS) enter() {
+	c.mu.Lock()
+	c.open++
+	if c.open > c.peak {
+		c.peak = c.open
+	}
+	c.mu.Unlock()
+
+	time.Sleep(2 * time.Millisecond)
+
+	c.mu.Lock()
+	c.open--
+	c.mu.Unlock()
+}
+
+func TestFileLimiter(t *testing.T) {
+	fs := &countingFS{}
+	readFile = func(name string) ([]byte, error) {
+		fs.enter()
+		return []byte(name), nil
+	}
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		fs.enter()
+		return nil
+	}
+	defer func() {
+		readFile = os.ReadFile
+		writeFile = os.WriteFile
+	}()
+
+	dir := t.TempDir()
+	source := Source{}
+	for i := 0; i < 50; i++ {
+		source.Files = append(source.Files, TextFile{Name: fmt.Sprintf("%d.txt", i), Folder: dir})
+	}
+
+	limiter := &FileLimiter{Max: 3}
+	end := tesei.End[TextFile]{}
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(source).
+		FanOut(ReadFile{Limiter: limiter}, 20).
+		FanOut(WriteFile{Limiter: limiter}, 20).
+		Sequential(end).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	if fs.peak > limiter.Max {
+		t.Errorf("peak of open files is %d, want at most %d", fs.peak, limiter.Max)
+	}
+	if fs.peak == 0 {
+		t.Errorf("no files were opened")
+	}
+}
@@ -0,0 +1,191 @@
+package files
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// ApplyPatch is a job that applies a unified diff to the content, for minimal-edit workflows
+// where a model is asked for a patch instead of the whole rewritten file.
+// The patch is taken from the Key metadata and applied to the content, or, if Key is empty,
+// the content is the patch and it is applied to the BaseKey metadata.
+// Hunks are located by their context, so shifted line numbers are tolerated; a hunk which
+// doesn't match the baseline sets ErrPatch and leaves the content untouched.
+type ApplyPatch struct {
+	// Key is the metadata key holding the patch. If empty, the content is the patch.
+	Key string
+	// BaseKey is the metadata key holding the baseline when the content is the patch. Defaults to "original".
+	BaseKey string
+}
+
+func (a ApplyPatch) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	tesei.TransformStage(ctx, in, out, "ApplyPatch", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		base, patch := msg.Data.Content, ""
+		if a.Key != "" {
+			value, ok := msg.Metadata[a.Key].(string)
+			if !ok {
+				return msg, fmt.Errorf("%w: patch %q not found in metadata", ErrPatch, a.Key)
+			}
+			patch = value
+		} else {
+			key := a.BaseKey
+			if key == "" {
+				key = "original"
+			}
+			value, ok := msg.Metadata[key].(string)
+			if !ok {
+				return msg, fmt.Errorf("%w: baseline %q not found in metadata", ErrPatch, key)
+			}
+			base, patch = value, msg.Data.Content
+		}
+
+		result, err := applyPatch(base, patch)
+		if err != nil {
+			return msg, fmt.Errorf("%w: %w", ErrPatch, err)
+		}
+		msg.Data.Content = result
+		return msg, nil
+	})
+}
+
+type hunk struct {
+	// start is the 1-based line of the hunk in the baseline, as stated by the header
+	start int
+	old   []string
+	new   []string
+}
+
+// parsePatch reads the hunks of a unified diff. File headers are skipped, as well as a
+// code fence around the patch, which models tend to add. Line counts of the hunk headers
+// are not trusted, a hunk runs until the next header.
+func parsePatch(patch string) ([]hunk, error) {
+	var hunks []hunk
+	var current *hunk
+
+	lines := strings.Split(strings.TrimRight(patch, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			start, err := hunkStart(line)
+			if err != nil {
+				return nil, err
+			}
+			hunks = append(hunks, hunk{start: start})
+			current = &hunks[len(hunks)-1]
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "),
+			strings.HasPrefix(line, "+++ ") && i > 0 && strings.HasPrefix(lines[i-1], "--- "):
+			current = nil
+		case current == nil:
+			// text around the patch: a code fence, "diff --git", index lines
+		case strings.HasPrefix(line, "+"):
+			current.new = append(current.new, line[1:])
+		case strings.HasPrefix(line, "-"):
+			current.old = append(current.old, line[1:])
+		case strings.HasPrefix(line, " "):
+			current.old = append(current.old, line[1:])
+			current.new = append(current.new, line[1:])
+		case line == "":
+			// an empty context line, trailing spaces are often lost
+			current.old = append(current.old, "")
+			current.new = append(current.new, "")
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+		case strings.HasPrefix(line, "```"):
+			current = nil
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", i+1, line)
+		}
+	}
+
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("no hunks")
+	}
+	return hunks, nil
+}
+
+// hunkStart returns the baseline line of a "@@ -start,count +start,count @@" header.
+func hunkStart(header string) (int, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") {
+		return 0, fmt.Errorf("invalid hunk header %q", header)
+	}
+	start, _, _ := strings.Cut(fields[1][1:], ",")
+	n, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hunk header %q", header)
+	}
+	return n, nil
+}
+
+// applyPatch applies the hunks of the patch in order. Each hunk is searched for around its
+// stated position, after the previous hunk.
+func applyPatch(content, patch string) (string, error) {
+	hunks, err := parsePatch(patch)
+	if err != nil {
+		return "", err
+	}
+
+	newline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	var result []string
+	pos, drift := 0, 0
+	for i, h := range hunks {
+		// a pure insertion at -N,0 goes after line N, otherwise the hunk starts at line N
+		stated := h.start - 1
+		if len(h.old) == 0 {
+			stated = h.start
+		}
+
+		// the next hunks are likely shifted by as much as this one
+		at := findHunk(lines, h.old, pos, stated+drift)
+		if at < 0 {
+			return "", fmt.Errorf("hunk %d (line %d) doesn't match", i+1, h.start)
+		}
+		drift = at - stated
+
+		result = append(result, lines[pos:at]...)
+		result = append(result, h.new...)
+		pos = at + len(h.old)
+	}
+	result = append(result, lines[pos:]...)
+
+	text := strings.Join(result, "\n")
+	if newline && len(result) > 0 {
+		text += "\n"
+	}
+	return text, nil
+}
+
+// findHunk returns the position of the old lines in lines, not before from,
+// searching outwards from the expected position. It returns -1 when there is no match.
+func findHunk(lines, old []string, from, expected int) int {
+	matches := func(at int) bool {
+		if at < from || at+len(old) > len(lines) {
+			return false
+		}
+		for i, line := range old {
+			if lines[at+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+
+	expected = max(from, expected)
+	for d := 0; expected-d >= from || expected+d <= len(lines); d++ {
+		if matches(expected - d) {
+			return expected - d
+		}
+		if matches(expected + d) {
+			return expected + d
+		}
+	}
+	return -1
+}
@@ -0,0 +1,82 @@
+package files
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/testutil"
+)
+
+const patchBase = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n\nfunc helper() {\n\treturn\n}\n"
+
+// withMeta is a source of one file with the given metadata.
+func withMeta(file TextFile, meta map[string]any) tesei.Job[TextFile] {
+	return tesei.JobFunc[TextFile](func(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+		defer close(out)
+		msg := tesei.NewMessage(file)
+		for k, v := range meta {
+			msg.Metadata[k] = v
+		}
+		out <- msg
+	})
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patch    string
+		expected string
+	}{
+		{
+			name: "Two hunks",
+			patch: "--- a/main.go\n+++ b/main.go\n" +
+				"@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hello, world\")\n }\n" +
+				"@@ -9,3 +9,4 @@\n func helper() {\n+\t// nothing to do\n \treturn\n }\n",
+			expected: "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello, world\")\n}\n\nfunc helper() {\n\t// nothing to do\n\treturn\n}\n",
+		},
+		{
+			name:     "Wrong line numbers in a fence",
+			patch:    "```diff\n@@ -1,2 +1,2 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"bye\")\n```\n",
+			expected: "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"bye\")\n}\n\nfunc helper() {\n\treturn\n}\n",
+		},
+		{
+			name:     "Insertion at the start",
+			patch:    "@@ -0,0 +1,1 @@\n+// Code generated. DO NOT EDIT.\n",
+			expected: "// Code generated. DO NOT EDIT.\n" + patchBase,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := testutil.RunOne(t, withMeta(TextFile{Name: "main.go", Content: patchBase}, map[string]any{"patch": tt.patch}), ApplyPatch{Key: "patch"})
+			if msg.Error != nil {
+				t.Fatalf("unexpected error: %v", msg.Error)
+			}
+			if msg.Data.Content != tt.expected {
+				t.Errorf("content =\n%s\nwant\n%s", msg.Data.Content, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApplyPatchContent(t *testing.T) {
+	// the content is the patch, as returned by a model, the baseline is kept in metadata
+	patch := "@@ -2,1 +2,1 @@\n-b\n+B\n"
+	msg := testutil.RunOne(t, withMeta(TextFile{Name: "a.txt", Content: patch}, map[string]any{"original": "a\nb\nc"}), ApplyPatch{})
+	if msg.Error != nil || msg.Data.Content != "a\nB\nc" {
+		t.Errorf("got %q, %v", msg.Data.Content, msg.Error)
+	}
+}
+
+func TestApplyPatchMismatch(t *testing.T) {
+	patch := "@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"goodbye\")\n+\tfmt.Println(\"hi\")\n }\n"
+	msg := testutil.RunOne(t, withMeta(TextFile{Name: "main.go", Content: patchBase}, map[string]any{"patch": patch}), ApplyPatch{Key: "patch"})
+
+	if !errors.Is(msg.Error, ErrPatch) {
+		t.Fatalf("expected ErrPatch, got %v", msg.Error)
+	}
+	if msg.Data.Content != patchBase {
+		t.Errorf("content changed: %q", msg.Data.Content)
+	}
+}
@@ -0,0 +1,40 @@
+package files
+
+import (
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// URLPath converts a file path to a URL-like path with forward slashes, regardless of the OS,
+// for paths written into content, like markdown links, or used as routes.
+// Backslashes are always treated as separators.
+func URLPath(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// SlashPaths is a job that converts path values in metadata to forward slashes,
+// so content generated from them doesn't carry Windows separators.
+// String and []string values are converted, other values are left as is.
+type SlashPaths struct {
+	// Keys are the metadata keys holding paths.
+	Keys []string
+}
+
+func (s SlashPaths) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	tesei.TransformStage(ctx, in, out, "SlashPaths", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		for _, key := range s.Keys {
+			switch v := msg.Metadata[key].(type) {
+			case string:
+				msg.Metadata[key] = URLPath(v)
+			case []string:
+				paths := make([]string, len(v))
+				for i, p := range v {
+					paths[i] = URLPath(p)
+				}
+				msg.Metadata[key] = paths
+			}
+		}
+		return msg, nil
+	})
+}
@@ -0,0 +1,98 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestSlashPaths(t *testing.T) {
+	var result []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "index.md"}}}).
+		Sequential(tesei.SetMetaData[TextFile]{Key: "path", Handler: func(*tesei.Message[TextFile]) any { return `docs\guide\intro.md` }}).
+		Sequential(tesei.SetMetaData[TextFile]{Key: "pages", Handler: func(*tesei.Message[TextFile]) any { return []string{`a\b.md`, "c/d.md"} }}).
+		Sequential(tesei.SetMetaData[TextFile]{Key: "count", Handler: func(*tesei.Message[TextFile]) any { return 2 }}).
+		Sequential(SlashPaths{Keys: []string{"path", "pages", "count", "missing"}}).
+		Sequential(Transform{Handler: func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+			msg.Data.Content = ResolveString("[Intro]({{path}})", msg)
+			return msg, nil
+		}}).
+		Sequential(tesei.Collect[TextFile]{Items: &result}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	msg := result[0]
+	if msg.Data.Content != "[Intro](docs/guide/intro.md)" {
+		t.Errorf("link = %q", msg.Data.Content)
+	}
+	if pages := msg.Metadata["pages"]; !reflect.DeepEqual(pages, []string{"a/b.md", "c/d.md"}) {
+		t.Errorf("pages = %v", pages)
+	}
+	if count := msg.Metadata["count"]; count != 2 {
+		t.Errorf("count = %v", count)
+	}
+	if _, ok := msg.Metadata["missing"]; ok {
+		t.Error("missing key was added")
+	}
+}
+
+func TestWriteFileBasePathSeparators(t *testing.T) {
+	dir := t.TempDir()
+
+	// the base path is given with Windows separators, the message folder with forward slashes
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "a.md", Folder: "src/docs/guide", Content: "a"}}}).
+		Sequential(WriteFile{Folder: dir, BasePath: `src\docs`}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "guide", "a.md")); err != nil {
+		t.Errorf("file not written under the relative folder: %v", err)
+	}
+}
+
+func TestRenameFileSeparators(t *testing.T) {
+	tests := []struct {
+		name     string
+		job      RenameFile
+		expected string
+	}{
+		{"template", RenameFile{Template: "{{section}}/{{slug}}.md"}, filepath.Join("guide", "setup", "intro.md")},
+		{"suffix", RenameFile{Suffix: `\{{slug}}`}, filepath.Join("index", "intro.md")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []*tesei.Message[TextFile]
+			_, err := tesei.NewPipeline[TextFile]().
+				Sequential(Source{Files: []TextFile{{Name: "index.md"}}}).
+				Sequential(tesei.SetMetaData[TextFile]{Key: "section", Handler: func(*tesei.Message[TextFile]) any { return `guide\setup` }}).
+				Sequential(tesei.SetMetaData[TextFile]{Key: "slug", Handler: func(*tesei.Message[TextFile]) any { return "intro" }}).
+				Sequential(tt.job).
+				Sequential(tesei.Collect[TextFile]{Items: &result}).
+				Sequential(tesei.End[TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			if name := result[0].Data.Name; name != tt.expected {
+				t.Errorf("name = %q, want %q", name, tt.expected)
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package files
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mkozhukh/tesei"
 )
@@ -33,6 +38,40 @@ func (s Source) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out c
 	}
 }
 
+// Generate is a job that emits one TextFile message per record of a data set.
+// Content and name are rendered from templates with {{key}} placeholders resolved against the record,
+// the record itself is copied to the message metadata.
+type Generate struct {
+	// Template is the template for the file content.
+	Template string
+	// Name is the template for the file name.
+	Name string
+	// Folder is the folder of generated files.
+	Folder string
+	// Data is the data set, each record produces one file.
+	Data []map[string]any
+}
+
+func (g Generate) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	defer close(out)
+	for _, record := range g.Data {
+		msg := tesei.NewMessage(TextFile{Folder: g.Folder})
+		for k, v := range record {
+			msg.Metadata[k] = v
+		}
+
+		msg.Data.Name = ResolveString(g.Name, msg)
+		msg.Data.Content = ResolveString(g.Template, msg)
+		msg.ID = filepath.Join(g.Folder, msg.Data.Name)
+
+		select {
+		case out <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // ListDir is a job that lists files in a directory and emits them as TextFile messages.
 // It supports filtering by extension, nested directories, and custom filters.
 type ListDir struct {
@@ -44,24 +83,40 @@ type ListDir struct {
 	MaxDepth      int
 	FilterFolders func(name, path string) bool
 	FilterFiles   func(name, path string) bool
+	// FollowSymlinks enters symlinked directories in nested mode, each directory is listed only once,
+	// so symlink loops are safe. By default symlinked directories are skipped.
+	FollowSymlinks bool
 }
 
 func (l ListDir) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
 	defer close(out)
-	l.processDirectory(ctx, l.Path, "", out, 0, 0)
+	l.processDirectory(ctx, l.Path, "", out, 0, 0, make(map[string]bool))
 }
 
-func (l ListDir) processDirectory(ctx *tesei.Thread, dirPath, relPath string, out chan<- *tesei.Message[TextFile], level int, count int) int {
+func (l ListDir) processDirectory(ctx *tesei.Thread, dirPath, relPath string, out chan<- *tesei.Message[TextFile], level int, count int, visited map[string]bool) int {
 	// Check if we've reached max depth
 	if l.MaxDepth > 0 && level >= l.MaxDepth {
 		return -1
 	}
 
+	if l.FollowSymlinks {
+		// resolve the real path, so a directory reached through different links is listed once
+		if real, err := filepath.EvalSymlinks(dirPath); err == nil {
+			if abs, err := filepath.Abs(real); err == nil {
+				real = abs
+			}
+			if visited[real] {
+				return count
+			}
+			visited[real] = true
+		}
+	}
+
 	files, err := os.ReadDir(dirPath)
 
 	if err != nil {
 		select {
-		case ctx.Error() <- fmt.Errorf("read dir: %w", err):
+		case ctx.Error() <- fmt.Errorf("%w: %w", ErrReadDir, err):
 		case <-ctx.Done():
 			return -1
 		}
@@ -74,12 +129,27 @@ func (l ListDir) processDirectory(ctx *tesei.Thread, dirPath, relPath string, ou
 
 	for _, file := range files {
 		baseName := file.Name()
-		if file.IsDir() {
+		isDir := file.IsDir()
+		// the info of a symlink describes its target, as used when the file is read or written
+		var info os.FileInfo
+		if file.Type()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(filepath.Join(dirPath, baseName)); err == nil {
+				info = target
+				if target.IsDir() {
+					if !l.FollowSymlinks {
+						continue
+					}
+					isDir = true
+				}
+			}
+		}
+
+		if isDir {
 			if l.Nested {
 				if l.FilterFolders != nil && !l.FilterFolders(baseName, filepath.Join(relPath, baseName)) {
 					continue
 				}
-				count = l.processDirectory(ctx, filepath.Join(dirPath, file.Name()), filepath.Join(relPath, file.Name()), out, level+1, count)
+				count = l.processDirectory(ctx, filepath.Join(dirPath, file.Name()), filepath.Join(relPath, file.Name()), out, level+1, count, visited)
 				if count < 0 || (l.Limit > 0 && count >= l.Limit) {
 					return count
 				}
@@ -104,8 +174,16 @@ func (l ListDir) processDirectory(ctx *tesei.Thread, dirPath, relPath string, ou
 			fmt.Println("list:", textFile.Name, textFile.Folder)
 		}
 
+		msg := tesei.NewMessageWithID(filepath.Join(dirPath, file.Name()), &textFile)
+		if info == nil {
+			info, _ = file.Info()
+		}
+		if info != nil {
+			setFileInfo(msg, info)
+		}
+
 		select {
-		case out <- tesei.NewMessageWithID(filepath.Join(dirPath, file.Name()), &textFile):
+		case out <- msg:
 		case <-ctx.Done():
 			return -1
 		}
@@ -118,20 +196,83 @@ func (l ListDir) processDirectory(ctx *tesei.Thread, dirPath, relPath string, ou
 	return count
 }
 
+// setFileInfo stores the permissions and the modification time of the file
+// in "file_mode" and "file_mod_time" metadata, for WriteFile to preserve them.
+func setFileInfo(msg *tesei.Message[TextFile], info os.FileInfo) {
+	msg.Metadata["file_mode"] = info.Mode().Perm()
+	msg.Metadata["file_mod_time"] = info.ModTime()
+}
+
 // ReadFile is a job that reads the content of files referenced by incoming TextFile messages.
-type ReadFile struct{}
+// The permissions and modification time of the file are stored in "file_mode" and "file_mod_time" metadata,
+// unless ListDir has stored them already.
+// A file which can't be read is dropped, unless PassUnreadable is set.
+type ReadFile struct {
+	// Limiter caps the number of open files. Defaults to the global one set by SetFileLimiter.
+	Limiter *FileLimiter
+	// PassUnreadable passes a file which can't be read on with an ErrReadFile error instead of dropping it,
+	// e.g. to report it with DeadLetter or an error handler.
+	PassUnreadable bool
+}
 
 func (r ReadFile) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
-		data, err := os.ReadFile(filepath.Join(msg.Data.Folder, msg.Data.Name))
+	limiter := LimiterOrDefault(r.Limiter)
+
+	tesei.TransformStage(ctx, in, out, "ReadFile", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		path := filepath.Join(msg.Data.Folder, msg.Data.Name)
+		data, err := limitedReadFile(ctx, limiter, path)
 		if err != nil {
-			return nil, err
+			if !r.PassUnreadable {
+				return nil, nil
+			}
+			return msg, fmt.Errorf("%w: %w", ErrReadFile, err)
 		}
 		msg.Data.Content = string(data)
+		if _, listed := msg.Metadata["file_mode"]; !listed {
+			if info, err := os.Stat(path); err == nil {
+				setFileInfo(msg, info)
+			}
+		}
 		return msg, nil
 	})
 }
 
+// CollisionPolicy defines how WriteFile handles a target path written more than once in a run.
+type CollisionPolicy int
+
+const (
+	// CollisionOverwrite writes the file again, the last message wins.
+	CollisionOverwrite CollisionPolicy = iota
+	// CollisionError marks the message with ErrCollision and doesn't write it.
+	CollisionError
+	// CollisionSkip passes the message on without writing it.
+	CollisionSkip
+	// CollisionSuffix writes the file under a new name with a numeric suffix, like name-1.txt.
+	CollisionSuffix
+)
+
+// WrittenPaths tracks target paths written during a run. It is safe for concurrent use.
+// The zero value is ready to use.
+type WrittenPaths struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+// claim marks the path as written, it returns false if the path was already claimed.
+func (w *WrittenPaths) claim(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.paths == nil {
+		w.paths = make(map[string]bool)
+	}
+	if w.paths[path] {
+		return false
+	}
+	w.paths[path] = true
+	return true
+}
+
 // WriteFile is a job that writes the content of TextFile messages to disk.
 // It can write to the original folder or a new target folder.
 type WriteFile struct {
@@ -143,18 +284,52 @@ type WriteFile struct {
 	DryRun bool
 	// Log enables logging of written files.
 	Log bool
+	// OnCollision defines what happens when a target path is written more than once. Defaults to CollisionOverwrite.
+	OnCollision CollisionPolicy
+	// Paths tracks written paths for collision detection. Set a shared instance
+	// when the job runs in a FanOut stage or several jobs write to the same folders.
+	// If nil, paths are tracked per job run.
+	Paths *WrittenPaths
+	// PreserveMode keeps the permissions of the source file, as captured in "file_mode" metadata by ListDir or ReadFile.
+	// Files without the metadata are written with the default permissions.
+	PreserveMode bool
+	// PreserveTime keeps the modification time of the source file, as captured in "file_mod_time" metadata.
+	PreserveTime bool
+	// Limiter caps the number of open files. Defaults to the global one set by SetFileLimiter.
+	Limiter *FileLimiter
+	// ContentAddressed stores the content as an object named by its SHA-256 hash, ab/cdef...,
+	// under Folder (or the message folder), like Git objects. Objects which already exist are not written again,
+	// so identical contents are stored once. The message folder and name are set to the object path.
+	ContentAddressed bool
+	// WriteMetadataSidecar writes the message metadata as a JSON object to a sidecar file,
+	// the target path with SidecarSuffix appended. Values which can't be serialized are stored as strings.
+	// Content-addressed objects get no sidecar.
+	WriteMetadataSidecar bool
+	// SidecarSuffix is appended to the target path to name the sidecar file. Defaults to ".meta.json".
+	SidecarSuffix string
 }
 
 func (w WriteFile) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+	paths := w.Paths
+	if paths == nil {
+		paths = &WrittenPaths{}
+	}
+	limiter := LimiterOrDefault(w.Limiter)
+
+	tesei.TransformStage(ctx, in, out, "WriteFile", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		if w.ContentAddressed {
+			return w.writeObject(ctx, limiter, paths, msg), nil
+		}
+
 		var target string
 
 		if w.Folder != "" {
 			if w.BasePath != "" {
-				// Replace base path while preserving nested structure
-				relativePath := strings.TrimPrefix(msg.Data.Folder, w.BasePath)
-				relativePath = strings.TrimPrefix(relativePath, string(filepath.Separator))
-				target = filepath.Join(w.Folder, relativePath, msg.Data.Name)
+				// Replace base path while preserving nested structure,
+				// compared with forward slashes as the paths can mix separators on Windows
+				relativePath := strings.TrimPrefix(URLPath(msg.Data.Folder), URLPath(w.BasePath))
+				relativePath = strings.TrimPrefix(relativePath, "/")
+				target = filepath.Join(w.Folder, filepath.FromSlash(relativePath), msg.Data.Name)
 			} else {
 				// Single folder behavior: completely replace folder
 				target = filepath.Join(w.Folder, msg.Data.Name)
@@ -164,36 +339,193 @@ func (w WriteFile) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], ou
 			target = filepath.Join(msg.Data.Folder, msg.Data.Name)
 		}
 
+		if w.OnCollision != CollisionOverwrite && !paths.claim(target) {
+			switch w.OnCollision {
+			case CollisionError:
+				return msg.WithError(fmt.Errorf("%w: %s", ErrCollision, target), "WriteFile"), nil
+			case CollisionSkip:
+				return msg, nil
+			case CollisionSuffix:
+				ext := filepath.Ext(target)
+				base := strings.TrimSuffix(target, ext)
+				for n := 1; ; n++ {
+					candidate := fmt.Sprintf("%s-%d%s", base, n, ext)
+					if paths.claim(candidate) {
+						target = candidate
+						msg.Data.Name = filepath.Base(candidate)
+						break
+					}
+				}
+			}
+		}
+
 		if !w.DryRun {
 			targetDir := filepath.Dir(target)
 			if err := os.MkdirAll(targetDir, 0755); err != nil {
-				return msg.WithError(err, "create directory"), nil
+				return msg.WithError(fmt.Errorf("%w: %w", ErrCreateDir, err), "WriteFile"), nil
 			}
 
-			err := os.WriteFile(target, []byte(msg.Data.Content), 0644)
+			err := limitedWriteFile(ctx, limiter, target, []byte(msg.Data.Content), 0644)
 			if err != nil {
-				return msg.WithError(err, "write file"), nil
+				return msg.WithError(fmt.Errorf("%w: %w", ErrWriteFile, err), "WriteFile"), nil
+			}
+
+			if err := w.preserve(target, msg.Metadata); err != nil {
+				return msg.WithError(fmt.Errorf("%w: %w", ErrWriteFile, err), "WriteFile"), nil
 			}
 		}
 
 		if w.Log {
 			fmt.Println("write file:", target)
 		}
+		if w.WriteMetadataSidecar {
+			if err := w.writeSidecar(ctx, limiter, target, msg.Metadata); err != nil {
+				return msg.WithError(fmt.Errorf("%w: %w", ErrWriteFile, err), "WriteFile"), nil
+			}
+		}
 		return msg, nil
 	})
 }
 
+// writeObject writes the content to its content-addressed path, unless the object is already there.
+func (w WriteFile) writeObject(ctx *tesei.Thread, limiter *FileLimiter, paths *WrittenPaths, msg *tesei.Message[TextFile]) *tesei.Message[TextFile] {
+	sum := sha256.Sum256([]byte(msg.Data.Content))
+	hash := hex.EncodeToString(sum[:])
+
+	root := w.Folder
+	if root == "" {
+		root = msg.Data.Folder
+	}
+	msg.Data.Folder = filepath.Join(root, hash[:2])
+	msg.Data.Name = hash[2:]
+	target := filepath.Join(msg.Data.Folder, msg.Data.Name)
+
+	// claimed paths cover objects written by other workers of the run, which may not be complete yet
+	if !paths.claim(target) {
+		return msg
+	}
+	if _, err := os.Stat(target); err == nil {
+		return msg
+	}
+
+	if !w.DryRun {
+		if err := os.MkdirAll(msg.Data.Folder, 0755); err != nil {
+			return msg.WithError(fmt.Errorf("%w: %w", ErrCreateDir, err), "WriteFile")
+		}
+		if err := limitedWriteFile(ctx, limiter, target, []byte(msg.Data.Content), 0644); err != nil {
+			return msg.WithError(fmt.Errorf("%w: %w", ErrWriteFile, err), "WriteFile")
+		}
+	}
+
+	if w.Log {
+		fmt.Println("write file:", target)
+	}
+	return msg
+}
+
+// writeSidecar writes the metadata as JSON next to the target file.
+func (w WriteFile) writeSidecar(ctx *tesei.Thread, limiter *FileLimiter, target string, metadata map[string]any) error {
+	suffix := w.SidecarSuffix
+	if suffix == "" {
+		suffix = ".meta.json"
+	}
+	sidecar := target + suffix
+
+	data, err := json.MarshalIndent(sidecarValues(metadata), "", "  ")
+	if err != nil {
+		return err
+	}
+	if !w.DryRun {
+		if err := limitedWriteFile(ctx, limiter, sidecar, data, 0644); err != nil {
+			return err
+		}
+	}
+
+	if w.Log {
+		fmt.Println("write file:", sidecar)
+	}
+	return nil
+}
+
+// sidecarValues serializes each metadata value, values which can't be serialized are stringified.
+func sidecarValues(metadata map[string]any) map[string]json.RawMessage {
+	values := make(map[string]json.RawMessage, len(metadata))
+	for key, value := range metadata {
+		data, err := json.Marshal(value)
+		if err != nil {
+			data, _ = json.Marshal(fmt.Sprint(value))
+		}
+		values[key] = data
+	}
+	return values
+}
+
+// preserve restores the permissions and modification time captured in metadata, when enabled.
+func (w WriteFile) preserve(target string, metadata map[string]any) error {
+	if mode, ok := metadata["file_mode"].(os.FileMode); ok && w.PreserveMode {
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+	}
+	if mtime, ok := metadata["file_mod_time"].(time.Time); ok && w.PreserveTime {
+		// zero access time is left unchanged
+		if err := os.Chtimes(target, time.Time{}, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // PrintContent is a job that prints the content of TextFile messages to stdout.
-type PrintContent struct{}
+type PrintContent struct {
+	// MaxLines limits the number of printed lines per file, 0 means no limit.
+	MaxLines int
+	// Header prints a rule line under the message ID, to separate files.
+	Header bool
+	// Color highlights the header with ANSI colors, when stdout is a terminal.
+	Color bool
+}
+
+const (
+	ansiHeader = "\x1b[1;36m"
+	ansiDim    = "\x1b[2m"
+	ansiReset  = "\x1b[0m"
+)
 
 func (p PrintContent) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
-		fmt.Println(msg.ID)
-		fmt.Println(msg.Data.Content)
+	color := p.Color && isTerminal(os.Stdout)
+	paint := func(code, text string) string {
+		if !color {
+			return text
+		}
+		return code + text + ansiReset
+	}
+
+	tesei.TransformStage(ctx, in, out, "PrintContent", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		fmt.Println(paint(ansiHeader, msg.ID))
+		if p.Header {
+			fmt.Println(paint(ansiDim, strings.Repeat("-", 40)))
+		}
+
+		content := msg.Data.Content
+		if p.MaxLines > 0 {
+			lines := strings.Split(content, "\n")
+			if len(lines) > p.MaxLines {
+				content = strings.Join(lines[:p.MaxLines], "\n") + "\n" +
+					paint(ansiDim, fmt.Sprintf("... (%d more lines)", len(lines)-p.MaxLines))
+			}
+		}
+		fmt.Println(content)
 		return msg, nil
 	})
 }
 
+// isTerminal reports whether the file is a character device, like an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 // HashContent is a job that calculates a hash of the file content and stores it in metadata.
 type HashContent struct {
 	// Key is the metadata key to store the hash in. Defaults to "hash".
@@ -203,7 +535,7 @@ type HashContent struct {
 }
 
 func (h HashContent) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+	tesei.TransformStage(ctx, in, out, "HashContent", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
 		key := h.Key
 		if key == "" {
 			key = "hash"
@@ -214,15 +546,24 @@ func (h HashContent) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile],
 }
 
 // RenameFile is a job that renames files by modifying their Name field.
+// Both slashes and backslashes in the new name, like in paths resolved from metadata,
+// separate folders and are converted to the separator of the OS.
 type RenameFile struct {
 	// Suffix is appended to the filename before the extension.
 	Suffix string
 	// Ext is the new extension to use. If empty, preserves original extension.
 	Ext string
+	// Template replaces the whole name, like "page-{{seq}}.html". Suffix and Ext are ignored when it is set.
+	Template string
 }
 
 func (r RenameFile) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+	tesei.TransformStage(ctx, in, out, "RenameFile", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		if r.Template != "" {
+			msg.Data.Name = filepath.FromSlash(URLPath(ResolveString(r.Template, msg)))
+			return msg, nil
+		}
+
 		ext := ResolveString(r.Ext, msg)
 		if ext == "" {
 			ext = filepath.Ext(msg.Data.Name)
@@ -230,7 +571,7 @@ func (r RenameFile) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], o
 		suffix := ResolveString(r.Suffix, msg)
 
 		prevExt := filepath.Ext(msg.Data.Name)
-		msg.Data.Name = strings.TrimSuffix(msg.Data.Name, prevExt) + suffix + ext
+		msg.Data.Name = filepath.FromSlash(URLPath(strings.TrimSuffix(msg.Data.Name, prevExt) + suffix + ext))
 		return msg, nil
 	})
 }
@@ -241,5 +582,5 @@ type Transform struct {
 }
 
 func (t Transform) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
-	tesei.Transform(ctx, in, out, t.Handler)
+	tesei.TransformStage(ctx, in, out, "Transform", t.Handler)
 }
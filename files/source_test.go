@@ -2,7 +2,15 @@ package files
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
 
 	"github.com/mkozhukh/tesei"
 )
@@ -84,6 +92,25 @@ func ExampleRenameFile_withHash() {
 	// write file: ../testdata/b_ivgFrYaM.txt
 }
 
+func ExampleRenameFile_withTemplate() {
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(ListDir{Path: "../testdata", Ext: ".txt"}).
+		Sequential(tesei.Sequence[TextFile]{Width: 3, Counter: &tesei.SequenceCounter{}}).
+		Sequential(RenameFile{Template: "page-{{seq}}.html"}).
+		Sequential(WriteFile{DryRun: true, Log: true}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+
+	if err != nil {
+		fmt.Println("error:", err)
+	}
+
+	// Output:
+	// write file: ../testdata/page-001.html
+	// write file: ../testdata/page-002.html
+}
+
 func ExampleRenameFile_withHashParralel() {
 	_, err := tesei.NewPipeline[TextFile]().
 		Sequential(ListDir{Path: "../testdata", Ext: ".txt", Limit: 1}).
@@ -134,3 +161,425 @@ func ExampleRenameFile_withHashParralelPipelines() {
 	// write file: ../testdata/a_ivgFrYaM.js
 	// write file: ../testdata/b_ivgFrYaM.js
 }
+
+func ExampleGenerate() {
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Generate{
+			Template: "# {{method}} {{path}}\n\n{{summary}}",
+			Name:     "{{id}}.md",
+			Data: []map[string]any{
+				{"id": "list-users", "method": "GET", "path": "/users", "summary": "List users"},
+				{"id": "create-user", "method": "POST", "path": "/users", "summary": "Create a user"},
+				{"id": "get-user", "method": "GET", "path": "/users/{id}", "summary": "Get a user"},
+			},
+		}).
+		Sequential(PrintContent{}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+
+	if err != nil {
+		fmt.Println("error:", err)
+	}
+
+	// Output:
+	// list-users.md
+	// # GET /users
+	//
+	// List users
+	// create-user.md
+	// # POST /users
+	//
+	// Create a user
+	// get-user.md
+	// # GET /users/{id}
+	//
+	// Get a user
+}
+
+func TestWriteFileOnCollision(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  CollisionPolicy
+		files   map[string]string
+		written int
+		errors  int
+	}{
+		{"overwrite", CollisionOverwrite, map[string]string{"a.txt": "second"}, 2, 0},
+		{"error", CollisionError, map[string]string{"a.txt": "first"}, 1, 1},
+		{"skip", CollisionSkip, map[string]string{"a.txt": "first"}, 2, 0},
+		{"suffix", CollisionSuffix, map[string]string{"a.txt": "first", "a-1.txt": "second"}, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			var results []*tesei.Message[TextFile]
+			_, err := tesei.NewPipeline[TextFile]().
+				Sequential(Source{Files: []TextFile{
+					{Name: "a.txt", Content: "first"},
+					{Name: "a.txt", Content: "second"},
+				}}).
+				Sequential(WriteFile{Folder: dir, OnCollision: tt.policy}).
+				Sequential(tesei.Collect[TextFile]{Items: &results}).
+				Sequential(tesei.End[TextFile]{}).
+				Build().
+				Start(context.Background())
+
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			var failed int
+			for _, msg := range results {
+				if msg.Error != nil {
+					failed++
+					if !errors.Is(msg.Error, ErrCollision) {
+						t.Errorf("Expected ErrCollision, got %v", msg.Error)
+					}
+				}
+			}
+			if len(results)-failed != tt.written || failed != tt.errors {
+				t.Errorf("Expected %d passed and %d failed messages, got %d and %d", tt.written, tt.errors, len(results)-failed, failed)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			sort.Strings(names)
+			if len(names) != len(tt.files) {
+				t.Fatalf("Expected files %v, got %v", tt.files, names)
+			}
+			for name, content := range tt.files {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("Expected file %s: %v", name, err)
+				}
+				if string(data) != content {
+					t.Errorf("Expected %s to contain %q, got %q", name, content, data)
+				}
+			}
+		})
+	}
+}
+
+func listNested(t *testing.T, l ListDir) []string {
+	t.Helper()
+
+	var results []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(l).
+		Sequential(tesei.Collect[TextFile]{Items: &results}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Errorf("Pipeline failed: %v", err)
+	}
+
+	var names []string
+	for _, msg := range results {
+		rel, _ := filepath.Rel(l.Path, msg.ID)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	return names
+}
+
+func TestListDirSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, path := range []string{filepath.Join(root, "a.txt"), filepath.Join(root, "sub", "b.txt"), filepath.Join(outside, "c.txt")} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a link back to the root makes a cycle, the other one leaves the tree
+	if err := os.Symlink(root, filepath.Join(root, "sub", "loop")); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "sub", "out")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		follow bool
+		want   string
+	}{
+		{"skip", false, "a.txt sub/b.txt"},
+		{"follow", true, "a.txt sub/b.txt sub/out/c.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan []string)
+			go func() {
+				done <- listNested(t, ListDir{Path: root, Nested: true, FollowSymlinks: tt.follow})
+			}()
+
+			select {
+			case names := <-done:
+				if got := strings.Join(names, " "); got != tt.want {
+					t.Errorf("Expected %q, got %q", tt.want, got)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("ListDir hangs on a symlink loop")
+			}
+		})
+	}
+}
+
+func TestWriteFilePreserve(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	path := filepath.Join(src, "a.txt")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// chmod bypasses umask
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		job    WriteFile
+		target string
+		touch  bool
+	}{
+		{"in place", WriteFile{PreserveMode: true, PreserveTime: true}, path, false},
+		{"new folder", WriteFile{Folder: dst, PreserveMode: true, PreserveTime: true}, filepath.Join(dst, "a.txt"), false},
+		// the source is changed after it was read, the captured values are kept
+		{"source changed", WriteFile{Folder: dst, PreserveMode: true, PreserveTime: true}, filepath.Join(dst, "a.txt"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tesei.NewPipeline[TextFile]().
+				Sequential(ListDir{Path: src}).
+				Sequential(ReadFile{}).
+				Sequential(tesei.TransformJob[TextFile]{Transform: func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+					msg.Data.Content += " new"
+					// user metadata doesn't collide with the captured file info
+					msg.Metadata["mode"] = "draft"
+					if tt.touch {
+						if err := os.Chmod(path, 0644); err != nil {
+							return msg, err
+						}
+						if err := os.Chtimes(path, time.Now(), time.Now()); err != nil {
+							return msg, err
+						}
+					}
+					return msg, nil
+				}}).
+				Sequential(tt.job).
+				Sequential(tesei.End[TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			info, err := os.Stat(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+			}
+			if !info.ModTime().Equal(mtime) {
+				t.Errorf("Expected mtime %v, got %v", mtime, info.ModTime())
+			}
+		})
+	}
+}
+
+func TestReadFileMissing(t *testing.T) {
+	folder := t.TempDir()
+	missing := Source{Files: []TextFile{{Folder: folder, Name: "missing.txt"}}}
+
+	var result []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(missing).
+		Sequential(ReadFile{}).
+		Sequential(tesei.Collect[TextFile]{Items: &result}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if len(result) != 0 {
+		t.Fatalf("Expected the unreadable file to be dropped, got %v", result)
+	}
+
+	_, err = tesei.NewPipeline[TextFile]().
+		Sequential(missing).
+		Sequential(ReadFile{PassUnreadable: true}).
+		Sequential(tesei.Collect[TextFile]{Items: &result}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	if len(result) != 1 || !errors.Is(result[0].Error, ErrReadFile) {
+		t.Fatalf("Expected the file with ErrReadFile, got %v", result)
+	}
+	if result[0].Data.Name != "missing.txt" {
+		t.Errorf("Expected the original file, got %q", result[0].Data.Name)
+	}
+}
+
+func TestWriteFileMetadataSidecar(t *testing.T) {
+	dst := t.TempDir()
+	setMeta := tesei.TransformJob[TextFile]{Transform: func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		msg.Metadata["title"] = "Hello"
+		msg.Metadata["tags"] = []string{"a", "b"}
+		msg.Metadata["count"] = 3
+		msg.Metadata["callback"] = func() {}
+		return msg, nil
+	}}
+
+	tests := []struct {
+		name    string
+		job     WriteFile
+		sidecar string
+	}{
+		{"default suffix", WriteFile{Folder: dst, WriteMetadataSidecar: true}, "a.md.meta.json"},
+		{"custom suffix", WriteFile{Folder: dst, WriteMetadataSidecar: true, SidecarSuffix: ".json"}, "a.md.json"},
+		{"dry run", WriteFile{Folder: dst, WriteMetadataSidecar: true, SidecarSuffix: ".dry.json", DryRun: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tesei.NewPipeline[TextFile]().
+				Sequential(Source{Files: []TextFile{{Name: "a.md", Content: "text"}}}).
+				Sequential(setMeta).
+				Sequential(tt.job).
+				Sequential(tesei.End[TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			if tt.sidecar == "" {
+				if _, err := os.Stat(filepath.Join(dst, "a.md.dry.json")); !os.IsNotExist(err) {
+					t.Errorf("Expected no sidecar in dry run, got %v", err)
+				}
+				return
+			}
+
+			data, err := os.ReadFile(filepath.Join(dst, tt.sidecar))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var meta map[string]any
+			if err := json.Unmarshal(data, &meta); err != nil {
+				t.Fatalf("Invalid sidecar %s: %v", data, err)
+			}
+			if meta["title"] != "Hello" || meta["count"] != 3.0 || fmt.Sprint(meta["tags"]) != "[a b]" {
+				t.Errorf("Expected the metadata in the sidecar, got %v", meta)
+			}
+			if _, ok := meta["callback"].(string); !ok {
+				t.Errorf("Expected the function to be stringified, got %v", meta["callback"])
+			}
+		})
+	}
+}
+
+func ExamplePrintContent() {
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{
+			{Name: "short.txt", Content: "one\ntwo"},
+			{Name: "long.txt", Content: "1\n2\n3\n4\n5\n6"},
+		}}).
+		Sequential(PrintContent{MaxLines: 3, Header: true, Color: true}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+
+	if err != nil {
+		fmt.Println("error:", err)
+	}
+
+	// Output:
+	// short.txt
+	// ----------------------------------------
+	// one
+	// two
+	// long.txt
+	// ----------------------------------------
+	// 1
+	// 2
+	// 3
+	// ... (3 more lines)
+}
+
+func TestWriteFileContentAddressed(t *testing.T) {
+	var writes []string
+	writeFile = func(name string, data []byte, perm os.FileMode) error {
+		writes = append(writes, name)
+		return os.WriteFile(name, data, perm)
+	}
+	defer func() { writeFile = os.WriteFile }()
+
+	dir := t.TempDir()
+	run := func() []*tesei.Message[TextFile] {
+		var results []*tesei.Message[TextFile]
+		_, err := tesei.NewPipeline[TextFile]().
+			Sequential(Source{Files: []TextFile{
+				{Name: "a.txt", Content: "same"},
+				{Name: "b.txt", Content: "same"},
+				{Name: "c.txt", Content: "other"},
+			}}).
+			Sequential(WriteFile{Folder: dir, ContentAddressed: true}).
+			Sequential(tesei.Collect[TextFile]{Items: &results}).
+			Sequential(tesei.End[TextFile]{}).
+			Build().
+			Start(context.Background())
+		if err != nil {
+			t.Fatalf("Pipeline failed: %v", err)
+		}
+		return results
+	}
+
+	results := run()
+	if len(writes) != 2 {
+		t.Fatalf("Expected 2 writes, got %v", writes)
+	}
+
+	// sha256("same")
+	const hash = "0967115f2813a3541eaef77de9d9d5773f1c0c04314b0bbfe4ff3b3b1c55b5d5"
+	want := filepath.Join(dir, hash[:2], hash[2:])
+	for _, msg := range results[:2] {
+		if msg.Error != nil {
+			t.Fatalf("Unexpected error: %v", msg.Error)
+		}
+		if got := filepath.Join(msg.Data.Folder, msg.Data.Name); got != want {
+			t.Errorf("Expected path %s, got %s", want, got)
+		}
+	}
+	if data, err := os.ReadFile(want); err != nil || string(data) != "same" {
+		t.Errorf("Expected object content, got %q, %v", data, err)
+	}
+
+	// objects from a previous run are not written again
+	writes = nil
+	run()
+	if len(writes) != 0 {
+		t.Errorf("Expected no writes, got %v", writes)
+	}
+}
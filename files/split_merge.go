@@ -2,8 +2,10 @@ package files
 
 import (
 	"fmt"
+	"regexp"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/mkozhukh/tesei"
 )
@@ -25,22 +27,106 @@ func (s Split) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out ch
 			continue
 		}
 
-		chunks := s.By(msg.Data.Content)
-		total := len(chunks)
+		if !sendChunks(ctx, msg, s.By(msg.Data.Content), out) {
+			return
+		}
+	}
+}
+
+// sendChunks emits a message for each chunk, with metadata for merging them back.
+func sendChunks(ctx *tesei.Thread, msg *tesei.Message[TextFile], chunks []string, out chan<- *tesei.Message[TextFile]) bool {
+	total := len(chunks)
+
+	for i, chunk := range chunks {
+		// Create a new message for each chunk, with a readable ID
+		newMsg := msg.Child()
+		newMsg.ID = fmt.Sprintf("%s_%d", msg.ID, i)
+		newMsg.Data.Content = chunk
+
+		// Set metadata for merging
+		newMsg.Metadata["split_id"] = msg.ID
+		newMsg.Metadata["split_index"] = i
+		newMsg.Metadata["split_total"] = total
+		// Child replaces the lineage of the file, Merge restores it
+		if parent, ok := msg.Metadata["parent_id"]; ok {
+			newMsg.Metadata["split_parent_id"] = parent
+		}
+
+		select {
+		case out <- newMsg:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	// the chunks don't hold the slot of the split message
+	ctx.Release(msg.ID)
+	return true
+}
+
+// SplitBySize returns a splitter for Split that cuts the text into chunks of at most size bytes.
+// Chunks end at UTF-8 character boundaries, so multi-byte characters are never cut.
+func SplitBySize(size int) func(text string) []string {
+	return func(text string) []string {
+		var chunks []string
+		for len(text) > size {
+			end := size
+			for end > 0 && !utf8.RuneStart(text[end]) {
+				end--
+			}
+			if end == 0 {
+				// size is smaller than the character, keep it whole
+				_, end = utf8.DecodeRuneInString(text)
+			}
+			chunks = append(chunks, text[:end])
+			text = text[end:]
+		}
+		return append(chunks, text)
+	}
+}
+
+// ExceedPolicy defines how MaxLength handles content over the limit.
+type ExceedPolicy int
+
+const (
+	// ExceedSplit splits the content into chunks with split_* metadata, which can be joined back by Merge.
+	ExceedSplit ExceedPolicy = iota
+	// ExceedError marks the message with ErrTooLong.
+	ExceedError
+)
 
-		for i, chunk := range chunks {
-			// Create a new message for each chunk
-			newMsg := msg.Clone()
-			newMsg.ID = fmt.Sprintf("%s_%d", msg.ID, i)
-			newMsg.Data.Content = chunk
+// MaxLength is a job that guarantees that the content doesn't exceed Limit bytes.
+// Shorter content passes through unchanged.
+type MaxLength struct {
+	// Limit is the maximum content length in bytes. Zero or less means no limit.
+	Limit int
+	// OnExceed defines what happens with longer content. Defaults to ExceedSplit.
+	OnExceed ExceedPolicy
+}
+
+func (m MaxLength) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	defer close(out)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
 
-			// Set metadata for merging
-			newMsg.Metadata["split_id"] = msg.ID
-			newMsg.Metadata["split_index"] = i
-			newMsg.Metadata["split_total"] = total
+			if msg.Error == nil && m.Limit > 0 && len(msg.Data.Content) > m.Limit {
+				if m.OnExceed == ExceedSplit {
+					if !sendChunks(ctx, msg, SplitBySize(m.Limit)(msg.Data.Content), out) {
+						return
+					}
+					continue
+				}
+				msg.WithError(fmt.Errorf("%w: %d bytes, limit %d", ErrTooLong, len(msg.Data.Content), m.Limit), "MaxLength")
+			}
 
 			select {
-			case out <- newMsg:
+			case out <- msg:
 			case <-ctx.Done():
 				return
 			}
@@ -48,6 +134,68 @@ func (s Split) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out ch
 	}
 }
 
+// SplitByRegex returns a splitter for Split that cuts the text at every match of pattern.
+// If keepDelimiter is true, the matched text is kept at the start of the following chunk,
+// otherwise it is dropped. Chunks that contain only whitespace are skipped.
+func SplitByRegex(pattern string, keepDelimiter bool) func(text string) []string {
+	re := regexp.MustCompile(pattern)
+
+	return func(text string) []string {
+		var chunks []string
+		add := func(chunk string) {
+			if strings.TrimSpace(chunk) != "" {
+				chunks = append(chunks, chunk)
+			}
+		}
+
+		start := 0
+		for _, match := range re.FindAllStringIndex(text, -1) {
+			add(text[start:match[0]])
+			if keepDelimiter {
+				start = match[0]
+			} else {
+				start = match[1]
+			}
+		}
+		add(text[start:])
+
+		return chunks
+	}
+}
+
+// SplitByHeading returns a splitter for Split that cuts markdown text before every heading
+// of the given level. Each heading stays with its section, text before the first heading
+// becomes a separate chunk. Lines in fenced code blocks, like shell comments, are not headings.
+// Chunks that contain only whitespace are skipped.
+func SplitByHeading(level int) func(text string) []string {
+	if level < 1 {
+		level = 1
+	}
+	heading := regexp.MustCompile(fmt.Sprintf(`^#{%d}[ \t]`, level))
+
+	return func(text string) []string {
+		var chunks []string
+		add := func(chunk string) {
+			if strings.TrimSpace(chunk) != "" {
+				chunks = append(chunks, chunk)
+			}
+		}
+
+		var fences FenceScanner
+		start, pos := 0, 0
+		for _, line := range strings.SplitAfter(text, "\n") {
+			if fences.Next(line) == OutsideFence && heading.MatchString(line) {
+				add(text[start:pos])
+				start = pos
+			}
+			pos += len(line)
+		}
+		add(text[start:])
+
+		return chunks
+	}
+}
+
 // Merge collects chunks and merges them back into a single file.
 type Merge struct {
 	// Glue is the string used to join chunks. Defaults to empty string.
@@ -55,6 +203,11 @@ type Merge struct {
 	// By is an optional custom function to join chunks.
 	// If provided, it overrides Glue.
 	By func(chunks []string) string
+	// Dedup drops chunks whose content is identical to an already merged chunk.
+	Dedup bool
+	// SortBy is an optional less function to reorder chunks before joining.
+	// If not provided, chunks are joined in their original split order.
+	SortBy func(a, b *tesei.Message[TextFile]) bool
 }
 
 // Run executes the merge logic.
@@ -86,17 +239,25 @@ func (m Merge) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out ch
 			chunks := buffer[splitID]
 			delete(buffer, splitID)
 
-			// Sort chunks by index
-			sort.Slice(chunks, func(i, j int) bool {
-				idxI, _ := chunks[i].Metadata["split_index"].(int)
-				idxJ, _ := chunks[j].Metadata["split_index"].(int)
-				return idxI < idxJ
-			})
+			sortChunks(chunks)
+
+			if m.SortBy != nil {
+				sort.SliceStable(chunks, func(i, j int) bool {
+					return m.SortBy(chunks[i], chunks[j])
+				})
+			}
 
 			// Extract content
-			strChunks := make([]string, len(chunks))
-			for i, c := range chunks {
-				strChunks[i] = c.Data.Content
+			strChunks := make([]string, 0, len(chunks))
+			seen := make(map[string]bool)
+			for _, c := range chunks {
+				if m.Dedup {
+					if seen[c.Data.Content] {
+						continue
+					}
+					seen[c.Data.Content] = true
+				}
+				strChunks = append(strChunks, c.Data.Content)
 			}
 
 			// Merge
@@ -107,26 +268,144 @@ func (m Merge) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out ch
 				mergedContent = strings.Join(strChunks, m.Glue)
 			}
 
-			// Create output message using the first chunk as a template
-			// We restore the original ID (which is split_id)
-			outMsg := chunks[0].Clone()
-			outMsg.ID = splitID
-			outMsg.Data.Content = mergedContent
+			select {
+			case out <- mergedMessage(chunks[0], splitID, mergedContent):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// mergedMessage creates the merged file, using the first chunk as a template.
+func mergedMessage(first *tesei.Message[TextFile], splitID, content string) *tesei.Message[TextFile] {
+	// We restore the original ID (which is split_id)
+	msg := first.Clone()
+	msg.ID = splitID
+	msg.Data.Content = content
+
+	// Clean up split metadata, the parent of the split file is restored
+	delete(msg.Metadata, "parent_id")
+	if parent, ok := msg.Metadata["split_parent_id"]; ok {
+		msg.Metadata["parent_id"] = parent
+		delete(msg.Metadata, "split_parent_id")
+	}
+	delete(msg.Metadata, "split_id")
+	delete(msg.Metadata, "split_index")
+	delete(msg.Metadata, "split_total")
+	return msg
+}
+
+// Coalesce merges adjacent small chunks of a split file, keeping them as split chunks.
+// Within a split_id group, chunks are joined in order until the merged chunk reaches Target,
+// and never beyond Max. The merged chunks are re-indexed, so Merge can join them later.
+type Coalesce struct {
+	// Target is the size a merged chunk grows to.
+	Target int
+	// Max is the size a merged chunk must not exceed. Defaults to Target.
+	// Chunks larger than Max on their own are passed as they are.
+	Max int
+	// Glue is the string used to join chunks, it counts towards the size.
+	Glue string
+	// Size measures a chunk, e.g. in tokens. Defaults to the length in bytes.
+	Size func(text string) int
+}
 
-			// Clean up split metadata
-			delete(outMsg.Metadata, "split_id")
-			delete(outMsg.Metadata, "split_index")
-			delete(outMsg.Metadata, "split_total")
+// Run executes the coalesce logic.
+func (c Coalesce) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	defer close(out)
+
+	buffer := make(map[string][]*tesei.Message[TextFile])
+	var order []string
 
+	send := func(chunks []*tesei.Message[TextFile]) bool {
+		for _, msg := range chunks {
 			select {
-			case out <- outMsg:
+			case out <- msg:
 			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+
+	for msg := range in {
+		splitID, ok := msg.Metadata["split_id"].(string)
+		if msg.Error != nil || !ok {
+			if !send([]*tesei.Message[TextFile]{msg}) {
+				return
+			}
+			continue
+		}
+
+		if _, ok := buffer[splitID]; !ok {
+			order = append(order, splitID)
+		}
+		buffer[splitID] = append(buffer[splitID], msg)
+
+		splitTotal, _ := msg.Metadata["split_total"].(int)
+		if len(buffer[splitID]) == splitTotal {
+			chunks := buffer[splitID]
+			delete(buffer, splitID)
+			if !send(c.coalesce(splitID, sortChunks(chunks))) {
+				return
+			}
+		}
+	}
+
+	// incomplete groups are passed on as they are
+	for _, splitID := range order {
+		if chunks, ok := buffer[splitID]; ok {
+			if !send(sortChunks(chunks)) {
 				return
 			}
 		}
 	}
 }
 
+// coalesce joins the ordered chunks of a group and re-indexes them.
+func (c Coalesce) coalesce(splitID string, chunks []*tesei.Message[TextFile]) []*tesei.Message[TextFile] {
+	size := c.Size
+	if size == nil {
+		size = func(text string) int { return len(text) }
+	}
+	limit := c.Max
+	if limit <= 0 {
+		limit = c.Target
+	}
+
+	var result []*tesei.Message[TextFile]
+	var current *tesei.Message[TextFile]
+	for _, chunk := range chunks {
+		if current != nil && size(current.Data.Content) < c.Target {
+			joined := current.Data.Content + c.Glue + chunk.Data.Content
+			if size(joined) <= limit {
+				current.Data.Content = joined
+				continue
+			}
+		}
+		current = chunk
+		result = append(result, current)
+	}
+
+	for i, msg := range result {
+		msg.ID = fmt.Sprintf("%s_%d", splitID, i)
+		msg.Metadata["split_index"] = i
+		msg.Metadata["split_total"] = len(result)
+	}
+	return result
+}
+
+// sortChunks orders the chunks of a split file by their index.
+func sortChunks(chunks []*tesei.Message[TextFile]) []*tesei.Message[TextFile] {
+	sort.Slice(chunks, func(i, j int) bool {
+		idxI, _ := chunks[i].Metadata["split_index"].(int)
+		idxJ, _ := chunks[j].Metadata["split_index"].(int)
+		return idxI < idxJ
+	})
+	return chunks
+}
+
 // Clone generates multiple messages from a single input message using a custom handler.
 // Unlike Split, it does not add metadata for merging.
 type Clone struct {
@@ -2,10 +2,12 @@ package files
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/testutil"
 )
 
 func TestSplitMerge(t *testing.T) {
@@ -62,6 +64,38 @@ func TestSplitMerge(t *testing.T) {
 	if result.ID == "" {
 		t.Error("Expected non-empty ID")
 	}
+	if _, ok := result.Metadata["parent_id"]; ok {
+		t.Errorf("Expected parent_id to be removed by merge, got %v", result.Metadata)
+	}
+}
+
+func TestMergeKeepsLineage(t *testing.T) {
+	var results []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "a.txt", Content: "a,b"}}}).
+		Sequential(tesei.TransformJob[TextFile]{Transform: func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+			return msg.Child(), nil
+		}}).
+		Sequential(Split{By: func(text string) []string { return strings.Split(text, ",") }}).
+		Sequential(Merge{Glue: ","}).
+		Sequential(tesei.Collect[TextFile]{Items: &results}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 merged message, got %d", len(results))
+	}
+	parent, ok := results[0].Metadata["parent_id"].(string)
+	if !ok || parent == results[0].ID {
+		t.Errorf("Expected the parent of the split file, got %v", results[0].Metadata)
+	}
+	if _, ok := results[0].Metadata["split_parent_id"]; ok {
+		t.Errorf("Expected split_parent_id to be removed, got %v", results[0].Metadata)
+	}
 }
 
 func TestSplitMetadata(t *testing.T) {
@@ -98,6 +132,14 @@ func TestSplitMetadata(t *testing.T) {
 	if chunks[1].Metadata["split_index"] != 1 || chunks[1].Metadata["split_total"] != 2 {
 		t.Errorf("Chunk 1 metadata incorrect: %v", chunks[1].Metadata)
 	}
+	if chunks[0].ID == chunks[1].ID {
+		t.Errorf("Expected unique chunk IDs, got %s", chunks[0].ID)
+	}
+	for _, chunk := range chunks {
+		if chunk.Metadata["parent_id"] != chunk.Metadata["split_id"] {
+			t.Errorf("Expected parent_id of the split message, got %v", chunk.Metadata)
+		}
+	}
 }
 
 func TestClone(t *testing.T) {
@@ -146,3 +188,180 @@ func TestClone(t *testing.T) {
 		t.Errorf("Expected clone2.txt, got %s", results[1].Data.Name)
 	}
 }
+
+func TestSplitByHeading(t *testing.T) {
+	input := TextFile{Content: "# Title\nintro\n## First\none\n### Sub\nsub\n## Second\ntwo\n"}
+	chunks := testutil.Run(t, Source{Files: []TextFile{input}}, Split{By: SplitByHeading(2)})
+
+	expected := []string{
+		"# Title\nintro\n",
+		"## First\none\n### Sub\nsub\n",
+		"## Second\ntwo\n",
+	}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d", len(expected), len(chunks))
+	}
+	for i, exp := range expected {
+		if chunks[i].Data.Content != exp {
+			t.Errorf("Chunk %d: expected %q, got %q", i, exp, chunks[i].Data.Content)
+		}
+		if chunks[i].Metadata["split_index"] != i || chunks[i].Metadata["split_total"] != len(expected) {
+			t.Errorf("Chunk %d metadata incorrect: %v", i, chunks[i].Metadata)
+		}
+	}
+}
+
+func TestSplitByHeadingSkipsCode(t *testing.T) {
+	input := TextFile{Content: "## Install\n```sh\n# fetch the sources\ngit clone repo\n```\n## Usage\nrun it\n"}
+	chunks := testutil.Run(t, Source{Files: []TextFile{input}}, Split{By: SplitByHeading(1)})
+	if len(chunks) != 1 {
+		t.Errorf("Expected no level 1 headings, got %d chunks", len(chunks))
+	}
+
+	input = TextFile{Content: "# Setup\n~~~\n# not a heading\n~~~\ntext\n# Next\nmore\n"}
+	chunks = testutil.Run(t, Source{Files: []TextFile{input}}, Split{By: SplitByHeading(1)})
+
+	expected := []string{"# Setup\n~~~\n# not a heading\n~~~\ntext\n", "# Next\nmore\n"}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d", len(expected), len(chunks))
+	}
+	for i, exp := range expected {
+		if chunks[i].Data.Content != exp {
+			t.Errorf("Chunk %d: expected %q, got %q", i, exp, chunks[i].Data.Content)
+		}
+	}
+}
+
+func TestSplitByRegexSeparator(t *testing.T) {
+	input := TextFile{Content: "first\n---\nsecond\n---\nthird"}
+	chunks := testutil.Run(t, Source{Files: []TextFile{input}}, Split{By: SplitByRegex(`(?m)^---\n`, false)})
+
+	expected := []string{"first\n", "second\n", "third"}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d", len(expected), len(chunks))
+	}
+	for i, exp := range expected {
+		if chunks[i].Data.Content != exp {
+			t.Errorf("Chunk %d: expected %q, got %q", i, exp, chunks[i].Data.Content)
+		}
+		if chunks[i].Metadata["split_id"] != chunks[0].Metadata["split_id"] {
+			t.Errorf("Chunk %d has wrong split_id: %v", i, chunks[i].Metadata["split_id"])
+		}
+	}
+}
+
+// commaSplit splits the content at commas, for the Merge tests.
+var commaSplit = Split{By: func(text string) []string { return strings.Split(text, ",") }}
+
+func TestMergeDedup(t *testing.T) {
+	result := testutil.RunOne(t, Source{Files: []TextFile{{Content: "a,b,a,c,b"}}}, commaSplit, Merge{Glue: "|", Dedup: true})
+
+	expected := "a|b|c"
+	if result.Data.Content != expected {
+		t.Errorf("Expected content %q, got %q", expected, result.Data.Content)
+	}
+}
+
+func TestMergeSortBy(t *testing.T) {
+	result := testutil.RunOne(t, Source{Files: []TextFile{{Content: "b,c,a"}}}, commaSplit, Merge{
+		Glue: "|",
+		SortBy: func(a, b *tesei.Message[TextFile]) bool {
+			return a.Data.Content < b.Data.Content
+		},
+	})
+
+	expected := "a|b|c"
+	if result.Data.Content != expected {
+		t.Errorf("Expected content %q, got %q", expected, result.Data.Content)
+	}
+}
+
+func TestMaxLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     MaxLength
+		content string
+		chunks  []string
+		err     error
+	}{
+		{"under limit", MaxLength{Limit: 10}, "short", []string{"short"}, nil},
+		{"split", MaxLength{Limit: 4}, "abcdefghij", []string{"abcd", "efgh", "ij"}, nil},
+		// "é" and "ж" take two bytes and are never cut in half
+		{"split utf-8", MaxLength{Limit: 4}, "abcé жx", []string{"abc", "é ", "жx"}, nil},
+		{"error", MaxLength{Limit: 4, OnExceed: ExceedError}, "abcdefghij", []string{"abcdefghij"}, ErrTooLong},
+		{"no limit", MaxLength{}, "abcdefghij", []string{"abcdefghij"}, nil},
+		{"no limit error", MaxLength{Limit: -1, OnExceed: ExceedError}, "abcdefghij", []string{"abcdefghij"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*tesei.Message[TextFile]
+			_, err := tesei.NewPipeline[TextFile]().
+				Sequential(Source{Files: []TextFile{{Name: "a.txt", Content: tt.content}}}).
+				Sequential(tt.job).
+				Sequential(tesei.Collect[TextFile]{Items: &results}).
+				Sequential(tesei.End[TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			if len(results) != len(tt.chunks) {
+				t.Fatalf("Expected %d messages, got %d", len(tt.chunks), len(results))
+			}
+			for i, msg := range results {
+				if msg.Data.Content != tt.chunks[i] {
+					t.Errorf("Expected chunk %q, got %q", tt.chunks[i], msg.Data.Content)
+				}
+				if !errors.Is(msg.Error, tt.err) {
+					t.Errorf("Expected error %v, got %v", tt.err, msg.Error)
+				}
+				if len(tt.chunks) > 1 && msg.Metadata["split_index"] != i {
+					t.Errorf("Expected split_index %d, got %v", i, msg.Metadata["split_index"])
+				}
+			}
+		})
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	content := "one,two,three,four,five,six,seven,eight,nine,ten,eleven,twelve,a-much-longer-chunk,x"
+	coalesce := Coalesce{Target: 10, Max: 14, Glue: ","}
+
+	var chunks, merged []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "a.txt", Content: content}}}).
+		Sequential(Split{By: func(text string) []string { return strings.Split(text, ",") }}).
+		Sequential(coalesce).
+		Sequential(tesei.Collect[TextFile]{Items: &chunks}).
+		Sequential(Merge{Glue: ","}).
+		Sequential(tesei.Collect[TextFile]{Items: &merged}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	// chunks grow while under 10 bytes, a chunk over the max is kept whole
+	expected := []string{"one,two,three", "four,five,six", "seven,eight", "nine,ten", "eleven,twelve", "a-much-longer-chunk", "x"}
+	if len(chunks) != len(expected) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(expected))
+	}
+	for i, msg := range chunks {
+		if msg.Data.Content != expected[i] {
+			t.Errorf("chunk %d = %q, want %q", i, msg.Data.Content, expected[i])
+		}
+		if len(msg.Data.Content) > coalesce.Max && !strings.Contains(expected[i], "longer") {
+			t.Errorf("chunk %d exceeds the max: %q", i, msg.Data.Content)
+		}
+		if msg.Metadata["split_index"] != i || msg.Metadata["split_total"] != len(expected) {
+			t.Errorf("chunk %d: split_index %v, split_total %v", i, msg.Metadata["split_index"], msg.Metadata["split_total"])
+		}
+	}
+
+	if len(merged) != 1 || merged[0].Data.Content != content {
+		t.Fatalf("merged = %v, want the original content", merged)
+	}
+}
@@ -0,0 +1,160 @@
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// StdinMode defines how Stdin turns the input into messages.
+type StdinMode int
+
+const (
+	// StdinLines emits a message per line, with the line as content.
+	StdinLines StdinMode = iota
+	// StdinWhole emits a single message with the whole input as content.
+	StdinWhole
+	// StdinPaths emits a message per line, with the line as a file path, ready for ReadFile.
+	StdinPaths
+)
+
+// maxLineSize is the longest line Stdin can read.
+const maxLineSize = 1024 * 1024
+
+// Stdin is a source job that reads messages from stdin, for composing tools with Unix pipes.
+// It stops at EOF, or right away when the pipeline is cancelled, even while waiting for input.
+// Empty lines are skipped in the line modes.
+type Stdin struct {
+	// Mode defines how the input is turned into messages. Defaults to StdinLines.
+	Mode StdinMode
+	// Name is the file name of messages in StdinLines and StdinWhole modes. Defaults to "stdin".
+	// In StdinLines mode, each message gets the name numbered, like "stdin-0001" or "input-0001.txt".
+	Name string
+	// Reader replaces stdin, if set.
+	Reader io.Reader
+}
+
+func (s Stdin) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	defer close(out)
+
+	reader := s.Reader
+	if reader == nil {
+		reader = os.Stdin
+	}
+	name := s.Name
+	if name == "" {
+		name = "stdin"
+	}
+
+	send := func(msg *tesei.Message[TextFile]) bool {
+		select {
+		case out <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// reads block until input comes, so they run in a goroutine and the job returns on cancellation,
+	// leaving the goroutine to exit after the pending read
+	if s.Mode == StdinWhole {
+		done := make(chan stdinRead, 1)
+		go func() {
+			data, err := io.ReadAll(reader)
+			done <- stdinRead{string(data), err}
+		}()
+
+		select {
+		case read := <-done:
+			if read.err != nil {
+				s.fail(ctx, read.err)
+				return
+			}
+			send(tesei.NewMessageWithID(name, &TextFile{Name: name, Content: read.text}))
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	lines := make(chan stdinRead)
+	go scanLines(ctx, reader, lines)
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	n := 0
+	for {
+		var read stdinRead
+		var ok bool
+		select {
+		case read, ok = <-lines:
+		case <-ctx.Done():
+			return
+		}
+		if !ok {
+			return
+		}
+		if read.err != nil {
+			s.fail(ctx, read.err)
+			return
+		}
+
+		line := strings.TrimRight(read.text, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var msg *tesei.Message[TextFile]
+		if s.Mode == StdinPaths {
+			path := strings.TrimSpace(line)
+			msg = tesei.NewMessageWithID(path, &TextFile{Name: filepath.Base(path), Folder: filepath.Dir(path)})
+		} else {
+			n++
+			numbered := fmt.Sprintf("%s-%04d%s", base, n, ext)
+			msg = tesei.NewMessageWithID(numbered, &TextFile{Name: numbered, Content: line})
+		}
+		if !send(msg) {
+			return
+		}
+	}
+}
+
+// stdinRead is a line or the whole input read by Stdin, or the read error.
+type stdinRead struct {
+	text string
+	err  error
+}
+
+// scanLines sends the lines of the reader, and the scanner error if any, until the reader ends or ctx is cancelled.
+func scanLines(ctx *tesei.Thread, reader io.Reader, lines chan<- stdinRead) {
+	defer close(lines)
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		select {
+		case lines <- stdinRead{text: scanner.Text()}:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		select {
+		case lines <- stdinRead{err: err}:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// fail reports a read error as critical.
+func (s Stdin) fail(ctx *tesei.Thread, err error) {
+	select {
+	case ctx.Error() <- fmt.Errorf("%w: %w", ErrReadFile, err):
+	case <-ctx.Done():
+	}
+}
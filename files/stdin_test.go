@@ -0,0 +1,96 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestStdin(t *testing.T) {
+	input := "first line\r\n\nsecond line\n../testdata/a.txt\n"
+
+	tests := []struct {
+		name     string
+		job      Stdin
+		expected []string
+	}{
+		{"lines", Stdin{}, []string{"stdin-0001 first line", "stdin-0002 second line", "stdin-0003 ../testdata/a.txt"}},
+		{"named lines", Stdin{Name: "input.txt"}, []string{"input-0001.txt first line", "input-0002.txt second line", "input-0003.txt ../testdata/a.txt"}},
+		{"whole", Stdin{Mode: StdinWhole, Name: "input.txt"}, []string{"input.txt " + input}},
+		{"paths", Stdin{Mode: StdinPaths}, []string{"first line " + ErrReadFile.Error(), "second line " + ErrReadFile.Error(), "../testdata/a.txt fileA"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := tt.job
+			job.Reader = bytes.NewReader([]byte(input))
+
+			p := tesei.NewPipeline[TextFile]().Sequential(job)
+			if job.Mode == StdinPaths {
+				// lines which are not paths get ErrReadFile from ReadFile
+				p.Sequential(ReadFile{PassUnreadable: true})
+			}
+
+			var results []*tesei.Message[TextFile]
+			_, err := p.
+				Sequential(tesei.Collect[TextFile]{Items: &results}).
+				Sequential(tesei.End[TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			var got []string
+			for _, msg := range results {
+				if errors.Is(msg.Error, ErrReadFile) {
+					got = append(got, msg.ID+" "+ErrReadFile.Error())
+					continue
+				}
+				if job.Mode == StdinLines && msg.ID != msg.Data.Name {
+					t.Errorf("Expected the name %q to match the ID", msg.Data.Name)
+				}
+				got = append(got, msg.ID+" "+msg.Data.Content)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestStdinCancel(t *testing.T) {
+	modes := map[string]StdinMode{"lines": StdinLines, "whole": StdinWhole}
+	for name, mode := range modes {
+		t.Run(name, func(t *testing.T) {
+			// the pipe is never written, so reads block
+			reader, writer := io.Pipe()
+			defer writer.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				tesei.NewPipeline[TextFile]().
+					Sequential(Stdin{Mode: mode, Reader: reader}).
+					Sequential(tesei.End[TextFile]{}).
+					Build().
+					Start(ctx)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Stdin ignores the cancellation while waiting for input")
+			}
+		})
+	}
+}
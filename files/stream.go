@@ -0,0 +1,142 @@
+package files
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// StreamReplace is a job that replaces strings in files line by line, streaming them from disk
+// instead of loading the content, so the memory use is bounded by the line length, not the file size.
+// The file at the message folder and name is rewritten in place, or written to Folder.
+// Replacements are applied to each line separately, patterns spanning several lines are not supported.
+type StreamReplace struct {
+	// Matches is a map of strings to replace. Key is the target, Value is the replacement.
+	// Value can contain template placeholders resolved against message metadata.
+	Matches map[string]string
+	// Folder is the target folder. If empty, the file is replaced in place.
+	Folder string
+	// MaxLine is the size of the line buffer, longer lines are processed in pieces of this size,
+	// missing the matches across the pieces. Defaults to 64KB.
+	MaxLine int
+	// Limiter caps the number of open files. Defaults to the global one set by SetFileLimiter.
+	Limiter *FileLimiter
+}
+
+func (s StreamReplace) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	limiter := LimiterOrDefault(s.Limiter)
+
+	tesei.TransformStage(ctx, in, out, "StreamReplace", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		matches := make(map[string]string, len(s.Matches))
+		for k, v := range s.Matches {
+			matches[k] = ResolveString(v, msg)
+		}
+
+		folder := s.Folder
+		if folder == "" {
+			folder = msg.Data.Folder
+		}
+
+		// both files are open at once, but take a single slot, so a limiter of one doesn't deadlock
+		if err := limiter.Acquire(ctx); err != nil {
+			return msg, err
+		}
+		defer limiter.Release()
+
+		err := s.replaceFile(filepath.Join(msg.Data.Folder, msg.Data.Name), folder, msg.Data.Name, matches)
+		if err != nil {
+			return msg, err
+		}
+
+		msg.Data.Folder = folder
+		return msg, nil
+	})
+}
+
+// replaceFile streams the source into a temporary file next to the target, which is renamed
+// over the target when done, so an in-place rewrite never reads its own output.
+func (s StreamReplace) replaceFile(source, folder, name string, matches map[string]string) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrReadFile, err)
+	}
+	defer src.Close()
+
+	// the name may include subfolders
+	target := filepath.Join(folder, name)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("%w: %w", ErrCreateDir, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*")
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteFile, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	err = replaceLines(src, tmp, matches, s.MaxLine)
+	if cerr := tmp.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("%w: %w", ErrWriteFile, cerr)
+	}
+	if err != nil {
+		return err
+	}
+
+	// temporary files are private, keep the permissions of the source
+	if info, err := src.Stat(); err == nil {
+		if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+			return fmt.Errorf("%w: %w", ErrWriteFile, err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteFile, err)
+	}
+	return nil
+}
+
+// replaceLines copies r to w, applying the replacements to each line, or each maxLine bytes of a longer line.
+func replaceLines(r io.Reader, w io.Writer, matches map[string]string, maxLine int) error {
+	if maxLine <= 0 {
+		maxLine = 64 * 1024
+	}
+
+	reader := bufio.NewReaderSize(r, maxLine)
+	writer := bufio.NewWriter(w)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			line, eol := chunk, []byte(nil)
+			if n := len(line) - 1; line[n] == '\n' {
+				line, eol = line[:n], line[n:]
+			}
+
+			text := string(line)
+			for k, v := range matches {
+				text = strings.ReplaceAll(text, k, v)
+			}
+			if _, werr := writer.WriteString(text); werr != nil {
+				return fmt.Errorf("%w: %w", ErrWriteFile, werr)
+			}
+			if _, werr := writer.Write(eol); werr != nil {
+				return fmt.Errorf("%w: %w", ErrWriteFile, werr)
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return fmt.Errorf("%w: %w", ErrReadFile, err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteFile, err)
+	}
+	return nil
+}
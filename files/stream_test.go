@@ -0,0 +1,192 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestStreamReplace(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&b, "line %d: foo and bar, foo again\n", i)
+	}
+	b.WriteString("no newline at the end foo")
+	content := b.String()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte(content), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	job := StreamReplace{Matches: map[string]string{"foo": "{{word}}", "bar": "baz"}}
+	full := Replace{Matches: job.Matches}
+
+	file := TextFile{Name: "a.txt", Folder: dir, Content: content}
+	var streamed, replaced []*tesei.Message[TextFile]
+	for _, run := range []struct {
+		job    tesei.Job[TextFile]
+		result *[]*tesei.Message[TextFile]
+	}{{job, &streamed}, {full, &replaced}} {
+		_, err := tesei.NewPipeline[TextFile]().
+			Sequential(Source{Files: []TextFile{file}}).
+			Sequential(tesei.SetMetaData[TextFile]{Key: "word", Handler: func(*tesei.Message[TextFile]) any { return "qux" }}).
+			Sequential(run.job).
+			Sequential(tesei.Collect[TextFile]{Items: run.result}).
+			Sequential(tesei.End[TextFile]{}).
+			Build().
+			Start(context.Background())
+		if err != nil {
+			t.Fatalf("pipeline failed: %v", err)
+		}
+	}
+
+	if streamed[0].Error != nil {
+		t.Fatalf("unexpected error: %v", streamed[0].Error)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != replaced[0].Data.Content {
+		t.Errorf("streamed result differs from the full-content one:\n%.200s", data)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "a.txt"))
+	if err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("permissions not kept: %v, %v", info.Mode(), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
+
+func TestStreamReplaceMissingFile(t *testing.T) {
+	var result []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "missing.txt", Folder: t.TempDir()}}}).
+		Sequential(StreamReplace{Matches: map[string]string{"a": "b"}}).
+		Sequential(tesei.Collect[TextFile]{Items: &result}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if len(result) != 1 || !errors.Is(result[0].Error, ErrReadFile) {
+		t.Errorf("expected ErrReadFile, got %v", result)
+	}
+}
+
+func TestStreamReplaceSubfolder(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "docs", "a.txt"), []byte("foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var result []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{{Name: "docs/a.txt", Folder: src}}}).
+		Sequential(StreamReplace{Folder: dst, Matches: map[string]string{"foo": "bar"}}).
+		Sequential(tesei.Collect[TextFile]{Items: &result}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if len(result) != 1 || result[0].Error != nil {
+		t.Fatalf("unexpected result: %v", result)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "docs", "a.txt"))
+	if err != nil || string(data) != "bar\n" {
+		t.Errorf("expected the replaced file in the subfolder, got %q, %v", data, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dst, "docs")); len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
+
+// lineReader generates n lines on the fly, without holding the whole content.
+type lineReader struct {
+	n    int
+	line []byte
+	rest []byte
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	if len(r.rest) == 0 {
+		if r.n == 0 {
+			return 0, io.EOF
+		}
+		r.n--
+		r.rest = r.line
+	}
+	n := copy(p, r.rest)
+	r.rest = r.rest[n:]
+	return n, nil
+}
+
+// heapWriter discards the output, sampling the heap size on the way.
+type heapWriter struct {
+	written int
+	writes  int
+	peak    uint64
+}
+
+func (w *heapWriter) Write(p []byte) (int, error) {
+	w.written += len(p)
+	w.writes++
+	if w.writes%256 == 0 {
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		w.peak = max(w.peak, stats.HeapAlloc)
+	}
+	return len(p), nil
+}
+
+func TestReplaceLinesBoundedMemory(t *testing.T) {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	base := stats.HeapAlloc
+
+	// 64MB of input, several times more than the allowed heap growth
+	line := []byte(strings.Repeat("foo bar ", 15) + "\n")
+	r := &lineReader{n: 64 << 20 / len(line), line: line}
+	w := &heapWriter{}
+
+	if err := replaceLines(r, w, map[string]string{"foo": "x"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := (64 << 20 / len(line)) * (len(line) - 30); w.written != want {
+		t.Errorf("written %d bytes, want %d", w.written, want)
+	}
+	if growth := int64(w.peak) - int64(base); growth > 8<<20 {
+		t.Errorf("heap grew by %d bytes while streaming", growth)
+	}
+}
+
+func TestReplaceLinesLongLine(t *testing.T) {
+	in := strings.Repeat("ab", 100) + "\nab\n"
+	var out strings.Builder
+	if err := replaceLines(strings.NewReader(in), &out, map[string]string{"a": "c"}, 16); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.ReplaceAll(in, "a", "c"); out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,143 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// WriteVectorIndex is a job that collects the embeddings of passing files and writes them to a JSON index file
+// when the input is closed, for loading into a vector store. Embeddings are read from metadata,
+// stored there by an upstream job calling an embedding model.
+// Files without an embedding or with errors are not recorded, entries are sorted by message ID.
+type WriteVectorIndex struct {
+	// Path is the index file.
+	Path string
+	// Key is the metadata key of the embedding, a []float32, []float64 or []any of numbers. Defaults to "embedding".
+	Key string
+	// Metadata lists the metadata keys stored with each vector. Values which can't be serialized are stored as strings.
+	Metadata []string
+}
+
+// VectorIndex is the content of the file written by WriteVectorIndex.
+type VectorIndex struct {
+	// Dimensions is the size of the vectors.
+	Dimensions int           `json:"dimensions"`
+	Items      []VectorEntry `json:"items"`
+}
+
+// VectorEntry is an embedding of a message in a VectorIndex.
+type VectorEntry struct {
+	ID       string                     `json:"id"`
+	Vector   any                        `json:"vector"`
+	Metadata map[string]json.RawMessage `json:"metadata,omitempty"`
+}
+
+func (w WriteVectorIndex) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	defer close(out)
+	key := w.Key
+	if key == "" {
+		key = "embedding"
+	}
+
+	index := VectorIndex{}
+	entries := make(map[string]VectorEntry)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				if err := w.save(index, entries); err != nil {
+					select {
+					case ctx.Error() <- fmt.Errorf("%w: %w", ErrWriteFile, err):
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+
+			if value, ok := msg.Metadata[key]; ok && msg.Error == nil {
+				vector, size := vectorValue(value)
+				switch {
+				case size == 0:
+					msg.WithError(fmt.Errorf("%w: %T", ErrVector, value), "WriteVectorIndex")
+				case index.Dimensions != 0 && size != index.Dimensions:
+					msg.WithError(fmt.Errorf("%w: %d dimensions, expected %d", ErrVector, size, index.Dimensions), "WriteVectorIndex")
+				default:
+					index.Dimensions = size
+					entries[msg.ID] = VectorEntry{ID: msg.ID, Vector: vector, Metadata: w.metadata(msg)}
+				}
+			}
+
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// metadata returns the serialized values of the stored metadata keys.
+func (w WriteVectorIndex) metadata(msg *tesei.Message[TextFile]) map[string]json.RawMessage {
+	if len(w.Metadata) == 0 {
+		return nil
+	}
+	values := make(map[string]any, len(w.Metadata))
+	for _, k := range w.Metadata {
+		if v, ok := msg.Metadata[k]; ok {
+			values[k] = v
+		}
+	}
+	return sidecarValues(values)
+}
+
+func (w WriteVectorIndex) save(index VectorIndex, entries map[string]VectorEntry) error {
+	index.Items = make([]VectorEntry, 0, len(entries))
+	for _, entry := range entries {
+		index.Items = append(index.Items, entry)
+	}
+	sort.Slice(index.Items, func(i, j int) bool { return index.Items[i].ID < index.Items[j].ID })
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(w.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(w.Path, append(data, '\n'), 0644)
+}
+
+// vectorValue returns the vector ready for serialization and its size, 0 if the value is not a vector of numbers.
+func vectorValue(value any) (any, int) {
+	switch v := value.(type) {
+	case []float32:
+		return v, len(v)
+	case []float64:
+		return v, len(v)
+	case []any:
+		vector := make([]float64, len(v))
+		for i, item := range v {
+			switch n := item.(type) {
+			case float64:
+				vector[i] = n
+			case float32:
+				vector[i] = float64(n)
+			case int:
+				vector[i] = float64(n)
+			default:
+				return nil, 0
+			}
+		}
+		return vector, len(vector)
+	}
+	return nil, 0
+}
@@ -0,0 +1,93 @@
+package files
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestWriteVectorIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index", "vectors.json")
+	embeddings := map[string]any{
+		"c.md": []float32{0.5, 0.25},
+		"a.md": []float64{1, 0},
+		"b.md": []any{0.0, 1.0},
+		"d.md": []float32{1, 2, 3},
+	}
+
+	var result []*tesei.Message[TextFile]
+	_, err := tesei.NewPipeline[TextFile]().
+		Sequential(Source{Files: []TextFile{
+			{Name: "c.md", Content: "C"},
+			{Name: "a.md", Content: "A"},
+			{Name: "skipped.md", Content: "no embedding"},
+			{Name: "b.md", Content: "B"},
+			{Name: "d.md", Content: "D"},
+		}}).
+		Sequential(tesei.TransformJob[TextFile]{Transform: func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+			// a mock embedding model
+			if vector, ok := embeddings[msg.Data.Name]; ok {
+				msg.Metadata["embedding"] = vector
+				msg.Metadata["title"] = msg.Data.Content
+			}
+			return msg, nil
+		}}).
+		Sequential(WriteVectorIndex{Path: path, Metadata: []string{"title"}}).
+		Sequential(tesei.Collect[TextFile]{Items: &result}).
+		Sequential(tesei.End[TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if len(result) != 5 {
+		t.Fatalf("Expected all 5 files to pass, got %d", len(result))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index struct {
+		Dimensions int `json:"dimensions"`
+		Items      []struct {
+			ID       string            `json:"id"`
+			Vector   []float64         `json:"vector"`
+			Metadata map[string]string `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatalf("Invalid index %s: %v", data, err)
+	}
+
+	if index.Dimensions != 2 {
+		t.Errorf("Expected 2 dimensions, got %d", index.Dimensions)
+	}
+	var ids []string
+	for _, item := range index.Items {
+		ids = append(ids, item.ID)
+		if expected := strings.ToUpper(strings.TrimSuffix(item.ID, ".md")); item.Metadata["title"] != expected {
+			t.Errorf("Expected title %q for %s, got %q", expected, item.ID, item.Metadata["title"])
+		}
+	}
+	if !reflect.DeepEqual(ids, []string{"a.md", "b.md", "c.md"}) {
+		t.Errorf("Expected entries a.md, b.md, c.md, got %v", ids)
+	}
+	if len(index.Items) == 3 && !reflect.DeepEqual(index.Items[2].Vector, []float64{0.5, 0.25}) {
+		t.Errorf("Expected the vector of c.md, got %v", index.Items[2].Vector)
+	}
+
+	// the vector with other dimensions is marked
+	for _, msg := range result {
+		if (msg.Data.Name == "d.md") != errors.Is(msg.Error, ErrVector) {
+			t.Errorf("%s: unexpected error %v", msg.Data.Name, msg.Error)
+		}
+	}
+}
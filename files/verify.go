@@ -0,0 +1,145 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// VerifyGo is a job that checks that the content is Go code which compiles, before it is written.
+// By default the file is parsed and type-checked in process, imports are resolved from the Go sources
+// of the standard library. With Build, the file is built and vetted by the go tool instead.
+// Failures mark the message with ErrCompile and store the compiler messages in "compile_errors" metadata.
+type VerifyGo struct {
+	// Build runs "go build" and "go vet" on the file. It needs the go tool.
+	Build bool
+	// Dir is a module directory the file is built in, so it can import the module packages and dependencies.
+	// The file is placed into a temporary package inside it. Defaults to a standalone temporary module.
+	Dir string
+}
+
+func (v VerifyGo) Run(ctx *tesei.Thread, in <-chan *tesei.Message[TextFile], out chan<- *tesei.Message[TextFile]) {
+	// the importer keeps the imported packages, so they are type-checked once per run
+	fset := token.NewFileSet()
+	imports := importer.ForCompiler(fset, "source", nil)
+
+	tesei.TransformStage(ctx, in, out, "VerifyGo", func(msg *tesei.Message[TextFile]) (*tesei.Message[TextFile], error) {
+		name := msg.Data.Name
+		if name == "" {
+			name = "main.go"
+		}
+
+		var problems []string
+		var err error
+		if v.Build {
+			problems, err = v.build(ctx, name, msg.Data.Content)
+		} else {
+			problems = typeCheck(fset, imports, name, msg.Data.Content)
+		}
+		if err != nil {
+			return msg, fmt.Errorf("%w: %w", ErrCompile, err)
+		}
+
+		if len(problems) > 0 {
+			msg.Metadata["compile_errors"] = problems
+			return msg, fmt.Errorf("%w: %s", ErrCompile, problems[0])
+		}
+		return msg, nil
+	})
+}
+
+func typeCheck(fset *token.FileSet, imports types.Importer, name, content string) []string {
+	file, err := parser.ParseFile(fset, name, content, parser.AllErrors)
+	if err != nil {
+		var list scanner.ErrorList
+		if errors.As(err, &list) {
+			// one error per line, the rest are usually caused by the first one
+			list.RemoveMultiples()
+			problems := make([]string, len(list))
+			for i, e := range list {
+				problems[i] = e.Error()
+			}
+			return problems
+		}
+		return []string{err.Error()}
+	}
+
+	var errs []types.Error
+	conf := types.Config{
+		Importer: imports,
+		Error: func(err error) {
+			errs = append(errs, err.(types.Error))
+		},
+	}
+	conf.Check(file.Name.Name, fset, []*ast.File{file}, nil)
+
+	// unused variables and imports are reported last, order the errors by position
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Pos < errs[j].Pos })
+	problems := make([]string, len(errs))
+	for i, e := range errs {
+		problems[i] = e.Error()
+	}
+	return problems
+}
+
+// build writes the file to a temporary package and runs the go tool on it.
+// Compiler messages are returned as problems, an error means the check couldn't run.
+func (v VerifyGo) build(ctx *tesei.Thread, name, content string) ([]string, error) {
+	dir, err := os.MkdirTemp(v.Dir, ".verify-go-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if v.Dir == "" {
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module verify\n\ngo 1.22\n"), 0644); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, filepath.Base(name)), []byte(content), 0644); err != nil {
+		return nil, err
+	}
+
+	for _, args := range [][]string{{"build", "-o", os.DevNull, "."}, {"vet", "."}} {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "go", args...)
+		cmd.Dir = dir
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			var exit *exec.ExitError
+			if !errors.As(err, &exit) {
+				return nil, err
+			}
+			return compilerMessages(stderr.String(), dir), nil
+		}
+	}
+	return nil, nil
+}
+
+// compilerMessages returns the lines of the go tool output, without the "# package" headers
+// and with paths relative to the temporary package.
+func compilerMessages(output, dir string) []string {
+	var problems []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.ReplaceAll(line, dir+string(filepath.Separator), "")
+		problems = append(problems, strings.TrimPrefix(line, "./"))
+	}
+	return problems
+}
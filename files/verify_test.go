@@ -0,0 +1,78 @@
+package files
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/testutil"
+)
+
+func TestVerifyGo(t *testing.T) {
+	const valid = "package main\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\nfunc main() {\n\tfmt.Println(strings.ToUpper(\"ok\"))\n}\n"
+	const invalid = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tcount := \"1\" + 1\n\tfmt.Println(cout)\n}\n"
+	const broken = "package main\n\nfunc main() {\n\tfmt.Println(\"unclosed\"\n}\n"
+
+	tests := []struct {
+		name     string
+		content  string
+		problems []string
+	}{
+		{"Valid", valid, nil},
+		{"Type errors", invalid, []string{
+			`main.go:6:2: declared and not used: count`,
+			`main.go:6:11: invalid operation: "1" + 1 (mismatched types untyped string and untyped int)`,
+			`main.go:7:14: undefined: cout`,
+		}},
+		{"Syntax error", broken, []string{
+			"main.go:4:24: missing ',' before newline in argument list",
+			"main.go:5:1: expected operand, found '}'",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := testutil.RunOne(t, mainGo(tt.content), VerifyGo{})
+			if tt.problems == nil {
+				if msg.Error != nil {
+					t.Fatalf("unexpected error: %v", msg.Error)
+				}
+				return
+			}
+			if !errors.Is(msg.Error, ErrCompile) {
+				t.Fatalf("error = %v, want ErrCompile", msg.Error)
+			}
+			if problems := msg.Metadata["compile_errors"]; !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("compile_errors = %q, want %q", problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestVerifyGoBuild(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool is not available")
+	}
+
+	msg := testutil.RunOne(t, mainGo("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"ok\")\n}\n"), VerifyGo{Build: true})
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+
+	// compiles, but vet finds the wrong format verb
+	msg = testutil.RunOne(t, mainGo("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Printf(\"%d\\n\", \"text\")\n}\n"), VerifyGo{Build: true})
+	if !errors.Is(msg.Error, ErrCompile) {
+		t.Fatalf("error = %v, want ErrCompile", msg.Error)
+	}
+	problems, _ := msg.Metadata["compile_errors"].([]string)
+	if len(problems) != 1 || problems[0] != "main.go:6:14: fmt.Printf format %d has arg \"text\" of wrong type string" {
+		t.Errorf("compile_errors = %q", problems)
+	}
+}
+
+// mainGo is a source of one main.go file.
+func mainGo(content string) tesei.Job[TextFile] {
+	return Source{Files: []TextFile{{Name: "main.go", Content: content}}}
+}
@@ -3,6 +3,8 @@ module github.com/mkozhukh/tesei
 go 1.24.5
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/mkozhukh/echo v0.5.0
 	github.com/mkozhukh/echo-templates v0.2.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,103 @@
+package tesei
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// StageError is a critical error reported by a stage of a fail-fast pipeline.
+type StageError struct {
+	// Index is the position of the stage in the pipeline, starting from 0.
+	Index int
+	// Stage describes the stage, like "files.ReadFile" or "parallel".
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %d (%s): %v", e.Index, e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// stageGroup runs stages under a shared context, the first error reported by a stage cancels the others.
+type stageGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	once sync.Once
+	err  error
+}
+
+func newStageGroup(parent context.Context) *stageGroup {
+	ctx, cancel := context.WithCancel(parent)
+	return &stageGroup{ctx: ctx, cancel: cancel}
+}
+
+// thread returns a thread of the group for the stage, derived from the parent thread.
+// Its errors fail the group until the returned finish func is called.
+func (g *stageGroup) thread(parent *Thread, index int, stage string) (*Thread, func()) {
+	thread := parent.derive()
+	thread.Context = g.ctx
+	thread.errorChan = make(chan error, 1)
+	thread.overflow = &errorOverflow{}
+	finished := make(chan struct{})
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case err := <-thread.Error():
+				g.fail(&StageError{Index: index, Stage: stage, Err: err})
+			case <-finished:
+				if err := thread.GetError(); err != nil {
+					g.fail(&StageError{Index: index, Stage: stage, Err: err})
+				}
+				return
+			}
+		}
+	}()
+
+	return thread, func() {
+		close(finished)
+		<-exited
+	}
+}
+
+func (g *stageGroup) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+// wait returns the first stage error, it must be called after all stages are finished.
+func (g *stageGroup) wait() error {
+	g.once.Do(func() {})
+	g.cancel()
+	return g.err
+}
+
+// describeStage returns a short description of the stage for error messages.
+func describeStage[T any](s stage[T]) string {
+	switch st := s.(type) {
+	case *sequentialStage[T]:
+		return fmt.Sprintf("%T", st.job)
+	case *fanOutStage[T]:
+		return fmt.Sprintf("fan-out %T", st.job)
+	case *orderedFanOutStage[T]:
+		return fmt.Sprintf("ordered fan-out %T", st.job)
+	case *autoFanOutStage[T]:
+		return fmt.Sprintf("fan-out %T", st.job)
+	case *parallelStage[T]:
+		return "parallel"
+	case *teeStage[T]:
+		return "tee"
+	default:
+		return fmt.Sprintf("%T", s)
+	}
+}
@@ -9,7 +9,7 @@ type CounterJob[T any] struct {
 }
 
 func (c CounterJob[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
-	Transform(ctx, in, out, func(msg *Message[T]) (*Message[T], error) {
+	TransformStage(ctx, in, out, "CounterJob", func(msg *Message[T]) (*Message[T], error) {
 		atomic.AddInt32(c.Count, 1)
 		return msg, nil
 	})
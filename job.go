@@ -21,6 +21,8 @@ type TransformJob[T any] struct {
 	// Transform is the function that processes the message.
 	// If it returns nil, nil, the message is filtered out (consumed).
 	Transform func(*Message[T]) (*Message[T], error)
+	// Stage is stamped as ErrorStage of the messages failed by Transform, unless Transform has set its own.
+	Stage string
 }
 
 func (t TransformJob[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
@@ -32,13 +34,15 @@ func (t TransformJob[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Mes
 				return
 			}
 			if msg.Error == nil || t.ProcessError {
+				id, before := msg.ID, msg.ErrorStage
 				var err error
 				msg, err = t.Transform(msg)
 				if msg == nil {
+					ctx.Release(id)
 					continue
 				}
 				if err != nil {
-					msg.Error = err
+					fail(msg, err, t.Stage, before)
 				}
 			}
 			select {
@@ -56,6 +60,12 @@ func (t TransformJob[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Mes
 // It handles the boilerplate of reading from input, checking for errors, and writing to output.
 // If the transform function returns nil, nil, the message is filtered out (consumed).
 func Transform[T any](ctx *Thread, in <-chan *Message[T], out chan<- *Message[T], transform func(*Message[T]) (*Message[T], error)) {
+	TransformStage(ctx, in, out, "", transform)
+}
+
+// TransformStage is Transform which stores a returned error with stage as ErrorStage, usually the type name
+// of the job, like "ReadFile". A stage set by the transform function itself is kept.
+func TransformStage[T any](ctx *Thread, in <-chan *Message[T], out chan<- *Message[T], stage string, transform func(*Message[T]) (*Message[T], error)) {
 	defer close(out)
 	for {
 		select {
@@ -64,13 +74,15 @@ func Transform[T any](ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]
 				return
 			}
 			if msg.Error == nil {
+				id, before := msg.ID, msg.ErrorStage
 				var err error
 				msg, err = transform(msg)
 				if msg == nil {
+					ctx.Release(id)
 					continue
 				}
 				if err != nil {
-					msg.Error = err
+					fail(msg, err, stage, before)
 				}
 			}
 			select {
@@ -84,6 +96,16 @@ func Transform[T any](ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]
 	}
 }
 
+// fail stores the error returned by a transform. The stage is stamped unless it is empty
+// or the transform has changed ErrorStage from the one the message had before.
+func fail[T any](msg *Message[T], err error, stage, before string) {
+	if stage == "" || msg.ErrorStage != before {
+		msg.Error = err
+		return
+	}
+	msg.WithError(err, stage)
+}
+
 // Filter is a helper function to create a filtering job.
 // It only passes messages for which the filter function returns true.
 func Filter[T any](ctx *Thread, in <-chan *Message[T], out chan<- *Message[T], filter func(*Message[T]) bool) {
@@ -97,12 +119,14 @@ func Filter[T any](ctx *Thread, in <-chan *Message[T], out chan<- *Message[T], f
 				return
 			}
 
-			if filter(msg) {
-				select {
-				case out <- msg:
-				case <-ctx.Done():
-					return
-				}
+			if !filter(msg) {
+				ctx.Release(msg.ID)
+				continue
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}
@@ -186,3 +186,74 @@ func TestTransformJobClosesOutput(t *testing.T) {
 		t.Error("Expected output channel to be closed")
 	}
 }
+
+type failingJob struct{}
+
+func (f failingJob) Run(ctx *Thread, in <-chan *Message[string], out chan<- *Message[string]) {
+	TransformStage(ctx, in, out, "failingJob", func(msg *Message[string]) (*Message[string], error) {
+		if msg.Data == "stamped" {
+			return msg.WithError(errors.New("failed"), "custom"), nil
+		}
+		if msg.Data == "stamped and returned" {
+			err := errors.New("failed")
+			return msg.WithError(err, "custom"), err
+		}
+		return msg, errors.New("failed")
+	})
+}
+
+type stagelessJob struct{}
+
+func (f stagelessJob) Run(ctx *Thread, in <-chan *Message[string], out chan<- *Message[string]) {
+	Transform(ctx, in, out, func(msg *Message[string]) (*Message[string], error) {
+		return msg, errors.New("failed")
+	})
+}
+
+func TestTransformErrorStage(t *testing.T) {
+	tests := []struct {
+		name  string
+		job   Job[string]
+		data  string
+		old   string
+		stage string
+	}{
+		{"named by job", failingJob{}, "test", "", "failingJob"},
+		{"set by transform", failingJob{}, "stamped", "", "custom"},
+		{"set by transform and returned", failingJob{}, "stamped and returned", "", "custom"},
+		{"no stage", stagelessJob{}, "test", "", ""},
+		{"transform job stage", TransformJob[string]{Stage: "validate", Transform: func(msg *Message[string]) (*Message[string], error) {
+			return msg, errors.New("failed")
+		}}, "test", "", "validate"},
+		{"earlier stage replaced", TransformJob[string]{Stage: "validate", ProcessError: true, Transform: func(msg *Message[string]) (*Message[string], error) {
+			return msg, errors.New("failed again")
+		}}, "test", "read", "validate"},
+		{"earlier stage replaced by transform", TransformJob[string]{Stage: "validate", ProcessError: true, Transform: func(msg *Message[string]) (*Message[string], error) {
+			err := errors.New("failed again")
+			return msg.WithError(err, "custom"), err
+		}}, "test", "read", "custom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := make(chan *Message[string], 1)
+			out := make(chan *Message[string], 1)
+			msg := NewMessage(tt.data)
+			if tt.old != "" {
+				msg.WithError(errors.New("failed"), tt.old)
+			}
+			in <- msg
+			close(in)
+
+			tt.job.Run(NewThread(context.Background(), 10), in, out)
+
+			result := <-out
+			if result.Error == nil {
+				t.Fatal("Expected error in message")
+			}
+			if result.ErrorStage != tt.stage {
+				t.Errorf("Expected stage %q, got %q", tt.stage, result.ErrorStage)
+			}
+		})
+	}
+}
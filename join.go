@@ -0,0 +1,126 @@
+package tesei
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// ErrJoinConflict is reported by JoinByID when branches set different values for the same metadata key.
+var ErrJoinConflict = errors.New("metadata conflict")
+
+// JoinConflict defines how JoinByID resolves a metadata key set to different values by several messages.
+type JoinConflict int
+
+const (
+	// JoinKeepFirst keeps the value of the message which arrived first.
+	JoinKeepFirst JoinConflict = iota
+	// JoinKeepLast keeps the value of the message which arrived last.
+	JoinKeepLast
+	// JoinFail marks the joined message with ErrJoinConflict.
+	JoinFail
+)
+
+// JoinByID is a job that recombines messages sharing an ID, like the outputs of Parallel branches.
+// Messages are buffered until Count of them have arrived, then one message is emitted with the data
+// of the first one and the union of their metadata. Incomplete groups are emitted when the input is closed.
+// If any of the messages has an error, the joined message gets the first error.
+// The merged parts release their WithMaxInFlight slots, the joined message keeps the slot of the first one.
+type JoinByID[T any] struct {
+	// Count is the number of messages expected for each ID, usually the number of branches.
+	// It must be at least 1, otherwise the run fails with ErrInvalidPipeline, as does Validate.
+	Count int
+	// Conflict defines how different values of the same metadata key are resolved. Defaults to JoinKeepFirst.
+	Conflict JoinConflict
+}
+
+func (j JoinByID[T]) check() error {
+	if j.Count <= 0 {
+		return fmt.Errorf("JoinByID with Count %d, at least 1 is required", j.Count)
+	}
+	return nil
+}
+
+func (j JoinByID[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+
+	if err := j.check(); err != nil {
+		ctx.SetError(fmt.Errorf("%w: %w", ErrInvalidPipeline, err))
+		return
+	}
+
+	groups := make(map[string][]*Message[T])
+	// order holds the IDs of the pending groups, in the order of their first message
+	var order []string
+
+	send := func(id string) bool {
+		parts := groups[id]
+		msg := j.join(parts)
+		for _, part := range parts[1:] {
+			ctx.Release(part.ID)
+		}
+		delete(groups, id)
+		if i := slices.Index(order, id); i >= 0 {
+			order = slices.Delete(order, i, i+1)
+		}
+		select {
+		case out <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				for len(order) > 0 {
+					if !send(order[0]) {
+						return
+					}
+				}
+				return
+			}
+
+			if _, pending := groups[msg.ID]; !pending {
+				order = append(order, msg.ID)
+			}
+			groups[msg.ID] = append(groups[msg.ID], msg)
+			if len(groups[msg.ID]) >= j.Count && !send(msg.ID) {
+				return
+			}
+		}
+	}
+}
+
+func (j JoinByID[T]) join(parts []*Message[T]) *Message[T] {
+	joined := parts[0]
+	for _, part := range parts[1:] {
+		if joined.Error == nil && part.Error != nil {
+			joined.Error = part.Error
+			joined.ErrorStage = part.ErrorStage
+		}
+
+		for k, v := range part.Metadata {
+			current, exists := joined.Metadata[k]
+			if !exists || reflect.DeepEqual(current, v) {
+				joined.Metadata[k] = v
+				continue
+			}
+
+			switch j.Conflict {
+			case JoinKeepLast:
+				joined.Metadata[k] = v
+			case JoinFail:
+				if joined.Error == nil {
+					joined.WithError(fmt.Errorf("%w: key %q", ErrJoinConflict, k), "JoinByID")
+				}
+			}
+		}
+	}
+	return joined
+}
@@ -0,0 +1,158 @@
+package tesei
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestJoinByID(t *testing.T) {
+	var results []*Message[string]
+	_, err := NewPipeline[string]().
+		Sequential(Slice[string]{Items: []string{"a", "bb"}}).
+		Sequential(SetMetaData[string]{Key: "source", Value: "list"}).
+		Parallel(
+			SetMetaData[string]{Key: "words", Handler: func(msg *Message[string]) any { return len(msg.Data) }},
+			SetMetaData[string]{Key: "hash", Handler: func(msg *Message[string]) any { return "h-" + msg.Data }},
+		).
+		Sequential(JoinByID[string]{Count: 2}).
+		Sequential(Collect[string]{Items: &results}).
+		Sequential(End[string]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 joined messages, got %d", len(results))
+	}
+	for _, msg := range results {
+		if msg.Metadata["words"] != len(msg.Data) || msg.Metadata["hash"] != "h-"+msg.Data || msg.Metadata["source"] != "list" {
+			t.Errorf("Expected merged metadata, got %v", msg.Metadata)
+		}
+		if msg.Error != nil {
+			t.Errorf("Expected no error, got %v", msg.Error)
+		}
+	}
+}
+
+func TestJoinByIDConflict(t *testing.T) {
+	tests := []struct {
+		name     string
+		conflict JoinConflict
+		status   string
+		err      error
+	}{
+		{"keep first", JoinKeepFirst, "first", nil},
+		{"keep last", JoinKeepLast, "last", nil},
+		{"fail", JoinFail, "first", ErrJoinConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := NewMessageWithID("id", new(string))
+			first.Metadata["status"] = "first"
+			first.Metadata["same"] = []string{"x"}
+			last := first.Clone()
+			last.Metadata["status"] = "last"
+
+			joined := JoinByID[string]{Count: 2, Conflict: tt.conflict}.join([]*Message[string]{first, last})
+			if joined.Metadata["status"] != tt.status {
+				t.Errorf("Expected status %q, got %v", tt.status, joined.Metadata["status"])
+			}
+			if !errors.Is(joined.Error, tt.err) {
+				t.Errorf("Expected error %v, got %v", tt.err, joined.Error)
+			}
+		})
+	}
+}
+
+func TestJoinByIDIncomplete(t *testing.T) {
+	var results []*Message[string]
+	_, err := NewPipeline[string]().
+		Sequential(Slice[string]{Items: []string{"a", "b"}}).
+		Sequential(JoinByID[string]{Count: 2}).
+		Sequential(Collect[string]{Items: &results}).
+		Sequential(End[string]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	if len(results) != 2 || results[0].Data != "a" || results[1].Data != "b" {
+		t.Errorf("Expected incomplete groups flushed in order, got %v", results)
+	}
+}
+
+func TestJoinByIDReusedID(t *testing.T) {
+	in := make(chan *Message[string], 4)
+	out := make(chan *Message[string], 4)
+	for _, id := range []string{"x", "x", "y", "x"} {
+		in <- NewMessageWithID(id, &id)
+	}
+	close(in)
+
+	JoinByID[string]{Count: 2}.Run(NewThread(context.Background(), 1), in, out)
+
+	var ids []string
+	for msg := range out {
+		ids = append(ids, msg.ID)
+	}
+	// the second "x" group starts after "y", so it is flushed last
+	if len(ids) != 3 || ids[0] != "x" || ids[1] != "y" || ids[2] != "x" {
+		t.Errorf("Expected groups x, y, x, got %v", ids)
+	}
+}
+
+func TestJoinByIDWithMaxInFlight(t *testing.T) {
+	// every ID is emitted twice by the source, so each part holds a slot
+	source := JobFunc[string](func(ctx *Thread, in <-chan *Message[string], out chan<- *Message[string]) {
+		defer close(out)
+		for i := 0; i < 20; i++ {
+			data := fmt.Sprint(i)
+			for range 2 {
+				select {
+				case out <- NewMessageWithID(data, &data):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	})
+
+	var results []*Message[string]
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := NewPipeline[string]().
+		WithMaxInFlight(3).
+		Sequential(source).
+		Sequential(JoinByID[string]{Count: 2}).
+		Sequential(Collect[string]{Items: &results}).
+		Sequential(End[string]{}).
+		Build().
+		Start(ctx)
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if len(results) != 20 {
+		t.Errorf("Expected 20 joined messages, got %d", len(results))
+	}
+}
+
+func TestJoinByIDInvalidCount(t *testing.T) {
+	p := NewPipeline[string]().
+		Sequential(Slice[string]{Items: []string{"a"}}).
+		Sequential(JoinByID[string]{}).
+		Sequential(End[string]{})
+
+	if err := p.Validate(); !errors.Is(err, ErrInvalidPipeline) {
+		t.Errorf("Expected ErrInvalidPipeline from Validate, got %v", err)
+	}
+	if _, err := p.Build().Start(context.Background()); !errors.Is(err, ErrInvalidPipeline) {
+		t.Errorf("Expected ErrInvalidPipeline from Start, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// BatchComplete is a job that sends several files to an LLM in a single numbered prompt,
+// and splits the response back into per-file results.
+// Each file is rendered with the Item template, the model is expected to answer with
+// a "[N]" line before the result for the N-th file.
+type BatchComplete struct {
+	Echo
+	// Prompt is the system prompt, it should ask for the numbered answer format.
+	Prompt string
+	// BatchSize is the maximal number of files per call. Defaults to 10.
+	BatchSize int
+	// Item is the template for one file, {{index}} is the 1-based number of the file in the batch,
+	// {{content}} its content, other {{key}} placeholders are resolved from metadata.
+	// Defaults to "[{{index}}]\n{{content}}".
+	Item string
+	// Glue joins the rendered files. Defaults to an empty line.
+	Glue string
+	// Parse splits the response into count results. Defaults to ParseNumbered.
+	Parse func(response string, count int) ([]string, error)
+	// TargetKey stores the result in metadata under this key, leaving the content untouched.
+	TargetKey string
+}
+
+func (b BatchComplete) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	defer close(out)
+
+	err := b.init(ctx)
+	if err != nil {
+		return
+	}
+
+	size := b.BatchSize
+	if size <= 0 {
+		size = 10
+	}
+
+	send := func(msg *tesei.Message[files.TextFile]) bool {
+		select {
+		case out <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	flush := func(batch []*tesei.Message[files.TextFile]) bool {
+		b.complete(ctx, batch)
+		for _, msg := range batch {
+			if !send(msg) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var batch []*tesei.Message[files.TextFile]
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				if len(batch) > 0 {
+					flush(batch)
+				}
+				return
+			}
+
+			if msg.Error != nil {
+				if !send(msg) {
+					return
+				}
+				continue
+			}
+
+			batch = append(batch, msg)
+			if len(batch) >= size {
+				if !flush(batch) {
+					return
+				}
+				batch = nil
+			}
+		}
+	}
+}
+
+// complete sends the batch in one call and stores the results, or errors, in its messages.
+func (b BatchComplete) complete(ctx *tesei.Thread, batch []*tesei.Message[files.TextFile]) {
+	item := b.Item
+	if item == "" {
+		item = "[{{index}}]\n{{content}}"
+	}
+	glue := b.Glue
+	if glue == "" {
+		glue = "\n\n"
+	}
+	parse := b.Parse
+	if parse == nil {
+		parse = ParseNumbered
+	}
+
+	parts := make([]string, len(batch))
+	for i, msg := range batch {
+		vars := msg.Clone()
+		vars.Metadata["index"] = i + 1
+		vars.Metadata["content"] = msg.Data.Content
+		parts[i] = files.ResolveString(item, vars)
+	}
+
+	response, err := b.call(ctx, echo.QuickMessage(strings.Join(parts, glue)), echo.WithSystemMessage(b.Prompt))
+	var results []string
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrComplete, err)
+	} else {
+		results, err = parse(response.Text, len(batch))
+		if err == nil && len(results) != len(batch) {
+			err = fmt.Errorf("%w: expected %d results, got %d", ErrBatch, len(batch), len(results))
+		}
+	}
+
+	for i, msg := range batch {
+		if err != nil {
+			msg.WithError(err, "BatchComplete")
+			continue
+		}
+		if b.TargetKey != "" {
+			msg.Metadata[b.TargetKey] = results[i]
+		} else {
+			msg.Data.Content = results[i]
+		}
+	}
+}
+
+var numberedPattern = regexp.MustCompile(`(?m)^[ \t]*\[(\d+)\][ \t]*\n?`)
+
+// ParseNumbered splits a response with "[N]" lines before each result into count results.
+// It fails with ErrBatch unless each number from 1 to count is present exactly once.
+func ParseNumbered(response string, count int) ([]string, error) {
+	matches := numberedPattern.FindAllStringSubmatchIndex(response, -1)
+	if len(matches) != count {
+		return nil, fmt.Errorf("%w: expected %d results, got %d", ErrBatch, count, len(matches))
+	}
+
+	results := make([]string, count)
+	found := make([]bool, count)
+	for i, match := range matches {
+		n, _ := strconv.Atoi(response[match[2]:match[3]])
+		if n < 1 || n > count || found[n-1] {
+			return nil, fmt.Errorf("%w: unexpected result number %d", ErrBatch, n)
+		}
+		found[n-1] = true
+
+		end := len(response)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		results[n-1] = strings.TrimSpace(response[match[1]:end])
+	}
+	return results, nil
+}
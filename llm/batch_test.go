@@ -0,0 +1,92 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/llm"
+	"github.com/mkozhukh/tesei/testutil"
+)
+
+// numberingClient answers each "[N]" item of the prompt with its uppercased content.
+type numberingClient struct {
+	calls int
+	skip  int
+}
+
+var itemPattern = regexp.MustCompile(`\[(\d+)\]\n(\S+)`)
+
+func (c *numberingClient) Call(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	c.calls++
+
+	var sb strings.Builder
+	for _, match := range itemPattern.FindAllStringSubmatch(messages[len(messages)-1].Content, -1) {
+		if match[1] == fmt.Sprint(c.skip) {
+			continue
+		}
+		fmt.Fprintf(&sb, "[%s]\n%s\n\n", match[1], strings.ToUpper(match[2]))
+	}
+	return &echo.Response{Text: sb.String()}, nil
+}
+
+func (c *numberingClient) StreamCall(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.StreamResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+// fruits is a source of three short files.
+var fruits = files.Source{Files: []files.TextFile{
+	{Name: "a.txt", Content: "apple"},
+	{Name: "b.txt", Content: "banana"},
+	{Name: "c.txt", Content: "cherry"},
+}}
+
+func TestBatchComplete(t *testing.T) {
+	client := &numberingClient{}
+	results := testutil.Run(t, fruits, llm.BatchComplete{Echo: llm.Echo{Client: client}, BatchSize: 3, TargetKey: "label"})
+
+	if client.calls != 1 {
+		t.Errorf("Expected a single call, got %d", client.calls)
+	}
+	for i, expected := range []string{"APPLE", "BANANA", "CHERRY"} {
+		msg := results[i]
+		if msg.Error != nil || msg.Metadata["label"] != expected {
+			t.Errorf("%s: expected %q, got %v (error %v)", msg.ID, expected, msg.Metadata["label"], msg.Error)
+		}
+	}
+
+	client = &numberingClient{}
+	results = testutil.Run(t, fruits, llm.BatchComplete{Echo: llm.Echo{Client: client}, BatchSize: 2})
+	if client.calls != 2 {
+		t.Errorf("Expected 2 calls for batches of 2, got %d", client.calls)
+	}
+	if results[2].Data.Content != "CHERRY" {
+		t.Errorf("Expected the content to be replaced, got %q", results[2].Data.Content)
+	}
+}
+
+func TestBatchCompleteMismatch(t *testing.T) {
+	results := testutil.Run(t, fruits, llm.BatchComplete{Echo: llm.Echo{Client: &numberingClient{skip: 2}}})
+
+	for _, msg := range results {
+		if !errors.Is(msg.Error, llm.ErrBatch) {
+			t.Errorf("%s: expected ErrBatch, got %v", msg.ID, msg.Error)
+		}
+	}
+}
+
+func TestParseNumbered(t *testing.T) {
+	results, err := llm.ParseNumbered("Sure!\n[2]\nsecond\n[1] first\n", 2)
+	if err != nil || len(results) != 2 || results[0] != "first" || results[1] != "second" {
+		t.Errorf("Unexpected results %q, %v", results, err)
+	}
+
+	if _, err := llm.ParseNumbered("[1]\na\n[1]\nb", 2); !errors.Is(err, llm.ErrBatch) {
+		t.Errorf("Expected ErrBatch for a repeated number, got %v", err)
+	}
+}
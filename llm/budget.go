@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"sync"
+
+	"github.com/mkozhukh/echo"
+)
+
+var budget *Budget
+
+// SetBudget sets the global token budget shared by all LLM jobs.
+func SetBudget(b *Budget) {
+	budget = b
+}
+
+// Budget is a cap of the tokens spent by LLM jobs, shared by all their workers.
+// Once the spent tokens reach MaxTokens, further calls are not made and fail with ErrOverBudget,
+// so the remaining messages pass on with this error. Calls already in flight are still counted,
+// so the spend can exceed the cap by the size of the concurrent calls.
+type Budget struct {
+	// MaxTokens is the cap of input and output tokens.
+	MaxTokens int
+
+	mu    sync.Mutex
+	spent int
+}
+
+// Spent returns the number of tokens spent so far.
+func (b *Budget) Spent() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
+
+// Exceeded reports whether the budget is used up.
+func (b *Budget) Exceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent >= b.MaxTokens
+}
+
+// Spend adds tokens to the spent ones.
+func (b *Budget) Spend(tokens int) {
+	b.mu.Lock()
+	b.spent += tokens
+	b.mu.Unlock()
+}
+
+// usedTokens returns the tokens of a call, as reported in the response metadata,
+// "input_tokens" and "output_tokens" (Anthropic) or "prompt_tokens" and "completion_tokens" (OpenAI).
+// Without them, the tokens are estimated from the length of the messages and of the response.
+func usedTokens(messages []echo.Message, response *echo.Response) int {
+	for _, keys := range [][2]string{{"input_tokens", "output_tokens"}, {"prompt_tokens", "completion_tokens"}} {
+		input, ok1 := toInt(response.Metadata[keys[0]])
+		output, ok2 := toInt(response.Metadata[keys[1]])
+		if ok1 || ok2 {
+			return input + output
+		}
+	}
+
+	tokens := estimateTokens(response.Text)
+	for _, m := range messages {
+		tokens += estimateTokens(m.Content)
+	}
+	return tokens
+}
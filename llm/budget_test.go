@@ -0,0 +1,82 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/llm"
+)
+
+// usageClient reports 100 tokens for each call.
+type usageClient struct {
+	calls atomic.Int32
+}
+
+func (c *usageClient) Call(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	c.calls.Add(1)
+	return &echo.Response{Text: "ok", Metadata: map[string]any{"input_tokens": 40, "output_tokens": 60}}, nil
+}
+
+func (c *usageClient) StreamCall(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.StreamResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		client echo.Client
+		calls  func(echo.Client) int32
+		max    int
+		spent  int
+		done   int
+	}{
+		// 0, 100 and 200 tokens are under the cap, the fourth call would start at 300
+		{"usage metadata", &usageClient{}, func(c echo.Client) int32 { return c.(*usageClient).calls.Load() }, 250, 300, 3},
+		// without usage, "summary of 0123456789" (6 tokens) and the content (3 tokens) are estimated
+		{"estimated", &countingClient{}, func(c echo.Client) int32 { return c.(*countingClient).calls.Load() }, 20, 27, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := &llm.Budget{MaxTokens: tt.max}
+
+			src := make([]files.TextFile, 10)
+			for i := range src {
+				src[i] = files.TextFile{Name: fmt.Sprint(i), Content: "0123456789"}
+			}
+			var results []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: src}).
+				Sequential(llm.CompleteContent{Echo: llm.Echo{Client: tt.client, Budget: budget}}).
+				Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			if calls := tt.calls(tt.client); calls != int32(tt.done) {
+				t.Errorf("Expected %d calls, got %d", tt.done, calls)
+			}
+			if budget.Spent() != tt.spent {
+				t.Errorf("Expected %d tokens spent, got %d", tt.spent, budget.Spent())
+			}
+			for i, msg := range results {
+				over := errors.Is(msg.Error, llm.ErrOverBudget)
+				if i < tt.done && (msg.Error != nil || msg.Data.Content == "0123456789") {
+					t.Errorf("Expected message %d to be completed, got %v", i, msg.Error)
+				}
+				if i >= tt.done && (!over || msg.Data.Content != "0123456789") {
+					t.Errorf("Expected message %d to be skipped over budget, got %v", i, msg.Error)
+				}
+			}
+		})
+	}
+}
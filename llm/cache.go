@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/mkozhukh/echo"
+	templates "github.com/mkozhukh/echo-templates"
+)
+
+// renderCache stores rendered templates keyed by template name and resolved vars.
+type renderCache struct {
+	mu      sync.Mutex
+	entries map[string]renderResult
+}
+
+type renderResult struct {
+	messages []echo.Message
+	meta     map[string]any
+}
+
+func (r *renderCache) render(engine templates.TemplateEngine, name string, vars map[string]any) ([]echo.Message, map[string]any, error) {
+	// vars which can't be serialized can't be compared, so they are rendered every time
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return engine.GenerateWithMetadata(name, vars)
+	}
+	sum := sha256.Sum256(data)
+	key := name + ":" + hex.EncodeToString(sum[:])
+
+	r.mu.Lock()
+	entry, ok := r.entries[key]
+	r.mu.Unlock()
+	if ok {
+		return append([]echo.Message(nil), entry.messages...), entry.meta, nil
+	}
+
+	messages, meta, err := engine.GenerateWithMetadata(name, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.mu.Lock()
+	if r.entries == nil {
+		r.entries = make(map[string]renderResult)
+	}
+	r.entries[key] = renderResult{messages: messages, meta: meta}
+	r.mu.Unlock()
+
+	return append([]echo.Message(nil), messages...), meta, nil
+}
@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkozhukh/echo"
+	templates "github.com/mkozhukh/echo-templates"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// countingEngine counts template renders.
+type countingEngine struct {
+	templates.TemplateEngine
+	renders int
+}
+
+func (e *countingEngine) GenerateWithMetadata(name string, vars map[string]any, opts ...templates.GenerateOptions) ([]echo.Message, map[string]any, error) {
+	e.renders++
+	return e.TemplateEngine.GenerateWithMetadata(name, vars, opts...)
+}
+
+func TestCompleteTemplateCacheRenders(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents []string
+		cache    bool
+		renders  int
+	}{
+		{"identical vars", []string{"same", "same"}, true, 1},
+		{"different vars", []string{"one", "two"}, true, 2},
+		{"disabled", []string{"same", "same"}, false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := templates.New(templates.Config{Source: templates.NewMockSource(map[string]string{
+				"do.md": "@system: X\n@user: {{user_query}}",
+			})})
+			if err != nil {
+				t.Fatal(err)
+			}
+			counting := &countingEngine{TemplateEngine: engine}
+
+			client, err := echo.NewClient("mock/test", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sources []files.TextFile
+			for _, content := range tt.contents {
+				sources = append(sources, files.TextFile{Name: content + ".txt", Content: content})
+			}
+
+			var results []*tesei.Message[files.TextFile]
+			_, err = tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: sources}).
+				Sequential(CompleteTemplate{
+					Echo:         Echo{Client: client, templatesEngine: counting},
+					Template:     "do",
+					CacheRenders: tt.cache,
+				}).
+				Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			if counting.renders != tt.renders {
+				t.Errorf("Expected %d renders, got %d", tt.renders, counting.renders)
+			}
+			for i, msg := range results {
+				want := "[system]: X\n[user]: " + tt.contents[i]
+				if msg.Data.Content != want {
+					t.Errorf("Expected %q, got %q", want, msg.Data.Content)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"fmt"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+)
+
+// DefaultContinuePrompt is the follow-up message used to continue a cut off response.
+const DefaultContinuePrompt = "Continue exactly where you stopped, without repeating anything."
+
+// callContinued makes the call and, with Continuations set, continues a response cut off
+// at the token limit by sending it back with a follow-up message, joining the parts.
+// The joined response has the metadata of the last part, with the token counts summed over all parts.
+// Responses of clients which don't report the stop reason are never continued.
+func (c *Echo) callContinued(ctx *tesei.Thread, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	response, err := c.call(ctx, messages, opts...)
+	if err != nil || c.Continuations <= 0 {
+		return response, err
+	}
+	// without a stop reason a cut off response can't be told apart, so it is returned as is
+	if !truncated(response) {
+		return response, nil
+	}
+
+	prompt := c.ContinuePrompt
+	if prompt == "" {
+		prompt = DefaultContinuePrompt
+	}
+
+	text := response.Text
+	usage := make(map[string]int)
+	addUsage(usage, response.Metadata)
+	chain := messages[:len(messages):len(messages)]
+	for i := 0; i < c.Continuations; i++ {
+		chain = append(chain,
+			echo.Message{Role: echo.Agent, Content: response.Text},
+			echo.Message{Role: echo.User, Content: prompt},
+		)
+		response, err = c.call(ctx, chain, opts...)
+		if err != nil {
+			return nil, err
+		}
+		text += response.Text
+		addUsage(usage, response.Metadata)
+		if !truncated(response) {
+			meta := make(echo.Metadata, len(response.Metadata))
+			for k, v := range response.Metadata {
+				meta[k] = v
+			}
+			for k, v := range usage {
+				meta[k] = v
+			}
+			return &echo.Response{Text: text, Metadata: meta}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: after %d continuations", ErrTruncated, c.Continuations)
+}
+
+// usageKeys are the metadata keys of token counts, summed over the parts of a continued response.
+var usageKeys = []string{"input_tokens", "output_tokens", "prompt_tokens", "completion_tokens", "total_tokens"}
+
+func addUsage(usage map[string]int, meta echo.Metadata) {
+	for _, key := range usageKeys {
+		if n, ok := toInt(meta[key]); ok {
+			usage[key] += n
+		}
+	}
+}
+
+// truncated reports whether the response stopped at the output token limit, as told by "stop_reason"
+// in the metadata. Only the Anthropic client of echo reports it, the OpenAI and Google ones don't.
+// "finish_reason" is checked as well, for custom clients passing on the one of OpenAI.
+func truncated(response *echo.Response) bool {
+	return response.Metadata["stop_reason"] == "max_tokens" || response.Metadata["finish_reason"] == "length"
+}
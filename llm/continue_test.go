@@ -0,0 +1,98 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/llm"
+	"github.com/mkozhukh/tesei/testutil"
+)
+
+// truncatingClient answers with the parts one by one, all but the last one cut off at the token limit.
+type truncatingClient struct {
+	parts []string
+	calls [][]echo.Message
+}
+
+func (c *truncatingClient) Call(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	c.calls = append(c.calls, messages)
+	n := len(c.calls) - 1
+	reason := "max_tokens"
+	if n == len(c.parts)-1 {
+		reason = "end_turn"
+	}
+	return &echo.Response{Text: c.parts[n], Metadata: echo.Metadata{"stop_reason": reason, "input_tokens": 10, "output_tokens": 5}}, nil
+}
+
+func (c *truncatingClient) StreamCall(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.StreamResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestCompleteContentContinuations(t *testing.T) {
+	tests := []struct {
+		name          string
+		continuations int
+		expected      string
+		calls         int
+		err           error
+	}{
+		{name: "Disabled", continuations: 0, expected: "The first part, ", calls: 1},
+		{name: "Continued", continuations: 3, expected: "The first part, the second part, the end.", calls: 3},
+		{name: "Cap reached", continuations: 1, expected: "text", calls: 2, err: llm.ErrTruncated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &truncatingClient{parts: []string{"The first part, ", "the second part, ", "the end."}}
+			budget := &llm.Budget{MaxTokens: 1000}
+
+			msg := testutil.RunOne(t, files.Source{Files: []files.TextFile{{Name: "a.md", Content: "text"}}}, llm.CompleteContent{
+				Echo:   llm.Echo{Client: client, Continuations: tt.continuations, Budget: budget},
+				Prompt: "Write a story",
+			})
+			if !errors.Is(msg.Error, tt.err) {
+				t.Fatalf("error = %v, want %v", msg.Error, tt.err)
+			}
+			if msg.Data.Content != tt.expected {
+				t.Errorf("content = %q, want %q", msg.Data.Content, tt.expected)
+			}
+			if len(client.calls) != tt.calls {
+				t.Fatalf("calls = %d, want %d", len(client.calls), tt.calls)
+			}
+			if spent := budget.Spent(); spent != 15*tt.calls {
+				t.Errorf("spent = %d, want the tokens of all %d calls", spent, tt.calls)
+			}
+
+			// each continuation sends the previous part back with the follow-up message
+			for i, call := range client.calls[1:] {
+				if len(call) != 3+2*i {
+					t.Fatalf("continuation %d: %d messages", i+1, len(call))
+				}
+				agent, user := call[len(call)-2], call[len(call)-1]
+				if agent.Role != echo.Agent || agent.Content != client.parts[i] {
+					t.Errorf("continuation %d: agent message %+v", i+1, agent)
+				}
+				if user.Role != echo.User || user.Content != llm.DefaultContinuePrompt {
+					t.Errorf("continuation %d: user message %+v", i+1, user)
+				}
+			}
+		})
+	}
+}
+
+func TestCompleteContentContinuationsWithoutStopReason(t *testing.T) {
+	// the mock client reports no stop reason, like the OpenAI and Google ones
+	llm.SetModel("mock/test")
+
+	msg := testutil.RunOne(t, files.Source{Files: []files.TextFile{{Name: "a.md", Content: "text"}}},
+		llm.CompleteContent{Echo: llm.Echo{Continuations: 2}, Prompt: "Write a story"})
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+	if msg.Data.Content == "" || msg.Data.Content == "text" {
+		t.Errorf("expected the response of a single call, got %q", msg.Data.Content)
+	}
+}
@@ -0,0 +1,18 @@
+package llm
+
+import "errors"
+
+var (
+	// ErrComplete is reported when the LLM call fails.
+	ErrComplete = errors.New("complete")
+	// ErrTemplate is reported when a prompt template can't be rendered.
+	ErrTemplate = errors.New("render template")
+	// ErrTemplatesPath is reported when neither templates path nor templates source is set.
+	ErrTemplatesPath = errors.New("templates path is not set")
+	// ErrBatch is reported when a batch response can't be split into per-file results.
+	ErrBatch = errors.New("parse batch response")
+	// ErrTruncated is reported when a response is still cut off after all continuations.
+	ErrTruncated = errors.New("response truncated")
+	// ErrOverBudget is reported for calls skipped because the token budget is used up.
+	ErrOverBudget = errors.New("over budget")
+)
@@ -0,0 +1,46 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/llm"
+	"github.com/mkozhukh/tesei/testutil"
+)
+
+var errProvider = errors.New("provider is down")
+
+type failingClient struct{}
+
+func (c failingClient) Call(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	return nil, errProvider
+}
+
+func (c failingClient) StreamCall(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.StreamResponse, error) {
+	return nil, errProvider
+}
+
+func TestErrComplete(t *testing.T) {
+	msg := testutil.RunOne(t, files.Source{Files: []files.TextFile{{Name: "a.txt", Content: "a"}}}, llm.CompleteContent{Echo: llm.Echo{Client: failingClient{}}})
+
+	if !errors.Is(msg.Error, llm.ErrComplete) {
+		t.Errorf("Expected ErrComplete, got %v", msg.Error)
+	}
+	if !errors.Is(msg.Error, errProvider) {
+		t.Errorf("Expected wrapped provider error, got %v", msg.Error)
+	}
+}
+
+func TestErrTemplate(t *testing.T) {
+	msg := testutil.RunOne(t, files.Source{Files: []files.TextFile{{Name: "a.txt", Content: "a"}}}, llm.CompleteTemplateString{
+		Echo:     llm.Echo{Client: failingClient{}},
+		Template: "@user: {{missing}}",
+	})
+
+	if !errors.Is(msg.Error, llm.ErrTemplate) {
+		t.Errorf("Expected ErrTemplate, got %v", msg.Error)
+	}
+}
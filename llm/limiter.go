@@ -0,0 +1,219 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var rateLimiter *RateLimiter
+
+// SetRateLimiter sets the global rate limiter shared by all LLM jobs.
+func SetRateLimiter(l *RateLimiter) {
+	rateLimiter = l
+}
+
+// RateLimiter is a call budget shared by all workers of LLM jobs.
+// It spaces calls by Interval and pauses every worker after a rate-limit error.
+// When responses report the remaining quota of the provider, calls are slowed down
+// as the quota nears zero, see Observe. The echo clients don't report it, so without
+// a client setting the quota metadata the limiter only spaces calls and backs off.
+type RateLimiter struct {
+	// Interval is the minimal time between two calls.
+	Interval time.Duration
+	// Retries is the number of retries after a rate-limit error.
+	Retries int
+	// Backoff is the pause after the first rate-limit error, doubled on each retry.
+	// It is ignored when the error reports its own retry delay. Defaults to 1s.
+	Backoff time.Duration
+	// Headroom is the number of remaining requests below which calls are spread evenly
+	// until the quota resets. Defaults to 10.
+	Headroom int
+
+	mu     sync.Mutex
+	next   time.Time
+	paused time.Time
+	last   time.Time
+	// spread is the interval between calls while the quota is low, until slowUntil
+	spread    time.Duration
+	slowUntil time.Time
+}
+
+// Wait blocks until the next call is allowed by the limiter.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		at := r.next
+		if at.Before(r.paused) {
+			at = r.paused
+		}
+		if at.Before(now) {
+			at = now
+		}
+		interval := r.Interval
+		if at.Before(r.slowUntil) && r.spread > interval {
+			interval = r.spread
+		}
+		r.last = at
+		r.next = at.Add(interval)
+		r.mu.Unlock()
+
+		if err := sleep(ctx, at.Sub(now)); err != nil {
+			return err
+		}
+
+		// a pause could be requested while sleeping, take a new slot after it
+		r.mu.Lock()
+		paused := time.Now().Before(r.paused)
+		r.mu.Unlock()
+		if !paused {
+			return nil
+		}
+	}
+}
+
+// Pause delays all further calls by at least d.
+func (r *RateLimiter) Pause(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	at := time.Now().Add(d)
+	if r.paused.Before(at) {
+		r.paused = at
+	}
+}
+
+// Observe updates the limiter with the quota reported in the metadata of a response.
+// It reads "ratelimit_remaining_requests" and "ratelimit_reset_requests": with less than Headroom
+// requests left, calls are spaced so the rest lasts until the reset, and with none left all calls
+// pause until the reset. "ratelimit_remaining_tokens" and "ratelimit_reset_tokens" pause the calls
+// when no tokens are left. Reset values are durations, seconds or timestamps.
+// None of the echo clients set these keys, they are for clients wrapping a provider
+// which exposes its rate-limit headers. Without them the limiter keeps its fixed Interval.
+func (r *RateLimiter) Observe(meta map[string]any) {
+	headroom := r.Headroom
+	if headroom <= 0 {
+		headroom = 10
+	}
+
+	if remaining, reset, ok := quota(meta, "requests"); ok {
+		switch {
+		case remaining <= 0:
+			r.Pause(reset)
+		case remaining < headroom:
+			r.slowDown(reset/time.Duration(remaining+1), reset)
+		}
+	}
+	if remaining, reset, ok := quota(meta, "tokens"); ok && remaining <= 0 {
+		r.Pause(reset)
+	}
+}
+
+// slowDown spaces the calls by interval for the given period.
+func (r *RateLimiter) slowDown(interval, period time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spread = interval
+	r.slowUntil = time.Now().Add(period)
+	if at := r.last.Add(interval); at.After(r.next) {
+		r.next = at
+	}
+}
+
+// quota returns the remaining amount and the time until the reset of the named budget.
+func quota(meta map[string]any, name string) (int, time.Duration, bool) {
+	remaining, ok := toInt(meta["ratelimit_remaining_"+name])
+	if !ok {
+		return 0, 0, false
+	}
+
+	var reset time.Duration
+	switch v := meta["ratelimit_reset_"+name].(type) {
+	case time.Duration:
+		reset = v
+	case time.Time:
+		reset = time.Until(v)
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			reset = d
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			reset = time.Until(t)
+		} else if n, err := strconv.ParseFloat(v, 64); err == nil {
+			reset = time.Duration(n * float64(time.Second))
+		}
+	default:
+		if n, ok := toFloat(v); ok {
+			reset = time.Duration(n * float64(time.Second))
+		}
+	}
+	return remaining, max(reset, 0), true
+}
+
+func toInt(v any) (int, bool) {
+	if s, ok := v.(string); ok {
+		n, err := strconv.Atoi(s)
+		return n, err == nil
+	}
+	f, ok := toFloat(v)
+	return int(f), ok
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func (r *RateLimiter) backoff(err error, attempt int) time.Duration {
+	var ra interface{ RetryAfter() time.Duration }
+	if errors.As(err, &ra) && ra.RetryAfter() > 0 {
+		return ra.RetryAfter()
+	}
+
+	d := r.Backoff
+	if d <= 0 {
+		d = time.Second
+	}
+	return d << attempt
+}
+
+// IsRateLimit reports whether the error is caused by the provider's rate limit.
+// It recognizes errors exposing a RetryAfter() method and HTTP 429 responses.
+func IsRateLimit(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var ra interface{ RetryAfter() time.Duration }
+	if errors.As(err, &ra) {
+		return true
+	}
+	return strings.Contains(err.Error(), "status code: 429")
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
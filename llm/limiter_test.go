@@ -0,0 +1,226 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/llm"
+)
+
+type rateLimitedClient struct {
+	mu       sync.Mutex
+	calls    []time.Time
+	failures int
+	failedAt time.Time
+}
+
+func (c *rateLimitedClient) Call(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, time.Now())
+	fail := c.failures > 0
+	if fail {
+		c.failures--
+	}
+	c.mu.Unlock()
+
+	if fail {
+		// let other workers reserve their slots before the error is reported
+		time.Sleep(30 * time.Millisecond)
+		c.mu.Lock()
+		c.failedAt = time.Now()
+		c.mu.Unlock()
+		return nil, errors.New("status code: 429, body: rate limit")
+	}
+	return &echo.Response{Text: "ok"}, nil
+}
+
+// StreamCall fails like Call, with the error in the first chunk of the stream.
+func (c *rateLimitedClient) StreamCall(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.StreamResponse, error) {
+	stream := make(chan echo.StreamChunk, 2)
+	defer close(stream)
+
+	response, err := c.Call(ctx, messages, opts...)
+	if err != nil {
+		stream <- echo.StreamChunk{Error: err}
+	} else {
+		stream <- echo.StreamChunk{Data: response.Text}
+	}
+	return &echo.StreamResponse{Stream: stream}, nil
+}
+
+func TestRateLimiterFanOut(t *testing.T) {
+	client := &rateLimitedClient{failures: 1}
+	limiter := &llm.RateLimiter{
+		Interval: 20 * time.Millisecond,
+		Retries:  2,
+		Backoff:  60 * time.Millisecond,
+	}
+
+	var mu sync.Mutex
+	var results []*tesei.Message[files.TextFile]
+
+	p := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{
+			{Name: "a", Content: "a"},
+			{Name: "b", Content: "b"},
+			{Name: "c", Content: "c"},
+			{Name: "d", Content: "d"},
+		}}).
+		FanOut(llm.CompleteContent{Echo: llm.Echo{Client: client, Limiter: limiter}}, 4).
+		Sequential(tesei.TransformJob[files.TextFile]{
+			ProcessError: true,
+			Transform: func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+				mu.Lock()
+				results = append(results, msg)
+				mu.Unlock()
+				return msg, nil
+			},
+		}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build()
+
+	_, err := p.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+	for _, msg := range results {
+		if msg.Error != nil || msg.Data.Content != "ok" {
+			t.Errorf("Expected successful completion for %s, got %q, %v", msg.ID, msg.Data.Content, msg.Error)
+		}
+	}
+
+	if len(client.calls) != 5 {
+		t.Fatalf("Expected 5 calls (one retry), got %d", len(client.calls))
+	}
+
+	// calls are spaced by the shared limiter
+	for i := 1; i < len(client.calls); i++ {
+		gap := client.calls[i].Sub(client.calls[i-1])
+		if gap < 15*time.Millisecond {
+			t.Errorf("Expected calls %d and %d to be spaced by the limiter, got gap %v", i-1, i, gap)
+		}
+	}
+
+	// no call is started during the backoff after the rate-limit error
+	for i, at := range client.calls {
+		if at.After(client.failedAt) && at.Sub(client.failedAt) < 55*time.Millisecond {
+			t.Errorf("Expected call %d to be backed off, started %v after the error", i, at.Sub(client.failedAt))
+		}
+	}
+}
+
+func TestRateLimiterStream(t *testing.T) {
+	client := &rateLimitedClient{failures: 1}
+	limiter := &llm.RateLimiter{Retries: 1, Backoff: 10 * time.Millisecond}
+	writer := &recordingWriter{}
+
+	var results []*tesei.Message[files.TextFile]
+	_, err := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{{Name: "a", Content: "a"}}}).
+		Sequential(llm.StreamToFile{
+			Echo: llm.Echo{Client: client, Limiter: limiter},
+			Open: func(*tesei.Message[files.TextFile]) (io.WriteCloser, error) { return writer, nil },
+		}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("Expected a successful stream, got %v", results)
+	}
+	if len(client.calls) != 2 {
+		t.Errorf("Expected 2 calls (one retry), got %d", len(client.calls))
+	}
+	if strings.Join(writer.writes, "") != "ok" {
+		t.Errorf("Expected only the retried response written, got %q", writer.writes)
+	}
+}
+
+// quotaClient reports a shrinking budget of remaining requests, which resets after 100ms.
+type quotaClient struct {
+	mu        sync.Mutex
+	calls     []time.Time
+	remaining int
+	headers   bool
+}
+
+func (c *quotaClient) Call(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, time.Now())
+	response := &echo.Response{Text: "ok", Metadata: map[string]any{}}
+	if c.headers {
+		response.Metadata["ratelimit_remaining_requests"] = c.remaining
+		response.Metadata["ratelimit_reset_requests"] = "100ms"
+		c.remaining--
+	}
+	return response, nil
+}
+
+func (c *quotaClient) StreamCall(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.StreamResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestRateLimiterObserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers bool
+		minGaps []time.Duration
+	}{
+		// remaining 3 is above the headroom, then calls are spread over the reset time: 100ms/3, 100ms/2,
+		// and with no requests left the next call waits for the reset
+		{"shrinking budget", true, []time.Duration{0, 30 * time.Millisecond, 45 * time.Millisecond, 95 * time.Millisecond}},
+		// without the quota metadata the fixed interval is used
+		{"no headers", false, []time.Duration{0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &quotaClient{remaining: 3, headers: tt.headers}
+			limiter := &llm.RateLimiter{Interval: time.Millisecond, Headroom: 3}
+
+			src := make([]files.TextFile, len(tt.minGaps)+1)
+			for i := range src {
+				src[i] = files.TextFile{Name: string(rune('a' + i)), Content: "x"}
+			}
+			p := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: src}).
+				Sequential(llm.CompleteContent{Echo: llm.Echo{Client: client, Limiter: limiter}}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build()
+			if _, err := p.Start(context.Background()); err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			if len(client.calls) != len(src) {
+				t.Fatalf("Expected %d calls, got %d", len(src), len(client.calls))
+			}
+			for i, want := range tt.minGaps {
+				gap := client.calls[i+1].Sub(client.calls[i])
+				if gap < want {
+					t.Errorf("Expected call %d to wait at least %v, got %v", i+1, want, gap)
+				}
+				if want == 0 && gap > 20*time.Millisecond {
+					t.Errorf("Expected call %d not to be slowed down, got %v", i+1, gap)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package llm
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/mkozhukh/echo"
 	templates "github.com/mkozhukh/echo-templates"
@@ -46,6 +47,26 @@ type Echo struct {
 	APIKey        string
 	TemplatesPath string
 	Client        echo.Client
+	// Limiter is the rate limiter for calls. Defaults to the global one set by SetRateLimiter.
+	Limiter *RateLimiter
+	// Budget caps the tokens spent by calls. Defaults to the global one set by SetBudget.
+	Budget *Budget
+	// SkipUnchanged reuses the previous result for an output file when the call would be the same
+	// (model, call options, prompt and content), instead of calling the model again.
+	SkipUnchanged bool
+	// Store keeps the results for SkipUnchanged. Defaults to the global one set by SetResultStore.
+	Store tesei.MemoStore[string]
+	// OutputPath returns the path of the file the result is eventually written to, which keys the stored results,
+	// e.g. when the file is renamed or written to another folder later. Defaults to the folder and name of the file.
+	OutputPath func(*tesei.Message[files.TextFile]) string
+	// Continuations is the maximum number of follow-up calls made when a response is cut off
+	// at the output token limit, the parts are joined into one result. 0 disables it.
+	// Only clients reporting the stop reason, like the Anthropic one, are supported;
+	// with the others a single call is made and its response is used even if cut off.
+	Continuations int
+	// ContinuePrompt is the follow-up message asking to continue a cut off response.
+	// Defaults to DefaultContinuePrompt.
+	ContinuePrompt string
 
 	templatesEngine templates.TemplateEngine
 }
@@ -68,23 +89,154 @@ func (c *Echo) init(ctx *tesei.Thread) error {
 	var err error
 	c.Client, err = echo.NewClient(m, a)
 	if err != nil {
-		ctx.Error() <- err
+		ctx.SetError(err)
 		return err
 	}
 
 	return nil
 }
 
+func (c *Echo) call(ctx *tesei.Thread, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	b := c.budget()
+	if b == nil {
+		return c.limitedCall(ctx, messages, opts...)
+	}
+	if b.Exceeded() {
+		return nil, ErrOverBudget
+	}
+
+	response, err := c.limitedCall(ctx, messages, opts...)
+	if response != nil {
+		b.Spend(usedTokens(messages, response))
+	}
+	return response, err
+}
+
+func (c *Echo) budget() *Budget {
+	if c.Budget != nil {
+		return c.Budget
+	}
+	return budget
+}
+
+func (c *Echo) limitedCall(ctx *tesei.Thread, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	var response *echo.Response
+	err := c.limited(ctx, func() (echo.Metadata, error) {
+		var err error
+		response, err = c.Client.Call(ctx, messages, opts...)
+		if response == nil {
+			return nil, err
+		}
+		return response.Metadata, err
+	})
+	return response, err
+}
+
+// limited makes the call through the rate limiter: it waits for a slot, observes the quota
+// in the returned metadata and retries after a rate-limit error, up to the Retries of the limiter.
+func (c *Echo) limited(ctx *tesei.Thread, call func() (echo.Metadata, error)) error {
+	limiter := c.Limiter
+	if limiter == nil {
+		limiter = rateLimiter
+	}
+	if limiter == nil {
+		_, err := call()
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		meta, err := call()
+		if meta != nil {
+			limiter.Observe(meta)
+		}
+		if !IsRateLimit(err) || attempt >= limiter.Retries {
+			return err
+		}
+
+		limiter.Pause(limiter.backoff(err, attempt))
+	}
+}
+
+// stream starts a streamed call through the rate limiter, like limitedCall. The first chunk is read
+// before returning, as providers report rate limits in it, and its metadata is observed.
+func (c *Echo) stream(ctx *tesei.Thread, messages []echo.Message, opts ...echo.CallOption) (*echo.StreamResponse, error) {
+	// streamed responses report no usage, their tokens are not counted
+	if b := c.budget(); b != nil && b.Exceeded() {
+		return nil, ErrOverBudget
+	}
+
+	var response *echo.StreamResponse
+	err := c.limited(ctx, func() (echo.Metadata, error) {
+		var err error
+		var meta echo.Metadata
+		response, meta, err = c.startStream(ctx, messages, opts...)
+		return meta, err
+	})
+	return response, err
+}
+
+// startStream makes the streamed call and waits for the first chunk. An error in the first chunk
+// is returned as the call error; otherwise the chunk is put back in front of the stream.
+func (c *Echo) startStream(ctx *tesei.Thread, messages []echo.Message, opts ...echo.CallOption) (*echo.StreamResponse, echo.Metadata, error) {
+	response, err := c.Client.StreamCall(ctx, messages, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var first echo.StreamChunk
+	var ok bool
+	select {
+	case first, ok = <-response.Stream:
+	case <-ctx.Done():
+		go drain(response.Stream)
+		return nil, nil, ctx.Err()
+	}
+	if !ok {
+		return response, nil, nil
+	}
+
+	var meta echo.Metadata
+	if first.Meta != nil {
+		meta = *first.Meta
+	}
+	if first.Error != nil {
+		go drain(response.Stream)
+		return nil, meta, first.Error
+	}
+
+	stream := make(chan echo.StreamChunk)
+	go func() {
+		defer close(stream)
+		stream <- first
+		for chunk := range response.Stream {
+			stream <- chunk
+		}
+	}()
+	return &echo.StreamResponse{Stream: stream}, meta, nil
+}
+
+func drain(stream <-chan echo.StreamChunk) {
+	for range stream {
+	}
+}
+
 func (c *Echo) initTemplatesEngine(ctx *tesei.Thread) error {
+	if c.templatesEngine != nil {
+		return nil
+	}
+
 	path := c.TemplatesPath
 	if path == "" {
 		path = templatesPath
 	}
 
 	if path == "" && templatesSource == nil {
-		err := fmt.Errorf("templates path is not set")
-		ctx.Error() <- err
-		return err
+		ctx.SetError(ErrTemplatesPath)
+		return ErrTemplatesPath
 	}
 
 	source := templatesSource
@@ -93,25 +245,44 @@ func (c *Echo) initTemplatesEngine(ctx *tesei.Thread) error {
 	if source == nil {
 		source, err = templates.NewFileSystemSource(path)
 		if err != nil {
-			ctx.Error() <- err
+			ctx.SetError(err)
 			return err
 		}
 	}
 
 	c.templatesEngine, err = templates.New(templates.Config{Source: source})
 	if err != nil {
-		ctx.Error() <- err
+		ctx.SetError(err)
 		return err
 	}
 
 	return nil
 }
 
+// Mode defines how the LLM response is combined with the original content.
+type Mode int
+
+const (
+	// Replace replaces the content with the response.
+	Replace Mode = iota
+	// Append adds the response after the content.
+	Append
+	// Prepend adds the response before the content.
+	Prepend
+)
+
 // CompleteContent is a job that sends the file content to an LLM and replaces it with the response.
 type CompleteContent struct {
 	Echo
 	// Prompt is the system prompt to use for the completion.
 	Prompt string
+	// Mode defines how the response is combined with the content. Defaults to Replace.
+	Mode Mode
+	// Separator is placed between the content and the response in Append and Prepend modes.
+	// Defaults to an empty line.
+	Separator string
+	// TargetKey stores the response in metadata under this key, leaving the content untouched.
+	TargetKey string
 }
 
 func (c CompleteContent) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
@@ -120,17 +291,38 @@ func (c CompleteContent) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.T
 		return
 	}
 
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
-		response, err := c.Client.Call(ctx, echo.QuickMessage(msg.Data.Content), echo.WithSystemMessage(c.Prompt))
+	tesei.TransformStage(ctx, in, out, "CompleteContent", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		text, err := c.complete(ctx, msg, echo.QuickMessage(msg.Data.Content), echo.WithSystemMessage(c.Prompt))
 		if err != nil {
-			return msg, fmt.Errorf("complete: %w", err)
+			return msg, fmt.Errorf("%w: %w", ErrComplete, err)
 		}
 
-		msg.Data.Content = response.Text
+		c.apply(msg, text)
 		return msg, nil
 	})
 }
 
+func (c CompleteContent) apply(msg *tesei.Message[files.TextFile], text string) {
+	if c.TargetKey != "" {
+		msg.Metadata[c.TargetKey] = text
+		return
+	}
+
+	separator := c.Separator
+	if separator == "" {
+		separator = "\n\n"
+	}
+
+	switch c.Mode {
+	case Append:
+		msg.Data.Content = msg.Data.Content + separator + text
+	case Prepend:
+		msg.Data.Content = text + separator + msg.Data.Content
+	default:
+		msg.Data.Content = text
+	}
+}
+
 // CompleteTemplateString is a job that renders a template string using metadata and sends it to an LLM.
 type CompleteTemplateString struct {
 	Echo
@@ -146,20 +338,20 @@ func (c CompleteTemplateString) Run(ctx *tesei.Thread, in <-chan *tesei.Message[
 		return
 	}
 
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+	tesei.TransformStage(ctx, in, out, "CompleteTemplateString", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
 		vars := extend(msg.Metadata, c.Vars, msg)
 		messages, meta, err := templates.GenerateWithMetadata(c.Template, vars)
 		if err != nil {
-			return msg, fmt.Errorf("complete: %w", err)
+			return msg, fmt.Errorf("%w: %w", ErrTemplate, err)
 		}
 
 		opts := templates.CallOptions(meta)
-		response, err := c.Client.Call(ctx, messages, opts...)
+		text, err := c.complete(ctx, msg, messages, opts...)
 		if err != nil {
-			return msg, fmt.Errorf("complete: %w", err)
+			return msg, fmt.Errorf("%w: %w", ErrComplete, err)
 		}
 
-		msg.Data.Content = response.Text
+		msg.Data.Content = text
 		return msg, nil
 	})
 }
@@ -171,6 +363,9 @@ type CompleteTemplate struct {
 	Vars map[string]any
 	// Template is the name of the template file to render.
 	Template string
+	// CacheRenders reuses the rendered messages when the template is rendered with identical vars,
+	// e.g. when the vars don't depend on the message.
+	CacheRenders bool
 }
 
 func (c CompleteTemplate) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
@@ -184,20 +379,91 @@ func (c CompleteTemplate) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.
 		return
 	}
 
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
-		vars := extend(msg.Metadata, c.Vars, msg)
-		messages, meta, err := c.templatesEngine.GenerateWithMetadata(c.Template, vars)
+	var cache *renderCache
+	if c.CacheRenders {
+		cache = &renderCache{}
+	}
+
+	tesei.TransformStage(ctx, in, out, "CompleteTemplate", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		text, err := c.completeTemplate(ctx, msg, cache, c.Template, extend(msg.Metadata, c.Vars, msg))
 		if err != nil {
-			return msg, fmt.Errorf("complete: %w", err)
+			return msg, err
 		}
 
-		opts := templates.CallOptions(meta)
-		response, err := c.Client.Call(ctx, messages, opts...)
+		msg.Data.Content = text
+		return msg, nil
+	})
+}
+
+// completeTemplate renders the named template and sends it to the LLM, cache is optional.
+func (c *Echo) completeTemplate(ctx *tesei.Thread, msg *tesei.Message[files.TextFile], cache *renderCache, name string, vars map[string]any) (string, error) {
+	var messages []echo.Message
+	var meta map[string]any
+	var err error
+	if cache != nil {
+		messages, meta, err = cache.render(c.templatesEngine, name, vars)
+	} else {
+		messages, meta, err = c.templatesEngine.GenerateWithMetadata(name, vars)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTemplate, err)
+	}
+
+	opts := templates.CallOptions(meta)
+	text, err := c.complete(ctx, msg, messages, opts...)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrComplete, err)
+	}
+	return text, nil
+}
+
+// CompleteByType is a job that selects a template by the file type and sends it to an LLM.
+// The type is the file extension, like ".go", or a metadata value if Key is set.
+type CompleteByType struct {
+	Echo
+	// Vars is a map of variables to pass to the templates.
+	Vars map[string]any
+	// Templates maps a type to the name of the template file.
+	Templates map[string]string
+	// Default is the template for types missing in Templates. If empty, such messages get an ErrTemplate error.
+	Default string
+	// Key is the metadata key holding the type. If empty, the file extension is used.
+	Key string
+}
+
+func (c CompleteByType) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	err := c.init(ctx)
+	if err != nil {
+		return
+	}
+
+	err = c.initTemplatesEngine(ctx)
+	if err != nil {
+		return
+	}
+
+	tesei.TransformStage(ctx, in, out, "CompleteByType", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		var kind string
+		if c.Key != "" {
+			kind = fmt.Sprint(msg.Metadata[c.Key])
+		} else {
+			kind = filepath.Ext(msg.Data.Name)
+		}
+
+		name, ok := c.Templates[kind]
+		if !ok {
+			name = c.Default
+		}
+		if name == "" {
+			return msg, fmt.Errorf("%w: no template for type %q", ErrTemplate, kind)
+		}
+
+		text, err := c.completeTemplate(ctx, msg, nil, name, extend(msg.Metadata, c.Vars, msg))
 		if err != nil {
-			return msg, fmt.Errorf("complete: %w", err)
+			return msg, err
 		}
 
-		msg.Data.Content = response.Text
+		msg.Data.Content = text
 		return msg, nil
 	})
 }
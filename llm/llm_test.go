@@ -3,6 +3,8 @@ package llm_test
 import (
 	"context"
 	"fmt"
+	"testing"
+	"time"
 
 	echotemplates "github.com/mkozhukh/echo-templates"
 	"github.com/mkozhukh/tesei"
@@ -62,6 +64,56 @@ func ExampleCompleteContent_withPrompt() {
 
 }
 
+func ExampleCompleteContent_append() {
+
+	llm.SetModel("mock/test")
+	p := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.ListDir{Path: "../testdata", Ext: ".txt", Limit: 1}).
+		Sequential(files.ReadFile{}).
+		Sequential(llm.CompleteContent{
+			Mode:      llm.Append,
+			Separator: " | ",
+		}).
+		Sequential(files.PrintContent{}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build()
+
+	_, err := p.Start(context.Background())
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// ../testdata/a.txt
+	// fileA | [user]: fileA
+
+}
+
+func ExampleCompleteContent_targetKey() {
+
+	llm.SetModel("mock/test")
+	p := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.ListDir{Path: "../testdata", Ext: ".txt", Limit: 1}).
+		Sequential(files.ReadFile{}).
+		Sequential(llm.CompleteContent{
+			TargetKey: "summary",
+		}).
+		Sequential(tesei.Log[files.TextFile]{Print: func(msg *tesei.Message[files.TextFile], err error) string {
+			return msg.Data.Content + " -> " + msg.Metadata["summary"].(string)
+		}}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build()
+
+	_, err := p.Start(context.Background())
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// fileA -> [user]: fileA
+
+}
+
 func ExampleCompleteTemplateString() {
 
 	llm.SetModel("mock/test")
@@ -166,3 +218,73 @@ func ExampleCompleteTemplate_withVars() {
 	// [system]: X
 	// [user]: fileB 100 123
 }
+
+func ExampleCompleteByType() {
+	source := echotemplates.NewMockSource(map[string]string{
+		"review-go.md": "@system: review go\n@user: {{user_query}}",
+		"review-md.md": "@system: proofread\n@user: {{user_query}}",
+	})
+
+	llm.SetModel("mock/test")
+	llm.SetTemplatesSource(source)
+
+	p := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{
+			{Name: "main.go", Content: "package main"},
+			{Name: "README.md", Content: "# Title"},
+			{Name: "schema.sql", Content: "SELECT 1"},
+		}}).
+		Sequential(llm.CompleteByType{
+			Templates: map[string]string{
+				".go": "review-go",
+			},
+			Default: "review-md",
+		}).
+		Sequential(files.PrintContent{}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build()
+
+	_, err := p.Start(context.Background())
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// main.go
+	// [system]: review go
+	// [user]: package main
+	// README.md
+	// [system]: proofread
+	// [user]: # Title
+	// schema.sql
+	// [system]: proofread
+	// [user]: SELECT 1
+}
+
+func TestFanOutFailingInit(t *testing.T) {
+	// every worker fails to load its templates, none of them may block on reporting it
+	llm.SetTemplatesSource(nil)
+	p := tesei.NewPipeline[files.TextFile]().
+		FanOut(llm.CompleteTemplate{Echo: llm.Echo{Model: "mock/test", TemplatesPath: "../testdata/missing"}, Template: "a"}, 4).
+		Build()
+
+	in := make(chan *tesei.Message[files.TextFile])
+	out := make(chan *tesei.Message[files.TextFile], 1)
+	close(in)
+
+	ctx := tesei.NewThread(context.Background(), 1)
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx, in, out)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("workers blocked on reporting the init error")
+	}
+	if ctx.GetError() == nil {
+		t.Error("expected the init error to be reported")
+	}
+}
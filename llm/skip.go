@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+var resultStore tesei.MemoStore[string] = &tesei.MemoryStore[string]{}
+
+// SetResultStore sets the global store of results used by jobs with SkipUnchanged.
+// Use a tesei.DirStore to skip unchanged files across program runs. Defaults to an in-memory store.
+func SetResultStore(s tesei.MemoStore[string]) {
+	resultStore = s
+}
+
+// complete calls the LLM and returns the response text. With SkipUnchanged, the result is stored
+// by the output path with the hash of the call, and the call is skipped while the hash is the same.
+func (c *Echo) complete(ctx *tesei.Thread, msg *tesei.Message[files.TextFile], messages []echo.Message, opts ...echo.CallOption) (string, error) {
+	if !c.SkipUnchanged {
+		response, err := c.callContinued(ctx, messages, opts...)
+		if err != nil {
+			return "", err
+		}
+		return response.Text, nil
+	}
+
+	store := c.Store
+	if store == nil {
+		store = resultStore
+	}
+
+	// the options override the model of the job, like in the client
+	config := echo.CallConfig{Model: c.Model}
+	if config.Model == "" {
+		config.Model = model
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	var output string
+	if c.OutputPath != nil {
+		output = c.OutputPath(msg)
+	} else {
+		output = filepath.Join(msg.Data.Folder, msg.Data.Name)
+	}
+
+	key := hashOf(output)
+	hash := hashOf(struct {
+		Config         echo.CallConfig
+		Messages       []echo.Message
+		Continuations  int
+		ContinuePrompt string
+	}{config, messages, c.Continuations, c.ContinuePrompt})
+
+	if entry, ok := store.Get(key); ok && entry.Metadata["hash"] == hash {
+		return entry.Data, nil
+	}
+
+	response, err := c.callContinued(ctx, messages, opts...)
+	if err != nil {
+		return "", err
+	}
+	store.Set(key, tesei.MemoEntry[string]{Data: response.Text, Metadata: map[string]any{"hash": hash}})
+	return response.Text, nil
+}
+
+func hashOf(value any) string {
+	data, _ := json.Marshal(value)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,110 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/llm"
+)
+
+type countingClient struct {
+	calls atomic.Int32
+}
+
+func (c *countingClient) Call(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	c.calls.Add(1)
+	return &echo.Response{Text: "summary of " + messages[len(messages)-1].Content}, nil
+}
+
+func (c *countingClient) StreamCall(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.StreamResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	client := &countingClient{}
+	store := &tesei.MemoryStore[string]{}
+
+	run := func(content, prompt string) string {
+		var results []*tesei.Message[files.TextFile]
+		_, err := tesei.NewPipeline[files.TextFile]().
+			Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Folder: "docs", Content: content}}}).
+			Sequential(llm.CompleteContent{
+				Echo:   llm.Echo{Client: client, SkipUnchanged: true, Store: store},
+				Prompt: prompt,
+			}).
+			Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+			Sequential(tesei.End[files.TextFile]{}).
+			Build().
+			Start(context.Background())
+		if err != nil {
+			t.Fatalf("Pipeline failed: %v", err)
+		}
+		return results[0].Data.Content
+	}
+
+	steps := []struct {
+		content string
+		prompt  string
+		calls   int32
+	}{
+		{"text", "Summarize", 1},
+		{"text", "Summarize", 1}, // unchanged, the stored result is used
+		{"new text", "Summarize", 2},
+		{"new text", "Shorten", 3},
+	}
+	for i, step := range steps {
+		result := run(step.content, step.prompt)
+		if result != "summary of "+step.content {
+			t.Errorf("run %d: unexpected result %q", i+1, result)
+		}
+		if n := client.calls.Load(); n != step.calls {
+			t.Errorf("run %d: expected %d calls in total, got %d", i+1, step.calls, n)
+		}
+	}
+}
+
+func TestSkipUnchangedKey(t *testing.T) {
+	client := &countingClient{}
+	store := &tesei.MemoryStore[string]{}
+
+	run := func(template, output string) {
+		_, err := tesei.NewPipeline[files.TextFile]().
+			Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Folder: "docs", Content: "text"}}}).
+			Sequential(llm.CompleteTemplateString{
+				Echo: llm.Echo{Client: client, SkipUnchanged: true, Store: store, OutputPath: func(*tesei.Message[files.TextFile]) string {
+					return output
+				}},
+				Template: template,
+			}).
+			Sequential(tesei.End[files.TextFile]{}).
+			Build().
+			Start(context.Background())
+		if err != nil {
+			t.Fatalf("Pipeline failed: %v", err)
+		}
+	}
+
+	steps := []struct {
+		template string
+		output   string
+		calls    int32
+	}{
+		{"---\ntemperature: 0.2\n---\nSummarize {{user_query}}", "out/a.md", 1},
+		{"---\ntemperature: 0.2\n---\nSummarize {{user_query}}", "out/a.md", 1},
+		{"---\ntemperature: 0.8\n---\nSummarize {{user_query}}", "out/a.md", 2},
+		{"---\nmodel: openai/gpt-4\ntemperature: 0.8\n---\nSummarize {{user_query}}", "out/a.md", 3},
+		{"---\nmodel: openai/gpt-4\ntemperature: 0.8\n---\nSummarize {{user_query}}", "out/b.md", 4},
+		{"---\nmodel: openai/gpt-4\ntemperature: 0.8\n---\nSummarize {{user_query}}", "out/b.md", 4},
+	}
+	for i, step := range steps {
+		run(step.template, step.output)
+		if n := client.calls.Load(); n != step.calls {
+			t.Errorf("run %d: expected %d calls in total, got %d", i+1, step.calls, n)
+		}
+	}
+}
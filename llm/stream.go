@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// StreamToFile is a job that streams the LLM response for the file content directly to the output file.
+// The response is written chunk by chunk and never held in memory as a whole, so it replaces
+// the CompleteContent + WriteFile pair for very long generations.
+// After the write, the message content is empty and the "written" metadata holds the number of written bytes.
+// The default writer streams into a temporary file next to the target, which replaces the target
+// only when the whole response is written, so a failed or cancelled stream leaves no partial file.
+type StreamToFile struct {
+	Echo
+	// Prompt is the system prompt to use for the completion.
+	Prompt string
+	// Folder is the target folder to write to. If empty, the original folder is used.
+	Folder string
+	// Open creates the writer for a message. Defaults to creating the file at Folder/Name.
+	// The writer is closed after the stream, also when it fails.
+	Open func(msg *tesei.Message[files.TextFile]) (io.WriteCloser, error)
+	// Limiter caps the number of open files of the default writer. Defaults to the global one set by files.SetFileLimiter.
+	Limiter *files.FileLimiter
+}
+
+func (s StreamToFile) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	err := s.init(ctx)
+	if err != nil {
+		return
+	}
+	limiter := files.LimiterOrDefault(s.Limiter)
+
+	tesei.TransformStage(ctx, in, out, "StreamToFile", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		if s.Folder != "" {
+			msg.Data.Folder = s.Folder
+		}
+
+		open := s.Open
+		if open == nil {
+			open = func(msg *tesei.Message[files.TextFile]) (io.WriteCloser, error) {
+				return createFile(ctx, limiter, msg)
+			}
+		}
+
+		response, err := s.stream(ctx, echo.QuickMessage(msg.Data.Content), echo.WithSystemMessage(s.Prompt))
+		if err != nil {
+			return msg, fmt.Errorf("%w: %w", ErrComplete, err)
+		}
+
+		w, err := open(msg)
+		if err != nil {
+			return msg, fmt.Errorf("%w: %w", files.ErrWriteFile, err)
+		}
+
+		written, err := writeStream(ctx, response, w)
+		if p, ok := w.(*pendingFile); ok && err != nil {
+			p.discard()
+		} else if cerr := w.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("%w: %w", files.ErrWriteFile, cerr)
+		}
+
+		msg.Data.Content = ""
+		msg.Metadata["written"] = written
+		return msg, err
+	})
+}
+
+func writeStream(ctx *tesei.Thread, response *echo.StreamResponse, w io.Writer) (int, error) {
+	// drain the rest of the stream on early return, so the provider doesn't block
+	defer func() {
+		go drain(response.Stream)
+	}()
+
+	written := 0
+	for {
+		var chunk echo.StreamChunk
+		var ok bool
+		select {
+		case chunk, ok = <-response.Stream:
+		case <-ctx.Done():
+			return written, fmt.Errorf("%w: %w", ErrComplete, ctx.Err())
+		}
+		if !ok {
+			return written, nil
+		}
+
+		if chunk.Error != nil {
+			return written, fmt.Errorf("%w: %w", ErrComplete, chunk.Error)
+		}
+		if chunk.Data == "" {
+			continue
+		}
+
+		n, err := io.WriteString(w, chunk.Data)
+		written += n
+		if err != nil {
+			return written, fmt.Errorf("%w: %w", files.ErrWriteFile, err)
+		}
+	}
+}
+
+// pendingFile is a temporary file which is moved to the target path when it is closed.
+// It holds a slot of the limiter while it is open.
+type pendingFile struct {
+	*os.File
+	target  string
+	limiter *files.FileLimiter
+}
+
+func createFile(ctx context.Context, limiter *files.FileLimiter, msg *tesei.Message[files.TextFile]) (io.WriteCloser, error) {
+	// the name may include subfolders
+	target := filepath.Join(msg.Data.Folder, msg.Data.Name)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, err
+	}
+
+	if err := limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	// created in the target folder, so the rename doesn't cross file systems
+	f, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		limiter.Release()
+		return nil, err
+	}
+	// temporary files are private, the target gets the mode of files written by files.WriteFile
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		limiter.Release()
+		return nil, err
+	}
+	return &pendingFile{File: f, target: target, limiter: limiter}, nil
+}
+
+func (p *pendingFile) Close() error {
+	defer p.limiter.Release()
+	if err := p.File.Close(); err != nil {
+		os.Remove(p.Name())
+		return err
+	}
+	if err := os.Rename(p.Name(), p.target); err != nil {
+		os.Remove(p.Name())
+		return err
+	}
+	return nil
+}
+
+// discard removes the temporary file, leaving the target untouched.
+func (p *pendingFile) discard() {
+	defer p.limiter.Release()
+	p.File.Close()
+	os.Remove(p.Name())
+}
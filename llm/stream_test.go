@@ -0,0 +1,207 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/llm"
+)
+
+type recordingWriter struct {
+	writes []string
+	closed bool
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func (w *recordingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestStreamToFile(t *testing.T) {
+	llm.SetModel("mock/test")
+
+	writer := &recordingWriter{}
+	var results []*tesei.Message[files.TextFile]
+
+	_, err := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: "a long generation request"}}}).
+		Sequential(llm.StreamToFile{
+			Open: func(msg *tesei.Message[files.TextFile]) (io.WriteCloser, error) {
+				return writer, nil
+			},
+		}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(context.Background())
+
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("Expected one successful result, got %v", results)
+	}
+
+	// the mock client streams the response in 10 character chunks
+	expected := "[user]: a long generation request"
+	if len(writer.writes) != 4 {
+		t.Errorf("Expected 4 incremental writes, got %d: %q", len(writer.writes), writer.writes)
+	}
+	if strings.Join(writer.writes, "") != expected {
+		t.Errorf("Expected written content %q, got %q", expected, strings.Join(writer.writes, ""))
+	}
+	if !writer.closed {
+		t.Error("Expected writer to be closed")
+	}
+	if results[0].Data.Content != "" {
+		t.Errorf("Expected content not to be kept in memory, got %q", results[0].Data.Content)
+	}
+	if results[0].Metadata["written"] != len(expected) {
+		t.Errorf("Expected %d written bytes, got %v", len(expected), results[0].Metadata["written"])
+	}
+}
+
+func TestStreamToFileDefaultWriter(t *testing.T) {
+	llm.SetModel("mock/test")
+	dir := t.TempDir()
+
+	_, err := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: "fileA"}}}).
+		Sequential(llm.StreamToFile{Folder: dir}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(context.Background())
+
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "a.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[user]: fileA" {
+		t.Errorf("Expected streamed file content, got %q", string(data))
+	}
+}
+
+func TestStreamToFileNestedName(t *testing.T) {
+	llm.SetModel("mock/test")
+	dir := t.TempDir()
+
+	// with one slot, the second file is written only if the first one gave its slot back
+	var results []*tesei.Message[files.TextFile]
+	_, err := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{
+			{Name: "docs/a.md", Content: "fileA"},
+			{Name: "docs/deep/b.md", Content: "fileB"},
+		}}).
+		Sequential(llm.StreamToFile{Folder: dir, Limiter: &files.FileLimiter{Max: 1}}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(context.Background())
+
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	for _, msg := range results {
+		if msg.Error != nil {
+			t.Fatalf("Expected no errors, got %v", msg.Error)
+		}
+	}
+
+	for name, content := range map[string]string{"docs/a.md": "[user]: fileA", "docs/deep/b.md": "[user]: fileB"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s to contain %q, got %q", name, content, string(data))
+		}
+	}
+}
+
+// brokenStreamClient streams one chunk, then fails or, without an error, waits until the call is cancelled.
+type brokenStreamClient struct {
+	err error
+}
+
+func (c brokenStreamClient) Call(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.Response, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c brokenStreamClient) StreamCall(ctx context.Context, messages []echo.Message, opts ...echo.CallOption) (*echo.StreamResponse, error) {
+	stream := make(chan echo.StreamChunk)
+	go func() {
+		defer close(stream)
+		stream <- echo.StreamChunk{Data: "partial"}
+		if c.err != nil {
+			stream <- echo.StreamChunk{Error: c.err}
+			return
+		}
+		<-ctx.Done()
+	}()
+	return &echo.StreamResponse{Stream: stream}, nil
+}
+
+func TestStreamToFileFailed(t *testing.T) {
+	tests := []struct {
+		name   string
+		client brokenStreamClient
+	}{
+		{"stream error", brokenStreamClient{err: errors.New("connection lost")}},
+		{"cancelled", brokenStreamClient{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			target := filepath.Join(dir, "a.md")
+			if err := os.WriteFile(target, []byte("previous"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			// with fail fast, Start waits for the stages to exit
+			var results []*tesei.Message[files.TextFile]
+			tesei.NewPipeline[files.TextFile]().
+				WithFailFast().
+				Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: "request"}}}).
+				Sequential(llm.StreamToFile{Echo: llm.Echo{Client: tt.client}, Folder: dir}).
+				Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(ctx)
+
+			if tt.client.err != nil && (len(results) != 1 || !errors.Is(results[0].Error, llm.ErrComplete)) {
+				t.Errorf("Expected the message with ErrComplete, got %v", results)
+			}
+
+			data, err := os.ReadFile(target)
+			if err != nil || string(data) != "previous" {
+				t.Errorf("Expected the target untouched, got %q, %v", data, err)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 1 {
+				t.Errorf("Expected no temporary files left, got %v", entries)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// Summarize is a job that produces a single summary of all incoming files.
+// Each file is summarized separately (map), then the summaries are combined
+// into one final message (reduce) emitted when the input is closed.
+type Summarize struct {
+	Echo
+	// MapPrompt is the system prompt used to summarize each file.
+	MapPrompt string
+	// ReducePrompt is the system prompt used to combine the summaries.
+	ReducePrompt string
+	// Glue is the string used to join summaries for the reduce step. Defaults to an empty line.
+	Glue string
+	// Name is the file name of the resulting message.
+	Name string
+	// Folder is the folder of the resulting message.
+	Folder string
+}
+
+func (s Summarize) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	defer close(out)
+
+	err := s.init(ctx)
+	if err != nil {
+		return
+	}
+
+	send := func(msg *tesei.Message[files.TextFile]) bool {
+		select {
+		case out <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var summaries []string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				if len(summaries) > 0 {
+					send(s.reduce(ctx, summaries))
+				}
+				return
+			}
+
+			if msg.Error != nil {
+				if !send(msg) {
+					return
+				}
+				continue
+			}
+
+			response, err := s.call(ctx, echo.QuickMessage(msg.Data.Content), echo.WithSystemMessage(s.MapPrompt))
+			if err != nil {
+				if !send(msg.WithError(fmt.Errorf("%w: %w", ErrComplete, err), "Summarize")) {
+					return
+				}
+				ctx.Release(msg.ID)
+				continue
+			}
+			summaries = append(summaries, response.Text)
+			// the file is consumed, only the summary message leaves the job
+			ctx.Release(msg.ID)
+		}
+	}
+}
+
+func (s Summarize) reduce(ctx *tesei.Thread, summaries []string) *tesei.Message[files.TextFile] {
+	glue := s.Glue
+	if glue == "" {
+		glue = "\n\n"
+	}
+
+	msg := tesei.NewMessage(files.TextFile{Name: s.Name, Folder: s.Folder})
+	msg.Metadata["summarized"] = len(summaries)
+
+	response, err := s.call(ctx, echo.QuickMessage(strings.Join(summaries, glue)), echo.WithSystemMessage(s.ReducePrompt))
+	if err != nil {
+		return msg.WithError(fmt.Errorf("%w: %w", ErrComplete, err), "Summarize")
+	}
+
+	msg.Data.Content = response.Text
+	return msg
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// SummarizeLong is a job that summarizes content larger than the context window.
+// The content is split into chunks of about ChunkTokens tokens, each chunk is summarized
+// with ChunkPrompt, then the summaries are combined with CombinePrompt into the new content.
+// Content which fits into one chunk is summarized with a single call.
+type SummarizeLong struct {
+	Echo
+	// ChunkPrompt is the system prompt used to summarize each chunk.
+	ChunkPrompt string
+	// CombinePrompt is the system prompt used to combine the chunk summaries.
+	CombinePrompt string
+	// ChunkTokens is the estimated size of a chunk in tokens. Defaults to 2000.
+	ChunkTokens int
+	// Overlap is the estimated number of tokens repeated at the start of the next chunk.
+	Overlap int
+	// Glue is the string used to join chunk summaries. Defaults to an empty line.
+	Glue string
+}
+
+func (s SummarizeLong) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	err := s.init(ctx)
+	if err != nil {
+		return
+	}
+
+	tesei.TransformStage(ctx, in, out, "SummarizeLong", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		size := s.ChunkTokens
+		if size <= 0 {
+			size = 2000
+		}
+		chunks := chunkByTokens(msg.Data.Content, size, s.Overlap)
+
+		summaries := make([]string, 0, len(chunks))
+		for _, chunk := range chunks {
+			response, err := s.call(ctx, echo.QuickMessage(chunk), echo.WithSystemMessage(s.ChunkPrompt))
+			if err != nil {
+				return msg, fmt.Errorf("%w: %w", ErrComplete, err)
+			}
+			summaries = append(summaries, response.Text)
+		}
+		msg.Metadata["chunks"] = len(chunks)
+
+		if len(summaries) == 1 {
+			msg.Data.Content = summaries[0]
+			return msg, nil
+		}
+
+		glue := s.Glue
+		if glue == "" {
+			glue = "\n\n"
+		}
+		response, err := s.call(ctx, echo.QuickMessage(strings.Join(summaries, glue)), echo.WithSystemMessage(s.CombinePrompt))
+		if err != nil {
+			return msg, fmt.Errorf("%w: %w", ErrComplete, err)
+		}
+
+		msg.Data.Content = response.Text
+		return msg, nil
+	})
+}
+
+// charsPerToken is a rough average for English text.
+const charsPerToken = 4
+
+// estimateTokens returns an approximate number of tokens in the text.
+func estimateTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+var wordPattern = regexp.MustCompile(`\S+\s*`)
+
+// chunkByTokens splits text at word boundaries into chunks of about size tokens,
+// each chunk after the first starts with about overlap tokens of the previous one.
+func chunkByTokens(text string, size, overlap int) []string {
+	words := wordPattern.FindAllString(text, -1)
+	if len(words) == 0 {
+		return []string{text}
+	}
+	if overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []string
+	start := 0
+	for start < len(words) {
+		end := start
+		tokens := 0
+		for end < len(words) && (end == start || tokens+estimateTokens(words[end]) <= size) {
+			tokens += estimateTokens(words[end])
+			end++
+		}
+		chunks = append(chunks, strings.TrimSpace(strings.Join(words[start:end], "")))
+		if end == len(words) {
+			break
+		}
+
+		// step back to repeat the overlap, but always move forward
+		next := end
+		for tokens = 0; next > start+1 && tokens+estimateTokens(words[next-1]) <= overlap; next-- {
+			tokens += estimateTokens(words[next-1])
+		}
+		start = next
+	}
+	return chunks
+}
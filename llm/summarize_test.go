@@ -0,0 +1,108 @@
+package llm_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/llm"
+)
+
+func ExampleSummarize() {
+
+	llm.SetModel("mock/test")
+	p := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.ListDir{Path: "../testdata", Ext: ".txt"}).
+		Sequential(files.ReadFile{}).
+		Sequential(llm.Summarize{
+			MapPrompt:    "map",
+			ReducePrompt: "reduce",
+			Glue:         " + ",
+			Name:         "summary.md",
+		}).
+		Sequential(tesei.Log[files.TextFile]{Print: func(msg *tesei.Message[files.TextFile], err error) string {
+			return fmt.Sprintf("%s (%d files)\n%s", msg.Data.Name, msg.Metadata["summarized"], msg.Data.Content)
+		}}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build()
+
+	_, err := p.Start(context.Background())
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// summary.md (2 files)
+	// [system]: reduce
+	// [user]: [system]: map
+	// [user]: fileA + [system]: map
+	// [user]: fileB
+
+}
+
+func ExampleSummarizeLong() {
+
+	llm.SetModel("mock/test")
+	p := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{
+			{Name: "long.md", Content: "alpha beta gamma delta epsilon zeta eta theta"},
+		}}).
+		Sequential(llm.SummarizeLong{
+			ChunkPrompt:   "chunk",
+			CombinePrompt: "combine",
+			ChunkTokens:   10,
+			Overlap:       2,
+			Glue:          " + ",
+		}).
+		Sequential(tesei.Log[files.TextFile]{Print: func(msg *tesei.Message[files.TextFile], err error) string {
+			return fmt.Sprintf("%s (%d chunks)\n%s", msg.Data.Name, msg.Metadata["chunks"], msg.Data.Content)
+		}}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build()
+
+	_, err := p.Start(context.Background())
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// long.md (2 chunks)
+	// [system]: combine
+	// [user]: [system]: chunk
+	// [user]: alpha beta gamma delta epsilon + [system]: chunk
+	// [user]: epsilon zeta eta theta
+}
+
+func TestSummarizeWithMaxInFlight(t *testing.T) {
+	llm.SetModel("mock/test")
+
+	var input []files.TextFile
+	for i := 0; i < 5; i++ {
+		input = append(input, files.TextFile{Name: fmt.Sprintf("%d.md", i), Content: "text"})
+	}
+
+	var results []*tesei.Message[files.TextFile]
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := tesei.NewPipeline[files.TextFile]().
+		WithMaxInFlight(2).
+		Sequential(files.Source{Files: input}).
+		Sequential(llm.Summarize{MapPrompt: "map", ReducePrompt: "reduce", Name: "summary.md"}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(ctx)
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("got %d messages, want 1", len(results))
+	}
+	if got := results[0].Metadata["summarized"]; got != 5 {
+		t.Errorf("summarized = %v, want 5", got)
+	}
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mkozhukh/echo"
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// Translate is a job that translates the content to TargetLang.
+// The Glossary is added to the prompt, so the terms are translated consistently.
+// Long content is translated in chunks of whole paragraphs.
+type Translate struct {
+	Echo
+	// TargetLang is the language to translate to, like "German" or "de".
+	TargetLang string
+	// SourceLang is the language of the content. If empty, the model detects it.
+	SourceLang string
+	// Glossary maps terms to their required translations. An empty translation
+	// means the term must be kept as is.
+	Glossary map[string]string
+	// Prompt is added to the generated system prompt, for extra instructions like the tone.
+	Prompt string
+	// ChunkTokens is the estimated size of a chunk in tokens. Defaults to 2000.
+	ChunkTokens int
+	// TargetKey stores the translation in metadata under this key, leaving the content untouched.
+	TargetKey string
+}
+
+func (t Translate) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	err := t.init(ctx)
+	if err != nil {
+		return
+	}
+
+	prompt := t.prompt()
+	size := t.ChunkTokens
+	if size <= 0 {
+		size = 2000
+	}
+
+	tesei.TransformStage(ctx, in, out, "Translate", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		chunks := chunkByParagraphs(msg.Data.Content, size)
+
+		parts := make([]string, 0, len(chunks))
+		for _, chunk := range chunks {
+			response, err := t.call(ctx, echo.QuickMessage(chunk), echo.WithSystemMessage(prompt))
+			if err != nil {
+				return msg, fmt.Errorf("%w: %w", ErrComplete, err)
+			}
+			parts = append(parts, strings.TrimSpace(response.Text))
+		}
+
+		text := strings.Join(parts, "\n\n")
+		if t.TargetKey != "" {
+			msg.Metadata[t.TargetKey] = text
+		} else {
+			msg.Data.Content = text
+		}
+		return msg, nil
+	})
+}
+
+func (t Translate) prompt() string {
+	var sb strings.Builder
+	sb.WriteString("Translate the text")
+	if t.SourceLang != "" {
+		sb.WriteString(" from " + t.SourceLang)
+	}
+	sb.WriteString(" to " + t.TargetLang + ". Keep the formatting and reply with the translation only.")
+
+	terms := make([]string, 0, len(t.Glossary))
+	for term := range t.Glossary {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	var translate, keep []string
+	for _, term := range terms {
+		if t.Glossary[term] == "" {
+			keep = append(keep, "- "+term)
+		} else {
+			translate = append(translate, "- "+term+" => "+t.Glossary[term])
+		}
+	}
+	if len(translate) > 0 {
+		sb.WriteString("\nTranslate these terms exactly as given:\n" + strings.Join(translate, "\n"))
+	}
+	if len(keep) > 0 {
+		sb.WriteString("\nDo not translate these terms:\n" + strings.Join(keep, "\n"))
+	}
+	if t.Prompt != "" {
+		sb.WriteString("\n" + t.Prompt)
+	}
+	return sb.String()
+}
+
+// chunkByParagraphs groups whole paragraphs into chunks of about size tokens,
+// paragraphs larger than a chunk are split at word boundaries.
+func chunkByParagraphs(text string, size int) []string {
+	var chunks []string
+	var current []string
+	tokens := 0
+
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+
+		n := estimateTokens(paragraph)
+		if tokens > 0 && tokens+n > size {
+			chunks = append(chunks, strings.Join(current, "\n\n"))
+			current, tokens = nil, 0
+		}
+		if n > size {
+			chunks = append(chunks, chunkByTokens(paragraph, size, 0)...)
+			continue
+		}
+		current = append(current, paragraph)
+		tokens += n
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, strings.Join(current, "\n\n"))
+	}
+	if len(chunks) == 0 {
+		return []string{text}
+	}
+	return chunks
+}
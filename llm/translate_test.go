@@ -0,0 +1,49 @@
+package llm_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/llm"
+)
+
+func ExampleTranslate() {
+
+	llm.SetModel("mock/test")
+	p := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{
+			{Name: "intro.md", Content: "Open the pipeline settings.\n\nThe job writes files."},
+		}}).
+		Sequential(llm.Translate{
+			SourceLang:  "English",
+			TargetLang:  "German",
+			Glossary:    map[string]string{"pipeline": "", "job": "Aufgabe"},
+			ChunkTokens: 8,
+		}).
+		Sequential(files.PrintContent{}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build()
+
+	_, err := p.Start(context.Background())
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// intro.md
+	// [system]: Translate the text from English to German. Keep the formatting and reply with the translation only.
+	// Translate these terms exactly as given:
+	// - job => Aufgabe
+	// Do not translate these terms:
+	// - pipeline
+	// [user]: Open the pipeline settings.
+	//
+	// [system]: Translate the text from English to German. Keep the formatting and reply with the translation only.
+	// Translate these terms exactly as given:
+	// - job => Aufgabe
+	// Do not translate these terms:
+	// - pipeline
+	// [user]: The job writes files.
+}
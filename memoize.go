@@ -0,0 +1,239 @@
+package tesei
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoEntry is a cached output of a memoized job.
+type MemoEntry[T any] struct {
+	Data     T
+	Metadata map[string]any
+}
+
+// MemoStore keeps the cached outputs of Memoize, by input hash.
+type MemoStore[T any] interface {
+	Get(key string) (MemoEntry[T], bool)
+	Set(key string, entry MemoEntry[T])
+}
+
+// MemoryStore is an in-memory MemoStore. The zero value is ready to use.
+type MemoryStore[T any] struct {
+	mu      sync.Mutex
+	entries map[string]MemoEntry[T]
+}
+
+func (s *MemoryStore[T]) Get(key string) (MemoEntry[T], bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	return entry, ok
+}
+
+func (s *MemoryStore[T]) Set(key string, entry MemoEntry[T]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = make(map[string]MemoEntry[T])
+	}
+	s.entries[key] = entry
+}
+
+// DirStore is a MemoStore keeping entries as JSON files in a folder, so they survive between runs.
+// Metadata values are restored as JSON types, e.g. numbers become float64.
+type DirStore[T any] struct {
+	Path string
+}
+
+func (s DirStore[T]) Get(key string) (MemoEntry[T], bool) {
+	var entry MemoEntry[T]
+	data, err := os.ReadFile(filepath.Join(s.Path, key+".json"))
+	if err != nil {
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+func (s DirStore[T]) Set(key string, entry MemoEntry[T]) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	// a failed write only means a cache miss next time
+	if err := os.MkdirAll(s.Path, 0755); err == nil {
+		_ = os.WriteFile(filepath.Join(s.Path, key+".json"), data, 0644)
+	}
+}
+
+// Memoize is a job that wraps an expensive 1:1 job and skips it for inputs it has already processed.
+// The input is hashed (Data and the metadata listed in Keys); on a hit the cached output is used,
+// otherwise the message goes through the job and its output is cached.
+// Identical inputs arriving while the first one is processed wait for its output.
+// The wrapped job must keep message IDs, outputs with unknown IDs are passed on without caching.
+// When the job drops a message, the identical inputs waiting for it are dropped too. Drops are noticed
+// through Thread.Release, which Transform and Filter call; inputs still waiting when the job closes are dropped.
+type Memoize[T any] struct {
+	Job Job[T]
+	// Keys lists the metadata keys which are part of the input hash.
+	Keys []string
+	// Store keeps the cached outputs. If nil, outputs are cached per job run.
+	Store MemoStore[T]
+}
+
+func (m Memoize[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+
+	store := m.Store
+	if store == nil {
+		store = &MemoryStore[T]{}
+	}
+
+	var mu sync.Mutex
+	pending := make(map[string]string)        // message ID -> input hash
+	waiting := make(map[string][]*Message[T]) // input hash -> messages waiting for the output
+
+	// a message dropped by the job leaves no output, its waiters are dropped with it
+	drop := func(waiters []*Message[T]) {
+		for _, w := range waiters {
+			ctx.Release(w.ID)
+		}
+	}
+	jobCtx := ctx.derive()
+	jobCtx.release = func(id string) {
+		ctx.Release(id)
+		mu.Lock()
+		key, ok := pending[id]
+		delete(pending, id)
+		var waiters []*Message[T]
+		if ok {
+			waiters = waiting[key]
+			delete(waiting, key)
+		}
+		mu.Unlock()
+		drop(waiters)
+	}
+
+	jobIn := make(chan *Message[T], 1)
+	jobOut := make(chan *Message[T], 1)
+	go m.Job.Run(jobCtx, jobIn, jobOut)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range jobOut {
+			mu.Lock()
+			key, ok := pending[msg.ID]
+			delete(pending, msg.ID)
+			waiters := waiting[key]
+			delete(waiting, key)
+			mu.Unlock()
+
+			batch := []*Message[T]{msg}
+			if ok {
+				entry := MemoEntry[T]{Data: msg.Data, Metadata: make(map[string]any, len(msg.Metadata))}
+				for k, v := range msg.Metadata {
+					entry.Metadata[k] = v
+				}
+				if msg.Error == nil {
+					store.Set(key, entry)
+				}
+				for _, w := range waiters {
+					if msg.Error != nil {
+						w.WithError(msg.Error, msg.ErrorStage)
+					} else {
+						applyMemo(w, entry)
+					}
+					batch = append(batch, w)
+				}
+			}
+
+			for _, msg := range batch {
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+				}
+			}
+		}
+
+		// the job dropped these inputs without a release
+		mu.Lock()
+		defer mu.Unlock()
+		for _, waiters := range waiting {
+			drop(waiters)
+		}
+	}()
+
+	defer func() { <-done }()
+	defer close(jobIn)
+
+	for {
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+
+			if msg.Error == nil {
+				if key, ok := m.key(msg); ok {
+					if entry, hit := store.Get(key); hit {
+						applyMemo(msg, entry)
+						select {
+						case out <- msg:
+						case <-ctx.Done():
+							return
+						}
+						continue
+					}
+
+					mu.Lock()
+					if waiters, busy := waiting[key]; busy {
+						waiting[key] = append(waiters, msg)
+						mu.Unlock()
+						continue
+					}
+					waiting[key] = nil
+					pending[msg.ID] = key
+					mu.Unlock()
+				}
+			}
+
+			select {
+			case jobIn <- msg:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// key returns the hash of the input, it fails if the input can't be serialized.
+func (m Memoize[T]) key(msg *Message[T]) (string, bool) {
+	meta := make(map[string]any, len(m.Keys))
+	for _, k := range m.Keys {
+		meta[k] = msg.Metadata[k]
+	}
+
+	data, err := json.Marshal(MemoEntry[T]{Data: msg.Data, Metadata: meta})
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// applyMemo replaces the message data with the cached output and merges its metadata.
+func applyMemo[T any](msg *Message[T], entry MemoEntry[T]) {
+	msg.Data = entry.Data
+	for k, v := range entry.Metadata {
+		msg.Metadata[k] = v
+	}
+}
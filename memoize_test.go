@@ -0,0 +1,160 @@
+package tesei_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestMemoize(t *testing.T) {
+	var calls atomic.Int32
+	upper := &tesei.TransformJob[string]{
+		Transform: func(msg *tesei.Message[string]) (*tesei.Message[string], error) {
+			calls.Add(1)
+			msg.Data = strings.ToUpper(msg.Data)
+			msg.Metadata["upper"] = true
+			return msg, nil
+		},
+	}
+
+	store := &tesei.MemoryStore[string]{}
+	var collected []*tesei.Message[string]
+	_, err := tesei.NewPipeline[string]().
+		Sequential(tesei.Slice[string]{Items: []string{"hello", "world", "hello"}}).
+		Sequential(tesei.Memoize[string]{Job: upper, Store: store}).
+		Sequential(tesei.Collect[string]{Items: &collected}).
+		Sequential(tesei.End[string]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected the job to run 2 times, got %d", n)
+	}
+
+	var results []string
+	for _, msg := range collected {
+		results = append(results, fmt.Sprintf("%s %v", msg.Data, msg.Metadata["upper"]))
+	}
+	sort.Strings(results)
+	if got := strings.Join(results, ", "); got != "HELLO true, HELLO true, WORLD true" {
+		t.Errorf("Unexpected results: %s", got)
+	}
+
+	// the store is shared, so a second run doesn't call the job at all
+	_, err = tesei.NewPipeline[string]().
+		Sequential(tesei.Slice[string]{Items: []string{"hello", "world"}}).
+		Sequential(tesei.Memoize[string]{Job: upper, Store: store}).
+		Sequential(tesei.End[string]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected no calls on the second run, got %d", n-2)
+	}
+}
+
+func TestMemoizeKeys(t *testing.T) {
+	var calls atomic.Int32
+	job := &tesei.TransformJob[string]{
+		Transform: func(msg *tesei.Message[string]) (*tesei.Message[string], error) {
+			calls.Add(1)
+			return msg, nil
+		},
+	}
+
+	tag := func(lang string) tesei.Job[string] {
+		return &tesei.TransformJob[string]{
+			Transform: func(msg *tesei.Message[string]) (*tesei.Message[string], error) {
+				msg.Metadata["lang"] = lang
+				return msg, nil
+			},
+		}
+	}
+
+	store := &tesei.MemoryStore[string]{}
+	for _, lang := range []string{"en", "de", "en"} {
+		_, err := tesei.NewPipeline[string]().
+			Sequential(tesei.Slice[string]{Items: []string{"text"}}).
+			Sequential(tag(lang)).
+			Sequential(tesei.Memoize[string]{Job: job, Keys: []string{"lang"}, Store: store}).
+			Sequential(tesei.End[string]{}).
+			Build().
+			Start(context.Background())
+		if err != nil {
+			t.Fatalf("pipeline failed: %v", err)
+		}
+	}
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected the job to run once per language, got %d", n)
+	}
+}
+
+func TestMemoizeDropped(t *testing.T) {
+	slowFilter := tesei.JobFunc[string](func(ctx *tesei.Thread, in <-chan *tesei.Message[string], out chan<- *tesei.Message[string]) {
+		tesei.Filter(ctx, in, out, func(msg *tesei.Message[string]) bool {
+			time.Sleep(20 * time.Millisecond)
+			return msg.Data != "skip"
+		})
+	})
+	// drops without a release, the waiters are dropped when the job closes
+	silentFilter := tesei.JobFunc[string](func(ctx *tesei.Thread, in <-chan *tesei.Message[string], out chan<- *tesei.Message[string]) {
+		defer close(out)
+		for msg := range in {
+			time.Sleep(20 * time.Millisecond)
+			if msg.Data != "skip" {
+				out <- msg
+			}
+		}
+	})
+
+	tests := []struct {
+		name        string
+		job         tesei.Job[string]
+		maxInFlight int
+	}{
+		{"Released", slowFilter, 0},
+		{"Released with in-flight limit", slowFilter, 2},
+		{"Not released", silentFilter, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var collected []*tesei.Message[string]
+			_, err := tesei.NewPipeline[string]().
+				Sequential(tesei.Slice[string]{Items: []string{"a", "skip", "skip", "skip", "b"}}).
+				Sequential(tesei.Memoize[string]{Job: tt.job}).
+				Sequential(tesei.Collect[string]{Items: &collected}).
+				Sequential(tesei.End[string]{}).
+				WithMaxInFlight(tt.maxInFlight).
+				Build().
+				Start(ctx)
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			var results []string
+			for _, msg := range collected {
+				results = append(results, msg.Data)
+			}
+			sort.Strings(results)
+			if got := strings.Join(results, ", "); got != "a, b" {
+				t.Errorf("Expected the skipped inputs to be dropped, got %s", got)
+			}
+		})
+	}
+}
@@ -71,6 +71,16 @@ func (m *Message[T]) Clone() *Message[T] {
 	return &n
 }
 
+// Child creates a clone of the message with a new unique ID, for jobs expanding a message
+// into several ones. The ID of the message is recorded in the "parent_id" metadata of the child,
+// so the lineage can be traced.
+func (m *Message[T]) Child() *Message[T] {
+	n := m.Clone()
+	n.ID = generateID()
+	n.Metadata["parent_id"] = m.ID
+	return n
+}
+
 func generateID() string {
 	b := make([]byte, 16)
 	_, err := rand.Read(b)
@@ -112,3 +112,32 @@ func TestGenerateID(t *testing.T) {
 		t.Errorf("Expected ID to be 32 characters (hex encoding of 16 bytes), got %d", len(id1))
 	}
 }
+
+func TestMessageChild(t *testing.T) {
+	parent := NewMessage("data")
+	parent.Metadata["key"] = "value"
+
+	first := parent.Child()
+	second := parent.Child()
+	grandchild := first.Child()
+
+	ids := map[string]bool{parent.ID: true, first.ID: true, second.ID: true, grandchild.ID: true}
+	if len(ids) != 4 {
+		t.Errorf("Expected unique IDs, got %v", ids)
+	}
+
+	for _, child := range []*Message[string]{first, second} {
+		if child.Metadata["parent_id"] != parent.ID {
+			t.Errorf("Expected parent_id %s, got %v", parent.ID, child.Metadata["parent_id"])
+		}
+		if child.Data != "data" || child.Metadata["key"] != "value" {
+			t.Errorf("Expected data and metadata to be copied, got %v %v", child.Data, child.Metadata)
+		}
+	}
+	if grandchild.Metadata["parent_id"] != first.ID {
+		t.Errorf("Expected parent_id %s, got %v", first.ID, grandchild.Metadata["parent_id"])
+	}
+	if _, ok := parent.Metadata["parent_id"]; ok {
+		t.Error("Expected parent metadata to be untouched")
+	}
+}
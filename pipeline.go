@@ -1,22 +1,46 @@
 package tesei
 
+import (
+	"errors"
+	"time"
+)
+
 var defaultBufferSize = 1
 
+// defaultBranchBuffer is the number of messages each branch of a Parallel stage may queue by default.
+var defaultBranchBuffer = 64
+
+// defaultOrderedWindow is the number of messages per worker an OrderedFanOut stage may hold by default.
+var defaultOrderedWindow = 4
+
 // Pipeline is a builder for creating data processing pipelines.
 // It allows chaining stages like Sequential, Parallel, and FanOut.
 type Pipeline[T any] struct {
-	stages     []stage[T]
-	bufferSize int
+	stages       []stage[T]
+	bufferSize   int
+	outputBuffer int
+	branchBuffer int
+	window       int
+	maxInFlight  int
+	failFast     bool
+	validation   bool
+	requireEnd   bool
+	seeded       bool
+	seed         int64
+	errorHandler ErrorHandler[T]
 }
 
 // ErrorHandler is a function type for handling errors in the pipeline.
+// The message is nil for critical errors.
 type ErrorHandler[T any] func(error, *Message[T])
 
 // NewPipeline creates a new pipeline builder for type T.
 func NewPipeline[T any]() *Pipeline[T] {
 	return &Pipeline[T]{
-		stages:     []stage[T]{},
-		bufferSize: defaultBufferSize,
+		stages:       []stage[T]{},
+		bufferSize:   defaultBufferSize,
+		branchBuffer: defaultBranchBuffer,
+		window:       defaultOrderedWindow,
 	}
 }
 
@@ -30,9 +54,18 @@ func (p *Pipeline[T]) Sequential(jobs ...Job[T]) *Pipeline[T] {
 }
 
 // Parallel adds a stage where input messages are broadcast to multiple jobs running in parallel.
-// Each job receives a clone of the input message.
+// Each job receives a clone of the input message through its own queue, so a slow branch doesn't hold back
+// the faster ones until it is 64 messages behind; WithBranchBuffer changes the queue size.
 func (p *Pipeline[T]) Parallel(jobs ...Job[T]) *Pipeline[T] {
-	p.stages = append(p.stages, &parallelStage[T]{jobs: jobs})
+	p.stages = append(p.stages, &parallelStage[T]{jobs: jobs, buffer: p.branchBuffer})
+	return p
+}
+
+// Tee adds a terminal stage where input messages are broadcast to multiple sinks running in parallel.
+// Each sink receives a clone of the input message, anything the sinks emit is discarded.
+// The stage completes when all sinks have drained their input.
+func (p *Pipeline[T]) Tee(sinks ...Job[T]) *Pipeline[T] {
+	p.stages = append(p.stages, &teeStage[T]{sinks: sinks})
 	return p
 }
 
@@ -46,6 +79,58 @@ func (p *Pipeline[T]) FanOut(job Job[T], count int) *Pipeline[T] {
 	return p
 }
 
+// OrderedFanOut adds a FanOut stage which emits the results in the order of the input messages.
+// Results are held in a reassembly buffer until their turn comes; at most window (see WithOrderedWindow,
+// default 4) messages per worker are in the stage at once, so a slow message holds back the next ones
+// instead of growing the buffer.
+// An input is done when the job emits it or releases it with Thread.Release, like Transform and Filter do
+// for the messages they drop; its children made with Child and emitted before that go along with it.
+// The job must finish each input before it has taken window*count of them: a job holding messages
+// until the input is closed, like Sort or JoinByID, or dropping them without Thread.Release,
+// blocks the stage forever. Use FanOut for such jobs.
+func (p *Pipeline[T]) OrderedFanOut(job Job[T], count int) *Pipeline[T] {
+	p.stages = append(p.stages, &orderedFanOutStage[T]{
+		job:    job,
+		count:  count,
+		window: p.window,
+	})
+	return p
+}
+
+// AutoFanOut adds a FanOut stage which scales the number of workers between minWorkers and maxWorkers with the load.
+// When a message waits for a free worker longer than 10ms, one more worker is started (up to maxWorkers);
+// a worker that waits for a message longer than 100ms is stopped (down to minWorkers).
+// Each worker gets its own input channel, so the job must close its output when the input is closed.
+func (p *Pipeline[T]) AutoFanOut(job Job[T], minWorkers, maxWorkers int) *Pipeline[T] {
+	p.stages = append(p.stages, &autoFanOutStage[T]{
+		job: job,
+		min: minWorkers,
+		max: max(minWorkers, maxWorkers, 1),
+	})
+	return p
+}
+
+// Route adds a stage which sends each message to the branch with the index returned by selector
+// and merges the outputs of the branches. Messages matching no branch are dropped,
+// use RouteJob with PassUnmatched to pass them through instead.
+func (p *Pipeline[T]) Route(selector func(*Message[T]) int, branches ...Executor[T]) *Pipeline[T] {
+	jobs := make([]Job[T], len(branches))
+	for i, branch := range branches {
+		jobs[i] = branch
+	}
+	return p.Sequential(RouteJob[T]{Selector: selector, Branches: jobs})
+}
+
+// Batch adds a stage which groups messages into batches of up to size messages, see BatchJob.
+func (p *Pipeline[T]) Batch(size int) *Pipeline[T] {
+	return p.Sequential(BatchJob[T]{Size: size})
+}
+
+// Throttle adds a stage which passes at most rate messages per interval, see ThrottleJob.
+func (p *Pipeline[T]) Throttle(rate int, per time.Duration) *Pipeline[T] {
+	return p.Sequential(ThrottleJob[T]{Rate: rate, Per: per})
+}
+
 // WithBufferSize sets the buffer size for channels between stages.
 // Default is 1.
 func (p *Pipeline[T]) WithBufferSize(size int) *Pipeline[T] {
@@ -53,12 +138,105 @@ func (p *Pipeline[T]) WithBufferSize(size int) *Pipeline[T] {
 	return p
 }
 
+// WithOutputBuffer sets the buffer size of the output channel returned by Executor.Output,
+// so the last stage can emit up to size messages before a slow consumer reads them,
+// without growing the buffers between stages. Defaults to the WithBufferSize value.
+func (p *Pipeline[T]) WithOutputBuffer(size int) *Pipeline[T] {
+	p.outputBuffer = size
+	return p
+}
+
+// WithBranchBuffer sets the size of the queue of each branch of the Parallel stages,
+// a slow branch holds back the faster ones only once it is size messages behind.
+// Default is 64; a smaller size caps the memory taken by the queued clones,
+// and 0 makes every branch take a message before the next one is broadcast.
+func (p *Pipeline[T]) WithBranchBuffer(size int) *Pipeline[T] {
+	p.branchBuffer = size
+	for _, s := range p.stages {
+		if ps, ok := s.(*parallelStage[T]); ok {
+			ps.buffer = size
+		}
+	}
+	return p
+}
+
+// WithOrderedWindow sets the number of messages per worker the OrderedFanOut stages may hold at once,
+// the inputs being processed and the results waiting for their turn. Default is 4; a larger window
+// lets a slow message be overtaken by more of the next ones, at the cost of buffering their results.
+func (p *Pipeline[T]) WithOrderedWindow(n int) *Pipeline[T] {
+	p.window = n
+	for _, s := range p.stages {
+		if os, ok := s.(*orderedFanOutStage[T]); ok {
+			os.window = n
+		}
+	}
+	return p
+}
+
+// WithMaxInFlight caps the total number of messages held by the pipeline at once.
+// A slot is taken when a message leaves the first stage and released when it reaches the last one,
+// so the source blocks while n messages are outstanding, regardless of buffer sizes.
+// Slots are tracked by message ID. Messages dropped by Transform, Filter and the jobs built on them release
+// their slots, as do split messages; custom jobs dropping messages call Thread.Release.
+// Jobs holding messages until the input is closed, like Sort, need a limit above the number of messages.
+// Default is 0 (no limit).
+func (p *Pipeline[T]) WithMaxInFlight(n int) *Pipeline[T] {
+	p.maxInFlight = n
+	return p
+}
+
+// WithFailFast runs the stages as a group: the first critical error reported by a stage
+// cancels all stages, and Start returns it as a *StageError after every stage has exited.
+// By default Start returns on the first error without waiting for the stages.
+func (p *Pipeline[T]) WithFailFast() *Pipeline[T] {
+	p.failFast = true
+	return p
+}
+
+// WithErrorHandler registers a handler called for every message with an error reaching the end of the pipeline,
+// the input of the last stage if it is a sink like End, otherwise its output. The message still goes on.
+// The handler is also called with a nil message for the critical error returned by Start,
+// or, for a nested pipeline, for every critical error of its stages before it reaches the outer pipeline.
+// It runs synchronously, so a slow handler slows down the pipeline.
+func (p *Pipeline[T]) WithErrorHandler(h ErrorHandler[T]) *Pipeline[T] {
+	p.errorHandler = h
+	return p
+}
+
+// WithSeed seeds the random number generators of the run, which jobs get with Thread.Rand.
+// Each stage gets its own generator, derived from the seed and the position of the stage,
+// so the random decisions of sequential jobs repeat from run to run. The workers of a FanOut stage share one.
+// A nested pipeline without a seed derives the generators of its stages from the one of its outer stage.
+func (p *Pipeline[T]) WithSeed(seed int64) *Pipeline[T] {
+	p.seeded = true
+	p.seed = seed
+	return p
+}
+
 // Build compiles the pipeline and returns an Executor.
 // The Executor can be started to run the pipeline.
 func (p *Pipeline[T]) Build() Executor[T] {
 	return &executor[T]{
-		stages:     p.compileStages(),
-		bufferSize: p.bufferSize,
+		stages:       p.compileStages(),
+		bufferSize:   p.bufferSize,
+		outputBuffer: p.outputBuffer,
+		maxInFlight:  p.maxInFlight,
+		failFast:     p.failFast,
+		invalid:      p.checks(),
+		seeded:       p.seeded,
+		seed:         p.seed,
+		errorHandler: p.errorHandler,
+	}
+}
+
+// checks returns the validation errors for the executor, if validation is enabled.
+func (p *Pipeline[T]) checks() *validation {
+	if !p.validation {
+		return nil
+	}
+	return &validation{
+		stages: errors.Join(p.validateStages()...),
+		all:    p.Validate(),
 	}
 }
 
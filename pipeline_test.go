@@ -1,7 +1,11 @@
 package tesei
 
 import (
+	"context"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNewPipeline(t *testing.T) {
@@ -210,6 +214,74 @@ func TestPipelineCompileStages(t *testing.T) {
 	}
 }
 
+func TestPipelineWithBranchBuffer(t *testing.T) {
+	job := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+	})
+
+	if ps := NewPipeline[int]().Parallel(job, job).stages[0].(*parallelStage[int]); ps.buffer != defaultBranchBuffer {
+		t.Errorf("Expected the default buffer %d, got %d", defaultBranchBuffer, ps.buffer)
+	}
+
+	// the buffer applies to the Parallel stages added before and after it is set
+	p := NewPipeline[int]().Parallel(job, job).WithBranchBuffer(8).Parallel(job, job)
+	for i, s := range p.stages {
+		if ps := s.(*parallelStage[int]); ps.buffer != 8 {
+			t.Errorf("Expected stage %d to have buffer 8, got %d", i, ps.buffer)
+		}
+	}
+}
+
+func TestPipelineParallelIndependentBranches(t *testing.T) {
+	var fastDone int32
+	release := make(chan struct{})
+
+	fast := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+		defer close(out)
+		for msg := range in {
+			out <- msg
+		}
+		atomic.StoreInt32(&fastDone, 1)
+	})
+	stalled := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+		defer close(out)
+		<-release
+		for msg := range in {
+			out <- msg
+		}
+	})
+
+	items := make([]int, 20)
+	var results []*Message[int]
+	done := make(chan error)
+	go func() {
+		_, err := NewPipeline[int]().
+			Sequential(Slice[int]{Items: items}).
+			Parallel(fast, stalled).
+			Sequential(Collect[int]{Items: &results}).
+			Sequential(End[int]{}).
+			Build().
+			Start(context.Background())
+		done <- err
+	}()
+
+	// the fast branch gets all of the input while the other one hasn't taken any
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&fastDone) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&fastDone) == 0 {
+		t.Error("Expected the fast branch to finish while the other one is stalled")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	if len(results) != 40 {
+		t.Errorf("Expected 40 results, got %d", len(results))
+	}
+}
+
 func TestPipelineNested(t *testing.T) {
 	job := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
 	})
@@ -228,3 +300,131 @@ func TestPipelineNested(t *testing.T) {
 		t.Errorf("Expected 4 stages, got %d", len(compiled))
 	}
 }
+
+func TestPipelineWithMaxInFlight(t *testing.T) {
+	var passed int32
+	release := make(chan struct{})
+
+	items := make([]int, 20)
+	var consumed int
+	p := NewPipeline[int]().
+		WithBufferSize(100).
+		WithMaxInFlight(3).
+		Sequential(Slice[int]{Items: items}).
+		Sequential(TransformJob[int]{
+			Transform: func(msg *Message[int]) (*Message[int], error) {
+				atomic.AddInt32(&passed, 1)
+				return msg, nil
+			},
+		}).
+		Sequential(JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+			defer close(out)
+			// the sink doesn't read until released, so no slot is freed
+			<-release
+			for range in {
+				consumed++
+			}
+		})).
+		Build()
+
+	done := make(chan error)
+	go func() {
+		_, err := p.Start(context.Background())
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&passed); n != 3 {
+		t.Errorf("Expected exactly 3 messages in flight, got %d", n)
+	}
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if consumed != 20 {
+		t.Errorf("Expected 20 consumed messages, got %d", consumed)
+	}
+}
+
+func TestPipelineWithMaxInFlightDropped(t *testing.T) {
+	items := make([]int, 30)
+	for i := range items {
+		items[i] = i
+	}
+
+	var results []*Message[int]
+	done := make(chan error)
+	go func() {
+		_, err := NewPipeline[int]().
+			WithMaxInFlight(2).
+			Sequential(Slice[int]{Items: items}).
+			Sequential(TransformJob[int]{
+				Transform: func(msg *Message[int]) (*Message[int], error) {
+					if msg.Data%3 == 0 {
+						return nil, nil
+					}
+					return msg, nil
+				},
+			}).
+			Sequential(DropConsecutiveDuplicates[int]{Key: func(msg *Message[int]) string { return strconv.Itoa(msg.Data % 2) }}).
+			Sequential(Collect[int]{Items: &results}).
+			Sequential(End[int]{}).
+			Build().
+			Start(context.Background())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dropped messages kept their slots")
+	}
+	if len(results) == 0 || len(results) >= 20 {
+		t.Errorf("Expected some messages to be dropped, got %d of 20", len(results))
+	}
+}
+
+func TestPipelineWithMaxInFlightDroppedNested(t *testing.T) {
+	items := make([]int, 30)
+	for i := range items {
+		items[i] = i
+	}
+
+	// a seeded nested pipeline gets its own generators, drops still release the slots of the outer one
+	nested := NewPipeline[int]().
+		WithSeed(1).
+		Sequential(JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+			Filter(ctx, in, out, func(msg *Message[int]) bool { return msg.Data%2 == 1 })
+		})).
+		Build()
+
+	var results []*Message[int]
+	done := make(chan error)
+	go func() {
+		_, err := NewPipeline[int]().
+			WithMaxInFlight(2).
+			Sequential(Slice[int]{Items: items}).
+			Sequential(nested).
+			Sequential(Collect[int]{Items: &results}).
+			Sequential(End[int]{}).
+			Build().
+			Start(context.Background())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Messages dropped in the nested pipeline kept their slots")
+	}
+	if len(results) != 15 {
+		t.Errorf("Expected 15 odd messages, got %d", len(results))
+	}
+}
@@ -0,0 +1,49 @@
+package tesei
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedSource makes a rand.Source safe for concurrent use, as jobs of a run share it.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+func newRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// defaultRand is used by threads of pipelines without a seed.
+var defaultRand = newRand(time.Now().UnixNano())
+
+// Rand returns the random number generator of the stage, derived from the seed of Pipeline.WithSeed
+// and the position of the stage. Randomized jobs (sampling, jitter, shuffling) should draw from it
+// instead of the math/rand functions, so seeded runs are reproducible. It is safe for concurrent use,
+// though the draws of the workers of a FanOut stage interleave in any order.
+func (t *Thread) Rand() *rand.Rand {
+	if t.rng == nil {
+		return defaultRand
+	}
+	return t.rng
+}
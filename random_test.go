@@ -0,0 +1,108 @@
+package tesei_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// sample passes on about half of the messages, drawing from the run generator.
+type sample struct{}
+
+func (s sample) Run(ctx *tesei.Thread, in <-chan *tesei.Message[int], out chan<- *tesei.Message[int]) {
+	tesei.Filter(ctx, in, out, func(msg *tesei.Message[int]) bool {
+		return ctx.Rand().Float64() < 0.5
+	})
+}
+
+// run adds a collector to the pipeline, runs it and returns the messages coming out.
+func run[T any](t *testing.T, p *tesei.Pipeline[T]) []*tesei.Message[T] {
+	t.Helper()
+
+	var results []*tesei.Message[T]
+	_, err := p.
+		Sequential(tesei.Collect[T]{Items: &results}).
+		Sequential(tesei.End[T]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	return results
+}
+
+// sampled passes the numbers 0..99 through the stages added by build and returns the kept ones.
+func sampled(t *testing.T, build func(p *tesei.Pipeline[int]) *tesei.Pipeline[int]) []int {
+	t.Helper()
+
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+
+	kept := run(t, build(tesei.NewPipeline[int]().Sequential(tesei.Slice[int]{Items: items})))
+	result := make([]int, len(kept))
+	for i, msg := range kept {
+		result[i] = msg.Data
+	}
+	return result
+}
+
+func TestWithSeed(t *testing.T) {
+	seeded := func(seed int64) func(p *tesei.Pipeline[int]) *tesei.Pipeline[int] {
+		return func(p *tesei.Pipeline[int]) *tesei.Pipeline[int] {
+			return p.WithSeed(seed).Sequential(sample{})
+		}
+	}
+
+	first := sampled(t, seeded(42))
+	second := sampled(t, seeded(42))
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected identical samples for the same seed, got\n%v\n%v", first, second)
+	}
+	if len(first) == 0 || len(first) == 100 {
+		t.Errorf("Expected a partial sample, got %d of 100", len(first))
+	}
+
+	if other := sampled(t, seeded(7)); reflect.DeepEqual(first, other) {
+		t.Error("Expected different samples for different seeds")
+	}
+
+	// fail-fast stages draw from the same per-stage generators
+	failFast := sampled(t, func(p *tesei.Pipeline[int]) *tesei.Pipeline[int] {
+		return p.WithSeed(42).WithFailFast().Sequential(sample{})
+	})
+	if !reflect.DeepEqual(first, failFast) {
+		t.Errorf("Expected identical samples, got\n%v\n%v", first, failFast)
+	}
+
+	// nested pipelines, seeded or deriving from the outer stage, repeat too
+	for _, build := range []func(p *tesei.Pipeline[int]) *tesei.Pipeline[int]{
+		func(p *tesei.Pipeline[int]) *tesei.Pipeline[int] {
+			return p.Sequential(tesei.NewPipeline[int]().WithSeed(42).Sequential(sample{}).Build())
+		},
+		func(p *tesei.Pipeline[int]) *tesei.Pipeline[int] {
+			return p.WithSeed(42).Sequential(tesei.NewPipeline[int]().Sequential(sample{}, sample{}).Build())
+		},
+	} {
+		if a, b := sampled(t, build), sampled(t, build); !reflect.DeepEqual(a, b) {
+			t.Errorf("Expected identical nested samples, got\n%v\n%v", a, b)
+		}
+	}
+}
+
+func TestWithSeedConcurrentStages(t *testing.T) {
+	// two randomized stages run concurrently, their draws must not interleave
+	build := func(p *tesei.Pipeline[int]) *tesei.Pipeline[int] {
+		return p.WithSeed(42).Sequential(sample{}, sample{}, sample{})
+	}
+
+	first := sampled(t, build)
+	for range 20 {
+		if next := sampled(t, build); !reflect.DeepEqual(first, next) {
+			t.Fatalf("Expected identical samples, got\n%v\n%v", first, next)
+		}
+	}
+}
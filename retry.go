@@ -0,0 +1,106 @@
+package tesei
+
+import "time"
+
+// Retry is a job that runs each message through Job, and runs it again when the result has an error,
+// up to MaxAttempts times. Every attempt gets a clone of the original message; after the last attempt
+// the result passes on with its error. The number of attempts is stored in "attempts" metadata.
+// Job is run for one message at a time, so it should be a 1:1 job; use FanOut for concurrency.
+type Retry[T any] struct {
+	// Job is the job to retry.
+	Job Job[T]
+	// Transform is a transform function to retry, used instead of Job.
+	Transform func(*Message[T]) (*Message[T], error)
+	// MaxAttempts is the maximal number of attempts. Defaults to 3.
+	MaxAttempts int
+	// Backoff returns the pause before the given retry, starting from 1. Defaults to no pause.
+	Backoff func(attempt int) time.Duration
+	// RetryIf reports whether an error is worth retrying. Defaults to all errors.
+	RetryIf func(error) bool
+}
+
+func (r Retry[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+
+	job := r.Job
+	if r.Transform != nil {
+		job = TransformJob[T]{Transform: r.Transform}
+	}
+	attempts := r.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+
+			results := []*Message[T]{msg}
+			if msg.Error == nil {
+				results = r.retry(ctx, job, msg, attempts)
+			}
+			for _, result := range results {
+				select {
+				case out <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// retry runs the job for the message until its results have no retryable error.
+func (r Retry[T]) retry(ctx *Thread, job Job[T], msg *Message[T], attempts int) []*Message[T] {
+	var results []*Message[T]
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 && r.Backoff != nil {
+			timer := time.NewTimer(r.Backoff(attempt - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return results
+			case <-timer.C:
+			}
+		}
+
+		results = runOne(ctx, job, msg.Clone())
+		for _, result := range results {
+			result.Metadata["attempts"] = attempt
+		}
+		if !r.failed(results) {
+			break
+		}
+	}
+	return results
+}
+
+// failed reports whether any of the results has a retryable error.
+func (r Retry[T]) failed(results []*Message[T]) bool {
+	for _, result := range results {
+		if result.Error != nil && (r.RetryIf == nil || r.RetryIf(result.Error)) {
+			return true
+		}
+	}
+	return false
+}
+
+// runOne runs the job for a single message and returns its output.
+func runOne[T any](ctx *Thread, job Job[T], msg *Message[T]) []*Message[T] {
+	in := make(chan *Message[T], 1)
+	out := make(chan *Message[T], 1)
+	in <- msg
+	close(in)
+	go job.Run(ctx, in, out)
+
+	var results []*Message[T]
+	for result := range out {
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,120 @@
+package tesei
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	errTransient = errors.New("transient")
+	errFatal     = errors.New("fatal")
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		err      error
+		attempts int
+		calls    int32
+		minTime  time.Duration
+		success  bool
+	}{
+		{"Success after retries", 2, errTransient, 3, 3, 30 * time.Millisecond, true},
+		{"Attempts exhausted", 5, errTransient, 3, 3, 30 * time.Millisecond, false},
+		{"Not retryable", 5, errFatal, 1, 1, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			retry := Retry[string]{
+				Transform: func(msg *Message[string]) (*Message[string], error) {
+					if atomic.AddInt32(&calls, 1) <= int32(tt.failures) {
+						return msg, tt.err
+					}
+					msg.Data += "!"
+					return msg, nil
+				},
+				MaxAttempts: 3,
+				// 10ms, then 20ms
+				Backoff: func(attempt int) time.Duration { return time.Duration(attempt) * 10 * time.Millisecond },
+				RetryIf: func(err error) bool { return errors.Is(err, errTransient) },
+			}
+
+			var results []*Message[string]
+			start := time.Now()
+			_, err := NewPipeline[string]().
+				Sequential(Slice[string]{Items: []string{"a"}}).
+				Sequential(retry).
+				Sequential(Collect[string]{Items: &results}).
+				Sequential(End[string]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+			elapsed := time.Since(start)
+
+			if calls != tt.calls {
+				t.Errorf("Expected %d calls, got %d", tt.calls, calls)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %d messages, want 1", len(results))
+			}
+			msg := results[0]
+			if msg.Metadata["attempts"] != tt.attempts {
+				t.Errorf("attempts = %v, want %d", msg.Metadata["attempts"], tt.attempts)
+			}
+			if tt.success && (msg.Error != nil || msg.Data != "a!") {
+				t.Errorf("Expected a successful result, got %q, %v", msg.Data, msg.Error)
+			}
+			if !tt.success && !errors.Is(msg.Error, tt.err) {
+				t.Errorf("Expected the last error %v, got %v", tt.err, msg.Error)
+			}
+			if elapsed < tt.minTime {
+				t.Errorf("Expected the backoff to take %v, got %v", tt.minTime, elapsed)
+			}
+			if tt.minTime == 0 && elapsed > 20*time.Millisecond {
+				t.Errorf("Expected no backoff for a non-retryable error, got %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestRetryJob(t *testing.T) {
+	var calls int32
+	job := JobFunc[string](func(ctx *Thread, in <-chan *Message[string], out chan<- *Message[string]) {
+		defer close(out)
+		for msg := range in {
+			// the job changes the message before failing, each attempt starts from the original
+			msg.Data += "?"
+			if atomic.AddInt32(&calls, 1) == 1 {
+				msg.WithError(errTransient, "job")
+			}
+			out <- msg
+		}
+	})
+
+	var results []*Message[string]
+	_, err := NewPipeline[string]().
+		Sequential(Slice[string]{Items: []string{"a"}}).
+		Sequential(Retry[string]{Job: job}).
+		Sequential(Collect[string]{Items: &results}).
+		Sequential(End[string]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Error != nil || results[0].Data != "a?" {
+		t.Fatalf("Expected one successful result, got %v", results)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
@@ -0,0 +1,67 @@
+package tesei
+
+// RouteJob is a job that sends each message to one of the branches, chosen by Selector,
+// and merges the outputs of all branches back into a single output, in no particular order.
+// Every branch gets its input closed when the input of the job is closed, even if it never got a message,
+// and the output is closed once all branches have closed theirs.
+type RouteJob[T any] struct {
+	// Selector returns the index of the branch for the message.
+	// A negative or out of range index means the message matches no branch.
+	Selector func(*Message[T]) int
+	// Branches are the jobs, usually built pipelines, messages are routed to.
+	Branches []Job[T]
+	// PassUnmatched sends the messages matching no branch to the output unchanged, instead of dropping them.
+	PassUnmatched bool
+}
+
+func (r RouteJob[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	inputs := make([]chan *Message[T], len(r.Branches))
+	outputs := make([]chan *Message[T], len(r.Branches)+1)
+	for i, branch := range r.Branches {
+		inputs[i] = make(chan *Message[T], 1)
+		outputs[i] = make(chan *Message[T], 1)
+		go branch.Run(ctx, inputs[i], outputs[i])
+	}
+	unmatched := make(chan *Message[T], 1)
+	outputs[len(r.Branches)] = unmatched
+
+	go r.dispatch(ctx, in, inputs, unmatched)
+	manyToOne(ctx, outputs, out)
+}
+
+// dispatch sends the messages to the branch inputs and closes all of them when the input is closed.
+func (r RouteJob[T]) dispatch(ctx *Thread, in <-chan *Message[T], inputs []chan *Message[T], unmatched chan *Message[T]) {
+	defer func() {
+		for _, ch := range inputs {
+			close(ch)
+		}
+		close(unmatched)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+
+			var target chan *Message[T]
+			if i := r.Selector(msg); i >= 0 && i < len(inputs) {
+				target = inputs[i]
+			} else if r.PassUnmatched {
+				target = unmatched
+			} else {
+				ctx.Release(msg.ID)
+				continue
+			}
+
+			select {
+			case target <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package tesei
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func tagBranch(tag string) Executor[int] {
+	return NewPipeline[int]().
+		Sequential(TransformJob[int]{
+			Transform: func(msg *Message[int]) (*Message[int], error) {
+				msg.Metadata["branch"] = tag
+				return msg, nil
+			},
+		}).
+		Build()
+}
+
+func TestRoute(t *testing.T) {
+	// 0 goes to the first branch, multiples of 5 to the second, negative numbers match no branch
+	selector := func(msg *Message[int]) int {
+		switch {
+		case msg.Data < 0:
+			return -1
+		case msg.Data%5 == 0:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	tests := []struct {
+		name          string
+		items         []int
+		passUnmatched bool
+		expected      map[string][]int
+	}{
+		{
+			name:     "Uneven distribution",
+			items:    []int{1, 2, 3, 4, 5, 6, 7, 8, 9},
+			expected: map[string][]int{"a": {1, 2, 3, 4, 6, 7, 8, 9}, "b": {5}},
+		},
+		{
+			name:     "Branch without messages",
+			items:    []int{1, 2, 3},
+			expected: map[string][]int{"a": {1, 2, 3}},
+		},
+		{
+			name:     "Unmatched dropped",
+			items:    []int{-1, 1, -2, 10},
+			expected: map[string][]int{"a": {1}, "b": {10}},
+		},
+		{
+			name:          "Unmatched passed",
+			items:         []int{-1, 1, -2, 10},
+			passUnmatched: true,
+			expected:      map[string][]int{"": {-2, -1}, "a": {1}, "b": {10}},
+		},
+		{
+			name:     "Empty input",
+			items:    nil,
+			expected: map[string][]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*Message[int]
+			_, err := NewPipeline[int]().
+				Sequential(Slice[int]{Items: tt.items}).
+				Sequential(RouteJob[int]{
+					Selector:      selector,
+					Branches:      []Job[int]{tagBranch("a"), tagBranch("b")},
+					PassUnmatched: tt.passUnmatched,
+				}).
+				Sequential(Collect[int]{Items: &results}).
+				Sequential(End[int]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			got := map[string][]int{}
+			for _, msg := range results {
+				tag, _ := msg.Metadata["branch"].(string)
+				got[tag] = append(got[tag], msg.Data)
+			}
+			for tag := range got {
+				slices.Sort(got[tag])
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected branches %v, got %v", tt.expected, got)
+			}
+			for tag, items := range tt.expected {
+				if !slices.Equal(got[tag], items) {
+					t.Errorf("branch %q: expected %v, got %v", tag, items, got[tag])
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineRoute(t *testing.T) {
+	var results []*Message[int]
+	_, err := NewPipeline[int]().
+		Sequential(Slice[int]{Items: []int{1, 2, 3, 4, 5, -6}}).
+		Route(func(msg *Message[int]) int {
+			if msg.Data < 0 {
+				return -1
+			}
+			return msg.Data % 2
+		}, tagBranch("even"), tagBranch("odd")).
+		Sequential(Collect[int]{Items: &results}).
+		Sequential(End[int]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	if len(results) != 5 {
+		t.Fatalf("expected 5 messages, the unmatched one dropped, got %d", len(results))
+	}
+	for _, msg := range results {
+		expected := []string{"even", "odd"}[msg.Data%2]
+		if msg.Metadata["branch"] != expected {
+			t.Errorf("message %d: expected branch %s, got %v", msg.Data, expected, msg.Metadata["branch"])
+		}
+	}
+}
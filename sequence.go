@@ -0,0 +1,88 @@
+package tesei
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Sequence is a job that numbers passing messages in the order they arrive, starting at 1,
+// and stores the number in metadata. Numbers are counted per group, if Group is set.
+// The numbers come from Counter, which must be set, by NewSequence or as &SequenceCounter{};
+// a Sequence without it fails the run with ErrInvalidPipeline.
+type Sequence[T any] struct {
+	// Key is the metadata key to store the number in. Defaults to "seq".
+	Key string
+	// Group is the metadata key whose value defines the group of the message, like "split_id".
+	// Empty means a single sequence for all messages.
+	Group string
+	// Width pads the number with zeros to the given width, "001" for 3, and stores it as a string.
+	// Without it the number is stored as an int.
+	Width int
+	// Counter hands out the numbers. One counter numbers messages without gaps or repeats,
+	// whether it serves a single stage, the workers of a FanOut or several stages.
+	Counter *SequenceCounter
+}
+
+// NewSequence returns a Sequence storing the numbers in the metadata key, counting from 1.
+// The counter continues where it stopped when the job is run again, build a new job to restart the numbering.
+func NewSequence[T any](key string) Sequence[T] {
+	return Sequence[T]{Key: key, Counter: &SequenceCounter{}}
+}
+
+// SequenceCounter holds the last numbers of a Sequence per group. It is safe for concurrent use.
+// The zero value is ready to use.
+type SequenceCounter struct {
+	mu     sync.Mutex
+	groups map[string]int
+}
+
+// next returns the next number of the group.
+func (c *SequenceCounter) next(group string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.groups == nil {
+		c.groups = make(map[string]int)
+	}
+	c.groups[group]++
+	return c.groups[group]
+}
+
+func (s Sequence[T]) check() error {
+	if s.Counter == nil {
+		return errors.New("Sequence without Counter, build it with NewSequence")
+	}
+	return nil
+}
+
+func (s Sequence[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	if err := s.check(); err != nil {
+		close(out)
+		ctx.SetError(fmt.Errorf("%w: %w", ErrInvalidPipeline, err))
+		return
+	}
+
+	key := s.Key
+	if key == "" {
+		key = "seq"
+	}
+	counter := s.Counter
+
+	TransformStage(ctx, in, out, "Sequence", func(msg *Message[T]) (*Message[T], error) {
+		group := ""
+		if s.Group != "" {
+			if value, ok := msg.Metadata[s.Group]; ok {
+				group = fmt.Sprint(value)
+			}
+		}
+
+		n := counter.next(group)
+		if s.Width > 0 {
+			msg.Metadata[key] = fmt.Sprintf("%0*d", s.Width, n)
+		} else {
+			msg.Metadata[key] = n
+		}
+		return msg, nil
+	})
+}
@@ -0,0 +1,118 @@
+package tesei_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestSequence(t *testing.T) {
+	var items []string
+	for i := 0; i < 5; i++ {
+		items = append(items, fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i))
+	}
+	items = append(items, "b5")
+
+	var mu sync.Mutex
+	seqs := map[string][]string{}
+	_, err := tesei.NewPipeline[string]().
+		Sequential(tesei.Slice[string]{Items: items}).
+		Sequential(tesei.SetMetaData[string]{Key: "group", Handler: func(msg *tesei.Message[string]) any {
+			return msg.Data[:1]
+		}}).
+		FanOut(tesei.Sequence[string]{Group: "group", Width: 3, Counter: &tesei.SequenceCounter{}}, 4).
+		Sequential(tesei.JobFunc[string](func(ctx *tesei.Thread, in <-chan *tesei.Message[string], out chan<- *tesei.Message[string]) {
+			tesei.Filter(ctx, in, out, func(msg *tesei.Message[string]) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				group := msg.Metadata["group"].(string)
+				seqs[group] = append(seqs[group], msg.Metadata["seq"].(string))
+				return true
+			})
+		})).
+		Sequential(tesei.End[string]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"a": {"001", "002", "003", "004", "005"},
+		"b": {"001", "002", "003", "004", "005", "006"},
+	}
+	for _, s := range seqs {
+		sort.Strings(s)
+	}
+	if !reflect.DeepEqual(seqs, expected) {
+		t.Errorf("sequences = %v, want %v", seqs, expected)
+	}
+}
+
+func TestNewSequenceFanOut(t *testing.T) {
+	items := make([]string, 50)
+	for i := range items {
+		items[i] = fmt.Sprint(i)
+	}
+
+	var results []*tesei.Message[string]
+	_, err := tesei.NewPipeline[string]().
+		Sequential(tesei.Slice[string]{Items: items}).
+		FanOut(tesei.NewSequence[string]("n"), 4).
+		Sequential(tesei.Collect[string]{Items: &results}).
+		Sequential(tesei.End[string]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	var numbers []int
+	for _, msg := range results {
+		numbers = append(numbers, msg.Metadata["n"].(int))
+	}
+	sort.Ints(numbers)
+	for i, n := range numbers {
+		if n != i+1 {
+			t.Fatalf("numbers = %v, want 1 to %d without repeats", numbers, len(items))
+		}
+	}
+}
+
+func TestSequenceWithoutCounter(t *testing.T) {
+	p := tesei.NewPipeline[string]().
+		Sequential(tesei.Slice[string]{Items: []string{"a", "b"}}).
+		FanOut(tesei.Sequence[string]{Key: "n"}, 4).
+		Sequential(tesei.End[string]{})
+
+	if err := p.Validate(); !errors.Is(err, tesei.ErrInvalidPipeline) {
+		t.Errorf("Validate() = %v, want ErrInvalidPipeline", err)
+	}
+	if _, err := p.Build().Start(context.Background()); !errors.Is(err, tesei.ErrInvalidPipeline) {
+		t.Errorf("Start() = %v, want ErrInvalidPipeline", err)
+	}
+}
+
+func ExampleSequence() {
+	p := tesei.NewPipeline[string]().
+		Sequential(tesei.Slice[string]{Items: []string{"intro", "setup", "usage"}}).
+		Sequential(tesei.NewSequence[string]("seq")).
+		Sequential(tesei.Log[string]{Print: func(msg *tesei.Message[string], err error) string {
+			return fmt.Sprint(msg.Metadata["seq"], " ", msg.Data)
+		}}).
+		Sequential(tesei.End[string]{}).
+		Build()
+
+	p.Start(context.Background())
+
+	// Output:
+	// 1 intro
+	// 2 setup
+	// 3 usage
+}
@@ -0,0 +1,133 @@
+package tesei
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SortKey is a job that computes a sort key for each message and stores it in metadata.
+// Use it with Sort to get a deterministic order of messages.
+type SortKey[T any] struct {
+	// Key is the metadata key to store the sort key in. Defaults to "sort_key".
+	Key string
+	// Func computes the sort key of a message.
+	Func func(msg *Message[T]) any
+}
+
+func (s SortKey[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	key := s.Key
+	if key == "" {
+		key = "sort_key"
+	}
+
+	TransformStage(ctx, in, out, "SortKey", func(msg *Message[T]) (*Message[T], error) {
+		msg.Metadata[key] = s.Func(msg)
+		return msg, nil
+	})
+}
+
+// Sort is a job that collects all messages and emits them in sorted order once the input is closed.
+// Messages are ordered by the metadata value under Key, or by Less if it is set.
+// Messages without the key are placed last, the original order is kept for equal keys.
+type Sort[T any] struct {
+	// Key is the metadata key to sort by. Defaults to "sort_key".
+	Key string
+	// Less is a custom comparison, it takes precedence over Key.
+	Less func(a, b *Message[T]) bool
+	// Desc reverses the order. Messages without the key stay last.
+	Desc bool
+}
+
+func (s Sort[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+
+	var messages []*Message[T]
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				s.sort(messages)
+				for _, msg := range messages {
+					select {
+					case out <- msg:
+					case <-ctx.Done():
+						return
+					}
+				}
+				return
+			}
+			messages = append(messages, msg)
+		}
+	}
+}
+
+func (s Sort[T]) sort(messages []*Message[T]) {
+	if s.Less != nil {
+		sort.SliceStable(messages, func(i, j int) bool {
+			if s.Desc {
+				return s.Less(messages[j], messages[i])
+			}
+			return s.Less(messages[i], messages[j])
+		})
+		return
+	}
+
+	key := s.Key
+	if key == "" {
+		key = "sort_key"
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		av, aok := messages[i].Metadata[key]
+		bv, bok := messages[j].Metadata[key]
+		if !aok || !bok {
+			// missing keys go last in both directions
+			return aok && !bok
+		}
+		if s.Desc {
+			return compareValues(bv, av) < 0
+		}
+		return compareValues(av, bv) < 0
+	})
+}
+
+// compareValues compares numbers numerically and other values by their string form.
+func compareValues(a, b any) int {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok && bok {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+
+	as, bs := fmt.Sprint(a), fmt.Sprint(b)
+	switch {
+	case as < bs:
+		return -1
+	case as > bs:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
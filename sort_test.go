@@ -0,0 +1,96 @@
+package tesei_test
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func ExampleSort() {
+	p := tesei.NewPipeline[string]().
+		Sequential(tesei.Slice[string]{Items: []string{"docs/B.md", "api/z.md", "docs/a.md", "API/index.md"}}).
+		Sequential(tesei.SortKey[string]{Func: func(msg *tesei.Message[string]) any {
+			return strings.ToLower(path.Clean(msg.Data))
+		}}).
+		Sequential(tesei.Sort[string]{}).
+		Sequential(tesei.Log[string]{Print: func(msg *tesei.Message[string], err error) string {
+			return msg.Data
+		}}).
+		Sequential(tesei.End[string]{}).
+		Build()
+
+	p.Start(context.Background())
+
+	// Output:
+	// API/index.md
+	// api/z.md
+	// docs/a.md
+	// docs/B.md
+}
+
+func ExampleSort_desc() {
+	p := tesei.NewPipeline[int]().
+		Sequential(tesei.Slice[int]{Items: []int{3, 10, 2}}).
+		Sequential(tesei.SortKey[int]{Key: "n", Func: func(msg *tesei.Message[int]) any { return msg.Data }}).
+		Sequential(tesei.Sort[int]{Key: "n", Desc: true}).
+		Sequential(tesei.Log[int]{Print: func(msg *tesei.Message[int], err error) string {
+			return fmt.Sprint(msg.Data)
+		}}).
+		Sequential(tesei.End[int]{}).
+		Build()
+
+	p.Start(context.Background())
+
+	// Output:
+	// 10
+	// 3
+	// 2
+}
+
+func TestSortMissingKey(t *testing.T) {
+	// 0 has no key, it goes last in both directions
+	setKey := tesei.TransformJob[int]{Transform: func(msg *tesei.Message[int]) (*tesei.Message[int], error) {
+		if msg.Data != 0 {
+			msg.Metadata["n"] = msg.Data
+		}
+		return msg, nil
+	}}
+
+	tests := []struct {
+		name     string
+		desc     bool
+		expected string
+	}{
+		{"Asc", false, "[2 3 10 0]"},
+		{"Desc", true, "[10 3 2 0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*tesei.Message[int]
+			_, err := tesei.NewPipeline[int]().
+				Sequential(tesei.Slice[int]{Items: []int{3, 0, 10, 2}}).
+				Sequential(setKey).
+				Sequential(tesei.Sort[int]{Key: "n", Desc: tt.desc}).
+				Sequential(tesei.Collect[int]{Items: &results}).
+				Sequential(tesei.End[int]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			var got []int
+			for _, msg := range results {
+				got = append(got, msg.Data)
+			}
+			if fmt.Sprint(got) != tt.expected {
+				t.Errorf("order = %v, want %s", got, tt.expected)
+			}
+		})
+	}
+}
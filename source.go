@@ -9,20 +9,52 @@ import (
 type End[T any] struct {
 	// Log determines if the job should log the completion of each message.
 	Log bool
+	// Summary prints the totals once the input is closed, instead of logging each message.
+	Summary bool
+	// Stats receives the totals for programmatic access. It should not be shared between FanOut workers.
+	Stats *Stats
+}
+
+// Stats holds the totals of messages consumed by End.
+type Stats struct {
+	// Total is the number of processed messages.
+	Total int
+	// Errors is the number of messages with an error.
+	Errors int
+	// ErrorIDs lists the IDs of messages with an error.
+	ErrorIDs []string
 }
 
 func (e End[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
 	defer close(out)
+
+	stats := e.Stats
+	if stats == nil {
+		stats = &Stats{}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case msg, ok := <-in:
 			if !ok {
+				if e.Summary {
+					fmt.Printf("summary: %d processed, %d failed\n", stats.Total, stats.Errors)
+					for _, id := range stats.ErrorIDs {
+						fmt.Println("failed:", id)
+					}
+				}
 				return
 			}
 
-			if e.Log {
+			stats.Total++
+			if msg.Error != nil {
+				stats.Errors++
+				stats.ErrorIDs = append(stats.ErrorIDs, msg.ID)
+			}
+
+			if e.Log && !e.Summary {
 				if msg.Error != nil {
 					fmt.Println("error:", msg.ID, msg.Error)
 				} else {
@@ -40,10 +72,14 @@ type Log[T any] struct {
 	Message string
 	// Print is a custom function to format the log message.
 	Print func(msg *Message[T], err error) string
+	// SampleEvery logs only every Nth message, messages with errors are always logged.
+	// Default is 0 (log all messages).
+	SampleEvery int
 }
 
 func (l Log[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
 	defer close(out)
+	count := 0
 	for {
 		select {
 		case <-ctx.Done():
@@ -53,18 +89,9 @@ func (l Log[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T])
 				return
 			}
 
-			if l.Print != nil {
-				fmt.Println(l.Print(msg, msg.Error))
-			} else {
-				if msg.Error != nil {
-					errorStr := msg.Error.Error()
-					if msg.ErrorStage != "" {
-						errorStr = msg.ErrorStage + ": " + errorStr
-					}
-					fmt.Println("[error]", l.Message, msg.ID, errorStr)
-				} else {
-					fmt.Println("[ok]", l.Message, msg.ID)
-				}
+			count++
+			if l.SampleEvery <= 1 || count%l.SampleEvery == 0 || msg.Error != nil {
+				l.log(msg)
 			}
 
 			select {
@@ -76,6 +103,23 @@ func (l Log[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T])
 	}
 }
 
+func (l Log[T]) log(msg *Message[T]) {
+	if l.Print != nil {
+		fmt.Println(l.Print(msg, msg.Error))
+		return
+	}
+
+	if msg.Error != nil {
+		errorStr := msg.Error.Error()
+		if msg.ErrorStage != "" {
+			errorStr = msg.ErrorStage + ": " + errorStr
+		}
+		fmt.Println("[error]", l.Message, msg.ID, errorStr)
+	} else {
+		fmt.Println("[ok]", l.Message, msg.ID)
+	}
+}
+
 // SetMetaData is a job that sets a metadata key-value pair on passing messages.
 type SetMetaData[T any] struct {
 	// Key is the metadata key to set.
@@ -87,7 +131,7 @@ type SetMetaData[T any] struct {
 }
 
 func (s SetMetaData[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
-	Transform(ctx, in, out, func(msg *Message[T]) (*Message[T], error) {
+	TransformStage(ctx, in, out, "SetMetaData", func(msg *Message[T]) (*Message[T], error) {
 		if s.Handler != nil {
 			msg.Metadata[s.Key] = s.Handler(msg)
 		} else {
@@ -97,6 +141,35 @@ func (s SetMetaData[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Mess
 	})
 }
 
+// Collect is a job that stores passing messages in a slice.
+// It is not safe for concurrent use, so it should not be placed in a FanOut stage.
+type Collect[T any] struct {
+	// Items receives the collected messages.
+	Items *[]*Message[T]
+}
+
+func (c Collect[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+
+			*c.Items = append(*c.Items, msg)
+
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
 // Slice is a source job that emits a slice of items as messages.
 type Slice[T any] struct {
 	Items []T
@@ -44,3 +44,61 @@ func ExampleSlice_string_async() {
 	// done: hello
 	// done: world
 }
+
+func ExampleEnd_summary() {
+	stats := &tesei.Stats{}
+	p := tesei.NewPipeline[int]().
+		Sequential(tesei.Slice[int]{Items: []int{1, 2, 3, 4, 5}}).
+		Sequential(tesei.TransformJob[int]{Transform: func(msg *tesei.Message[int]) (*tesei.Message[int], error) {
+			msg.ID = fmt.Sprintf("item-%d", msg.Data)
+			if msg.Data%2 == 0 {
+				return msg, fmt.Errorf("even")
+			}
+			return msg, nil
+		}}).
+		Sequential(tesei.End[int]{Log: true, Summary: true, Stats: stats}).
+		Build()
+
+	p.Start(context.Background())
+	fmt.Println(stats.Total, stats.Errors)
+
+	// Output:
+	// summary: 5 processed, 2 failed
+	// failed: item-2
+	// failed: item-4
+	// 5 2
+}
+
+func ExampleLog_sampleEvery() {
+	items := make([]int, 1000)
+	for i := range items {
+		items[i] = i + 1
+	}
+
+	p := tesei.NewPipeline[int]().
+		Sequential(tesei.Slice[int]{Items: items}).
+		Sequential(tesei.TransformJob[int]{Transform: func(msg *tesei.Message[int]) (*tesei.Message[int], error) {
+			if msg.Data == 42 || msg.Data == 555 {
+				return msg, fmt.Errorf("bad item")
+			}
+			return msg, nil
+		}}).
+		Sequential(tesei.Log[int]{SampleEvery: 250, Print: func(msg *tesei.Message[int], err error) string {
+			if err != nil {
+				return fmt.Sprintf("error at %d: %v", msg.Data, err)
+			}
+			return fmt.Sprintf("processed %d", msg.Data)
+		}}).
+		Sequential(tesei.End[int]{}).
+		Build()
+
+	p.Start(context.Background())
+
+	// Output:
+	// error at 42: bad item
+	// processed 250
+	// processed 500
+	// error at 555: bad item
+	// processed 750
+	// processed 1000
+}
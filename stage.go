@@ -2,7 +2,9 @@ package tesei
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"time"
 )
 
 type stage[T any] interface {
@@ -19,6 +21,8 @@ func (s *sequentialStage[T]) run(ctx *Thread, in <-chan *Message[T], out chan<-
 
 type parallelStage[T any] struct {
 	jobs []Job[T]
+	// buffer is the size of the queue of each branch, 0 delivers to the branches directly
+	buffer int
 }
 
 func (s *parallelStage[T]) run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
@@ -29,7 +33,18 @@ func (s *parallelStage[T]) run(ctx *Thread, in <-chan *Message[T], out chan<- *M
 		outChannels[i] = make(chan *Message[T], 1)
 	}
 
-	go oneToMany(ctx, in, inChannels)
+	if s.buffer > 0 {
+		// each branch is fed through its own queue, so a slow branch holds back the others
+		// only once it is buffer messages behind
+		queueChannels := make([]chan *Message[T], len(s.jobs))
+		for i := range queueChannels {
+			queueChannels[i] = make(chan *Message[T])
+			go queue(ctx, queueChannels[i], inChannels[i], s.buffer)
+		}
+		go oneToMany(ctx, in, queueChannels)
+	} else {
+		go oneToMany(ctx, in, inChannels)
+	}
 	go manyToOne(ctx, outChannels, out)
 
 	var wg sync.WaitGroup
@@ -45,6 +60,40 @@ func (s *parallelStage[T]) run(ctx *Thread, in <-chan *Message[T], out chan<- *M
 	wg.Wait()
 }
 
+type teeStage[T any] struct {
+	sinks []Job[T]
+}
+
+func (s *teeStage[T]) run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+
+	inChannels := make([]chan *Message[T], len(s.sinks))
+	for i := range inChannels {
+		inChannels[i] = make(chan *Message[T], 1)
+	}
+
+	go oneToMany(ctx, in, inChannels)
+
+	var wg sync.WaitGroup
+
+	for i, job := range s.sinks {
+		wg.Add(1)
+		go func(ind int, jb Job[T]) {
+			defer wg.Done()
+
+			// sinks may pass messages through, drain them so they never block
+			sinkOut := make(chan *Message[T], 1)
+			go func() {
+				for range sinkOut {
+				}
+			}()
+			jb.Run(ctx, inChannels[ind], sinkOut)
+		}(i, job)
+	}
+
+	wg.Wait()
+}
+
 type fanOutStage[T any] struct {
 	job   Job[T]
 	count int
@@ -70,6 +119,402 @@ func (s *fanOutStage[T]) run(ctx *Thread, in <-chan *Message[T], out chan<- *Mes
 	wg.Wait()
 }
 
+// Heuristics of the AutoFanOut stage.
+var (
+	// autoScaleUpAfter is how long a message may wait for a free worker before a new worker is started.
+	autoScaleUpAfter = 10 * time.Millisecond
+	// autoIdleAfter is how long a worker may wait for a message before it is stopped.
+	autoIdleAfter = 100 * time.Millisecond
+)
+
+type autoFanOutStage[T any] struct {
+	job Job[T]
+	min int
+	max int
+}
+
+// autoPool is the set of workers of one run of an AutoFanOut stage,
+// the stage may run several times at once, e.g. in a sub-pipeline of FanOut.
+type autoPool[T any] struct {
+	*autoFanOutStage[T]
+
+	mu      sync.Mutex
+	workers int
+}
+
+func (s *autoFanOutStage[T]) run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	// work is unbuffered, so a send succeeds only when a worker is waiting for a message
+	work := make(chan *Message[T])
+	var wg sync.WaitGroup
+
+	pool := &autoPool[T]{autoFanOutStage: s}
+	for range max(s.min, 1) {
+		pool.spawn(ctx, work, out, &wg)
+	}
+
+	pool.dispatch(ctx, in, work, out, &wg)
+	close(work)
+
+	wg.Wait()
+	close(out)
+}
+
+func (s *autoPool[T]) dispatch(ctx *Thread, in <-chan *Message[T], work chan *Message[T], out chan<- *Message[T], wg *sync.WaitGroup) {
+	timer := time.NewTimer(autoScaleUpAfter)
+	defer timer.Stop()
+
+	for {
+		var msg *Message[T]
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-in:
+			if !ok {
+				return
+			}
+			msg = m
+		}
+
+		select {
+		case work <- msg:
+			continue
+		default:
+		}
+
+		// all workers are busy, add one more each time the message keeps waiting
+		timer.Reset(autoScaleUpAfter)
+		for sent := false; !sent; {
+			select {
+			case <-ctx.Done():
+				return
+			case work <- msg:
+				sent = true
+			case <-timer.C:
+				s.mu.Lock()
+				grow := s.workers < s.max
+				s.mu.Unlock()
+				if grow {
+					s.spawn(ctx, work, out, wg)
+				}
+				timer.Reset(autoScaleUpAfter)
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+func (s *autoPool[T]) spawn(ctx *Thread, work <-chan *Message[T], out chan<- *Message[T], wg *sync.WaitGroup) {
+	s.mu.Lock()
+	s.workers++
+	s.mu.Unlock()
+
+	in := make(chan *Message[T])
+	res := make(chan *Message[T], 1)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.job.Run(ctx, in, res)
+	}()
+	go func() {
+		defer wg.Done()
+		for msg := range res {
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				// keep draining, so the job can exit
+			}
+		}
+	}()
+
+	go s.feed(ctx, work, in)
+}
+
+// feed passes messages from the shared work channel to a worker, until the work is done
+// or the worker stays idle while there are more than min workers.
+func (s *autoPool[T]) feed(ctx *Thread, work <-chan *Message[T], in chan<- *Message[T]) {
+	defer close(in)
+
+	idle := time.NewTimer(autoIdleAfter)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-work:
+			if !ok {
+				return
+			}
+			select {
+			case in <- msg:
+			case <-ctx.Done():
+				return
+			}
+		case <-idle.C:
+			s.mu.Lock()
+			retire := s.workers > s.min
+			if retire {
+				s.workers--
+			}
+			s.mu.Unlock()
+			if retire {
+				return
+			}
+		}
+
+		if !idle.Stop() {
+			select {
+			case <-idle.C:
+			default:
+			}
+		}
+		idle.Reset(autoIdleAfter)
+	}
+}
+
+// errOrderedReleased marks the notice a worker's Thread.Release puts in the job output,
+// after the results the job emitted so far; the stage never passes it on.
+var errOrderedReleased = errors.New("ordered fan-out release")
+
+type orderedFanOutStage[T any] struct {
+	job   Job[T]
+	count int
+	// window is the number of messages per worker the stage may hold at once.
+	window int
+}
+
+// orderedTicket is an input message with its position.
+type orderedTicket[T any] struct {
+	seq int
+	msg *Message[T]
+}
+
+// orderedResult is an output message of a worker, a nil message means the worker is done.
+type orderedResult[T any] struct {
+	worker int
+	msg    *Message[T]
+}
+
+func (s *orderedFanOutStage[T]) run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+
+	// a slot is taken for each dispatched message and released when its turn comes,
+	// which bounds the reassembly buffer
+	slots := make(chan struct{}, s.window*s.count)
+	work := make(chan orderedTicket[T])
+	results := make(chan orderedResult[T], s.count)
+	r := newReorder[T](s.count, slots)
+
+	go func() {
+		defer close(work)
+		for seq := 0; ; seq++ {
+			var msg *Message[T]
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-in:
+				if !ok {
+					return
+				}
+				msg = m
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case work <- orderedTicket[T]{seq: seq, msg: msg}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := range s.count {
+		jobIn := make(chan *Message[T])
+		jobOut := make(chan *Message[T], 1)
+
+		// the release notice goes through the job output, so it comes after the results emitted before it
+		worker := ctx.derive()
+		worker.release = func(id string) {
+			ctx.Release(id)
+			select {
+			case jobOut <- &Message[T]{ID: id, Error: errOrderedReleased}:
+			case <-ctx.Done():
+			}
+		}
+
+		// the position is recorded before the worker gets the message, so it is known when the results arrive
+		go func() {
+			defer close(jobIn)
+			for t := range work {
+				r.assign(w, t)
+				select {
+				case jobIn <- t.msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		go s.job.Run(worker, jobIn, jobOut)
+		go func() {
+			for msg := range jobOut {
+				select {
+				case results <- orderedResult[T]{worker: w, msg: msg}:
+				case <-ctx.Done():
+					// keep draining, so the job can exit
+				}
+			}
+			select {
+			case results <- orderedResult[T]{worker: w}:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	for running := s.count; running > 0; {
+		var res orderedResult[T]
+		select {
+		case <-ctx.Done():
+			return
+		case res = <-results:
+		}
+
+		var ready []*Message[T]
+		switch {
+		case res.msg == nil:
+			running--
+			ready = r.finish(res.worker)
+		case res.msg.Error == errOrderedReleased:
+			ready = r.release(res.msg.ID)
+		default:
+			ready = r.add(res.msg)
+		}
+
+		for _, msg := range ready {
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// reorder restores the input order of the messages processed by OrderedFanOut workers.
+type reorder[T any] struct {
+	mu       sync.Mutex
+	assigned [][]orderedTicket[T]
+
+	// results of the inputs being processed, by position
+	results map[int][]*Message[T]
+	// done marks the positions whose results are complete
+	done  map[int]bool
+	next  int
+	slots <-chan struct{}
+}
+
+func newReorder[T any](workers int, slots <-chan struct{}) *reorder[T] {
+	return &reorder[T]{
+		assigned: make([][]orderedTicket[T], workers),
+		results:  make(map[int][]*Message[T]),
+		done:     make(map[int]bool),
+		slots:    slots,
+	}
+}
+
+func (r *reorder[T]) assign(worker int, t orderedTicket[T]) {
+	r.mu.Lock()
+	r.assigned[worker] = append(r.assigned[worker], t)
+	r.mu.Unlock()
+}
+
+// find returns the worker and the index of the oldest input being processed with the id.
+func (r *reorder[T]) find(id string) (int, int, bool) {
+	worker, index, seq := 0, 0, -1
+	for w, queue := range r.assigned {
+		for i, t := range queue {
+			if t.msg.ID == id && (seq < 0 || t.seq < seq) {
+				worker, index, seq = w, i, t.seq
+			}
+		}
+	}
+	return worker, index, seq >= 0
+}
+
+// add stores a result. A message with the ID of an input completes it, a child of an input goes with it;
+// any other message, like a flushed buffer or an input emitted twice, has no position and is returned right away.
+func (r *reorder[T]) add(msg *Message[T]) []*Message[T] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if w, i, ok := r.find(msg.ID); ok {
+		seq := r.assigned[w][i].seq
+		r.results[seq] = append(r.results[seq], msg)
+		return r.complete(w, i)
+	}
+	if parent, ok := msg.Metadata["parent_id"].(string); ok {
+		if w, i, ok := r.find(parent); ok {
+			seq := r.assigned[w][i].seq
+			r.results[seq] = append(r.results[seq], msg)
+			return nil
+		}
+	}
+	return []*Message[T]{msg}
+}
+
+// release completes the input the job released, with the results emitted so far.
+func (r *reorder[T]) release(id string) []*Message[T] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if w, i, ok := r.find(id); ok {
+		return r.complete(w, i)
+	}
+	return nil
+}
+
+// finish completes the remaining inputs of a stopped worker.
+func (r *reorder[T]) finish(worker int) []*Message[T] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.assigned[worker] {
+		r.done[t.seq] = true
+	}
+	r.assigned[worker] = nil
+	return r.ready()
+}
+
+// complete marks an input as done and returns the messages whose turn has come.
+func (r *reorder[T]) complete(worker, index int) []*Message[T] {
+	queue := r.assigned[worker]
+	r.done[queue[index].seq] = true
+	r.assigned[worker] = append(queue[:index:index], queue[index+1:]...)
+	return r.ready()
+}
+
+// ready removes the results whose turn has come and releases their slots.
+func (r *reorder[T]) ready() []*Message[T] {
+	var ready []*Message[T]
+	for r.done[r.next] {
+		ready = append(ready, r.results[r.next]...)
+		delete(r.results, r.next)
+		delete(r.done, r.next)
+		r.next++
+		<-r.slots
+	}
+	return ready
+}
+
 func oneToMany[T any](ctx context.Context, in <-chan *Message[T], out []chan *Message[T]) {
 	defer func() {
 		for _, ch := range out {
@@ -106,6 +551,40 @@ func oneToMany[T any](ctx context.Context, in <-chan *Message[T], out []chan *Me
 	}
 }
 
+// queue forwards messages from in to out, buffering up to limit messages while out is busy.
+func queue[T any](ctx context.Context, in <-chan *Message[T], out chan<- *Message[T], limit int) {
+	defer close(out)
+
+	var pending []*Message[T]
+	for in != nil || len(pending) > 0 {
+		var send chan<- *Message[T]
+		var next *Message[T]
+		if len(pending) > 0 {
+			send = out
+			next = pending[0]
+		}
+		// a full queue stops reading, which blocks the sender
+		recv := in
+		if len(pending) >= limit {
+			recv = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			pending = append(pending, msg)
+		case send <- next:
+			pending[0] = nil
+			pending = pending[1:]
+		}
+	}
+}
+
 func manyToOne[T any](ctx context.Context, ins []chan *Message[T], out chan<- *Message[T]) {
 	var wg sync.WaitGroup
 	for _, ch := range ins {
@@ -2,6 +2,8 @@ package tesei
 
 import (
 	"context"
+	"errors"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -122,6 +124,119 @@ done:
 	}
 }
 
+func TestParallelStageSlowBranch(t *testing.T) {
+	fastDone := make(chan time.Time, 1)
+
+	fast := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+		defer close(out)
+		count := 0
+		for msg := range in {
+			out <- msg
+			count++
+			if count == 5 {
+				fastDone <- time.Now()
+			}
+		}
+	})
+
+	slow := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+		defer close(out)
+		for msg := range in {
+			time.Sleep(50 * time.Millisecond)
+			out <- msg
+		}
+	})
+
+	stage := &parallelStage[int]{jobs: []Job[int]{fast, slow}, buffer: 5}
+
+	in := make(chan *Message[int], 5)
+	out := make(chan *Message[int], 10)
+	for i := 0; i < 5; i++ {
+		in <- NewMessage(i)
+	}
+	close(in)
+
+	start := time.Now()
+	ctx := NewThread(context.Background(), 1)
+	go stage.run(ctx, in, out)
+
+	select {
+	case at := <-fastDone:
+		if at.Sub(start) > 100*time.Millisecond {
+			t.Errorf("Expected fast branch not to wait for the slow one, took %v", at.Sub(start))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Fast branch didn't finish")
+	}
+
+	count := 0
+	for range out {
+		count++
+	}
+	if count != 10 {
+		t.Errorf("Expected 10 results, got %d", count)
+	}
+}
+
+func TestParallelStageBranchBuffer(t *testing.T) {
+	// the stuck branch holds one message in the job and one in its input channel, plus the queued ones;
+	// the next message still reaches the fast branch before the broadcast blocks
+	tests := []struct {
+		name     string
+		buffer   int
+		expected int32
+	}{
+		{"Unbuffered", 0, 3},
+		{"Buffered", 2, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received int32
+			release := make(chan struct{})
+
+			fast := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+				defer close(out)
+				for msg := range in {
+					atomic.AddInt32(&received, 1)
+					out <- msg
+				}
+			})
+			stuck := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+				defer close(out)
+				for msg := range in {
+					<-release
+					out <- msg
+				}
+			})
+
+			stage := &parallelStage[int]{jobs: []Job[int]{fast, stuck}, buffer: tt.buffer}
+			in := make(chan *Message[int], 10)
+			out := make(chan *Message[int], 20)
+			for i := 0; i < 10; i++ {
+				in <- NewMessage(i)
+			}
+			close(in)
+
+			go stage.run(NewThread(context.Background(), 1), in, out)
+
+			time.Sleep(50 * time.Millisecond)
+			if got := atomic.LoadInt32(&received); got != tt.expected {
+				t.Errorf("Expected the fast branch to get %d messages ahead, got %d", tt.expected, got)
+			}
+
+			close(release)
+			count := 0
+			for range out {
+				count++
+			}
+			if count != 20 {
+				t.Errorf("Expected 20 results, got %d", count)
+			}
+		})
+	}
+}
+
 func TestFanOutStage(t *testing.T) {
 	var counter int32
 	var mu sync.Mutex
@@ -310,3 +425,404 @@ func TestFanOutStageClosesOutput(t *testing.T) {
 		t.Error("Expected output channel to be closed")
 	}
 }
+
+func TestTeeStage(t *testing.T) {
+	var count int32
+	var collected []*Message[int]
+
+	p := NewPipeline[int]().
+		Sequential(Slice[int]{Items: []int{1, 2, 3, 4, 5}}).
+		Tee(Collect[int]{Items: &collected}, CounterJob[int]{Count: &count}).
+		Build()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Start(context.Background())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected tee stage to complete")
+	}
+
+	if atomic.LoadInt32(&count) != 5 {
+		t.Errorf("Expected counter to see 5 messages, got %d", count)
+	}
+
+	if len(collected) != 5 {
+		t.Fatalf("Expected 5 collected messages, got %d", len(collected))
+	}
+	for i, msg := range collected {
+		if msg.Data != i+1 {
+			t.Errorf("Expected collected message %d to be %d, got %d", i, i+1, msg.Data)
+		}
+	}
+}
+
+func TestAutoFanOutStage(t *testing.T) {
+	var active, peak, processed int32
+	job := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+		defer close(out)
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		for msg := range in {
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&processed, 1)
+			out <- msg
+		}
+	})
+
+	stage := &autoFanOutStage[int]{job: job, min: 1, max: 4}
+
+	in := make(chan *Message[int])
+	out := make(chan *Message[int], 100)
+	ctx := NewThread(context.Background(), 1)
+
+	go func() {
+		defer close(in)
+		// a burst, a pause long enough to scale down, and a single message
+		for i := 0; i < 40; i++ {
+			in <- NewMessage(i)
+		}
+		time.Sleep(3 * autoIdleAfter)
+		if n := atomic.LoadInt32(&active); n != 1 {
+			t.Errorf("Expected to scale back to 1 worker when idle, got %d", n)
+		}
+		in <- NewMessage(40)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		stage.run(ctx, in, out)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AutoFanOut stage didn't finish")
+	}
+
+	if p := atomic.LoadInt32(&peak); p < 2 || p > 4 {
+		t.Errorf("Expected the burst to scale workers to 2..4, got %d", p)
+	}
+	if n := atomic.LoadInt32(&processed); n != 41 {
+		t.Errorf("Expected 41 processed messages, got %d", n)
+	}
+
+	count := 0
+	for range out {
+		count++
+	}
+	if count != 41 {
+		t.Errorf("Expected 41 results, got %d", count)
+	}
+}
+
+func TestAutoFanOutStageConcurrentRuns(t *testing.T) {
+	// each worker counts itself for the run of its first message, the data is the run number
+	var mu sync.Mutex
+	active := map[int]int{}
+	peak := map[int]int{}
+	job := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+		defer close(out)
+		run := -1
+		for msg := range in {
+			if run < 0 {
+				run = msg.Data
+				mu.Lock()
+				active[run]++
+				peak[run] = max(peak[run], active[run])
+				mu.Unlock()
+				defer func() {
+					mu.Lock()
+					active[run]--
+					mu.Unlock()
+				}()
+			}
+			time.Sleep(20 * time.Millisecond)
+			out <- msg
+		}
+	})
+
+	stage := &autoFanOutStage[int]{job: job, min: 1, max: 2}
+	ctx := NewThread(context.Background(), 1)
+
+	var wg sync.WaitGroup
+	counts := make([]int, 2)
+	for run := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// the first run gets a burst and scales to its maximum,
+			// the second one starts meanwhile and gets a message now and then
+			time.Sleep(time.Duration(run) * 50 * time.Millisecond)
+
+			in := make(chan *Message[int])
+			out := make(chan *Message[int], 100)
+			go func() {
+				defer close(in)
+				for range 30 {
+					in <- NewMessage(run)
+					if run == 1 {
+						time.Sleep(30 * time.Millisecond)
+					}
+				}
+			}()
+			stage.run(ctx, in, out)
+			for range out {
+				counts[run]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	for run := range 2 {
+		if counts[run] != 30 {
+			t.Errorf("Expected 30 results of run %d, got %d", run, counts[run])
+		}
+		if peak[run] > 2 {
+			t.Errorf("Expected at most 2 workers in run %d, got %d", run, peak[run])
+		}
+	}
+}
+
+func TestOrderedFanOutStage(t *testing.T) {
+	items := make([]int, 200)
+	for i := range items {
+		items[i] = i
+	}
+
+	tests := []struct {
+		name  string
+		delay func(n int) time.Duration
+		drop  func(n int) bool
+	}{
+		{"Random delays", func(int) time.Duration { return time.Duration(rand.Intn(5)) * time.Millisecond }, nil},
+		{"One slow message", func(n int) time.Duration {
+			if n == 10 {
+				return 100 * time.Millisecond
+			}
+			return 0
+		}, nil},
+		{"Dropped messages", func(int) time.Duration { return time.Duration(rand.Intn(3)) * time.Millisecond }, func(n int) bool { return n%7 == 0 }},
+		{"Dense drops", func(int) time.Duration { return time.Millisecond }, func(n int) bool { return n%3 == 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var active, peak int32
+			job := TransformJob[int]{
+				Transform: func(msg *Message[int]) (*Message[int], error) {
+					n := atomic.AddInt32(&active, 1)
+					defer atomic.AddInt32(&active, -1)
+					for p := atomic.LoadInt32(&peak); n > p && !atomic.CompareAndSwapInt32(&peak, p, n); p = atomic.LoadInt32(&peak) {
+					}
+
+					time.Sleep(tt.delay(msg.Data))
+					if tt.drop != nil && tt.drop(msg.Data) {
+						return nil, nil
+					}
+					return msg, nil
+				},
+			}
+
+			var results []*Message[int]
+			done := make(chan error)
+			go func() {
+				_, err := NewPipeline[int]().
+					Sequential(Slice[int]{Items: items}).
+					OrderedFanOut(job, 4).
+					Sequential(Collect[int]{Items: &results}).
+					Sequential(End[int]{}).
+					Build().
+					Start(context.Background())
+				done <- err
+			}()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Pipeline failed: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("OrderedFanOut stage didn't finish")
+			}
+
+			var expected []int
+			for _, n := range items {
+				if tt.drop == nil || !tt.drop(n) {
+					expected = append(expected, n)
+				}
+			}
+			if len(results) != len(expected) {
+				t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+			}
+			for i, msg := range results {
+				if msg.Data != expected[i] {
+					t.Fatalf("Expected %d at position %d, got %d", expected[i], i, msg.Data)
+				}
+			}
+			if p := atomic.LoadInt32(&peak); p < 2 {
+				t.Errorf("Expected the messages to be processed concurrently, peak %d", p)
+			}
+		})
+	}
+}
+
+func TestOrderedFanOutStageWindow(t *testing.T) {
+	// the job emits its inputs in pairs, so it holds one message until the next one comes
+	pairs := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+		defer close(out)
+		var held *Message[int]
+		for msg := range in {
+			if held == nil {
+				held = msg
+				continue
+			}
+			out <- held
+			out <- msg
+			held = nil
+		}
+		if held != nil {
+			out <- held
+		}
+	})
+
+	var results []*Message[int]
+	done := make(chan error)
+	go func() {
+		_, err := NewPipeline[int]().
+			WithOrderedWindow(2).
+			Sequential(Slice[int]{Items: []int{0, 1, 2, 3, 4, 5}}).
+			OrderedFanOut(pairs, 1).
+			Sequential(Collect[int]{Items: &results}).
+			Sequential(End[int]{}).
+			Build().
+			Start(context.Background())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Pipeline failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OrderedFanOut stage didn't finish")
+	}
+	for i, msg := range results {
+		if msg.Data != i {
+			t.Fatalf("Expected %d at position %d, got %d", i, i, msg.Data)
+		}
+	}
+	if len(results) != 6 {
+		t.Errorf("Expected 6 results, got %d", len(results))
+	}
+}
+
+func TestOrderedFanOutStageExpands(t *testing.T) {
+	items := make([]int, 50)
+	for i := range items {
+		items[i] = i
+	}
+
+	// every input is emitted after a child of it, odd ones are dropped
+	job := JobFunc[int](func(ctx *Thread, in <-chan *Message[int], out chan<- *Message[int]) {
+		defer close(out)
+		for msg := range in {
+			if msg.Data%2 == 1 {
+				ctx.Release(msg.ID)
+				continue
+			}
+			time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+			out <- msg.Child()
+			out <- msg
+		}
+	})
+
+	var results []*Message[int]
+	_, err := NewPipeline[int]().
+		Sequential(Slice[int]{Items: items}).
+		OrderedFanOut(job, 3).
+		Sequential(Collect[int]{Items: &results}).
+		Sequential(End[int]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	if len(results) != 50 {
+		t.Fatalf("Expected 50 results, got %d", len(results))
+	}
+	for i, msg := range results {
+		if msg.Data != i/2*2 {
+			t.Fatalf("Expected %d at position %d, got %d", i/2*2, i, msg.Data)
+		}
+	}
+}
+
+func TestOrderedFanOutStageDropsErrors(t *testing.T) {
+	items := make([]int, 10)
+	for i := range items {
+		items[i] = i
+	}
+
+	var failed []int
+	job := TransformJob[int]{
+		Transform: func(msg *Message[int]) (*Message[int], error) {
+			if msg.Data%2 == 0 {
+				return msg, errors.New("even")
+			}
+			return msg, nil
+		},
+	}
+
+	var results []*Message[int]
+	done := make(chan error)
+	go func() {
+		_, err := NewPipeline[int]().
+			Sequential(Slice[int]{Items: items}).
+			Sequential(job).
+			OrderedFanOut(DeadLetter[int]{Handler: func(msg *Message[int]) { failed = append(failed, msg.Data) }}, 1).
+			Sequential(Collect[int]{Items: &results}).
+			Sequential(End[int]{}).
+			Build().
+			Start(context.Background())
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Pipeline failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OrderedFanOut stage with a dropping job didn't finish")
+	}
+
+	if len(failed) != 5 {
+		t.Errorf("Expected 5 failed messages, got %d", len(failed))
+	}
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %d", len(results))
+	}
+	for i, msg := range results {
+		if msg.Data != i*2+1 {
+			t.Fatalf("Expected %d at position %d, got %d", i*2+1, i, msg.Data)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package tesei
+
+import "os"
+
+// Stamp is a job that records where a message was processed, for auditing runs sharded across machines.
+// It stores the host name under "host", the process ID under "pid", and Worker under "worker" when set.
+type Stamp[T any] struct {
+	// Worker is an ID of the worker, like a shard number given to the process.
+	Worker string
+	// Values are other run-level values to store, like a run ID.
+	Values map[string]any
+	// Prefix is prepended to the metadata keys, like "run_" for "run_host".
+	Prefix string
+}
+
+func (s Stamp[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	values := map[string]any{"pid": os.Getpid()}
+	if host, err := os.Hostname(); err == nil {
+		values["host"] = host
+	}
+	if s.Worker != "" {
+		values["worker"] = s.Worker
+	}
+	for k, v := range s.Values {
+		values[k] = v
+	}
+
+	TransformStage(ctx, in, out, "Stamp", func(msg *Message[T]) (*Message[T], error) {
+		for k, v := range values {
+			msg.Metadata[s.Prefix+k] = v
+		}
+		return msg, nil
+	})
+}
@@ -0,0 +1,59 @@
+package tesei
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestStamp(t *testing.T) {
+	host, _ := os.Hostname()
+	tests := []struct {
+		name     string
+		job      Stamp[string]
+		expected map[string]any
+	}{
+		{
+			name:     "Defaults",
+			job:      Stamp[string]{},
+			expected: map[string]any{"host": host, "pid": os.Getpid()},
+		},
+		{
+			name: "Worker and values",
+			job:  Stamp[string]{Worker: "shard-2", Values: map[string]any{"run": "r1"}, Prefix: "run_"},
+			expected: map[string]any{
+				"run_host": host, "run_pid": os.Getpid(), "run_worker": "shard-2", "run_run": "r1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*Message[string]
+			_, err := NewPipeline[string]().
+				Sequential(Slice[string]{Items: []string{"a", "b"}}).
+				Sequential(tt.job).
+				Sequential(Collect[string]{Items: &results}).
+				Sequential(End[string]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			if len(results) != 2 {
+				t.Fatalf("got %d messages, want 2", len(results))
+			}
+			for _, msg := range results {
+				for k, v := range tt.expected {
+					if msg.Metadata[k] != v {
+						t.Errorf("%s: %s = %v, want %v", msg.Data, k, msg.Metadata[k], v)
+					}
+				}
+				if _, ok := msg.Metadata["worker"]; ok {
+					t.Errorf("%s: unexpected worker without a prefix", msg.Data)
+				}
+			}
+		})
+	}
+}
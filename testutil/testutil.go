@@ -0,0 +1,40 @@
+// Package testutil provides helpers for testing jobs, shared by the tests of tesei packages
+// and usable in the tests of custom jobs.
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+// Run passes the messages of source through the jobs and returns the messages coming out of the last one.
+// It fails the test if the pipeline fails.
+func Run[T any](t testing.TB, source tesei.Job[T], jobs ...tesei.Job[T]) []*tesei.Message[T] {
+	t.Helper()
+
+	var results []*tesei.Message[T]
+	_, err := tesei.NewPipeline[T]().
+		Sequential(source).
+		Sequential(jobs...).
+		Sequential(tesei.Collect[T]{Items: &results}).
+		Sequential(tesei.End[T]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	return results
+}
+
+// RunOne is Run for the jobs emitting exactly one message. It fails the test for any other number of messages.
+func RunOne[T any](t testing.TB, source tesei.Job[T], jobs ...tesei.Job[T]) *tesei.Message[T] {
+	t.Helper()
+
+	results := Run(t, source, jobs...)
+	if len(results) != 1 {
+		t.Fatalf("got %d messages, want 1", len(results))
+	}
+	return results[0]
+}
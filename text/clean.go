@@ -12,7 +12,7 @@ import (
 type CleanAfterLLM struct{}
 
 func (c CleanAfterLLM) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+	tesei.TransformStage(ctx, in, out, "CleanAfterLLM", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
 		msg.Data.Content = c.cleanText(msg.Data.Content)
 		return msg, nil
 	})
@@ -0,0 +1,375 @@
+package text
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+	"gopkg.in/yaml.v3"
+)
+
+// FrontmatterFormat is the syntax of a front-matter block.
+type FrontmatterFormat int
+
+const (
+	// FrontmatterYAML is a YAML block between "---" lines.
+	FrontmatterYAML FrontmatterFormat = iota
+	// FrontmatterTOML is a TOML block between "+++" lines.
+	FrontmatterTOML
+	// FrontmatterJSON is a JSON object at the start of the content.
+	FrontmatterJSON
+)
+
+// ConvertFrontmatter is a job that re-emits the front-matter of the content in another format.
+// The source format is detected, the body is kept as is. Dates, numbers and booleans keep their types,
+// as far as the target format has them: JSON stores dates as strings, YAML stores local times of TOML as strings.
+// TOML has no null values, a block with them gets an ErrFrontmatter error when converted to TOML.
+type ConvertFrontmatter struct {
+	// To is the target format.
+	To FrontmatterFormat
+}
+
+func (c ConvertFrontmatter) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	tesei.TransformStage(ctx, in, out, "ConvertFrontmatter", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		format, block, body, ok := detectFrontmatter(msg.Data.Content)
+		if !ok || format == c.To {
+			return msg, nil
+		}
+
+		data, err := decodeFrontmatter(format, block)
+		if err != nil {
+			return msg, fmt.Errorf("%w: %w", ErrFrontmatter, err)
+		}
+		encoded, err := encodeFrontmatter(c.To, data)
+		if err != nil {
+			return msg, fmt.Errorf("%w: %w", ErrFrontmatter, err)
+		}
+
+		msg.Data.Content = encoded + body
+		return msg, nil
+	})
+}
+
+// detectFrontmatter returns the format and the text of the leading front-matter block, and the body after it.
+func detectFrontmatter(content string) (FrontmatterFormat, string, string, bool) {
+	if lines, body, ok := splitFrontmatter(content); ok {
+		return FrontmatterYAML, strings.Join(lines, "\n"), body, true
+	}
+	if block, body, ok := splitFence(content, "+++"); ok {
+		return FrontmatterTOML, block, body, true
+	}
+	if strings.HasPrefix(content, "{") {
+		dec := json.NewDecoder(strings.NewReader(content))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == nil {
+			// the object has to end its line, so a body starting with "{" isn't taken for front-matter
+			body := strings.TrimPrefix(content[dec.InputOffset():], "\r")
+			if body == "" || body[0] == '\n' {
+				return FrontmatterJSON, string(raw), strings.TrimPrefix(body, "\n"), true
+			}
+		}
+	}
+	return 0, "", content, false
+}
+
+// splitFence splits content into a leading block between fence lines and the body.
+func splitFence(content, fence string) (string, string, bool) {
+	first, rest, ok := strings.Cut(content, "\n")
+	if !ok || strings.TrimRight(first, "\r") != fence {
+		return "", content, false
+	}
+	for pos := 0; pos < len(rest); {
+		line, _, _ := strings.Cut(rest[pos:], "\n")
+		if strings.TrimRight(line, "\r") == fence {
+			end := min(pos+len(line)+1, len(rest))
+			return rest[:pos], rest[end:], true
+		}
+		pos += len(line) + 1
+	}
+	return "", content, false
+}
+
+func decodeFrontmatter(format FrontmatterFormat, block string) (*orderedMap, error) {
+	switch format {
+	case FrontmatterTOML:
+		return parseTOML(block)
+	case FrontmatterJSON:
+		dec := json.NewDecoder(strings.NewReader(block))
+		dec.UseNumber()
+		value, err := decodeJSON(dec)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := value.(*orderedMap)
+		if !ok {
+			return nil, fmt.Errorf("not an object")
+		}
+		return m, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(block), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return newOrderedMap(), nil
+	}
+	value, err := fromYAMLNode(doc.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	m, ok := value.(*orderedMap)
+	if !ok {
+		return nil, fmt.Errorf("not a mapping")
+	}
+	return m, nil
+}
+
+func encodeFrontmatter(format FrontmatterFormat, m *orderedMap) (string, error) {
+	switch format {
+	case FrontmatterTOML:
+		doc, err := formatTOML(m)
+		if err != nil {
+			return "", err
+		}
+		return "+++\n" + doc + "+++\n", nil
+	case FrontmatterJSON:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(toJSONValue(m)); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	if len(m.keys) > 0 {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(toYAMLNode(m)); err != nil {
+			return "", err
+		}
+		enc.Close()
+	}
+	buf.WriteString("---\n")
+	return buf.String(), nil
+}
+
+func fromYAMLNode(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return fromYAMLNode(node.Alias)
+	case yaml.MappingNode:
+		m := newOrderedMap()
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value, err := fromYAMLNode(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m.set(node.Content[i].Value, value)
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		items := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := fromYAMLNode(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	}
+
+	var err error
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		err = node.Decode(&b)
+		return b, err
+	case "!!int":
+		var n int64
+		if err = node.Decode(&n); err != nil {
+			// out of the int64 range, kept as a float like the large numbers of JSON
+			var f float64
+			if node.Decode(&f) == nil {
+				return f, nil
+			}
+		}
+		return n, err
+	case "!!float":
+		var f float64
+		err = node.Decode(&f)
+		return f, err
+	case "!!timestamp":
+		var t time.Time
+		err = node.Decode(&t)
+		return t, err
+	}
+	return node.Value, nil
+}
+
+func toYAMLNode(v any) *yaml.Node {
+	scalar := func(tag, value string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return scalar("!!null", "null")
+	case string:
+		return scalar("!!str", val)
+	case bool:
+		return scalar("!!bool", fmt.Sprint(val))
+	case int64:
+		return scalar("!!int", fmt.Sprint(val))
+	case float64:
+		switch {
+		case math.IsInf(val, 1):
+			return scalar("!!float", ".inf")
+		case math.IsInf(val, -1):
+			return scalar("!!float", "-.inf")
+		case math.IsNaN(val):
+			return scalar("!!float", ".nan")
+		}
+		return scalar("!!float", formatFloat(val))
+	case time.Time:
+		switch val.Location().String() {
+		case "time-local":
+			// YAML has no time of day without a date
+			return scalar("!!str", formatTime(val))
+		case "datetime-local":
+			// the form of a YAML timestamp without an offset
+			return scalar("!!timestamp", val.Format("2006-01-02 15:04:05.999999999"))
+		}
+		return scalar("!!timestamp", formatTime(val))
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range val {
+			child := toYAMLNode(item)
+			if child.Kind != yaml.ScalarNode {
+				node.Style = 0
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node
+	case *orderedMap:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range val.keys {
+			node.Content = append(node.Content, scalar("!!str", key), toYAMLNode(val.values[key]))
+		}
+		return node
+	}
+	return scalar("!!str", fmt.Sprint(v))
+}
+
+// decodeJSON reads a JSON value, keeping the order of object keys.
+func decodeJSON(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch val := tok.(type) {
+	case json.Delim:
+		if val == '[' {
+			items := []any{}
+			for dec.More() {
+				item, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			_, err := dec.Token()
+			return items, err
+		}
+
+		m := newOrderedMap()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			m.set(key.(string), value)
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			return n, nil
+		}
+		return val.Float64()
+	}
+	return tok, nil
+}
+
+// jsonObject is an ordered map prepared for encoding.
+type jsonObject struct {
+	keys   []string
+	values []any
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := enc.Encode(key); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(':')
+		if err := enc.Encode(o.values[i]); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// toJSONValue converts dates to strings, floats to numbers with a fraction and ordered maps to objects keeping the key order.
+func toJSONValue(v any) any {
+	switch val := v.(type) {
+	case time.Time:
+		return formatTime(val)
+	case float64:
+		if math.IsInf(val, 0) || math.IsNaN(val) {
+			// not representable in JSON
+			return nil
+		}
+		// keep "2.0" a float when read back
+		return json.Number(formatFloat(val))
+	case []any:
+		items := make([]any, len(val))
+		for i, item := range val {
+			items[i] = toJSONValue(item)
+		}
+		return items
+	case *orderedMap:
+		obj := jsonObject{keys: val.keys, values: make([]any, len(val.keys))}
+		for i, key := range val.keys {
+			obj.values[i] = toJSONValue(val.values[key])
+		}
+		return obj
+	}
+	return v
+}
@@ -0,0 +1,144 @@
+package text
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestConvertFrontmatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		to       FrontmatterFormat
+		input    string
+		expected string
+		err      error
+	}{
+		{
+			name: "YAML to TOML",
+			to:   FrontmatterTOML,
+			input: "---\ntitle: \"Hello: World\"\ndate: 2024-05-01\nupdated: 2024-05-02T10:30:00+02:00\n" +
+				"weight: 10\nratio: 1.0\ndraft: false\nversion: \"2\"\ntags: [go, docs]\n" +
+				"params:\n  author: Ann\n  cover: {src: a.png}\n---\n# Doc\n\n---\nText\n",
+			expected: "+++\ntitle = \"Hello: World\"\ndate = 2024-05-01\nupdated = 2024-05-02T10:30:00+02:00\n" +
+				"weight = 10\nratio = 1.0\ndraft = false\nversion = \"2\"\ntags = [\"go\", \"docs\"]\n\n" +
+				"[params]\nauthor = \"Ann\"\n\n[params.cover]\nsrc = \"a.png\"\n+++\n# Doc\n\n---\nText\n",
+		},
+		{
+			name: "TOML to JSON",
+			to:   FrontmatterJSON,
+			input: "+++\ntitle = 'Hello <World>' # comment\ndate = 2024-05-01\nweight = 1_000\nratio = 0.5\n" +
+				"aliases = [\n  \"/old\",\n  \"/older\",\n]\n\n[[menu]]\nname = \"main\"\n\n[[menu]]\nname = \"footer\"\n+++\nText\n",
+			expected: "{\n  \"title\": \"Hello <World>\",\n  \"date\": \"2024-05-01\",\n  \"weight\": 1000,\n  \"ratio\": 0.5,\n" +
+				"  \"aliases\": [\n    \"/old\",\n    \"/older\"\n  ],\n  \"menu\": [\n    {\n      \"name\": \"main\"\n    },\n" +
+				"    {\n      \"name\": \"footer\"\n    }\n  ]\n}\nText\n",
+		},
+		{
+			name:     "JSON to YAML",
+			to:       FrontmatterYAML,
+			input:    "{\n  \"title\": \"Doc\",\n  \"weight\": 2,\n  \"draft\": true,\n  \"version\": \"1.0\",\n  \"tags\": [\"a\"]\n}\nText\n",
+			expected: "---\ntitle: Doc\nweight: 2\ndraft: true\nversion: \"1.0\"\ntags: [a]\n---\nText\n",
+		},
+		{
+			name:     "Same format",
+			to:       FrontmatterYAML,
+			input:    "---\ntitle:   Doc # kept\n---\nText\n",
+			expected: "---\ntitle:   Doc # kept\n---\nText\n",
+		},
+		{
+			name:     "No front-matter",
+			to:       FrontmatterTOML,
+			input:    "# Doc\n",
+			expected: "# Doc\n",
+		},
+		{
+			name:     "TOML local dates and times",
+			to:       FrontmatterYAML,
+			input:    "+++\ndate = 2024-05-01\npublished = 2024-05-01T10:30:00\nat = 07:32:00\n+++\nText\n",
+			expected: "---\ndate: 2024-05-01\npublished: 2024-05-01 10:30:00\nat: 07:32:00\n---\nText\n",
+		},
+		{
+			name:     "TOML local dates and times to JSON",
+			to:       FrontmatterJSON,
+			input:    "+++\npublished = 2024-05-01T10:30:00.5\nat = 07:32:00\n+++\nText\n",
+			expected: "{\n  \"published\": \"2024-05-01T10:30:00.5\",\n  \"at\": \"07:32:00\"\n}\nText\n",
+		},
+		{
+			name:     "YAML integer beyond int64",
+			to:       FrontmatterJSON,
+			input:    "---\nid: 18446744073709551615\n---\nText\n",
+			expected: "{\n  \"id\": 1.8446744073709552e+19\n}\nText\n",
+		},
+		{
+			name:     "YAML null to TOML",
+			to:       FrontmatterTOML,
+			input:    "---\ntitle: Doc\nsummary:\n---\nText\n",
+			expected: "---\ntitle: Doc\nsummary:\n---\nText\n",
+			err:      ErrFrontmatter,
+		},
+		{
+			name:     "JSON not ending its line",
+			to:       FrontmatterYAML,
+			input:    "{\"a\": 1} is not front-matter\n",
+			expected: "{\"a\": 1} is not front-matter\n",
+		},
+		{
+			name:     "Invalid TOML",
+			to:       FrontmatterYAML,
+			input:    "+++\ntitle = \"Doc\ndate = 2024-05-01\n+++\nText\n",
+			expected: "+++\ntitle = \"Doc\ndate = 2024-05-01\n+++\nText\n",
+			err:      ErrFrontmatter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: tt.input}}}).
+				Sequential(ConvertFrontmatter{To: tt.to}).
+				Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			msg := result[0]
+			if msg.Data.Content != tt.expected {
+				t.Errorf("content = %q, want %q", msg.Data.Content, tt.expected)
+			}
+			if !errors.Is(msg.Error, tt.err) {
+				t.Errorf("error = %v, want %v", msg.Error, tt.err)
+			}
+		})
+	}
+}
+
+func TestConvertFrontmatterRoundTrip(t *testing.T) {
+	input := "---\ntitle: Doc\ndate: 2024-05-01T10:30:00Z\nweight: 3\nratio: 2.0\ntags: [a, b]\nparams:\n  nested: true\n---\nText\n"
+
+	content := input
+	for _, to := range []FrontmatterFormat{FrontmatterTOML, FrontmatterJSON, FrontmatterTOML, FrontmatterYAML} {
+		format, block, body, _ := detectFrontmatter(content)
+		data, err := decodeFrontmatter(format, block)
+		if err != nil {
+			t.Fatalf("decode %v: %v", format, err)
+		}
+		encoded, err := encodeFrontmatter(to, data)
+		if err != nil {
+			t.Fatalf("encode %v: %v", to, err)
+		}
+		content = encoded + body
+	}
+
+	// JSON has no dates, so the date comes back as a quoted string
+	expected := "---\ntitle: Doc\ndate: \"2024-05-01T10:30:00Z\"\nweight: 3\nratio: 2.0\ntags: [a, b]\nparams:\n  nested: true\n---\nText\n"
+	if content != expected {
+		t.Errorf("content = %q, want %q", content, expected)
+	}
+}
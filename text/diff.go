@@ -0,0 +1,314 @@
+package text
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// Diff is a job that compares the content with a baseline version stored in metadata.
+// It produces a unified diff or, if Words is set, a word-level diff.
+// Versions differing by more than 1000 lines or words are diffed as a replacement of everything
+// between their common start and end.
+type Diff struct {
+	// BaseKey is the metadata key holding the baseline content. Defaults to "original".
+	BaseKey string
+	// TargetKey is the metadata key to store the diff in.
+	// If empty, the content is replaced with the diff.
+	TargetKey string
+	// Words produces a word-level diff, marking changes as [-removed-]{+added+}.
+	Words bool
+	// Context is the number of unchanged lines around changes in a unified diff. Defaults to 3.
+	Context int
+}
+
+func (d Diff) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	tesei.TransformStage(ctx, in, out, "Diff", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		key := d.BaseKey
+		if key == "" {
+			key = "original"
+		}
+
+		base, ok := msg.Metadata[key].(string)
+		if !ok {
+			return msg, fmt.Errorf("diff: baseline %q not found in metadata", key)
+		}
+
+		var result string
+		if d.Words {
+			result = d.wordDiff(base, msg.Data.Content)
+		} else {
+			result = d.unifiedDiff(base, msg.Data.Content, msg.Data.Name)
+		}
+
+		if d.TargetKey != "" {
+			msg.Metadata[d.TargetKey] = result
+		} else {
+			msg.Data.Content = result
+		}
+		return msg, nil
+	})
+}
+
+type diffKind int
+
+const (
+	diffEqual diffKind = iota
+	diffDelete
+	diffInsert
+)
+
+type diffOp[T comparable] struct {
+	kind  diffKind
+	value T
+}
+
+// maxDiffEdits caps the edit distance diffSequences searches for, beyond it the sequences are replaced as a whole.
+// The search keeps a trace growing with the square of the distance.
+var maxDiffEdits = 1000
+
+// diffSequences returns the shortest edit script turning a into b, found with the Myers algorithm.
+// When more than maxDiffEdits edits are needed, all of a is deleted and all of b inserted.
+func diffSequences[T comparable](a, b []T) []diffOp[T] {
+	ops := make([]diffOp[T], 0, len(a)+len(b))
+
+	// the common prefix and suffix don't need a search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, diffOp[T]{diffEqual, a[prefix]})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, value := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp[T]{diffEqual, value})
+	}
+	return ops
+}
+
+// myersDiff searches the edit graph of a and b by the number of edits d.
+// v[k] is the furthest x reached on the diagonal k = x - y, trace[d] keeps v[-d..d] after the round d.
+func myersDiff[T comparable](a, b []T) []diffOp[T] {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+	for d := 0; d <= n+m; d++ {
+		if d > maxDiffEdits {
+			return replaceAll(a, b)
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				trace = append(trace, v[offset-d:offset+d+1])
+				return myersPath(a, b, trace)
+			}
+		}
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+	}
+	return nil
+}
+
+// myersPath walks the trace back from the end of both sequences and returns the edit script.
+func myersPath[T comparable](a, b []T, trace [][]int) []diffOp[T] {
+	var ops []diffOp[T]
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		// the point right after the edit, followed by a diagonal run of equal values
+		midX, midY := prevX+1, prevY
+		if prevK == k+1 {
+			midX, midY = prevX, prevY+1
+		}
+		for x > midX && y > midY {
+			x--
+			y--
+			ops = append(ops, diffOp[T]{diffEqual, a[x]})
+		}
+		if prevK == k+1 {
+			ops = append(ops, diffOp[T]{diffInsert, b[prevY]})
+		} else {
+			ops = append(ops, diffOp[T]{diffDelete, a[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, diffOp[T]{diffEqual, a[x]})
+	}
+
+	slices.Reverse(ops)
+	return ops
+}
+
+func replaceAll[T comparable](a, b []T) []diffOp[T] {
+	ops := make([]diffOp[T], 0, len(a)+len(b))
+	for _, value := range a {
+		ops = append(ops, diffOp[T]{diffDelete, value})
+	}
+	for _, value := range b {
+		ops = append(ops, diffOp[T]{diffInsert, value})
+	}
+	return ops
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+func (d Diff) unifiedDiff(base, content, name string) string {
+	ops := diffSequences(splitLines(base), splitLines(content))
+
+	context := d.Context
+	if context <= 0 {
+		context = 3
+	}
+
+	// line positions in the old and new versions before each operation
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	var changes []int
+	for i, op := range ops {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if op.kind != diffInsert {
+			aPos[i+1]++
+		}
+		if op.kind != diffDelete {
+			bPos[i+1]++
+		}
+		if op.kind != diffEqual {
+			changes = append(changes, i)
+		}
+	}
+
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- a/" + name + "\n")
+	sb.WriteString("+++ b/" + name + "\n")
+
+	for c := 0; c < len(changes); {
+		start := max(changes[c]-context, 0)
+
+		// extend the hunk while the next change is close enough to share context
+		last := changes[c]
+		c++
+		for c < len(changes) && changes[c]-last <= 2*context {
+			last = changes[c]
+			c++
+		}
+		end := min(last+context+1, len(ops))
+
+		aStart, aCount := aPos[start], aPos[end]-aPos[start]
+		bStart, bCount := bPos[start], bPos[end]-bPos[start]
+		if aCount > 0 {
+			aStart++
+		}
+		if bCount > 0 {
+			bStart++
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+
+		for _, op := range ops[start:end] {
+			switch op.kind {
+			case diffEqual:
+				sb.WriteString(" ")
+			case diffDelete:
+				sb.WriteString("-")
+			case diffInsert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(op.value + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// splitWords splits text into words and whitespace runs, so joining the parts restores the text.
+func splitWords(text string) []string {
+	var parts []string
+	start := 0
+	for i := 1; i <= len(text); i++ {
+		if i == len(text) || isSpace(text[i]) != isSpace(text[start]) {
+			parts = append(parts, text[start:i])
+			start = i
+		}
+	}
+	return parts
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (d Diff) wordDiff(base, content string) string {
+	ops := diffSequences(splitWords(base), splitWords(content))
+
+	var sb strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == diffEqual {
+			sb.WriteString(ops[i].value)
+			i++
+			continue
+		}
+
+		// group adjacent changes, so a replaced word reads as [-old-]{+new+}
+		var removed, added strings.Builder
+		for ; i < len(ops) && ops[i].kind != diffEqual; i++ {
+			if ops[i].kind == diffDelete {
+				removed.WriteString(ops[i].value)
+			} else {
+				added.WriteString(ops[i].value)
+			}
+		}
+		if removed.Len() > 0 {
+			sb.WriteString("[-" + removed.String() + "-]")
+		}
+		if added.Len() > 0 {
+			sb.WriteString("{+" + added.String() + "+}")
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,140 @@
+package text
+
+import (
+	"context"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/testutil"
+)
+
+// changed is a source of one file with its original content in metadata.
+func changed(base, content string) tesei.Job[files.TextFile] {
+	return tesei.JobFunc[files.TextFile](func(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+		defer close(out)
+		msg := tesei.NewMessage(files.TextFile{Name: "test.md", Content: content})
+		msg.Metadata["original"] = base
+		out <- msg
+	})
+}
+
+func TestDiff_AddedLine(t *testing.T) {
+	result := testutil.RunOne(t, changed("a\nb\nc\n", "a\nb\nnew\nc\n"), Diff{})
+
+	expected := "--- a/test.md\n+++ b/test.md\n@@ -1,3 +1,4 @@\n a\n b\n+new\n c\n"
+	if result.Data.Content != expected {
+		t.Errorf("Diff() = %q, want %q", result.Data.Content, expected)
+	}
+}
+
+func TestDiff_RemovedLine(t *testing.T) {
+	base := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	content := "1\n2\n3\n4\n6\n7\n8\n9\n"
+	result := testutil.RunOne(t, changed(base, content), Diff{Context: 1, TargetKey: "diff"})
+
+	expected := "--- a/test.md\n+++ b/test.md\n@@ -4,3 +4,2 @@\n 4\n-5\n 6\n"
+	if result.Metadata["diff"] != expected {
+		t.Errorf("Diff() = %q, want %q", result.Metadata["diff"], expected)
+	}
+	if result.Data.Content != content {
+		t.Errorf("Expected content to stay unchanged, got %q", result.Data.Content)
+	}
+}
+
+func TestDiff_WordChange(t *testing.T) {
+	result := testutil.RunOne(t, changed("The quick brown fox", "The quick red fox"), Diff{Words: true})
+
+	expected := "The quick [-brown-]{+red+} fox"
+	if result.Data.Content != expected {
+		t.Errorf("Diff() = %q, want %q", result.Data.Content, expected)
+	}
+}
+
+func TestDiff_MissingBaseline(t *testing.T) {
+	in := make(chan *tesei.Message[files.TextFile], 1)
+	out := make(chan *tesei.Message[files.TextFile], 1)
+
+	in <- tesei.NewMessage(files.TextFile{Name: "test.md", Content: "a"})
+	close(in)
+
+	ctx := tesei.NewThread(context.Background(), 10)
+	go Diff{}.Run(ctx, in, out)
+
+	result := <-out
+	if result.Error == nil {
+		t.Error("Expected error for missing baseline")
+	}
+}
+
+func TestDiffSequences(t *testing.T) {
+	// lcs is the reference length of the longest common subsequence
+	lcs := func(a, b []byte) int {
+		table := make([][]int, len(a)+1)
+		for i := range table {
+			table[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					table[i][j] = table[i+1][j+1] + 1
+				} else {
+					table[i][j] = max(table[i+1][j], table[i][j+1])
+				}
+			}
+		}
+		return table[0][0]
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	random := func() []byte {
+		s := make([]byte, rng.Intn(30))
+		for i := range s {
+			s[i] = "abc"[rng.Intn(3)]
+		}
+		return s
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := random(), random()
+		ops := diffSequences(a, b)
+
+		var gotA, gotB []byte
+		equal := 0
+		for _, op := range ops {
+			if op.kind != diffInsert {
+				gotA = append(gotA, op.value)
+			}
+			if op.kind != diffDelete {
+				gotB = append(gotB, op.value)
+			}
+			if op.kind == diffEqual {
+				equal++
+			}
+		}
+		if string(gotA) != string(a) || string(gotB) != string(b) {
+			t.Fatalf("%q -> %q: script gives %q -> %q", a, b, gotA, gotB)
+		}
+		if expected := lcs(a, b); equal != expected {
+			t.Fatalf("%q -> %q: %d equal values, want %d", a, b, equal, expected)
+		}
+	}
+}
+
+func TestDiffSequencesTooManyEdits(t *testing.T) {
+	defer func(n int) { maxDiffEdits = n }(maxDiffEdits)
+	maxDiffEdits = 2
+
+	ops := diffSequences([]string{"head", "a", "b", "c", "tail"}, []string{"head", "x", "b", "y", "tail"})
+	expected := []diffOp[string]{
+		{diffEqual, "head"},
+		{diffDelete, "a"}, {diffDelete, "b"}, {diffDelete, "c"},
+		{diffInsert, "x"}, {diffInsert, "b"}, {diffInsert, "y"},
+		{diffEqual, "tail"},
+	}
+	if !slices.Equal(ops, expected) {
+		t.Errorf("ops = %v, want %v", ops, expected)
+	}
+}
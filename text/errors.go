@@ -0,0 +1,14 @@
+package text
+
+import "errors"
+
+var (
+	// ErrDeadLinks is reported when the content has links which can't be reached.
+	ErrDeadLinks = errors.New("dead links")
+	// ErrHeadingLevel is reported when the content has headings deeper than the allowed level.
+	ErrHeadingLevel = errors.New("heading too deep")
+	// ErrFrontmatter is reported when the front-matter block can't be parsed.
+	ErrFrontmatter = errors.New("invalid front-matter")
+	// ErrYAML is reported when the content is not valid YAML.
+	ErrYAML = errors.New("invalid yaml")
+)
@@ -0,0 +1,102 @@
+package text
+
+import (
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// ExtractExcerpt is a job that stores the beginning of the content in metadata, as a title or a preview.
+// Front-matter, fenced code blocks and blank lines are skipped, the content is left as is.
+type ExtractExcerpt struct {
+	// Key is the metadata key to store the excerpt in. Defaults to "excerpt".
+	Key string
+	// Lines is the number of non-empty lines to take, joined with spaces. Defaults to 1.
+	Lines int
+	// Paragraph takes the first paragraph instead of a number of lines. A heading is a paragraph on its own.
+	Paragraph bool
+	// MaxChars cuts the excerpt at a word boundary and appends "…", 0 means no limit.
+	MaxChars int
+	// StripMarkdown removes markdown syntax, like heading markers, emphasis and links, from the excerpt.
+	StripMarkdown bool
+}
+
+func (e ExtractExcerpt) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	key := e.Key
+	if key == "" {
+		key = "excerpt"
+	}
+
+	tesei.TransformStage(ctx, in, out, "ExtractExcerpt", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		if excerpt := e.excerpt(msg.Data.Content); excerpt != "" {
+			msg.Metadata[key] = excerpt
+		}
+		return msg, nil
+	})
+}
+
+func (e ExtractExcerpt) excerpt(content string) string {
+	_, _, body, _ := detectFrontmatter(content)
+	limit := max(e.Lines, 1)
+
+	var lines []string
+	var fences files.FenceScanner
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+
+		if place := fences.Next(line); place != files.OutsideFence {
+			if place == files.OpeningFence && e.Paragraph && len(lines) > 0 {
+				break
+			}
+			continue
+		}
+
+		if line == "" {
+			if e.Paragraph && len(lines) > 0 {
+				break
+			}
+			continue
+		}
+
+		heading := stripHeadingPattern.MatchString(line)
+		if e.Paragraph && heading && len(lines) > 0 {
+			break
+		}
+		if e.StripMarkdown {
+			line = e.strip(line)
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		if e.Paragraph && heading || !e.Paragraph && len(lines) == limit {
+			break
+		}
+	}
+
+	return cutWords(strings.Join(lines, " "), e.MaxChars)
+}
+
+// strip removes markdown syntax from a line of text.
+func (e ExtractExcerpt) strip(line string) string {
+	line = quotePattern.ReplaceAllString(line, "")
+	if match := stripHeadingPattern.FindStringSubmatch(line); match != nil {
+		line = headingIDPattern.ReplaceAllString(match[1], "")
+	}
+	line = stripListPattern.ReplaceAllString(line, "")
+	return strings.TrimSpace(StripMarkdown{}.stripInline(line))
+}
+
+// cutWords shortens the text to at most limit characters, at a word boundary when possible.
+func cutWords(text string, limit int) string {
+	runes := []rune(text)
+	if limit <= 0 || len(runes) <= limit {
+		return text
+	}
+
+	cut := string(runes[:limit-1])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.") + "…"
+}
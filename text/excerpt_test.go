@@ -0,0 +1,78 @@
+package text
+
+import "testing"
+
+func TestExtractExcerpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		job      ExtractExcerpt
+		input    string
+		expected string
+	}{
+		{
+			name:     "Heading",
+			input:    "# Getting *Started*\n\nInstall the package.",
+			expected: "# Getting *Started*",
+		},
+		{
+			name:     "Heading stripped",
+			job:      ExtractExcerpt{StripMarkdown: true},
+			input:    "\n## Getting *Started* {#start}\n\nInstall the package.",
+			expected: "Getting Started",
+		},
+		{
+			name:     "Front-matter skipped",
+			job:      ExtractExcerpt{StripMarkdown: true},
+			input:    "---\ntitle: Guide\n---\n\nRead [the docs](/docs) first.\nThen run it.",
+			expected: "Read the docs first.",
+		},
+		{
+			name:     "TOML front-matter skipped",
+			input:    "+++\ntitle = \"Guide\"\n+++\nFirst line",
+			expected: "First line",
+		},
+		{
+			name:     "Code block skipped",
+			input:    "```go\npackage main\n```\n\nThe main package.",
+			expected: "The main package.",
+		},
+		{
+			name:     "Lines",
+			job:      ExtractExcerpt{Lines: 2},
+			input:    "# Title\n\nFirst line\nsecond line\nthird line",
+			expected: "# Title First line",
+		},
+		{
+			name:     "Paragraph",
+			job:      ExtractExcerpt{Paragraph: true, StripMarkdown: true},
+			input:    "```\ncode\n```\nThe **first** paragraph\nwraps here.\n\nThe second one.",
+			expected: "The first paragraph wraps here.",
+		},
+		{
+			name:     "Paragraph after heading",
+			job:      ExtractExcerpt{Paragraph: true},
+			input:    "# Title\nText",
+			expected: "# Title",
+		},
+		{
+			name:     "Max chars",
+			job:      ExtractExcerpt{Paragraph: true, MaxChars: 20},
+			input:    "A long paragraph, which is cut at a word boundary.",
+			expected: "A long paragraph…",
+		},
+		{
+			name:     "Only code",
+			input:    "---\ntitle: x\n---\n```\ncode\n```\n",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.job.excerpt(tt.input)
+			if result != tt.expected {
+				t.Errorf("excerpt = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package text
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// DeadLink is an external link which can't be reached.
+type DeadLink struct {
+	URL string
+	// Status is the HTTP status code, 0 if the request failed.
+	Status int
+	// Error describes the failed request.
+	Error string
+}
+
+// CheckExternalLinks is a job that checks http(s) links of markdown content with HEAD requests,
+// falling back to GET when HEAD fails. Links answering with a non-2xx status or not answering in time
+// are stored in metadata as []DeadLink. Each URL is checked once per message.
+type CheckExternalLinks struct {
+	// Key is the metadata key to store the dead links in. Defaults to "dead_links".
+	Key string
+	// Concurrency is the maximal number of requests at once. Defaults to 4.
+	Concurrency int
+	// Timeout limits each request. Defaults to 10s.
+	Timeout time.Duration
+	// Client is the HTTP client to use. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Cache reuses the results for URLs already checked in this run.
+	Cache bool
+	// Fail sets ErrDeadLinks as the message error when dead links are found.
+	Fail bool
+}
+
+func (c CheckExternalLinks) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	key := c.Key
+	if key == "" {
+		key = "dead_links"
+	}
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	slots := make(chan struct{}, concurrency)
+	var mu sync.Mutex
+	cache := make(map[string]*DeadLink)
+
+	tesei.TransformStage(ctx, in, out, "CheckExternalLinks", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		var urls []string
+		seen := make(map[string]bool)
+		for _, link := range extractLinks(msg.Data.Content) {
+			lower := strings.ToLower(link.URL)
+			if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+				continue
+			}
+			if !seen[link.URL] {
+				seen[link.URL] = true
+				urls = append(urls, link.URL)
+			}
+		}
+
+		results := make([]*DeadLink, len(urls))
+		var wg sync.WaitGroup
+		for i, url := range urls {
+			if c.Cache {
+				mu.Lock()
+				result, ok := cache[url]
+				mu.Unlock()
+				if ok {
+					results[i] = result
+					continue
+				}
+			}
+
+			wg.Add(1)
+			go func(i int, url string) {
+				defer wg.Done()
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				defer func() { <-slots }()
+
+				results[i] = c.check(ctx, url)
+				if c.Cache && ctx.Err() == nil {
+					mu.Lock()
+					cache[url] = results[i]
+					mu.Unlock()
+				}
+			}(i, url)
+		}
+		wg.Wait()
+
+		if ctx.Err() != nil {
+			return msg, ctx.Err()
+		}
+
+		dead := []DeadLink{}
+		for _, result := range results {
+			if result != nil {
+				dead = append(dead, *result)
+			}
+		}
+		msg.Metadata[key] = dead
+
+		if c.Fail && len(dead) > 0 {
+			urls := make([]string, len(dead))
+			for i, link := range dead {
+				urls[i] = link.URL
+			}
+			return msg, fmt.Errorf("%w: %s", ErrDeadLinks, strings.Join(urls, ", "))
+		}
+		return msg, nil
+	})
+}
+
+// check requests the URL, it returns nil if the link is alive.
+func (c CheckExternalLinks) check(ctx context.Context, url string) *DeadLink {
+	status, err := c.request(ctx, http.MethodHead, url)
+	if err == nil && status >= 200 && status < 300 {
+		return nil
+	}
+	if ctx.Err() == nil {
+		// some servers don't support HEAD
+		status, err = c.request(ctx, http.MethodGet, url)
+		if err == nil && status >= 200 && status < 300 {
+			return nil
+		}
+	}
+
+	if err != nil {
+		return &DeadLink{URL: url, Error: err.Error()}
+	}
+	return &DeadLink{URL: url, Status: status, Error: http.StatusText(status)}
+}
+
+func (c CheckExternalLinks) request(ctx context.Context, method, url string) (int, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
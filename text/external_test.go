@@ -0,0 +1,91 @@
+package text
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestCheckExternalLinks(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/live":
+			w.WriteHeader(http.StatusOK)
+		case "/nohead":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	content := "[live](" + server.URL + "/live), [again](" + server.URL + "/live), " +
+		"[missing](" + server.URL + "/missing), [get only](" + server.URL + "/nohead), " +
+		"[slow](" + server.URL + "/slow), [local](/docs/page.md)\n" +
+		"```\n[in code](" + server.URL + "/code)\n```\n"
+
+	in := make(chan *tesei.Message[files.TextFile], 2)
+	out := make(chan *tesei.Message[files.TextFile], 2)
+	in <- tesei.NewMessage(files.TextFile{Name: "a.md", Content: content})
+	in <- tesei.NewMessage(files.TextFile{Name: "b.md", Content: "[live](" + server.URL + "/live)"})
+	close(in)
+
+	ctx := tesei.NewThread(context.Background(), 10)
+	go CheckExternalLinks{Timeout: 50 * time.Millisecond, Cache: true, Fail: true}.Run(ctx, in, out)
+
+	first := <-out
+	dead, ok := first.Metadata["dead_links"].([]DeadLink)
+	if !ok {
+		t.Fatalf("Expected []DeadLink in metadata, got %T", first.Metadata["dead_links"])
+	}
+	if len(dead) != 2 {
+		t.Fatalf("Expected 2 dead links, got %+v", dead)
+	}
+	if dead[0].URL != server.URL+"/missing" || dead[0].Status != http.StatusNotFound {
+		t.Errorf("Expected the missing link with 404, got %+v", dead[0])
+	}
+	if dead[1].URL != server.URL+"/slow" || dead[1].Status != 0 || dead[1].Error == "" {
+		t.Errorf("Expected the slow link to time out, got %+v", dead[1])
+	}
+	if !errors.Is(first.Error, ErrDeadLinks) {
+		t.Errorf("Expected ErrDeadLinks, got %v", first.Error)
+	}
+
+	second := <-out
+	if second.Error != nil || len(second.Metadata["dead_links"].([]DeadLink)) != 0 {
+		t.Errorf("Expected no dead links, got %v %v", second.Error, second.Metadata["dead_links"])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests["HEAD /live"] != 1 {
+		t.Errorf("Expected the live link to be requested once, got %d", requests["HEAD /live"])
+	}
+	if requests["GET /nohead"] != 1 {
+		t.Errorf("Expected a GET fallback, got %d", requests["GET /nohead"])
+	}
+	if requests["HEAD /code"] != 0 {
+		t.Errorf("Expected links in code blocks to be skipped")
+	}
+}
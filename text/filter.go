@@ -0,0 +1,52 @@
+package text
+
+import (
+	"fmt"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// FilterByFrontmatter is a job that keeps only the files whose front-matter matches a predicate,
+// like skipping drafts of a static site. YAML, TOML and JSON front-matter is parsed, as by ConvertFrontmatter.
+// A block that can't be parsed gets an ErrFrontmatter error, the file is passed on.
+type FilterByFrontmatter struct {
+	// Match reports whether a file with the given front-matter is kept.
+	// Nested tables are maps, arrays are []any, integers are int64 and dates and times are time.Time.
+	Match func(map[string]any) bool
+	// Default keeps the files without front-matter.
+	Default bool
+}
+
+func (f FilterByFrontmatter) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	tesei.TransformStage(ctx, in, out, "FilterByFrontmatter", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		format, block, _, ok := detectFrontmatter(msg.Data.Content)
+		if !ok {
+			return keepIf(msg, f.Default), nil
+		}
+
+		data, err := decodeFrontmatter(format, block)
+		if err != nil {
+			return msg, fmt.Errorf("%w: %w", ErrFrontmatter, err)
+		}
+		return keepIf(msg, f.Match == nil || f.Match(data.plain())), nil
+	})
+}
+
+// keepIf returns the message, or nil to drop it.
+func keepIf(msg *tesei.Message[files.TextFile], keep bool) *tesei.Message[files.TextFile] {
+	if !keep {
+		return nil
+	}
+	return msg
+}
+
+// FrontmatterEquals returns a FilterByFrontmatter predicate matching the front-matter with the key set to value,
+// like FrontmatterEquals("published", true). Values are compared by their text, so 1 matches int64(1).
+func FrontmatterEquals(key string, value any) func(map[string]any) bool {
+	expected := fmt.Sprint(value)
+	return func(data map[string]any) bool {
+		v, ok := data[key]
+		return ok && fmt.Sprint(v) == expected
+	}
+}
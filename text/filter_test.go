@@ -0,0 +1,78 @@
+package text
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestFilterByFrontmatter(t *testing.T) {
+	source := []files.TextFile{
+		{Name: "draft.md", Content: "---\ntitle: Draft\ndraft: true\n---\nText\n"},
+		{Name: "published.md", Content: "---\ntitle: Post\npublished: true\n---\nText\n"},
+		{Name: "plain.md", Content: "# No front-matter\n"},
+		{Name: "toml.md", Content: "+++\ntitle = \"Post\"\npublished = true\n[params]\nweight = 2\n+++\nText\n"},
+	}
+	notDraft := func(data map[string]any) bool { return data["draft"] != true }
+
+	tests := []struct {
+		name     string
+		job      FilterByFrontmatter
+		expected []string
+	}{
+		{"Drafts filtered", FilterByFrontmatter{Match: notDraft}, []string{"published.md", "toml.md"}},
+		{"Default keeps files without front-matter", FilterByFrontmatter{Match: notDraft, Default: true}, []string{"published.md", "plain.md", "toml.md"}},
+		{"Published only", FilterByFrontmatter{Match: FrontmatterEquals("published", true)}, []string{"published.md", "toml.md"}},
+		{"Nested values", FilterByFrontmatter{Match: func(data map[string]any) bool {
+			params, _ := data["params"].(map[string]any)
+			return params["weight"] == int64(2)
+		}}, []string{"toml.md"}},
+		{"No predicate", FilterByFrontmatter{}, []string{"draft.md", "published.md", "toml.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: source}).
+				Sequential(tt.job).
+				Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			var names []string
+			for _, msg := range result {
+				names = append(names, msg.Data.Name)
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("files = %v, want %v", names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilterByFrontmatterInvalid(t *testing.T) {
+	var result []*tesei.Message[files.TextFile]
+	_, err := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: "+++\ntitle = \"Doc\n+++\nText\n"}}}).
+		Sequential(FilterByFrontmatter{Match: func(map[string]any) bool { return false }}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	if len(result) != 1 || !errors.Is(result[0].Error, ErrFrontmatter) {
+		t.Errorf("expected the file with ErrFrontmatter, got %v", result)
+	}
+}
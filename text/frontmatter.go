@@ -0,0 +1,271 @@
+package text
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// frontmatterEntry is a top-level key of a front-matter block with its raw lines,
+// nested values and comments stay attached to the preceding key.
+type frontmatterEntry struct {
+	key   string
+	lines []string
+}
+
+// splitFrontmatter splits content into the lines of a leading YAML front-matter block and the body.
+func splitFrontmatter(content string) ([]string, string, bool) {
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return nil, content, false
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		if line == "---" || line == "..." {
+			block := make([]string, 0, i-1)
+			for _, l := range lines[1:i] {
+				block = append(block, strings.TrimRight(l, "\r\n"))
+			}
+			return block, strings.Join(lines[i+1:], ""), true
+		}
+	}
+	return nil, content, false
+}
+
+func parseFrontmatter(lines []string) []frontmatterEntry {
+	var entries []frontmatterEntry
+	for _, line := range lines {
+		if key, ok := frontmatterKey(line); ok {
+			entries = append(entries, frontmatterEntry{key: key, lines: []string{line}})
+			continue
+		}
+		if len(entries) == 0 {
+			// leading comments or blank lines
+			entries = append(entries, frontmatterEntry{})
+		}
+		last := &entries[len(entries)-1]
+		last.lines = append(last.lines, line)
+	}
+	return entries
+}
+
+// frontmatterKey returns the key of a top-level "key: value" line.
+func frontmatterKey(line string) (string, bool) {
+	if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' || line[0] == '-' {
+		return "", false
+	}
+	key, _, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", false
+	}
+	return strings.Trim(strings.TrimSpace(key), `"'`), true
+}
+
+func formatFrontmatter(entries []frontmatterEntry, body string) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	for _, e := range entries {
+		for _, line := range e.lines {
+			sb.WriteString(line + "\n")
+		}
+	}
+	sb.WriteString("---\n")
+	sb.WriteString(body)
+	return sb.String()
+}
+
+// formatYAMLValue renders a value as an inline YAML scalar or flow collection.
+func formatYAMLValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return formatYAMLString(val)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(val)
+	case time.Time:
+		return formatTime(val)
+	case []string:
+		items := make([]string, len(val))
+		for i, s := range val {
+			items[i] = formatYAMLString(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []any:
+		items := make([]string, len(val))
+		for i, s := range val {
+			items[i] = formatYAMLValue(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			items[i] = formatYAMLString(k) + ": " + formatYAMLValue(val[k])
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+	return formatYAMLString(fmt.Sprint(v))
+}
+
+func formatYAMLString(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s, "\n\r\t\"") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.ContainsRune("-?:,[]{}#&*!|>'%@`", rune(s[0])) {
+		return strconv.Quote(s)
+	}
+
+	// strings which would be read back as another type
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// WriteFrontmatter is a job that writes metadata values into the front-matter block of the content.
+// Existing keys are updated in place, new keys are appended, other keys and the body are kept as is.
+// If the content has no front-matter, a new YAML block is created.
+// YAML blocks are edited line by line, so comments are kept. TOML and JSON blocks are decoded and
+// written back in their format, comments of TOML are lost. A block that can't be parsed gets an ErrFrontmatter error.
+type WriteFrontmatter struct {
+	// Keys are the metadata keys to write, in order. Keys missing in metadata are skipped.
+	Keys []string
+}
+
+func (w WriteFrontmatter) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	tesei.TransformStage(ctx, in, out, "WriteFrontmatter", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		format, block, body, ok := detectFrontmatter(msg.Data.Content)
+		if ok && format != FrontmatterYAML {
+			data, err := decodeFrontmatter(format, block)
+			if err != nil {
+				return msg, fmt.Errorf("%w: %w", ErrFrontmatter, err)
+			}
+			for _, key := range w.Keys {
+				if value, ok := msg.Metadata[key]; ok {
+					data.set(key, toOrderedValue(value))
+				}
+			}
+			encoded, err := encodeFrontmatter(format, data)
+			if err != nil {
+				return msg, fmt.Errorf("%w: %w", ErrFrontmatter, err)
+			}
+			msg.Data.Content = encoded + body
+			return msg, nil
+		}
+
+		lines, body, _ := splitFrontmatter(msg.Data.Content)
+		entries := parseFrontmatter(lines)
+
+		for _, key := range w.Keys {
+			value, ok := msg.Metadata[key]
+			if !ok {
+				continue
+			}
+
+			line := key + ": " + formatYAMLValue(value)
+			found := false
+			for i := range entries {
+				if entries[i].key == key {
+					// the old value is replaced, the comments and blank lines after it stay
+					entries[i].lines = append([]string{line}, entries[i].trailing()...)
+					found = true
+					break
+				}
+			}
+			if !found {
+				entries = append(entries, frontmatterEntry{key: key, lines: []string{line}})
+			}
+		}
+
+		msg.Data.Content = formatFrontmatter(entries, body)
+		return msg, nil
+	})
+}
+
+// trailing returns the blank and comment lines at the end of the entry, after its value.
+func (e frontmatterEntry) trailing() []string {
+	i := len(e.lines)
+	for i > 1 {
+		line := strings.TrimSpace(e.lines[i-1])
+		if line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		i--
+	}
+	return e.lines[i:]
+}
+
+// uintValue converts an unsigned integer to int64, values beyond its range are kept as decimal strings.
+func uintValue(v uint64) any {
+	if v > math.MaxInt64 {
+		return strconv.FormatUint(v, 10)
+	}
+	return int64(v)
+}
+
+// toOrderedValue converts a metadata value to the types of decoded front-matter.
+func toOrderedValue(v any) any {
+	switch val := v.(type) {
+	case nil, string, bool, int64, float64, time.Time:
+		return val
+	case int:
+		return int64(val)
+	case int8:
+		return int64(val)
+	case int16:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case uint:
+		return uintValue(uint64(val))
+	case uint8:
+		return int64(val)
+	case uint16:
+		return int64(val)
+	case uint32:
+		return int64(val)
+	case uint64:
+		return uintValue(val)
+	case float32:
+		return float64(val)
+	case []string:
+		items := make([]any, len(val))
+		for i, s := range val {
+			items[i] = s
+		}
+		return items
+	case []any:
+		items := make([]any, len(val))
+		for i, item := range val {
+			items[i] = toOrderedValue(item)
+		}
+		return items
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		m := newOrderedMap()
+		for _, k := range keys {
+			m.set(k, toOrderedValue(val[k]))
+		}
+		return m
+	}
+	return fmt.Sprint(v)
+}
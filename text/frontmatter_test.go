@@ -0,0 +1,145 @@
+package text
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestWriteFrontmatter(t *testing.T) {
+	meta := map[string]any{
+		"words":     120,
+		"hash":      "a1b2c3",
+		"generated": time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		"title":     "New: Title",
+		"tags":      []string{"go", "docs"},
+		"big":       uint64(math.MaxUint64),
+	}
+
+	tests := []struct {
+		name     string
+		keys     []string
+		input    string
+		expected string
+	}{
+		{
+			name:     "create",
+			keys:     []string{"words", "hash", "generated"},
+			input:    "# Doc\n\nText\n",
+			expected: "---\nwords: 120\nhash: a1b2c3\ngenerated: 2024-05-01\n---\n# Doc\n\nText\n",
+		},
+		{
+			name:     "merge into existing",
+			keys:     []string{"title", "words", "tags"},
+			input:    "---\n# comment\ntitle: Old\nauthor: Ann\nnested:\n  a: 1\nwords: 3\n---\n# Doc\n\n---\n\nText\n",
+			expected: "---\n# comment\ntitle: \"New: Title\"\nauthor: Ann\nnested:\n  a: 1\nwords: 120\ntags: [go, docs]\n---\n# Doc\n\n---\n\nText\n",
+		},
+		{
+			name:     "missing keys",
+			keys:     []string{"unknown"},
+			input:    "---\ntitle: Old\n---\nText",
+			expected: "---\ntitle: Old\n---\nText",
+		},
+		{
+			name:     "keep comments after an updated key",
+			keys:     []string{"tags", "words"},
+			input:    "---\ntags:\n  - old\n  - older\n# keep me\n\nwords: 3 # inline\n---\nText",
+			expected: "---\ntags: [go, docs]\n# keep me\n\nwords: 120\n---\nText",
+		},
+		{
+			name:     "toml",
+			keys:     []string{"title", "words"},
+			input:    "+++\ntitle = \"Old\"\ndraft = true\n+++\nText",
+			expected: "+++\ntitle = \"New: Title\"\ndraft = true\nwords = 120\n+++\nText",
+		},
+		{
+			name:     "uint64 beyond int64",
+			keys:     []string{"big"},
+			input:    "+++\ntitle = \"Old\"\n+++\nText",
+			expected: "+++\ntitle = \"Old\"\nbig = \"18446744073709551615\"\n+++\nText",
+		},
+		{
+			name:     "json",
+			keys:     []string{"title", "tags"},
+			input:    "{\"title\": \"Old\", \"draft\": true}\nText",
+			expected: "{\n  \"title\": \"New: Title\",\n  \"draft\": true,\n  \"tags\": [\n    \"go\",\n    \"docs\"\n  ]\n}\nText",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: tt.input}}}).
+				Sequential(tesei.TransformJob[files.TextFile]{Transform: func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+					for k, v := range meta {
+						msg.Metadata[k] = v
+					}
+					return msg, nil
+				}}).
+				Sequential(WriteFrontmatter{Keys: tt.keys}).
+				Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			if got := results[0].Data.Content; got != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWriteFrontmatterInvalid(t *testing.T) {
+	var results []*tesei.Message[files.TextFile]
+	_, err := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: "+++\ntitle = \n+++\nText"}}}).
+		Sequential(tesei.SetMetaData[files.TextFile]{Key: "words", Value: 1}).
+		Sequential(WriteFrontmatter{Keys: []string{"words"}}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+
+	var parseErr toml.ParseError
+	if !errors.Is(results[0].Error, ErrFrontmatter) || !errors.As(results[0].Error, &parseErr) {
+		t.Errorf("Expected ErrFrontmatter wrapping the parse error, got %v", results[0].Error)
+	}
+}
+
+func TestFormatYAMLValue(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{"plain text", "plain text"},
+		{"", `""`},
+		{"true", `"true"`},
+		{"42", `"42"`},
+		{"- item", `"- item"`},
+		{"a # b", `"a # b"`},
+		{3.5, "3.5"},
+		{false, "false"},
+		{time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), "2024-05-01T10:30:00Z"},
+		{map[string]any{"b": 1, "a": "x"}, "{a: x, b: 1}"},
+	}
+
+	for _, tt := range tests {
+		if got := formatYAMLValue(tt.value); got != tt.expected {
+			t.Errorf("formatYAMLValue(%v): expected %q, got %q", tt.value, tt.expected, got)
+		}
+	}
+}
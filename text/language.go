@@ -0,0 +1,112 @@
+package text
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// DetectLanguage is a job that detects the language of the content by its stop words,
+// and stores the ISO 639-1 code in metadata with a confidence from 0 to 1 under Key + "_confidence".
+// Short or mixed content gets a low confidence, and below MinConfidence the code is empty.
+// Code blocks are ignored. Supported languages: en, de, fr, es, it, pt, nl, ru.
+type DetectLanguage struct {
+	// Key is the metadata key to store the language code in. Defaults to "lang".
+	Key string
+	// MinConfidence is the confidence below which the language is unknown. Defaults to 0.3.
+	MinConfidence float64
+}
+
+var stopWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "is", "that", "it", "for", "with", "as", "was", "on", "are", "be",
+		"this", "by", "you", "not", "or", "have", "from", "at", "which", "an", "but", "they", "we", "can", "will"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "von", "sich", "des",
+		"auf", "für", "im", "dem", "auch", "es", "werden", "aus", "er", "hat", "dass", "sie", "nach", "wird", "bei", "oder"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "un", "une", "du", "que", "qui", "dans", "pour", "pas",
+		"sur", "au", "avec", "ce", "il", "sont", "par", "plus", "ne", "se", "nous", "vous", "cette", "aux", "être"},
+	"es": {"el", "la", "los", "las", "de", "y", "que", "en", "un", "una", "es", "por", "con", "no", "para",
+		"se", "del", "al", "lo", "como", "más", "pero", "sus", "ya", "este", "está", "son", "también", "muy", "hay"},
+	"it": {"il", "la", "di", "che", "e", "un", "una", "per", "non", "sono", "del", "della", "con", "si", "le",
+		"gli", "da", "in", "al", "è", "anche", "come", "questo", "più", "nel", "ma", "lo", "ha", "questa", "alla"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "do", "da", "em", "um", "uma", "para", "com", "não",
+		"por", "se", "no", "na", "mais", "dos", "das", "como", "mas", "ao", "é", "são", "foi", "também", "pelo"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "voor", "met", "die",
+		"in", "er", "ook", "aan", "als", "bij", "door", "maar", "om", "wordt", "worden", "naar", "hij", "ze", "wij", "nog"},
+	"ru": {"и", "в", "не", "на", "что", "с", "по", "это", "как", "он", "к", "из", "для", "но", "я",
+		"то", "все", "так", "его", "она", "же", "от", "бы", "у", "мы", "или", "был", "если", "уже", "только"},
+}
+
+// stopWordIndex maps a stop word to its languages
+var stopWordIndex = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range stopWords {
+		for _, word := range words {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+func (d DetectLanguage) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	key := d.Key
+	if key == "" {
+		key = "lang"
+	}
+	threshold := d.MinConfidence
+	if threshold <= 0 {
+		threshold = 0.3
+	}
+
+	tesei.TransformStage(ctx, in, out, "DetectLanguage", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		lang, confidence := detectLanguage(msg.Data.Content)
+		if confidence < threshold {
+			lang = ""
+		}
+		msg.Metadata[key] = lang
+		msg.Metadata[key+"_confidence"] = confidence
+		return msg, nil
+	})
+}
+
+// detectLanguage returns the most likely language and the confidence of the guess.
+func detectLanguage(content string) (string, float64) {
+	content = StripMarkdown{DropCode: true}.strip(content)
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) == 0 {
+		return "", 0
+	}
+
+	hits := make(map[string]int)
+	for _, word := range words {
+		for _, lang := range stopWordIndex[word] {
+			hits[lang]++
+		}
+	}
+
+	best, bestHits, second := "", 0, 0
+	for lang, n := range hits {
+		switch {
+		case n > bestHits || n == bestHits && lang < best:
+			second = max(second, bestHits)
+			best, bestHits = lang, n
+		case n > second:
+			second = n
+		}
+	}
+	if best == "" {
+		return "", 0
+	}
+
+	// how clearly the best language wins
+	margin := float64(bestHits-second) / float64(bestHits)
+	// prose has about a quarter of stop words, less means the guess relies on a few words
+	coverage := min(1, float64(bestHits)/float64(len(words))/0.25)
+	// short texts are unreliable
+	length := min(1, float64(len(words))/20)
+
+	return best, margin * coverage * length
+}
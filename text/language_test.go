@@ -0,0 +1,55 @@
+package text
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		low      bool
+	}{
+		{
+			name: "English",
+			input: "The pipeline reads all files in the folder and sends them to the model. " +
+				"It is important that the results are checked by a person before they are published on the site.",
+			expected: "en",
+		},
+		{
+			name: "German",
+			input: "Die Pipeline liest alle Dateien in dem Ordner und sendet sie an das Modell. " +
+				"Es ist wichtig, dass die Ergebnisse von einer Person geprüft werden, bevor sie auf der Seite veröffentlicht werden.",
+			expected: "de",
+		},
+		{
+			name:     "Code is ignored",
+			input:    "Der Text ist kurz, aber die Sprache ist klar und das ist gut für den Test.\n\n```go\n// the code of the example is in English\nfunc main() {}\n```",
+			expected: "de",
+		},
+		{
+			name:  "Too short",
+			input: "Hello world",
+			low:   true,
+		},
+		{
+			name:  "Mixed",
+			input: "The results are good. Die Ergebnisse sind gut. The team is happy and der Kunde ist zufrieden mit dem Ergebnis and the price.",
+			low:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lang, confidence := detectLanguage(tt.input)
+			if tt.low {
+				if confidence >= 0.3 {
+					t.Errorf("detectLanguage() = %s with confidence %.2f, want a low confidence", lang, confidence)
+				}
+				return
+			}
+			if lang != tt.expected || confidence < 0.3 {
+				t.Errorf("detectLanguage() = %s with confidence %.2f, want %s", lang, confidence, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package text
+
+import (
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// LinkType is the kind of link target.
+type LinkType string
+
+const (
+	// LinkInternal is a link to another document of the site.
+	LinkInternal LinkType = "internal"
+	// LinkExternal is a link with a scheme, like http:// or mailto:.
+	LinkExternal LinkType = "external"
+	// LinkAnchor is a link to a heading of the same document.
+	LinkAnchor LinkType = "anchor"
+)
+
+// LinkRef is a link found in markdown content.
+type LinkRef struct {
+	Text  string
+	URL   string
+	Type  LinkType
+	Image bool
+}
+
+// ExtractLinks is a job that collects markdown links and images into metadata as []LinkRef.
+// Links inside code blocks are skipped.
+type ExtractLinks struct {
+	// Key is the metadata key to store the links in. Defaults to "links".
+	Key string
+}
+
+func (e ExtractLinks) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	tesei.TransformStage(ctx, in, out, "ExtractLinks", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		key := e.Key
+		if key == "" {
+			key = "links"
+		}
+		msg.Metadata[key] = extractLinks(msg.Data.Content)
+		return msg, nil
+	})
+}
+
+func extractLinks(content string) []LinkRef {
+	m := Markdown{}
+	blocks := m.findCodeBlocks(content)
+
+	links := []LinkRef{}
+	for _, match := range linkPattern.FindAllStringSubmatchIndex(content, -1) {
+		if m.isInCodeBlock(match[0], match[1], blocks) {
+			continue
+		}
+
+		// the link target may be followed by a title: [text](url "title")
+		url := content[match[4]:match[5]]
+		if fields := strings.Fields(url); len(fields) > 0 {
+			url = fields[0]
+		}
+
+		links = append(links, LinkRef{
+			Text:  content[match[2]:match[3]],
+			URL:   url,
+			Type:  linkType(url),
+			Image: match[0] > 0 && content[match[0]-1] == '!',
+		})
+	}
+	return links
+}
+
+func linkType(url string) LinkType {
+	if strings.HasPrefix(url, "#") {
+		return LinkAnchor
+	}
+
+	lower := strings.ToLower(url)
+	if strings.HasPrefix(lower, "//") {
+		return LinkExternal
+	}
+	if i := strings.Index(lower, ":"); i > 0 {
+		scheme := lower[:i]
+		if strings.Trim(scheme, "abcdefghijklmnopqrstuvwxyz0123456789+-.") == "" {
+			return LinkExternal
+		}
+	}
+	return LinkInternal
+}
@@ -0,0 +1,42 @@
+package text
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestExtractLinks(t *testing.T) {
+	content := "See [guide](/docs/guide.md) and [site](https://example.com \"Example\").\n" +
+		"Jump to [setup](#setup). ![logo](img/logo.png)\n" +
+		"```\n[skipped](/in/code.md)\n```\n" +
+		"Also `[inline](/skipped.md)` code."
+
+	in := make(chan *tesei.Message[files.TextFile], 1)
+	out := make(chan *tesei.Message[files.TextFile], 1)
+
+	in <- tesei.NewMessage(files.TextFile{Name: "test.md", Content: content})
+	close(in)
+
+	ctx := tesei.NewThread(context.Background(), 10)
+	go ExtractLinks{}.Run(ctx, in, out)
+
+	result := <-out
+	links, ok := result.Metadata["links"].([]LinkRef)
+	if !ok {
+		t.Fatalf("Expected []LinkRef in metadata, got %T", result.Metadata["links"])
+	}
+
+	expected := []LinkRef{
+		{Text: "guide", URL: "/docs/guide.md", Type: LinkInternal},
+		{Text: "site", URL: "https://example.com", Type: LinkExternal},
+		{Text: "setup", URL: "#setup", Type: LinkAnchor},
+		{Text: "logo", URL: "img/logo.png", Type: LinkInternal, Image: true},
+	}
+	if !reflect.DeepEqual(links, expected) {
+		t.Errorf("ExtractLinks() = %+v, want %+v", links, expected)
+	}
+}
@@ -1,8 +1,13 @@
 package text
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/mkozhukh/tesei"
 	"github.com/mkozhukh/tesei/files"
@@ -15,40 +20,174 @@ type Markdown struct {
 	EscapeTagsInContent bool
 	// LowerCaseLinks determines if internal links should be lowercased.
 	LowerCaseLinks bool
+	// NormalizeLinkStyle rewrites internal links to index files, like "docs/index.md", as links to their folder,
+	// "docs/", and applies TrailingSlash. External links, anchors and code are left as is.
+	NormalizeLinkStyle bool
+	// TrailingSlash is the trailing slash policy of NormalizeLinkStyle. Defaults to SlashKeep.
+	TrailingSlash SlashPolicy
+	// IndexFiles are the file names collapsed by NormalizeLinkStyle. Defaults to "index.md" and "index.html".
+	IndexFiles []string
+	// AddHeadingAnchors appends an explicit {#slug} anchor to headings that don't have one.
+	// Duplicate slugs get a numeric suffix.
+	AddHeadingAnchors bool
+	// ReindentCodeFences aligns fenced code blocks with the list item they belong to,
+	// keeping the relative indentation of the code.
+	ReindentCodeFences bool
+	// TidyReferences removes unused link reference definitions, merges the ones pointing
+	// to the same URL and moves them, sorted, to the end of the document.
+	TidyReferences bool
+	// RenumberFootnotes renumbers numeric footnotes, [^1], and numeric reference links, [text][1],
+	// in the order of their first use. Definitions are relabeled in place, unused ones are numbered last.
+	RenumberFootnotes bool
+	// NormalizeEmphasis rewrites bold and italic text with the preferred markers.
+	// Intraword underscores, like in some_variable_name, and code are left as is.
+	NormalizeEmphasis bool
+	// BoldMarker is the preferred bold marker, "**" or "__". Defaults to "**".
+	BoldMarker string
+	// ItalicMarker is the preferred italic marker, "*" or "_". Defaults to "*".
+	ItalicMarker string
+	// MaxHeadingLevel is the deepest allowed heading level, 0 means no limit.
+	// Deeper headings are handled according to FixHeadings.
+	MaxHeadingLevel int
+	// FixHeadings defines what happens with headings deeper than MaxHeadingLevel. Defaults to HeadingError.
+	FixHeadings HeadingFix
+	// NormalizeBlockquotes rewrites blockquote markers as a single "> " per level,
+	// without indentation. Code blocks inside quotes are left as is.
+	NormalizeBlockquotes bool
+	// CalloutStyle rewrites callouts, like "> [!NOTE]" or "> **Note:**", in the given style.
+	CalloutStyle CalloutStyle
+	// NormalizeCodeLang rewrites the language tags of fenced code blocks with CodeLangAliases.
+	// Only the info string of the opening fence is changed.
+	NormalizeCodeLang bool
+	// CodeLangAliases maps language tags, case-insensitively, to the preferred ones.
+	// Defaults to DefaultCodeLangAliases.
+	CodeLangAliases map[string]string
+	// FlagUntaggedCode stores the line numbers of fenced code blocks without a language in "untagged_code" metadata.
+	FlagUntaggedCode bool
 }
 
+// DefaultCodeLangAliases are the language aliases used by NormalizeCodeLang by default.
+var DefaultCodeLangAliases = map[string]string{
+	"js":     "javascript",
+	"ts":     "typescript",
+	"py":     "python",
+	"rb":     "ruby",
+	"golang": "go",
+	"yml":    "yaml",
+	"md":     "markdown",
+}
+
+// CalloutStyle is the syntax of callout blockquotes (notes, tips, warnings).
+type CalloutStyle int
+
+const (
+	// CalloutKeep leaves callouts as they are.
+	CalloutKeep CalloutStyle = iota
+	// CalloutGitHub writes callouts as GitHub alerts, "> [!NOTE]" on its own line.
+	CalloutGitHub
+	// CalloutBold writes callouts with a bold label, "> **Note:** text".
+	CalloutBold
+)
+
+// SlashPolicy defines how NormalizeLinkStyle handles trailing slashes of internal links.
+type SlashPolicy int
+
+const (
+	// SlashKeep leaves trailing slashes as they are.
+	SlashKeep SlashPolicy = iota
+	// SlashAdd adds a trailing slash to links without a file extension, "docs/intro" becomes "docs/intro/".
+	SlashAdd
+	// SlashRemove removes trailing slashes, "docs/intro/" becomes "docs/intro". Root links, "/", are kept.
+	SlashRemove
+)
+
+// HeadingFix defines how Markdown handles headings deeper than MaxHeadingLevel.
+type HeadingFix int
+
+const (
+	// HeadingError marks the message with ErrHeadingLevel and stores the offending headings
+	// in "deep_headings" metadata, the content is left as is.
+	HeadingError HeadingFix = iota
+	// HeadingClamp raises the deeper headings to MaxHeadingLevel.
+	HeadingClamp
+	// HeadingShift raises all headings by the same number of levels, so the deepest one fits
+	// and the relative structure is kept. Headings don't go above H1.
+	HeadingShift
+)
+
 type codeBlock struct {
 	start int
 	end   int
 }
 
 func (m Markdown) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
-	tesei.Transform(ctx, in, out, func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+	tesei.TransformStage(ctx, in, out, "Markdown", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
 		if m.EscapeTagsInContent {
 			msg.Data.Content = m.escapeTagsInContent(msg.Data.Content)
 		}
 		if m.LowerCaseLinks {
 			msg.Data.Content = m.lowerCaseLinks(msg.Data.Content)
 		}
+		if m.NormalizeLinkStyle {
+			msg.Data.Content = m.normalizeLinkStyle(msg.Data.Content)
+		}
+		if m.AddHeadingAnchors {
+			msg.Data.Content = m.addHeadingAnchors(msg.Data.Content)
+		}
+		if m.ReindentCodeFences {
+			msg.Data.Content = m.reindentCodeFences(msg.Data.Content)
+		}
+		if m.RenumberFootnotes {
+			msg.Data.Content = m.renumberFootnotes(msg.Data.Content)
+		}
+		if m.TidyReferences {
+			msg.Data.Content = m.tidyReferences(msg.Data.Content)
+		}
+		if m.NormalizeEmphasis {
+			msg.Data.Content = m.normalizeEmphasis(msg.Data.Content)
+		}
+		if m.NormalizeBlockquotes || m.CalloutStyle != CalloutKeep {
+			msg.Data.Content = m.normalizeBlockquotes(msg.Data.Content)
+		}
+		if m.NormalizeCodeLang || m.FlagUntaggedCode {
+			content, untagged := m.normalizeCodeLang(msg.Data.Content)
+			msg.Data.Content = content
+			if m.FlagUntaggedCode && len(untagged) > 0 {
+				msg.Metadata["untagged_code"] = untagged
+			}
+		}
+		if m.MaxHeadingLevel > 0 {
+			content, deep := m.limitHeadings(msg.Data.Content)
+			if len(deep) > 0 {
+				msg.Metadata["deep_headings"] = deep
+				return msg, fmt.Errorf("%w: %d headings deeper than H%d", ErrHeadingLevel, len(deep), m.MaxHeadingLevel)
+			}
+			msg.Data.Content = content
+		}
 		return msg, nil
 	})
 }
 
+// tagPattern matches HTML-like tags with optional markdown formatting (bold/italic) around them.
+// Tags with attributes like <tag attr="value"> or <tag attr={value}> and self-closing tags
+// like <br/> or <img /> are matched too.
+var tagPattern = regexp.MustCompile(`(\*{1,2}|_{1,2})?(<[a-zA-Z]+(?:\s+[^>]*)?/?>)(\*{1,2}|_{1,2})?`)
+
 func (m Markdown) escapeTagsInContent(content string) string {
+	matches := tagPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return content
+	}
+
 	// First, identify all code blocks
 	blocks := m.findCodeBlocks(content)
 
-	// Find and escape HTML-like tags that are not in code blocks
-	// This pattern captures optional markdown formatting (bold/italic) around tags
-	// Updated to match tags with attributes like <tag attr="value"> or <tag attr={value}>
-	// Also matches self-closing tags like <br/> or <img />
-	tagPattern := regexp.MustCompile(`(\*{1,2}|_{1,2})?(<[a-zA-Z]+(?:\s+[^>]*)?/?>)(\*{1,2}|_{1,2})?`)
-
-	// Work with bytes since regex returns byte positions
-	result := []byte(content)
-	offset := 0
+	// Build the result in a single pass, copying the gaps between the matches.
+	// An escaped tag grows by two backticks at most, so the builder never reallocates.
+	var result strings.Builder
+	result.Grow(len(content) + 2*len(matches))
 
-	matches := tagPattern.FindAllStringSubmatchIndex(content, -1)
+	last := 0
 	for _, match := range matches {
 		// match[0], match[1] - full match start and end (in bytes)
 		// match[2], match[3] - first formatting group (prefix)
@@ -65,10 +204,6 @@ func (m Markdown) escapeTagsInContent(content string) string {
 			continue
 		}
 
-		// Get the components
-		fullMatch := content[fullStart:fullEnd]
-		tag := content[tagStart:tagEnd]
-
 		// Check if we have matching markdown formatting
 		prefixStart, prefixEnd := match[2], match[3]
 		suffixStart, suffixEnd := match[6], match[7]
@@ -81,71 +216,80 @@ func (m Markdown) escapeTagsInContent(content string) string {
 			suffix = content[suffixStart:suffixEnd]
 		}
 
-		// Determine the replacement
-		var replacement string
+		result.WriteString(content[last:fullStart])
 		if prefix != "" && suffix != "" && prefix == suffix {
 			// We have matching bold/italic markers, remove them
-			replacement = "`" + tag + "`"
+			result.WriteByte('`')
+			result.WriteString(content[tagStart:tagEnd])
+			result.WriteByte('`')
 		} else {
 			// No matching markers or only one side, just wrap the tag
-			replacement = fullMatch[:tagStart-fullStart] + "`" + tag + "`" + fullMatch[tagEnd-fullStart:]
+			result.WriteString(content[fullStart:tagStart])
+			result.WriteByte('`')
+			result.WriteString(content[tagStart:tagEnd])
+			result.WriteByte('`')
+			result.WriteString(content[tagEnd:fullEnd])
 		}
-
-		// Calculate the position with offset (in bytes)
-		adjustedStart := fullStart + offset
-		adjustedEnd := fullEnd + offset
-
-		// Replace in result (working with bytes)
-		replacementBytes := []byte(replacement)
-		newResult := make([]byte, 0, len(result)+(len(replacementBytes)-(adjustedEnd-adjustedStart)))
-		newResult = append(newResult, result[:adjustedStart]...)
-		newResult = append(newResult, replacementBytes...)
-		newResult = append(newResult, result[adjustedEnd:]...)
-
-		result = newResult
-		offset += len(replacementBytes) - (fullEnd - fullStart)
+		last = fullEnd
 	}
+	result.WriteString(content[last:])
 
-	return string(result)
+	return result.String()
 }
 
+// findCodeBlocks returns the ```fenced``` blocks, followed by the `inline` code spans outside of them.
 func (m Markdown) findCodeBlocks(content string) []codeBlock {
 	var blocks []codeBlock
 
-	// Find triple backtick code blocks
-	tripleBacktickPattern := regexp.MustCompile("(?s)```.*?```")
-	tripleMatches := tripleBacktickPattern.FindAllStringIndex(content, -1)
-	for _, match := range tripleMatches {
-		blocks = append(blocks, codeBlock{start: match[0], end: match[1]})
+	// Find triple backtick code blocks, each one runs to the nearest closing triple backtick
+	for i := 0; ; {
+		start := strings.Index(content[i:], "```")
+		if start == -1 {
+			break
+		}
+		start += i
+		end := strings.Index(content[start+3:], "```")
+		if end == -1 {
+			break
+		}
+		end += start + 6
+		blocks = append(blocks, codeBlock{start: start, end: end})
+		i = end
 	}
+	fenced := len(blocks)
 
 	// Find inline code blocks (single backticks on the same line)
-	lines := strings.Split(content, "\n")
-	currentPos := 0
+	start := strings.IndexByte(content, '`')
+	for start != -1 {
+		next := strings.IndexByte(content[start+1:], '`')
+		if next == -1 {
+			break
+		}
+		end := start + 1 + next
 
-	for _, line := range lines {
-		inlinePattern := regexp.MustCompile("`[^`\n]+`")
-		lineMatches := inlinePattern.FindAllStringIndex(line, -1)
-
-		for _, match := range lineMatches {
-			absoluteStart := currentPos + match[0]
-			absoluteEnd := currentPos + match[1]
-
-			// Check if this inline block is inside a triple backtick block
-			isInsideTriple := false
-			for _, tripleBlock := range blocks {
-				if absoluteStart >= tripleBlock.start && absoluteEnd <= tripleBlock.end {
-					isInsideTriple = true
-					break
-				}
-			}
+		// an empty span or a line break between the backticks, the closing one may open a new span
+		if end == start+1 || strings.IndexByte(content[start:end], '\n') != -1 {
+			start = end
+			continue
+		}
 
-			if !isInsideTriple {
-				blocks = append(blocks, codeBlock{start: absoluteStart, end: absoluteEnd})
+		// Check if this inline block is inside a triple backtick block
+		isInsideTriple := false
+		for _, tripleBlock := range blocks[:fenced] {
+			if start >= tripleBlock.start && end+1 <= tripleBlock.end {
+				isInsideTriple = true
+				break
 			}
 		}
+		if !isInsideTriple {
+			blocks = append(blocks, codeBlock{start: start, end: end + 1})
+		}
 
-		currentPos += len(line) + 1 // +1 for newline
+		next = strings.IndexByte(content[end+1:], '`')
+		if next == -1 {
+			break
+		}
+		start = end + 1 + next
 	}
 
 	return blocks
@@ -167,10 +311,10 @@ func (m Markdown) isInCodeBlock(start, end int, blocks []codeBlock) bool {
 	return false
 }
 
-func (m Markdown) lowerCaseLinks(content string) string {
-	// Find markdown links: [text](url)
-	linkPattern := regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+// linkPattern matches markdown links: [text](url)
+var linkPattern = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
 
+func (m Markdown) lowerCaseLinks(content string) string {
 	result := linkPattern.ReplaceAllStringFunc(content, func(match string) string {
 		// Extract the parts of the link
 		matches := linkPattern.FindStringSubmatch(match)
@@ -194,3 +338,709 @@ func (m Markdown) lowerCaseLinks(content string) string {
 
 	return result
 }
+
+// linkSchemePattern matches the scheme of an absolute URL, like "https:" or "mailto:"
+var linkSchemePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+func (m Markdown) normalizeLinkStyle(content string) string {
+	blocks := m.findCodeBlocks(content)
+
+	// URLs of inline links and of reference definitions
+	var spans [][2]int
+	for _, match := range linkPattern.FindAllStringSubmatchIndex(content, -1) {
+		spans = append(spans, [2]int{match[4], match[5]})
+	}
+	pos := 0
+	for _, line := range strings.Split(content, "\n") {
+		if match := referenceDefPattern.FindStringSubmatchIndex(line); match != nil {
+			spans = append(spans, [2]int{pos + match[4], pos + match[5]})
+		}
+		pos += len(line) + 1
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var sb strings.Builder
+	last := 0
+	for _, span := range spans {
+		start, end := span[0], span[1]
+		if start < last || m.isInCodeBlock(start, end, blocks) {
+			continue
+		}
+
+		// the URL may be followed by a title
+		url, _, _ := strings.Cut(content[start:end], " ")
+		normalized := m.normalizeLink(url)
+		if normalized == url {
+			continue
+		}
+		sb.WriteString(content[last:start])
+		sb.WriteString(normalized)
+		last = start + len(url)
+	}
+	if last == 0 {
+		return content
+	}
+	sb.WriteString(content[last:])
+	return sb.String()
+}
+
+// normalizeLink collapses index files and applies the trailing slash policy to an internal link.
+func (m Markdown) normalizeLink(url string) string {
+	if url == "" || strings.HasPrefix(url, "#") || strings.HasPrefix(url, "//") || linkSchemePattern.MatchString(url) {
+		return url
+	}
+
+	path, suffix := url, ""
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		path, suffix = url[:i], url[i:]
+	}
+
+	indexFiles := m.IndexFiles
+	if indexFiles == nil {
+		indexFiles = []string{"index.md", "index.html"}
+	}
+	dir, file := path[:strings.LastIndex(path, "/")+1], path[strings.LastIndex(path, "/")+1:]
+	for _, index := range indexFiles {
+		if strings.EqualFold(file, index) {
+			path = dir
+			if path == "" {
+				path = "./"
+			}
+			break
+		}
+	}
+
+	switch m.TrailingSlash {
+	case SlashAdd:
+		if !strings.HasSuffix(path, "/") && path != "" && !strings.Contains(path[strings.LastIndex(path, "/")+1:], ".") {
+			path += "/"
+		}
+	case SlashRemove:
+		trimmed := strings.TrimRight(path, "/")
+		if trimmed != "" && trimmed != "." && trimmed != ".." && !strings.HasSuffix(trimmed, "/..") && !strings.HasSuffix(trimmed, "/.") {
+			path = trimmed
+		}
+	}
+	return path + suffix
+}
+
+var headingPattern = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t]*$`)
+var headingIDPattern = regexp.MustCompile(`\{#([^}\s]+)\}$`)
+
+type headingLine struct {
+	index int
+	level int
+	text  string
+}
+
+// findHeadings returns ATX headings of the content lines, skipping the ones in code blocks.
+func (m Markdown) findHeadings(content string, lines []string) []headingLine {
+	blocks := m.findCodeBlocks(content)
+
+	var headings []headingLine
+	pos := 0
+	for i, line := range lines {
+		start := pos
+		pos += len(line) + 1
+
+		match := headingPattern.FindStringSubmatch(line)
+		if match == nil || m.isInCodeBlock(start, start+1, blocks) {
+			continue
+		}
+		headings = append(headings, headingLine{index: i, level: len(match[1]), text: match[2]})
+	}
+	return headings
+}
+
+// limitHeadings fits the headings into MaxHeadingLevel. In the HeadingError mode the content is
+// not changed and the lines of the deeper headings are returned instead.
+func (m Markdown) limitHeadings(content string) (string, []string) {
+	lines := strings.Split(content, "\n")
+	headings := m.findHeadings(content, lines)
+
+	deepest := 0
+	for _, h := range headings {
+		deepest = max(deepest, h.level)
+	}
+	if deepest <= m.MaxHeadingLevel {
+		return content, nil
+	}
+
+	var deep []string
+	for _, h := range headings {
+		level := h.level
+		switch m.FixHeadings {
+		case HeadingClamp:
+			level = min(level, m.MaxHeadingLevel)
+		case HeadingShift:
+			level = max(level-(deepest-m.MaxHeadingLevel), 1)
+		default:
+			if level > m.MaxHeadingLevel {
+				deep = append(deep, lines[h.index])
+			}
+			continue
+		}
+		lines[h.index] = strings.Repeat("#", level) + lines[h.index][h.level:]
+	}
+
+	if deep != nil {
+		return content, deep
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (m Markdown) addHeadingAnchors(content string) string {
+	lines := strings.Split(content, "\n")
+	headings := m.findHeadings(content, lines)
+
+	// explicit IDs are kept as is and reserve their slugs
+	used := make(map[string]bool)
+	for _, h := range headings {
+		if match := headingIDPattern.FindStringSubmatch(h.text); match != nil {
+			used[match[1]] = true
+		}
+	}
+
+	for _, h := range headings {
+		if headingIDPattern.MatchString(h.text) {
+			continue
+		}
+
+		base := slugify(h.text)
+		if base == "" {
+			continue
+		}
+
+		slug := base
+		for n := 1; used[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[slug] = true
+
+		lines[h.index] = strings.TrimRight(lines[h.index], " \t") + " {#" + slug + "}"
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+var listItemPattern = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])( +)`)
+var quotePattern = regexp.MustCompile(`^ {0,3}(?:> ?)+`)
+
+type listItem struct {
+	marker  int // indentation of the list marker
+	content int // indentation of the item content
+}
+
+// reindentCodeFences moves fenced code blocks to the content indentation of their list item.
+// A fence belongs to the deepest open item whose marker is less indented than the fence,
+// or to the innermost item if it directly follows the list without a blank line.
+// A fence at the first column after a blank line is a top-level one.
+// Blockquote markers are kept, indentation is normalized after them.
+func (m Markdown) reindentCodeFences(content string) string {
+	lines := strings.Split(content, "\n")
+
+	var items []listItem
+	var fences files.FenceScanner
+	blank := false
+	shift := 0
+
+	for i, line := range lines {
+		prefix := quotePattern.FindString(line)
+		rest := line[len(prefix):]
+		indent := len(rest) - len(strings.TrimLeft(rest, " "))
+		trimmed := strings.TrimSpace(rest)
+
+		switch fences.Next(rest) {
+		case files.ClosingFence:
+			lines[i] = prefix + strings.Repeat(" ", max(indent+shift, 0)) + trimmed
+			continue
+		case files.InsideFence:
+			if shift > 0 && trimmed != "" {
+				lines[i] = prefix + strings.Repeat(" ", shift) + rest
+			} else if shift < 0 {
+				lines[i] = prefix + rest[min(indent, -shift):]
+			}
+			continue
+		case files.OpeningFence:
+			if blank && indent == 0 {
+				items = nil
+			}
+
+			target := 0
+			if len(items) > 0 {
+				target = items[len(items)-1].content
+				if blank {
+					target = 0
+					for _, item := range items {
+						if item.marker < indent {
+							target = item.content
+						}
+					}
+				}
+			}
+
+			shift = target - indent
+			lines[i] = prefix + strings.Repeat(" ", target) + rest[indent:]
+			blank = false
+			continue
+		}
+
+		if trimmed == "" {
+			blank = true
+			continue
+		}
+
+		if match := listItemPattern.FindStringSubmatch(rest); match != nil {
+			for len(items) > 0 && items[len(items)-1].content > indent {
+				items = items[:len(items)-1]
+			}
+			items = append(items, listItem{marker: indent, content: len(match[0])})
+		} else if blank {
+			for len(items) > 0 && items[len(items)-1].content > indent {
+				items = items[:len(items)-1]
+			}
+		}
+		blank = false
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// referenceDefPattern matches link reference definitions: [id]: url "title"
+var referenceDefPattern = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:[ \t]*(\S+)[ \t]*(.*)$`)
+
+// referenceUsePattern matches [text][id], [id][] and [id] links, the trailing ( marks an inline link
+var referenceUsePattern = regexp.MustCompile(`\[([^\]]+)\](?:\[([^\]]*)\])?(\()?`)
+
+type referenceDef struct {
+	label string
+	url   string
+	title string
+}
+
+// referenceKey normalizes a reference label, labels are case-insensitive and whitespace is collapsed.
+func referenceKey(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+func (m Markdown) tidyReferences(content string) string {
+	blocks := m.findCodeBlocks(content)
+	lines := strings.Split(content, "\n")
+
+	// collect definitions, the first definition of a label wins
+	defs := make(map[string]referenceDef)
+	alias := make(map[string]string)
+	byTarget := make(map[string]string)
+	var body []string
+	var offsets []int
+	pos := 0
+	removed := false
+	for _, line := range lines {
+		start := pos
+		pos += len(line) + 1
+
+		match := referenceDefPattern.FindStringSubmatch(line)
+		if match == nil || m.isInCodeBlock(start, start+1, blocks) {
+			// don't leave a double blank line in place of removed definitions
+			blank := strings.TrimSpace(line) == ""
+			if blank && removed && (len(body) == 0 || strings.TrimSpace(body[len(body)-1]) == "") {
+				continue
+			}
+			removed = removed && blank
+			body = append(body, line)
+			offsets = append(offsets, start)
+			continue
+		}
+		removed = true
+
+		key := referenceKey(match[1])
+		if _, ok := defs[key]; ok {
+			continue
+		}
+		if _, ok := alias[key]; ok {
+			continue
+		}
+
+		def := referenceDef{label: match[1], url: match[2], title: strings.TrimSpace(match[3])}
+		target := def.url + " " + def.title
+		if owner, ok := byTarget[target]; ok {
+			alias[key] = owner
+			continue
+		}
+		byTarget[target] = key
+		defs[key] = def
+	}
+
+	if len(defs) == 0 {
+		return content
+	}
+
+	// find used references, pointing the merged ones to the kept label
+	used := make(map[string]bool)
+	for i, line := range body {
+		matches := referenceUsePattern.FindAllStringSubmatchIndex(line, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var sb strings.Builder
+		last := 0
+		for _, match := range matches {
+			if match[6] >= 0 || m.isInCodeBlock(offsets[i]+match[0], offsets[i]+match[1], blocks) {
+				continue
+			}
+
+			text := line[match[2]:match[3]]
+			label := text
+			if match[4] >= 0 && match[5] > match[4] {
+				label = line[match[4]:match[5]]
+			}
+
+			key := referenceKey(label)
+			if owner, ok := alias[key]; ok {
+				used[owner] = true
+				sb.WriteString(line[last:match[0]])
+				sb.WriteString("[" + text + "][" + defs[owner].label + "]")
+				last = match[1]
+			} else if _, ok := defs[key]; ok {
+				used[key] = true
+			}
+		}
+		if last > 0 {
+			sb.WriteString(line[last:])
+			body[i] = sb.String()
+		}
+	}
+
+	keys := make([]string, 0, len(used))
+	for key := range used {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := strings.TrimRight(strings.Join(body, "\n"), " \t\n")
+	if len(keys) > 0 {
+		result += "\n"
+		for _, key := range keys {
+			def := defs[key]
+			result += "\n[" + def.label + "]: " + def.url
+			if def.title != "" {
+				result += " " + def.title
+			}
+		}
+	}
+	if strings.HasSuffix(content, "\n") {
+		result += "\n"
+	}
+	return result
+}
+
+// footnotePattern matches footnote references and definitions with numeric labels: [^1]
+var footnotePattern = regexp.MustCompile(`\[\^(\d+)\]`)
+
+// numberLabel is the position of a numeric label in the content.
+type numberLabel struct {
+	start, end int
+	footnote   bool
+	def        bool
+}
+
+func isNumber(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (m Markdown) renumberFootnotes(content string) string {
+	blocks := m.findCodeBlocks(content)
+
+	var labels []numberLabel
+	pos := 0
+	for _, line := range strings.Split(content, "\n") {
+		start := pos
+		pos += len(line) + 1
+
+		for _, match := range footnotePattern.FindAllStringSubmatchIndex(line, -1) {
+			if m.isInCodeBlock(start+match[0], start+match[1], blocks) {
+				continue
+			}
+			def := match[0] <= 3 && strings.TrimLeft(line[:match[0]], " ") == "" && strings.HasPrefix(line[match[1]:], ":")
+			labels = append(labels, numberLabel{start: start + match[2], end: start + match[3], footnote: true, def: def})
+		}
+
+		if match := referenceDefPattern.FindStringSubmatchIndex(line); match != nil {
+			if isNumber(line[match[2]:match[3]]) && !m.isInCodeBlock(start, start+1, blocks) {
+				labels = append(labels, numberLabel{start: start + match[2], end: start + match[3], def: true})
+			}
+			continue
+		}
+		for _, match := range referenceUsePattern.FindAllStringSubmatchIndex(line, -1) {
+			if match[6] >= 0 || m.isInCodeBlock(start+match[0], start+match[1], blocks) {
+				continue
+			}
+			from, to := match[2], match[3]
+			if match[4] >= 0 && match[5] > match[4] {
+				from, to = match[4], match[5]
+			}
+			if isNumber(line[from:to]) {
+				labels = append(labels, numberLabel{start: start + from, end: start + to})
+			}
+		}
+	}
+	if len(labels) == 0 {
+		return content
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].start < labels[j].start })
+
+	// footnotes are numbered even without a definition, link labels only with one
+	defined := make(map[string]bool)
+	for _, l := range labels {
+		if l.def && !l.footnote {
+			defined[content[l.start:l.end]] = true
+		}
+	}
+
+	// footnotes and links are numbered separately, footnote keys keep the caret
+	key := func(l numberLabel) string {
+		if l.footnote {
+			return "^" + content[l.start:l.end]
+		}
+		return content[l.start:l.end]
+	}
+	numbers := make(map[string]string)
+	counts := make(map[bool]int)
+	assign := func(l numberLabel) {
+		if _, ok := numbers[key(l)]; !ok {
+			counts[l.footnote]++
+			numbers[key(l)] = strconv.Itoa(counts[l.footnote])
+		}
+	}
+	for _, l := range labels {
+		if !l.def && (l.footnote || defined[content[l.start:l.end]]) {
+			assign(l)
+		}
+	}
+	for _, l := range labels {
+		if l.def {
+			assign(l)
+		}
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, l := range labels {
+		number, ok := numbers[key(l)]
+		if !ok {
+			continue
+		}
+		sb.WriteString(content[last:l.start])
+		sb.WriteString(number)
+		last = l.end
+	}
+	sb.WriteString(content[last:])
+	return sb.String()
+}
+
+var (
+	boldStarPattern    = regexp.MustCompile(`\*\*(\S(?:[^\n]*?\S)?)\*\*`)
+	boldUnderPattern   = regexp.MustCompile(`__(\S(?:[^\n]*?\S)?)__`)
+	italicStarPattern  = regexp.MustCompile(`\*([^\s*](?:[^*\n]*?[^\s*])?)\*`)
+	italicUnderPattern = regexp.MustCompile(`_([^\s_](?:[^_\n]*?[^\s_])?)_`)
+)
+
+func (m Markdown) normalizeEmphasis(content string) string {
+	bold := m.BoldMarker
+	if bold != "__" {
+		bold = "**"
+	}
+	italic := m.ItalicMarker
+	if italic != "_" {
+		italic = "*"
+	}
+
+	blocks := m.findCodeBlocks(content)
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].start < blocks[j].start })
+
+	convert := func(text string) string {
+		if bold == "**" {
+			text = replaceEmphasis(text, boldUnderPattern, "__", bold)
+		} else {
+			text = replaceEmphasis(text, boldStarPattern, "**", bold)
+		}
+		if italic == "*" {
+			text = replaceEmphasis(text, italicUnderPattern, "_", italic)
+		} else {
+			text = replaceEmphasis(text, italicStarPattern, "*", italic)
+		}
+		return text
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, block := range blocks {
+		if block.start < last {
+			continue
+		}
+		sb.WriteString(convert(content[last:block.start]))
+		sb.WriteString(content[block.start:block.end])
+		last = block.end
+	}
+	sb.WriteString(convert(content[last:]))
+	return sb.String()
+}
+
+// replaceEmphasis replaces the marker of emphasized text matched by the pattern.
+// Matches which are part of a longer marker run, or intraword when an underscore is involved, are skipped.
+func replaceEmphasis(text string, pattern *regexp.Regexp, marker, to string) string {
+	var sb strings.Builder
+	last := 0
+	pos := 0
+	for pos < len(text) {
+		match := pattern.FindStringSubmatchIndex(text[pos:])
+		if match == nil {
+			break
+		}
+		start, end := pos+match[0], pos+match[1]
+
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		skip := before == rune(marker[0]) || after == rune(marker[0])
+		if strings.Contains(marker+to, "_") && (isWordRune(before) || isWordRune(after)) {
+			skip = true
+		}
+		if skip {
+			pos = start + 1
+			continue
+		}
+
+		sb.WriteString(text[last:start])
+		sb.WriteString(to + text[pos+match[2]:pos+match[3]] + to)
+		last = end
+		pos = end
+	}
+	if last == 0 {
+		return text
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// callouts recognize the types of GitHub alerts
+var (
+	calloutAlertPattern = regexp.MustCompile(`(?i)^\[!(note|tip|important|warning|caution)\][+-]?[ \t]*(.*)$`)
+	calloutBoldPattern  = regexp.MustCompile(`(?i)^\*\*(note|tip|important|warning|caution)(?::\*\*|\*\*:?)[ \t]*(.*)$`)
+)
+
+func (m Markdown) normalizeBlockquotes(content string) string {
+	lines := strings.Split(content, "\n")
+	result := make([]string, 0, len(lines))
+	var fences files.FenceScanner
+	quoted := false
+	for _, line := range lines {
+		prefix := quotePattern.FindString(line)
+		if fences.Next(line[len(prefix):]) != files.OutsideFence || prefix == "" {
+			// lines of a code block inside a quote, fences included, are kept as they are
+			quoted = prefix != ""
+			result = append(result, line)
+			continue
+		}
+
+		text := line[len(prefix):]
+		level := strings.Count(prefix, ">")
+		if m.NormalizeBlockquotes {
+			// the pattern takes one space after the markers, more spaces are part of the text
+			prefix = strings.TrimSuffix(strings.Repeat("> ", level), " ")
+			if text != "" {
+				prefix += " "
+			}
+		}
+		first := !quoted
+		quoted = true
+
+		if first && level == 1 && m.CalloutStyle != CalloutKeep {
+			if kind, rest, ok := parseCallout(text); ok {
+				result = append(result, m.callout(prefix, kind, rest)...)
+				continue
+			}
+		}
+
+		result = append(result, prefix+text)
+	}
+	return strings.Join(result, "\n")
+}
+
+// parseCallout returns the callout type (like "NOTE") and the text after the label,
+// if the first line of a quote is a callout.
+func parseCallout(text string) (string, string, bool) {
+	match := calloutAlertPattern.FindStringSubmatch(text)
+	if match == nil {
+		match = calloutBoldPattern.FindStringSubmatch(text)
+	}
+	if match == nil {
+		return "", "", false
+	}
+	return strings.ToUpper(match[1]), match[2], true
+}
+
+// callout returns the lines of the callout label in the configured style.
+func (m Markdown) callout(prefix, kind, rest string) []string {
+	marker := strings.TrimRight(prefix, " ")
+	if m.CalloutStyle == CalloutBold {
+		label := marker + " **" + kind[:1] + strings.ToLower(kind[1:]) + ":**"
+		if rest != "" {
+			label += " " + rest
+		}
+		return []string{label}
+	}
+
+	lines := []string{marker + " [!" + kind + "]"}
+	if rest != "" {
+		lines = append(lines, marker+" "+rest)
+	}
+	return lines
+}
+
+// normalizeCodeLang replaces aliased languages in the info strings of opening fences,
+// and returns the line numbers of the fences without a language.
+func (m Markdown) normalizeCodeLang(content string) (string, []int) {
+	aliases := DefaultCodeLangAliases
+	if m.CodeLangAliases != nil {
+		aliases = make(map[string]string, len(m.CodeLangAliases))
+		for from, to := range m.CodeLangAliases {
+			aliases[strings.ToLower(from)] = to
+		}
+	}
+
+	lines := strings.Split(content, "\n")
+	var untagged []int
+	var fences files.FenceScanner
+	for i, line := range lines {
+		prefix := quotePattern.FindString(line)
+		if fences.Next(line[len(prefix):]) != files.OpeningFence {
+			continue
+		}
+
+		open := len(prefix) + len(fences.Match[0])
+		info := line[open:]
+		lang := strings.Fields(info)
+		if len(lang) == 0 {
+			untagged = append(untagged, i+1)
+			continue
+		}
+		if !m.NormalizeCodeLang {
+			continue
+		}
+		if to, ok := aliases[strings.ToLower(lang[0])]; ok {
+			start := open + strings.Index(info, lang[0])
+			lines[i] = line[:start] + to + line[start+len(lang[0]):]
+		}
+	}
+	return strings.Join(lines, "\n"), untagged
+}
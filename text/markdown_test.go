@@ -2,6 +2,12 @@ package text
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/mkozhukh/tesei"
@@ -499,3 +505,671 @@ func TestMarkdown_BothRulesEnabled(t *testing.T) {
 		t.Errorf("Run() with both rules = %q, want %q", result.Data.Content, expectedContent)
 	}
 }
+
+func TestMarkdown_AddHeadingAnchors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Plain heading",
+			input:    "# Getting Started\n\nSome text",
+			expected: "# Getting Started {#getting-started}\n\nSome text",
+		},
+		{
+			name:     "Duplicate headings get a suffix",
+			input:    "## Usage\ntext\n## Usage\ntext\n## Usage",
+			expected: "## Usage {#usage}\ntext\n## Usage {#usage-1}\ntext\n## Usage {#usage-2}",
+		},
+		{
+			name:     "Existing ID is preserved and reserved",
+			input:    "## Setup {#usage}\n## Usage",
+			expected: "## Setup {#usage}\n## Usage {#usage-1}",
+		},
+		{
+			name:     "Heading inside code block is untouched",
+			input:    "```\n# comment\n```\n# Real",
+			expected: "```\n# comment\n```\n# Real {#real}",
+		},
+		{
+			name:     "Punctuation and inline code",
+			input:    "### The `Run()` method, explained!",
+			expected: "### The `Run()` method, explained! {#the-run-method-explained}",
+		},
+	}
+
+	m := Markdown{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := m.addHeadingAnchors(tt.input)
+			if result != tt.expected {
+				t.Errorf("addHeadingAnchors() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMarkdown_ReindentCodeFences(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Top-level fence with stray indentation",
+			input:    "Text\n\n  ```go\n  func main() {\n      run()\n  }\n  ```",
+			expected: "Text\n\n```go\nfunc main() {\n    run()\n}\n```",
+		},
+		{
+			name:     "Fence in nested list item lost indentation",
+			input:    "- item\n  - nested\n```go\nif x {\n    y()\n}\n```\n- next",
+			expected: "- item\n  - nested\n    ```go\n    if x {\n        y()\n    }\n    ```\n- next",
+		},
+		{
+			name:     "Fence in nested list item gained indentation",
+			input:    "1. item\n   - nested\n\n         ```\n         code\n           more\n         ```",
+			expected: "1. item\n   - nested\n\n     ```\n     code\n       more\n     ```",
+		},
+		{
+			name:     "Top-level fence after a list",
+			input:    "- item\n\n```\ncode\n```",
+			expected: "- item\n\n```\ncode\n```",
+		},
+		{
+			name:     "Fence inside blockquote",
+			input:    "> Quote\n>   ```\n>   code\n>   ```",
+			expected: "> Quote\n> ```\n> code\n> ```",
+		},
+	}
+
+	m := Markdown{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := m.reindentCodeFences(tt.input)
+			if result != tt.expected {
+				t.Errorf("reindentCodeFences() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMarkdown_TidyReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Unused definition is removed",
+			input:    "See [the docs][docs].\n\n[docs]: https://example.com/docs\n[old]: https://example.com/old\n",
+			expected: "See [the docs][docs].\n\n[docs]: https://example.com/docs\n",
+		},
+		{
+			name:     "Duplicates are merged",
+			input:    "Read [guide][a] and [manual][b], or just [b].\n\n[a]: https://example.com/guide\n[b]: https://example.com/guide\n[A]: https://example.com/other\n",
+			expected: "Read [guide][a] and [manual][a], or just [b][a].\n\n[a]: https://example.com/guide\n",
+		},
+		{
+			name:     "Used definitions are kept and sorted",
+			input:    "[Zeta] and [alpha][] and [text][Mid]\n\n[zeta]: /z \"Zeta\"\n[mid]: /m\n\nMore text\n\n[alpha]: /a\n",
+			expected: "[Zeta] and [alpha][] and [text][Mid]\n\nMore text\n\n[alpha]: /a\n[mid]: /m\n[zeta]: /z \"Zeta\"\n",
+		},
+		{
+			name:     "Inline links and code are ignored",
+			input:    "[docs](https://example.com) `[code]`\n\n```\n[code]: /inside\n```\n\n[docs]: /docs\n[code]: /code\n",
+			expected: "[docs](https://example.com) `[code]`\n\n```\n[code]: /inside\n```\n",
+		},
+		{
+			name:     "No definitions",
+			input:    "Plain [text].",
+			expected: "Plain [text].",
+		},
+	}
+
+	m := Markdown{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := m.tidyReferences(tt.input)
+			if result != tt.expected {
+				t.Errorf("tidyReferences() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMarkdown_NormalizeEmphasis(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown Markdown
+		input    string
+		expected string
+	}{
+		{
+			name:     "Underscores to stars",
+			input:    "Some _italic_ and __bold__ text, _x_.",
+			expected: "Some *italic* and **bold** text, *x*.",
+		},
+		{
+			name:     "Intraword underscores are kept",
+			input:    "Use a_b_c or some_variable_name, and _this_ one.",
+			expected: "Use a_b_c or some_variable_name, and *this* one.",
+		},
+		{
+			name:     "Code is unchanged",
+			input:    "Text _a_\n\n```go\nx := _b_ + __c__\n```\n\nInline `_d_` and _e_.",
+			expected: "Text *a*\n\n```go\nx := _b_ + __c__\n```\n\nInline `_d_` and *e*.",
+		},
+		{
+			name:     "Stars to underscores",
+			markdown: Markdown{BoldMarker: "__", ItalicMarker: "_"},
+			input:    "Some *italic* and **bold** text, but 2 * 3 * 4 and foo*bar*baz.",
+			expected: "Some _italic_ and __bold__ text, but 2 * 3 * 4 and foo*bar*baz.",
+		},
+		{
+			name:     "List markers are not emphasis",
+			markdown: Markdown{ItalicMarker: "_"},
+			input:    "* item with *em*\n* another",
+			expected: "* item with _em_\n* another",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.markdown.normalizeEmphasis(tt.input)
+			if result != tt.expected {
+				t.Errorf("normalizeEmphasis() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMarkdown_MaxHeadingLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		fix      HeadingFix
+		input    string
+		expected string
+		deep     []string
+	}{
+		{
+			name:     "Within limits",
+			input:    "# Title\n## Part\n### Details\n```\n#### code\n```",
+			expected: "# Title\n## Part\n### Details\n```\n#### code\n```",
+		},
+		{
+			name:     "H4 flagged",
+			input:    "# Title\n### Details\n#### Deep\n##### Deeper",
+			expected: "# Title\n### Details\n#### Deep\n##### Deeper",
+			deep:     []string{"#### Deep", "##### Deeper"},
+		},
+		{
+			name:     "H4 clamped",
+			fix:      HeadingClamp,
+			input:    "# Title\n### Details\n#### Deep {#deep}\n```\n#### code\n```",
+			expected: "# Title\n### Details\n### Deep {#deep}\n```\n#### code\n```",
+		},
+		{
+			name:     "Shifted",
+			fix:      HeadingShift,
+			input:    "# Title\n## Part\n#### Deep\n##### Deeper",
+			expected: "# Title\n# Part\n## Deep\n### Deeper",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: tt.input}}}).
+				Sequential(Markdown{MaxHeadingLevel: 3, FixHeadings: tt.fix}).
+				Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			msg := result[0]
+			if msg.Data.Content != tt.expected {
+				t.Errorf("content = %q, want %q", msg.Data.Content, tt.expected)
+			}
+			if tt.deep == nil {
+				if msg.Error != nil {
+					t.Errorf("unexpected error: %v", msg.Error)
+				}
+				return
+			}
+			if !errors.Is(msg.Error, ErrHeadingLevel) {
+				t.Errorf("expected ErrHeadingLevel, got %v", msg.Error)
+			}
+			if deep := msg.Metadata["deep_headings"]; !reflect.DeepEqual(deep, tt.deep) {
+				t.Errorf("deep_headings = %v, want %v", deep, tt.deep)
+			}
+		})
+	}
+}
+
+// escapeTagsRebuild is the previous implementation of escapeTagsInContent, which rebuilt
+// the whole content for every tag. It is kept as a reference for the output and the benchmark.
+func escapeTagsRebuild(m Markdown, content string) string {
+	blocks := m.findCodeBlocks(content)
+	result := []byte(content)
+	offset := 0
+
+	for _, match := range tagPattern.FindAllStringSubmatchIndex(content, -1) {
+		fullStart, fullEnd := match[0], match[1]
+		tagStart, tagEnd := match[4], match[5]
+		if m.isInCodeBlock(tagStart, tagEnd, blocks) {
+			continue
+		}
+
+		fullMatch := content[fullStart:fullEnd]
+		tag := content[tagStart:tagEnd]
+		var prefix, suffix string
+		if match[2] >= 0 {
+			prefix = content[match[2]:match[3]]
+		}
+		if match[6] >= 0 {
+			suffix = content[match[6]:match[7]]
+		}
+
+		var replacement string
+		if prefix != "" && suffix != "" && prefix == suffix {
+			replacement = "`" + tag + "`"
+		} else {
+			replacement = fullMatch[:tagStart-fullStart] + "`" + tag + "`" + fullMatch[tagEnd-fullStart:]
+		}
+
+		adjustedStart := fullStart + offset
+		adjustedEnd := fullEnd + offset
+		newResult := make([]byte, 0, len(result)+len(replacement)-(adjustedEnd-adjustedStart))
+		newResult = append(newResult, result[:adjustedStart]...)
+		newResult = append(newResult, replacement...)
+		newResult = append(newResult, result[adjustedEnd:]...)
+		result = newResult
+		offset += len(replacement) - (fullEnd - fullStart)
+	}
+
+	return string(result)
+}
+
+// taggedDocument builds a markdown document with n paragraphs, each with a few tags
+// in and out of code.
+func taggedDocument(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "## Section %d\n\n", i)
+		b.WriteString("Use the <Button> component with **<Icon/>** and _<Label>*, not `<span>`.\n")
+		b.WriteString("A <div class=\"note\"> wraps the __<Tooltip position={top}>__ text.\n\n")
+		if i%5 == 0 {
+			b.WriteString("```html\n<div>\n  <p>code</p>\n</div>\n```\n\n")
+		}
+	}
+	return b.String()
+}
+
+func TestMarkdown_EscapeTagsInContentSameOutput(t *testing.T) {
+	m := Markdown{EscapeTagsInContent: true}
+	docs := []string{
+		taggedDocument(1),
+		taggedDocument(50),
+		"<a><b>**<c>**`<d>`<e>",
+		"no tags at all",
+		"",
+	}
+
+	for _, doc := range docs {
+		if got, want := m.escapeTagsInContent(doc), escapeTagsRebuild(m, doc); got != want {
+			t.Errorf("output differs for %q:\ngot  %q\nwant %q", doc, got, want)
+		}
+	}
+}
+
+func BenchmarkEscapeTagsInContent(b *testing.B) {
+	m := Markdown{EscapeTagsInContent: true}
+	// 16KB with 640 tags, 140 of them in code
+	doc := taggedDocument(100)
+
+	b.Run("SinglePass", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m.escapeTagsInContent(doc)
+		}
+	})
+	b.Run("Rebuild", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			escapeTagsRebuild(m, doc)
+		}
+	})
+}
+
+// findCodeBlocksRegexp is the previous, regexp based implementation of findCodeBlocks,
+// kept as a reference for the output and the benchmark.
+func findCodeBlocksRegexp(content string) []codeBlock {
+	var blocks []codeBlock
+
+	tripleBacktickPattern := regexp.MustCompile("(?s)```.*?```")
+	for _, match := range tripleBacktickPattern.FindAllStringIndex(content, -1) {
+		blocks = append(blocks, codeBlock{start: match[0], end: match[1]})
+	}
+
+	currentPos := 0
+	for _, line := range strings.Split(content, "\n") {
+		inlinePattern := regexp.MustCompile("`[^`\n]+`")
+		for _, match := range inlinePattern.FindAllStringIndex(line, -1) {
+			absoluteStart := currentPos + match[0]
+			absoluteEnd := currentPos + match[1]
+
+			isInsideTriple := false
+			for _, tripleBlock := range blocks {
+				if absoluteStart >= tripleBlock.start && absoluteEnd <= tripleBlock.end {
+					isInsideTriple = true
+					break
+				}
+			}
+			if !isInsideTriple {
+				blocks = append(blocks, codeBlock{start: absoluteStart, end: absoluteEnd})
+			}
+		}
+		currentPos += len(line) + 1
+	}
+
+	return blocks
+}
+
+func TestMarkdown_FindCodeBlocksSameOutput(t *testing.T) {
+	docs := []string{
+		taggedDocument(20),
+		"",
+		"no code",
+		"`a` and `b`",
+		"`` empty `x`",
+		"`open\nclose` `x`",
+		"````\ncode\n````",
+		"```\nunclosed `x` fence",
+		"```a``` `b` ```c\n`d`\n```",
+		"a ` b ` c `",
+	}
+
+	// random mixes of backticks, line breaks and text
+	rnd := rand.New(rand.NewSource(1))
+	alphabet := []string{"`", "`", "```", "\n", "a", " "}
+	for i := 0; i < 500; i++ {
+		var b strings.Builder
+		for j := rnd.Intn(40); j > 0; j-- {
+			b.WriteString(alphabet[rnd.Intn(len(alphabet))])
+		}
+		docs = append(docs, b.String())
+	}
+
+	m := Markdown{}
+	for _, doc := range docs {
+		got, want := m.findCodeBlocks(doc), findCodeBlocksRegexp(doc)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("blocks differ for %q:\ngot  %v\nwant %v", doc, got, want)
+		}
+	}
+}
+
+func BenchmarkFindCodeBlocks(b *testing.B) {
+	m := Markdown{}
+	// ~6000 lines
+	doc := taggedDocument(1000)
+
+	b.Run("Scan", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m.findCodeBlocks(doc)
+		}
+	})
+	b.Run("Regexp", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			findCodeBlocksRegexp(doc)
+		}
+	})
+}
+
+func TestMarkdown_Blockquotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       Markdown
+		input    string
+		expected string
+	}{
+		{
+			name:     "Ragged quote",
+			md:       Markdown{NormalizeBlockquotes: true},
+			input:    "Text\n\n  >Quote start\n>continued\n>\n>>nested\n > >  deeper\n\nafter > not a quote",
+			expected: "Text\n\n> Quote start\n> continued\n>\n> > nested\n> >  deeper\n\nafter > not a quote",
+		},
+		{
+			name:     "Bold callout to GitHub",
+			md:       Markdown{NormalizeBlockquotes: true, CalloutStyle: CalloutGitHub},
+			input:    ">**Note:** keep the key\n>secret",
+			expected: "> [!NOTE]\n> keep the key\n> secret",
+		},
+		{
+			name:     "GitHub callout to bold",
+			md:       Markdown{CalloutStyle: CalloutBold},
+			input:    "> [!tip]\n> Use `make`\n\n> [!WARNING] Slow\n\n> Not a [!NOTE]",
+			expected: "> **Tip:**\n> Use `make`\n\n> **Warning:** Slow\n\n> Not a [!NOTE]",
+		},
+		{
+			name:     "Callout only on first line",
+			md:       Markdown{CalloutStyle: CalloutGitHub},
+			input:    "> Intro\n> **Note:** inline",
+			expected: "> Intro\n> **Note:** inline",
+		},
+		{
+			name:     "Code block in quote",
+			md:       Markdown{NormalizeBlockquotes: true, CalloutStyle: CalloutGitHub},
+			input:    " >Example:\n>  ```go\n>   if a >b {\n>>**Note:** kept\n> ```\n>done",
+			expected: "> Example:\n>  ```go\n>   if a >b {\n>>**Note:** kept\n> ```\n> done",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: tt.input}}}).
+				Sequential(tt.md).
+				Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			if got := result[0].Data.Content; got != tt.expected {
+				t.Errorf("content = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMarkdown_NormalizeCodeLang(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       Markdown
+		input    string
+		expected string
+		untagged []int
+	}{
+		{
+			name:     "Alias",
+			md:       Markdown{NormalizeCodeLang: true},
+			input:    "Text\n\n```JS {linenos=true}\nconst js = 1\n```\n\n> ~~~py\n> print('py')\n> ~~~",
+			expected: "Text\n\n```javascript {linenos=true}\nconst js = 1\n```\n\n> ~~~python\n> print('py')\n> ~~~",
+		},
+		{
+			name:     "Custom aliases",
+			md:       Markdown{NormalizeCodeLang: true, CodeLangAliases: map[string]string{"Shell": "bash"}},
+			input:    "```shell\nls\n```\n```js\nx\n```",
+			expected: "```bash\nls\n```\n```js\nx\n```",
+		},
+		{
+			name:     "Untagged flagged",
+			md:       Markdown{NormalizeCodeLang: true, FlagUntaggedCode: true},
+			input:    "# Doc\n\n```\n```js\n```\n\n  ````  \ncode\n````",
+			expected: "# Doc\n\n```\n```js\n```\n\n  ````  \ncode\n````",
+			untagged: []int{3, 7},
+		},
+		{
+			name:     "Tagged left alone",
+			md:       Markdown{NormalizeCodeLang: true, FlagUntaggedCode: true},
+			input:    "```javascript\n```js\n```\n\nInline `js` code.",
+			expected: "```javascript\n```js\n```\n\nInline `js` code.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: tt.input}}}).
+				Sequential(tt.md).
+				Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			msg := result[0]
+			if msg.Data.Content != tt.expected {
+				t.Errorf("content = %q, want %q", msg.Data.Content, tt.expected)
+			}
+			untagged, _ := msg.Metadata["untagged_code"].([]int)
+			if !reflect.DeepEqual(untagged, tt.untagged) {
+				t.Errorf("untagged_code = %v, want %v", untagged, tt.untagged)
+			}
+		})
+	}
+}
+
+func TestMarkdown_RenumberFootnotes(t *testing.T) {
+	md := Markdown{RenumberFootnotes: true}
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Out of order",
+			input:    "First[^3] and second[^1].\n\n[^1]: Second note.\n[^3]: First note.",
+			expected: "First[^1] and second[^2].\n\n[^2]: Second note.\n[^1]: First note.",
+		},
+		{
+			name:     "Duplicate reference",
+			input:    "A[^2], B[^5], again A[^2].\n\n[^5]: B.\n[^2]: A.\n[^9]: Unused.",
+			expected: "A[^1], B[^2], again A[^1].\n\n[^2]: B.\n[^1]: A.\n[^3]: Unused.",
+		},
+		{
+			name:     "Code left alone",
+			input:    "Note[^2] and `x[^1]`.\n\n```\ny[^1]\n[^1]: code\n```\n\n[^2]: Note.",
+			expected: "Note[^1] and `x[^1]`.\n\n```\ny[^1]\n[^1]: code\n```\n\n[^1]: Note.",
+		},
+		{
+			name:     "Reference links",
+			input:    "See [docs][4] and [api][2], [docs][4] and [4]. Not a link [7], [inline](x) and [named][ref].\n\n[2]: /api\n[4]: /docs\n[ref]: /ref",
+			expected: "See [docs][1] and [api][2], [docs][1] and [1]. Not a link [7], [inline](x) and [named][ref].\n\n[2]: /api\n[1]: /docs\n[ref]: /ref",
+		},
+		{
+			name:     "Footnotes and links numbered separately",
+			input:    "Text[^4] with [link][3].\n\n[^4]: Note.\n[3]: /url",
+			expected: "Text[^1] with [link][1].\n\n[^1]: Note.\n[1]: /url",
+		},
+		{
+			name:     "Named footnotes kept",
+			input:    "Text[^note] and[^2].\n\n[^note]: Named.\n[^2]: Numbered.",
+			expected: "Text[^note] and[^1].\n\n[^note]: Named.\n[^1]: Numbered.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: tt.input}}}).
+				Sequential(md).
+				Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			if result[0].Data.Content != tt.expected {
+				t.Errorf("content = %q, want %q", result[0].Data.Content, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMarkdown_NormalizeLinkStyle(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       Markdown
+		input    string
+		expected string
+	}{
+		{
+			name:     "Index files",
+			md:       Markdown{NormalizeLinkStyle: true},
+			input:    "[Docs](/docs/index.md), [API](api/INDEX.html#auth \"API\"), [Up](../index.md) and [Here](index.md?v=1)",
+			expected: "[Docs](/docs/), [API](api/#auth \"API\"), [Up](../) and [Here](./?v=1)",
+		},
+		{
+			name:     "Add slash",
+			md:       Markdown{NormalizeLinkStyle: true, TrailingSlash: SlashAdd},
+			input:    "[Intro](/docs/intro), [Guide](guide#setup), [Image](img/a.png), [Dir](/docs/) and [Index](/blog/index.md)",
+			expected: "[Intro](/docs/intro/), [Guide](guide/#setup), [Image](img/a.png), [Dir](/docs/) and [Index](/blog/)",
+		},
+		{
+			name:     "Remove slash",
+			md:       Markdown{NormalizeLinkStyle: true, TrailingSlash: SlashRemove},
+			input:    "[Intro](/docs/intro/), [Home](/), [Up](../), [Index](/blog/index.md#top)",
+			expected: "[Intro](/docs/intro), [Home](/), [Up](../), [Index](/blog#top)",
+		},
+		{
+			name:     "External, anchors and code left alone",
+			md:       Markdown{NormalizeLinkStyle: true, TrailingSlash: SlashAdd},
+			input:    "[Site](https://example.com/index.md), [Mail](mailto:a@b.c), [Top](#top), [CDN](//cdn.example.com/x)\n\n`[a](/docs/index.md)`\n\n```\n[b](/docs/index.md)\n```",
+			expected: "[Site](https://example.com/index.md), [Mail](mailto:a@b.c), [Top](#top), [CDN](//cdn.example.com/x)\n\n`[a](/docs/index.md)`\n\n```\n[b](/docs/index.md)\n```",
+		},
+		{
+			name:     "Reference definitions",
+			md:       Markdown{NormalizeLinkStyle: true, TrailingSlash: SlashAdd, IndexFiles: []string{"README.md"}},
+			input:    "See [docs][d] and [intro](/intro).\n\n[d]: /docs/README.md \"Docs\"",
+			expected: "See [docs][d] and [intro](/intro/).\n\n[d]: /docs/ \"Docs\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: tt.input}}}).
+				Sequential(tt.md).
+				Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("pipeline failed: %v", err)
+			}
+
+			if result[0].Data.Content != tt.expected {
+				t.Errorf("content = %q, want %q", result[0].Data.Content, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package text
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// Marker is a marker comment, like TODO, found in content.
+type Marker struct {
+	File string
+	// Line is the 1-based line number.
+	Line   int
+	Marker string
+	// Text is the rest of the line after the marker.
+	Text string
+}
+
+// ExtractMarkers is a job that collects marker comments, like TODO and FIXME, into metadata as []Marker.
+// With Report set, a report file listing the markers of all files is emitted when the input is closed.
+type ExtractMarkers struct {
+	// Markers are the marker keywords. Defaults to TODO and FIXME.
+	Markers []string
+	// IgnoreCase matches markers in any case, e.g. "todo".
+	IgnoreCase bool
+	// Partial matches markers inside words too, e.g. "TODOS".
+	Partial bool
+	// Key is the metadata key to store the markers in. Defaults to "markers".
+	Key string
+	// Report is the name of the report file to emit, empty means no report.
+	Report string
+}
+
+// markerTrim is stripped from the start of the marker text, like the colon after the marker.
+const markerTrim = " \t:-"
+
+func (e ExtractMarkers) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	defer close(out)
+
+	key := e.Key
+	if key == "" {
+		key = "markers"
+	}
+	pattern := e.pattern()
+
+	var all []Marker
+	for {
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				if e.Report != "" {
+					report := files.TextFile{Name: e.Report, Content: formatMarkers(all)}
+					select {
+					case out <- tesei.NewMessageWithID(e.Report, &report):
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+
+			if msg.Error == nil {
+				markers := extractMarkers(pattern, files.URLPath(filepath.Join(msg.Data.Folder, msg.Data.Name)), msg.Data.Content)
+				msg.Metadata[key] = markers
+				all = append(all, markers...)
+			}
+
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (e ExtractMarkers) pattern() *regexp.Regexp {
+	markers := e.Markers
+	if len(markers) == 0 {
+		markers = []string{"TODO", "FIXME"}
+	}
+
+	quoted := make([]string, len(markers))
+	for i, marker := range markers {
+		quoted[i] = regexp.QuoteMeta(marker)
+	}
+
+	expr := "(" + strings.Join(quoted, "|") + ")"
+	if !e.Partial {
+		expr = `\b` + expr + `\b`
+	}
+	if e.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	return regexp.MustCompile(expr)
+}
+
+func extractMarkers(pattern *regexp.Regexp, file, content string) []Marker {
+	markers := []Marker{}
+	for i, line := range strings.Split(content, "\n") {
+		match := pattern.FindStringSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+
+		text := strings.TrimSpace(line[match[1]:])
+		for _, end := range []string{"*/", "-->"} {
+			text = strings.TrimSpace(strings.TrimSuffix(text, end))
+		}
+		markers = append(markers, Marker{
+			File:   file,
+			Line:   i + 1,
+			Marker: line[match[2]:match[3]],
+			Text:   strings.TrimLeft(text, markerTrim),
+		})
+	}
+	return markers
+}
+
+// formatMarkers formats markers as "file:line: MARKER text" lines.
+func formatMarkers(markers []Marker) string {
+	var sb strings.Builder
+	for _, m := range markers {
+		fmt.Fprintf(&sb, "%s:%d: %s %s\n", m.File, m.Line, m.Marker, m.Text)
+	}
+	return sb.String()
+}
@@ -0,0 +1,75 @@
+package text
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestExtractMarkers(t *testing.T) {
+	content := "# Guide\n\n" +
+		"<!-- TODO: add screenshots -->\n" +
+		"Some text. FIXME(max) broken link\n" +
+		"// HACK: works around the parser\n" +
+		"The TODOS list and a lowercase todo are not markers.\n"
+
+	var result []*tesei.Message[files.TextFile]
+	_, err := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{{Name: "guide.md", Folder: "docs", Content: content}}}).
+		Sequential(ExtractMarkers{Markers: []string{"TODO", "FIXME", "HACK"}, Report: "markers.txt"}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("Expected the file and the report, got %d messages", len(result))
+	}
+
+	expected := []Marker{
+		{File: "docs/guide.md", Line: 3, Marker: "TODO", Text: "add screenshots"},
+		{File: "docs/guide.md", Line: 4, Marker: "FIXME", Text: "(max) broken link"},
+		{File: "docs/guide.md", Line: 5, Marker: "HACK", Text: "works around the parser"},
+	}
+	if markers := result[0].Metadata["markers"]; !reflect.DeepEqual(markers, expected) {
+		t.Errorf("markers = %+v, want %+v", markers, expected)
+	}
+
+	report := "docs/guide.md:3: TODO add screenshots\n" +
+		"docs/guide.md:4: FIXME (max) broken link\n" +
+		"docs/guide.md:5: HACK works around the parser\n"
+	if result[1].Data.Name != "markers.txt" || result[1].Data.Content != report {
+		t.Errorf("report %s = %q, want %q", result[1].Data.Name, result[1].Data.Content, report)
+	}
+}
+
+func TestExtractMarkersMatching(t *testing.T) {
+	content := "todo: lower\nTODOS plural\nTODO upper"
+
+	tests := []struct {
+		name     string
+		job      ExtractMarkers
+		expected []int
+	}{
+		{name: "Default", job: ExtractMarkers{}, expected: []int{3}},
+		{name: "IgnoreCase", job: ExtractMarkers{IgnoreCase: true}, expected: []int{1, 3}},
+		{name: "Partial", job: ExtractMarkers{Partial: true}, expected: []int{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []int
+			for _, m := range extractMarkers(tt.job.pattern(), "a.md", content) {
+				lines = append(lines, m.Line)
+			}
+			if !reflect.DeepEqual(lines, tt.expected) {
+				t.Errorf("lines = %v, want %v", lines, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package text
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// NavIndex is a job that builds a navigation file, like SUMMARY.md for a docs site, listing all files
+// of the input grouped by folder and sorted by path. Files pass through unchanged, the navigation file
+// is emitted when the input is closed.
+// Titles are the first H1 heading of each file (or a metadata value), falling back to the file name.
+type NavIndex struct {
+	// Name is the name of the navigation file. Defaults to "SUMMARY.md".
+	Name string
+	// Folder is the folder of the navigation file, links are relative to it.
+	Folder string
+	// Key is the metadata key holding the title. If empty, the first H1 heading is used.
+	Key string
+	// Header starts the navigation file. Defaults to "# Summary\n".
+	Header string
+	// Group is the template of a folder heading, with {{folder}}. Defaults to "## {{folder}}".
+	// Files in the root folder are listed first, without a heading.
+	Group string
+	// Item is the template of a file entry, with {{title}}, {{path}} and the file metadata.
+	// Defaults to "- [{{title}}]({{path}})".
+	Item string
+}
+
+type navEntry struct {
+	folder string
+	path   string
+	msg    *tesei.Message[files.TextFile]
+}
+
+func (n NavIndex) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	defer close(out)
+
+	var entries []navEntry
+	for {
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				index := n.index(entries)
+				select {
+				case out <- tesei.NewMessageWithID(filepath.Join(index.Folder, index.Name), &index):
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			if msg.Error == nil {
+				entries = append(entries, n.entry(msg))
+			}
+
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// entry records the path and title of the message, the message itself goes on down the pipeline.
+func (n NavIndex) entry(msg *tesei.Message[files.TextFile]) navEntry {
+	full := filepath.Join(msg.Data.Folder, msg.Data.Name)
+	if rel, err := filepath.Rel(n.Folder, full); n.Folder != "" && err == nil {
+		full = rel
+	}
+	p := files.URLPath(full)
+
+	var title string
+	if n.Key != "" {
+		title = files.FormatValue(msg.Metadata[n.Key])
+	} else {
+		title = firstTitle(msg.Data.Content)
+	}
+	if title == "" {
+		title = strings.TrimSuffix(msg.Data.Name, filepath.Ext(msg.Data.Name))
+	}
+
+	vars := tesei.NewMessage(files.TextFile{})
+	for k, v := range msg.Metadata {
+		vars.Metadata[k] = v
+	}
+	vars.Metadata["title"] = title
+	vars.Metadata["path"] = p
+
+	folder := path.Dir(p)
+	if folder == "." {
+		folder = ""
+	}
+	return navEntry{folder: folder, path: p, msg: vars}
+}
+
+func (n NavIndex) index(entries []navEntry) files.TextFile {
+	name := n.Name
+	if name == "" {
+		name = "SUMMARY.md"
+	}
+	header := n.Header
+	if header == "" {
+		header = "# Summary\n"
+	}
+	group := n.Group
+	if group == "" {
+		group = "## {{folder}}"
+	}
+	item := n.Item
+	if item == "" {
+		item = "- [{{title}}]({{path}})"
+	}
+
+	// root files first, then folders by name, files by path within them
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].folder != entries[j].folder {
+			return entries[i].folder < entries[j].folder
+		}
+		return entries[i].path < entries[j].path
+	})
+
+	var b strings.Builder
+	b.WriteString(header)
+	for i, e := range entries {
+		if i == 0 || e.folder != entries[i-1].folder {
+			b.WriteString("\n")
+			if e.folder != "" {
+				folder := tesei.NewMessage(files.TextFile{})
+				folder.Metadata["folder"] = e.folder
+				b.WriteString(files.ResolveString(group, folder))
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(files.ResolveString(item, e.msg))
+		b.WriteString("\n")
+	}
+
+	return files.TextFile{Name: name, Folder: n.Folder, Content: b.String()}
+}
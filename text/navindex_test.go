@@ -0,0 +1,66 @@
+package text
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestNavIndex(t *testing.T) {
+	var result []*tesei.Message[files.TextFile]
+	_, err := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{
+			{Name: "b.md", Folder: "docs/guide", Content: "# Beta {#beta}\n\nText"},
+			{Name: "x.md", Folder: "docs/api", Content: "No heading"},
+			{Name: "a.md", Folder: "docs/guide", Content: "---\ntitle: meta\n---\n# Alpha\n"},
+		}}).
+		Sequential(NavIndex{Folder: "docs"}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	if len(result) != 4 {
+		t.Fatalf("Expected 3 files and the index, got %d messages", len(result))
+	}
+
+	index := result[3].Data
+	expected := "# Summary\n" +
+		"\n## api\n\n" +
+		"- [x](api/x.md)\n" +
+		"\n## guide\n\n" +
+		"- [Alpha](guide/a.md)\n" +
+		"- [Beta](guide/b.md)\n"
+	if index.Name != "SUMMARY.md" || index.Folder != "docs" {
+		t.Errorf("index path = %s/%s", index.Folder, index.Name)
+	}
+	if index.Content != expected {
+		t.Errorf("index =\n%s\nwant\n%s", index.Content, expected)
+	}
+}
+
+func TestNavIndexTemplates(t *testing.T) {
+	var result []*tesei.Message[files.TextFile]
+	_, err := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{
+			{Name: "index.md", Content: "# Home"},
+			{Name: "setup.md", Folder: "guide", Content: "# Setup"},
+		}}).
+		Sequential(NavIndex{Name: "index.md", Header: "# Contents\n", Group: "### {{folder}}/", Item: "* {{title}}: {{path}}"}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &result}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	expected := "# Contents\n\n* Home: index.md\n\n### guide/\n\n* Setup: guide/setup.md\n"
+	if got := result[len(result)-1].Data.Content; got != expected {
+		t.Errorf("index =\n%s\nwant\n%s", got, expected)
+	}
+}
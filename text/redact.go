@@ -0,0 +1,137 @@
+package text
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// built-in redaction patterns, applied in this order
+var redactPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+	valid   func(string) bool
+}{
+	{
+		name: "key",
+		// OpenAI/Anthropic, AWS access key, GitHub, Google and Slack tokens
+		pattern: regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{20,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|AIza[0-9A-Za-z_-]{35}|xox[abprs]-[A-Za-z0-9-]{10,})`),
+	},
+	{
+		name:    "email",
+		pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`),
+	},
+	{
+		name:    "card",
+		pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid:   luhn,
+	},
+}
+
+// Redact is a job that replaces sensitive data in content with a placeholder, before sending
+// it to an LLM or logging it. Built-in patterns find emails, common API key formats (OpenAI, Anthropic,
+// AWS, GitHub, Google, Slack) and card numbers, which must pass the Luhn check.
+// The number of redactions by pattern name is stored in metadata.
+type Redact struct {
+	// Patterns are additional regexes by name, a name of a built-in pattern ("email", "key", "card") replaces it.
+	Patterns map[string]string
+	// NoBuiltin disables the built-in patterns.
+	NoBuiltin bool
+	// Placeholder replaces the matches, {{name}} is the pattern name. Defaults to "[REDACTED]".
+	Placeholder string
+	// Key is the metadata key for the map of counts by pattern name. Defaults to "redacted".
+	Key string
+}
+
+type redactRule struct {
+	name    string
+	pattern *regexp.Regexp
+	valid   func(string) bool
+}
+
+func (r Redact) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	key := r.Key
+	if key == "" {
+		key = "redacted"
+	}
+	placeholder := r.Placeholder
+	if placeholder == "" {
+		placeholder = "[REDACTED]"
+	}
+	rules, err := r.rules()
+
+	tesei.TransformStage(ctx, in, out, "Redact", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		if err != nil {
+			return msg, err
+		}
+
+		counts := make(map[string]int)
+		content := msg.Data.Content
+		for _, rule := range rules {
+			replacement := strings.ReplaceAll(placeholder, "{{name}}", rule.name)
+			content = rule.pattern.ReplaceAllStringFunc(content, func(match string) string {
+				if rule.valid != nil && !rule.valid(match) {
+					return match
+				}
+				counts[rule.name]++
+				return replacement
+			})
+		}
+
+		msg.Data.Content = content
+		msg.Metadata[key] = counts
+		return msg, nil
+	})
+}
+
+// rules returns the built-in patterns followed by the custom ones, sorted by name.
+func (r Redact) rules() ([]redactRule, error) {
+	var rules []redactRule
+	if !r.NoBuiltin {
+		for _, p := range redactPatterns {
+			if _, ok := r.Patterns[p.name]; !ok {
+				rules = append(rules, redactRule{p.name, p.pattern, p.valid})
+			}
+		}
+	}
+
+	names := make([]string, 0, len(r.Patterns))
+	for name := range r.Patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pattern, err := regexp.Compile(r.Patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("redact pattern %q: %w", name, err)
+		}
+		rules = append(rules, redactRule{name: name, pattern: pattern})
+	}
+	return rules, nil
+}
+
+// luhn reports whether the digits of the number pass the Luhn checksum.
+func luhn(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
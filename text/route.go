@@ -0,0 +1,88 @@
+package text
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// RouteByFrontmatter is a job that computes the output path of a file from its front-matter.
+// Values are taken from metadata, then from the YAML, TOML or JSON front-matter of the content, then from Defaults;
+// the "name" value is always available as the file name without extension.
+// A permalink value overrides the Path template, a permalink ending with "/" becomes a folder with index.html.
+type RouteByFrontmatter struct {
+	// Path is the template of the output path, like "{{category}}/{{slug}}/index.html".
+	Path string
+	// Root is the folder the computed path is relative to.
+	Root string
+	// Defaults are the values for keys missing in metadata and front-matter.
+	Defaults map[string]string
+	// PermalinkKey is the key of the permalink value. Defaults to "permalink".
+	PermalinkKey string
+}
+
+func (r RouteByFrontmatter) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	permalinkKey := r.PermalinkKey
+	if permalinkKey == "" {
+		permalinkKey = "permalink"
+	}
+
+	tesei.TransformStage(ctx, in, out, "RouteByFrontmatter", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		vars := tesei.NewMessage(files.TextFile{})
+		for k, v := range r.Defaults {
+			vars.Metadata[k] = v
+		}
+		vars.Metadata["name"] = strings.TrimSuffix(msg.Data.Name, filepath.Ext(msg.Data.Name))
+		if format, block, _, ok := detectFrontmatter(msg.Data.Content); ok {
+			data, err := decodeFrontmatter(format, block)
+			if err != nil {
+				return msg, fmt.Errorf("%w: %w", ErrFrontmatter, err)
+			}
+			for k, v := range data.plain() {
+				if value, ok := routeValue(v); ok {
+					vars.Metadata[k] = value
+				}
+			}
+		}
+		for k, v := range msg.Metadata {
+			vars.Metadata[k] = v
+		}
+
+		target := files.FormatValue(vars.Metadata[permalinkKey])
+		if target != "" {
+			if strings.HasSuffix(target, "/") {
+				target += "index.html"
+			}
+		} else {
+			target = files.ResolveString(r.Path, vars)
+		}
+
+		// missing values leave empty segments, which are dropped by cleaning;
+		// values taken from OS paths may use backslashes
+		target = strings.TrimPrefix(path.Clean("/"+files.URLPath(target)), "/")
+		if target == "" {
+			return msg, nil
+		}
+
+		msg.Data.Folder = filepath.Join(r.Root, filepath.FromSlash(path.Dir(target)))
+		msg.Data.Name = path.Base(target)
+		return msg, nil
+	})
+}
+
+// routeValue renders a scalar front-matter value for the path template, nested values are skipped.
+func routeValue(v any) (string, bool) {
+	switch val := v.(type) {
+	case nil, map[string]any, []any:
+		return "", false
+	case time.Time:
+		return formatTime(val), true
+	default:
+		return fmt.Sprint(val), true
+	}
+}
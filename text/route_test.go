@@ -0,0 +1,104 @@
+package text
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestRouteByFrontmatter(t *testing.T) {
+	job := RouteByFrontmatter{
+		Path:     "{{category}}/{{slug}}/index.html",
+		Root:     "public",
+		Defaults: map[string]string{"category": "misc"},
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		meta     map[string]any
+		expected string
+	}{
+		{
+			name:     "category and slug",
+			content:  "---\ntitle: Hello\ncategory: blog\nslug: \"hello-world\"\n---\n# Hello\n",
+			expected: "public/blog/hello-world/index.html",
+		},
+		{
+			name:     "permalink override",
+			content:  "---\ncategory: blog\nslug: hello\npermalink: /about/team/\n---\n",
+			expected: "public/about/team/index.html",
+		},
+		{
+			name:     "permalink file",
+			content:  "---\npermalink: feeds/rss.xml\n---\n",
+			expected: "public/feeds/rss.xml",
+		},
+		{
+			name:     "defaults and missing keys",
+			content:  "# No front-matter\n",
+			expected: "public/misc/index.html",
+		},
+		{
+			name:     "metadata takes precedence",
+			content:  "---\ncategory: blog\n---\n",
+			meta:     map[string]any{"slug": "from-meta"},
+			expected: "public/blog/from-meta/index.html",
+		},
+		{
+			name:     "quoted value with a comment",
+			content:  "---\ncategory: \"blog\" # the section\nslug: 'it''s'\n---\n",
+			expected: "public/blog/it's/index.html",
+		},
+		{
+			name:     "nested values skipped",
+			content:  "---\ncategory: blog\nseo:\n  slug: nested\nslug: top\n---\n",
+			expected: "public/blog/top/index.html",
+		},
+		{
+			name:     "toml front-matter",
+			content:  "+++\ncategory = \"news\"\nslug = 2024\n+++\n# Hello\n",
+			expected: "public/news/2024/index.html",
+		},
+		{
+			name:     "json front-matter",
+			content:  "{\"category\": \"docs\", \"slug\": \"intro\"}\n# Hello\n",
+			expected: "public/docs/intro/index.html",
+		},
+		{
+			name:     "windows separators in values",
+			meta:     map[string]any{"category": `guides\api`, "slug": "auth"},
+			expected: "public/guides/api/auth/index.html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: []files.TextFile{{Name: "post.md", Folder: "src", Content: tt.content}}}).
+				Sequential(tesei.TransformJob[files.TextFile]{Transform: func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+					for k, v := range tt.meta {
+						msg.Metadata[k] = v
+					}
+					return msg, nil
+				}}).
+				Sequential(job).
+				Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			got := filepath.ToSlash(filepath.Join(results[0].Data.Folder, results[0].Data.Name))
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
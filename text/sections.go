@@ -0,0 +1,188 @@
+package text
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// ChunkBySection is a job that splits markdown into one chunk per section, for RAG over docs.
+// Each chunk starts with the breadcrumb of its headings, like "# Guide > ## Setup", followed by
+// the section text, so a retrieved chunk keeps its context. The breadcrumb is also stored in metadata.
+// Chunks carry the split_* metadata of files.Split. Headings in code blocks are ignored.
+type ChunkBySection struct {
+	// MaxLevel is the deepest heading level starting a section, deeper headings stay in the text. Defaults to 6.
+	MaxLevel int
+	// MaxBytes limits the size of the chunks with their breadcrumbs, 0 means no limit. Longer sections are split
+	// by paragraphs (or at spaces for long paragraphs), each piece gets the breadcrumb.
+	MaxBytes int
+	// Key is the metadata key for the breadcrumb. Defaults to "breadcrumb".
+	Key string
+}
+
+type sectionChunk struct {
+	breadcrumb string
+	text       string
+}
+
+func (c ChunkBySection) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	defer close(out)
+
+	key := c.Key
+	if key == "" {
+		key = "breadcrumb"
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+
+			if msg.Error != nil {
+				select {
+				case out <- msg:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			chunks := c.chunks(msg.Data.Content)
+			for i, chunk := range chunks {
+				newMsg := msg.Child()
+				newMsg.ID = fmt.Sprintf("%s_%d", msg.ID, i)
+				newMsg.Data.Content = chunk.text
+				newMsg.Metadata[key] = chunk.breadcrumb
+				newMsg.Metadata["split_id"] = msg.ID
+				newMsg.Metadata["split_index"] = i
+				newMsg.Metadata["split_total"] = len(chunks)
+				if parent, ok := msg.Metadata["parent_id"]; ok {
+					newMsg.Metadata["split_parent_id"] = parent
+				}
+
+				select {
+				case out <- newMsg:
+				case <-ctx.Done():
+					return
+				}
+			}
+			ctx.Release(msg.ID)
+		}
+	}
+}
+
+// chunks returns the sections of the content with their breadcrumbs, the text before
+// the first heading has no breadcrumb. Sections without text are skipped.
+func (c ChunkBySection) chunks(content string) []sectionChunk {
+	maxLevel := c.MaxLevel
+	if maxLevel <= 0 {
+		maxLevel = 6
+	}
+
+	m := Markdown{}
+	lines := strings.Split(content, "\n")
+
+	var chunks []sectionChunk
+	var path []headingLine
+	start, breadcrumb := 0, ""
+	flush := func(end int) {
+		text := strings.Trim(strings.Join(lines[start:end], "\n"), "\n")
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, c.split(breadcrumb, text)...)
+		}
+	}
+
+	for _, h := range m.findHeadings(content, lines) {
+		if h.level > maxLevel {
+			continue
+		}
+		flush(h.index)
+
+		for len(path) > 0 && path[len(path)-1].level >= h.level {
+			path = path[:len(path)-1]
+		}
+		path = append(path, h)
+
+		parts := make([]string, len(path))
+		for i, p := range path {
+			parts[i] = strings.Repeat("#", p.level) + " " + strings.TrimSpace(headingIDPattern.ReplaceAllString(p.text, ""))
+		}
+		breadcrumb = strings.Join(parts, " > ")
+		start = h.index + 1
+	}
+	flush(len(lines))
+
+	return chunks
+}
+
+// split prefixes the text with the breadcrumb, cutting it into pieces within MaxBytes.
+// A breadcrumb taking more than half of the limit is left out of the text, it is still in metadata.
+func (c ChunkBySection) split(breadcrumb, text string) []sectionChunk {
+	prefix := ""
+	if breadcrumb != "" {
+		prefix = breadcrumb + "\n\n"
+	}
+	if c.MaxBytes <= 0 || len(prefix)+len(text) <= c.MaxBytes {
+		return []sectionChunk{{breadcrumb, prefix + text}}
+	}
+	if len(prefix) > c.MaxBytes/2 {
+		prefix = ""
+	}
+	budget := c.MaxBytes - len(prefix)
+
+	var pieces []string
+	current := ""
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if current != "" && len(current)+2+len(paragraph) <= budget {
+			current += "\n\n" + paragraph
+			continue
+		}
+		if current != "" {
+			pieces = append(pieces, current)
+			current = ""
+		}
+		if len(paragraph) <= budget {
+			current = paragraph
+			continue
+		}
+		pieces = append(pieces, cutAtSpaces(paragraph, budget)...)
+	}
+	if current != "" {
+		pieces = append(pieces, current)
+	}
+
+	chunks := make([]sectionChunk, len(pieces))
+	for i, piece := range pieces {
+		chunks[i] = sectionChunk{breadcrumb, prefix + piece}
+	}
+	return chunks
+}
+
+// cutAtSpaces cuts the text into pieces of at most size bytes at whitespace, the whitespace at the cuts is dropped.
+// Words longer than size are cut by files.SplitBySize.
+func cutAtSpaces(text string, size int) []string {
+	var pieces []string
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	for len(text) > size {
+		// the whitespace right after the limit still ends a fitting piece
+		cut := strings.LastIndexFunc(text[:size+1], unicode.IsSpace)
+		if cut < 0 {
+			cut = len(files.SplitBySize(size)(text)[0])
+		}
+		if piece := strings.TrimRightFunc(text[:cut], unicode.IsSpace); piece != "" {
+			pieces = append(pieces, piece)
+		}
+		text = strings.TrimLeftFunc(text[cut:], unicode.IsSpace)
+	}
+	if text != "" {
+		pieces = append(pieces, text)
+	}
+	return pieces
+}
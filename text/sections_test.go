@@ -0,0 +1,114 @@
+package text
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/testutil"
+)
+
+// guide is a source of one markdown file.
+func guide(content string) tesei.Job[files.TextFile] {
+	return files.Source{Files: []files.TextFile{{Name: "guide.md", Content: content}}}
+}
+
+func TestChunkBySection(t *testing.T) {
+	content := "Intro text\n\n" +
+		"# Guide\n\nWelcome.\n\n" +
+		"## Setup\n\nInstall it.\n\n" +
+		"### Linux\n\nUse apt.\n\n" +
+		"## Usage {#usage}\n\nRun it.\n```\n# not a heading\n```\n\n" +
+		"# API\n" +
+		"## Auth\n\nTokens.\n"
+
+	result := testutil.Run(t, guide(content), ChunkBySection{})
+
+	expected := []struct{ breadcrumb, content string }{
+		{"", "Intro text"},
+		{"# Guide", "# Guide\n\nWelcome."},
+		{"# Guide > ## Setup", "# Guide > ## Setup\n\nInstall it."},
+		{"# Guide > ## Setup > ### Linux", "# Guide > ## Setup > ### Linux\n\nUse apt."},
+		{"# Guide > ## Usage", "# Guide > ## Usage\n\nRun it.\n```\n# not a heading\n```"},
+		{"# API > ## Auth", "# API > ## Auth\n\nTokens."},
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d", len(expected), len(result))
+	}
+	for i, msg := range result {
+		if msg.Data.Content != expected[i].content || msg.Metadata["breadcrumb"] != expected[i].breadcrumb {
+			t.Errorf("chunk %d = %q (%v), want %q (%q)", i, msg.Data.Content, msg.Metadata["breadcrumb"], expected[i].content, expected[i].breadcrumb)
+		}
+		if msg.Metadata["split_id"] != "guide.md" || msg.Metadata["split_index"] != i || msg.Metadata["split_total"] != len(expected) {
+			t.Errorf("chunk %d split metadata = %v", i, msg.Metadata)
+		}
+	}
+}
+
+func TestChunkBySectionMaxBytes(t *testing.T) {
+	content := "# Guide\n## Setup\n\nFirst paragraph.\n\nSecond one.\n\nThird paragraph, which is rather long."
+
+	result := testutil.Run(t, guide(content), ChunkBySection{MaxBytes: 50})
+
+	var chunks []string
+	for _, msg := range result {
+		if len(msg.Data.Content) > 50 {
+			t.Errorf("chunk over the limit: %q", msg.Data.Content)
+		}
+		if msg.Metadata["breadcrumb"] != "# Guide > ## Setup" {
+			t.Errorf("breadcrumb = %v", msg.Metadata["breadcrumb"])
+		}
+		chunks = append(chunks, msg.Data.Content)
+	}
+
+	expected := []string{
+		"# Guide > ## Setup\n\nFirst paragraph.\n\nSecond one.",
+		"# Guide > ## Setup\n\nThird paragraph, which is",
+		"# Guide > ## Setup\n\nrather long.",
+	}
+	if !reflect.DeepEqual(chunks, expected) {
+		t.Errorf("chunks = %q, want %q", chunks, expected)
+	}
+}
+
+func TestChunkBySectionMaxBytesEdges(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		breadcrumb string
+		expected   []string
+	}{
+		{
+			name:       "Long breadcrumb left out",
+			content:    "# A rather long heading title\n\nShort text that goes on.",
+			breadcrumb: "# A rather long heading title",
+			expected:   []string{"Short text that goes on."},
+		},
+		{
+			name:     "Long word cut",
+			content:  "tiny Supercalifragilisticexpialidocious end",
+			expected: []string{"tiny", "Supercalifragilisticexpialidoc", "ious end"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := testutil.Run(t, guide(tt.content), ChunkBySection{MaxBytes: 30})
+
+			var chunks []string
+			for _, msg := range result {
+				if len(msg.Data.Content) > 30 {
+					t.Errorf("chunk over the limit: %q", msg.Data.Content)
+				}
+				if msg.Metadata["breadcrumb"] != tt.breadcrumb {
+					t.Errorf("breadcrumb = %v, want %q", msg.Metadata["breadcrumb"], tt.breadcrumb)
+				}
+				chunks = append(chunks, msg.Data.Content)
+			}
+			if !reflect.DeepEqual(chunks, tt.expected) {
+				t.Errorf("chunks = %q, want %q", chunks, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package text
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// SplitSentences is a job that splits the content into sentences, one message per sentence,
+// with the split_* metadata of files.Split. The whitespace between sentences is not kept, so
+// files.Merge{Glue: " "} joins them back into one text, but paragraph and line breaks become spaces.
+// Abbreviations, initials and decimal numbers don't end a sentence. Paragraphs and headings
+// always end one, and fenced code blocks are kept as single units.
+type SplitSentences struct{}
+
+func (s SplitSentences) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	files.Split{By: splitSentences}.Run(ctx, in, out)
+}
+
+// abbreviations don't end a sentence when followed by a dot
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true,
+	"st": true, "vs": true, "inc": true, "ltd": true, "co": true, "no": true, "fig": true,
+	"cf": true, "approx": true, "dept": true, "est": true, "vol": true, "jan": true,
+	"feb": true, "mar": true, "apr": true, "jun": true, "jul": true, "aug": true,
+	"sep": true, "sept": true, "oct": true, "nov": true, "dec": true,
+}
+
+const (
+	sentenceEnds    = ".!?…"
+	sentenceClosers = "\"')]»”’"
+)
+
+func splitSentences(content string) []string {
+	var sentences []string
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			sentences = append(sentences, splitParagraph(strings.Join(paragraph, "\n"))...)
+			paragraph = nil
+		}
+	}
+
+	var block []string
+	var fences files.FenceScanner
+	for _, line := range strings.Split(content, "\n") {
+		switch fences.Next(line) {
+		case files.OpeningFence:
+			flush()
+			block = []string{line}
+			continue
+		case files.InsideFence:
+			block = append(block, line)
+			continue
+		case files.ClosingFence:
+			sentences = append(sentences, strings.Join(append(block, line), "\n"))
+			block = nil
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case headingPattern.MatchString(trimmed):
+			flush()
+			sentences = append(sentences, trimmed)
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+	if len(block) > 0 {
+		// unclosed code block
+		sentences = append(sentences, strings.Join(block, "\n"))
+	}
+
+	return sentences
+}
+
+func splitParagraph(text string) []string {
+	var sentences []string
+	add := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			sentences = append(sentences, s)
+		}
+	}
+
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !strings.ContainsRune(sentenceEnds, r) {
+			i += size
+			continue
+		}
+
+		end := i + size
+		for end < len(text) {
+			next, n := utf8.DecodeRuneInString(text[end:])
+			if !strings.ContainsRune(sentenceEnds+sentenceClosers, next) {
+				break
+			}
+			end += n
+		}
+
+		if isSentenceEnd(text, start, i, end, r) {
+			add(text[start:end])
+			start = end
+		}
+		i = end
+	}
+	add(text[start:])
+
+	return sentences
+}
+
+// isSentenceEnd checks the terminator r at text[at:end] of the sentence starting at start.
+func isSentenceEnd(text string, start, at, end int, r rune) bool {
+	// decimals, versions, domains: no space after the dot
+	if end < len(text) {
+		next, _ := utf8.DecodeRuneInString(text[end:])
+		if !unicode.IsSpace(next) {
+			return false
+		}
+	}
+
+	// a sentence starts with a capital letter, a digit or punctuation, not a lowercase word
+	rest := strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+	if next, _ := utf8.DecodeRuneInString(rest); rest != "" && unicode.IsLower(next) {
+		return false
+	}
+
+	if r != '.' || end-at > 1 {
+		return true
+	}
+
+	fields := strings.Fields(text[start:at])
+	if len(fields) == 0 {
+		return true
+	}
+	word := strings.TrimLeft(fields[len(fields)-1], "([\"'")
+
+	// initials and dotted abbreviations, like J. or e.g. or U.S.
+	if utf8.RuneCountInString(word) == 1 && unicode.IsLetter([]rune(word)[0]) || strings.Contains(word, ".") {
+		return false
+	}
+	return !abbreviations[strings.ToLower(word)]
+}
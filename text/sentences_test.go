@@ -0,0 +1,84 @@
+package text
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "Abbreviations and decimals",
+			input: "Dr. Smith paid $3.50 for coffee, e.g. a latte. It was 2.5 times the usual price! " +
+				"Was it worth it? Mr. J. R. Doe thinks so, see Fig. 2 in the U.S. report.",
+			expected: []string{
+				"Dr. Smith paid $3.50 for coffee, e.g. a latte.",
+				"It was 2.5 times the usual price!",
+				"Was it worth it?",
+				"Mr. J. R. Doe thinks so, see Fig. 2 in the U.S. report.",
+			},
+		},
+		{
+			name:  "Quotes and line breaks",
+			input: "She said \"Stop.\" Then she left...\nThe end?!",
+			expected: []string{
+				"She said \"Stop.\"",
+				"Then she left...",
+				"The end?!",
+			},
+		},
+		{
+			name:  "Headings, paragraphs and code blocks",
+			input: "# Setup\nRun this. Then that.\n\n```go\nx := 1. // no split. Here\n```\n\nVersion 1.2.3 is out",
+			expected: []string{
+				"# Setup",
+				"Run this.",
+				"Then that.",
+				"```go\nx := 1. // no split. Here\n```",
+				"Version 1.2.3 is out",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := splitSentences(tt.input)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("splitSentences() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSplitSentencesMerge(t *testing.T) {
+	content := "Dr. Smith paid $3.50 for coffee. It was 2.5 times the usual price!"
+
+	var chunks, merged []*tesei.Message[files.TextFile]
+	_, err := tesei.NewPipeline[files.TextFile]().
+		Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: content}}}).
+		Sequential(SplitSentences{}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &chunks}).
+		Sequential(files.Merge{Glue: " "}).
+		Sequential(tesei.Collect[files.TextFile]{Items: &merged}).
+		Sequential(tesei.End[files.TextFile]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+
+	if len(chunks) != 2 || chunks[1].Metadata["split_index"] != 1 || chunks[1].Metadata["split_total"] != 2 {
+		t.Errorf("Expected 2 sentence chunks with split metadata, got %d", len(chunks))
+	}
+	if len(merged) != 1 || merged[0].Data.Content != content {
+		t.Errorf("Expected the merged content to equal the input, got %v", merged)
+	}
+}
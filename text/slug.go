@@ -0,0 +1,127 @@
+package text
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// slugify converts text to a lowercase, hyphen separated identifier.
+func slugify(text string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return sb.String()
+}
+
+// asciiSlugify converts text to a lowercase, hyphen separated identifier of ASCII letters and digits.
+func asciiSlugify(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if r <= unicode.MaxASCII {
+			sb.WriteRune(r)
+		} else if t, ok := transliterations[r]; ok {
+			sb.WriteString(t)
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			// unknown letters are dropped, but still separate words
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return slugify(sb.String())
+}
+
+// transliterations maps lowercase non-ASCII letters to their ASCII spelling.
+var transliterations = map[rune]string{
+	// latin
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ł': "l", 'ľ': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+	// cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "h", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+	// greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o", 'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+}
+
+// SlugName is a job that renames files after their title.
+// The title is the first H1 heading of the content or a metadata value, it is slugified
+// into a lowercase, hyphenated ASCII name, the extension of the original name is kept.
+// Files without a title keep their name, colliding names get a numeric suffix.
+type SlugName struct {
+	// Key is the metadata key holding the title. If empty, the first H1 heading is used.
+	Key string
+}
+
+func (s SlugName) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	var mu sync.Mutex
+	used := make(map[string]bool)
+
+	tesei.TransformStage(ctx, in, out, "SlugName", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		base := asciiSlugify(s.title(msg))
+		if base == "" {
+			return msg, nil
+		}
+
+		ext := filepath.Ext(msg.Data.Name)
+		name := base + ext
+
+		mu.Lock()
+		for n := 1; used[filepath.Join(msg.Data.Folder, name)]; n++ {
+			name = fmt.Sprintf("%s-%d%s", base, n, ext)
+		}
+		used[filepath.Join(msg.Data.Folder, name)] = true
+		mu.Unlock()
+
+		msg.Data.Name = name
+		return msg, nil
+	})
+}
+
+func (s SlugName) title(msg *tesei.Message[files.TextFile]) string {
+	if s.Key != "" {
+		title, _ := msg.Metadata[s.Key].(string)
+		return title
+	}
+
+	return firstTitle(msg.Data.Content)
+}
+
+// firstTitle returns the text of the first H1 heading of the content, without its {#id}.
+func firstTitle(content string) string {
+	m := Markdown{}
+	for _, h := range m.findHeadings(content, strings.Split(content, "\n")) {
+		if h.level == 1 {
+			return strings.TrimSpace(headingIDPattern.ReplaceAllString(h.text, ""))
+		}
+	}
+	return ""
+}
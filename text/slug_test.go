@@ -0,0 +1,78 @@
+package text
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestSlugName(t *testing.T) {
+	tests := []struct {
+		name  string
+		job   SlugName
+		input []files.TextFile
+		meta  map[string]any
+		want  []string
+	}{
+		{
+			name:  "punctuation",
+			input: []files.TextFile{{Name: "a.md", Content: "Intro\n\n# Hello, World: A (Short) Guide!\n\nText"}},
+			want:  []string{"hello-world-a-short-guide.md"},
+		},
+		{
+			name:  "unicode",
+			input: []files.TextFile{{Name: "a.md", Content: "# Café Über Straße\n"}, {Name: "b.md", Content: "# Привет мир\n"}},
+			want:  []string{"cafe-uber-strasse.md", "privet-mir.md"},
+		},
+		{
+			name:  "missing title",
+			input: []files.TextFile{{Name: "notes.md", Content: "## Only a subheading\n\n```\n# not a title\n```\n"}},
+			want:  []string{"notes.md"},
+		},
+		{
+			name:  "collision",
+			input: []files.TextFile{{Name: "a.md", Content: "# Setup"}, {Name: "b.md", Content: "# Setup"}, {Name: "c.txt", Content: "# Setup"}},
+			want:  []string{"setup.md", "setup-1.md", "setup.txt"},
+		},
+		{
+			name:  "metadata title",
+			job:   SlugName{Key: "title"},
+			input: []files.TextFile{{Name: "a.md", Content: "# Ignored"}},
+			meta:  map[string]any{"title": "From Metadata"},
+			want:  []string{"from-metadata.md"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: tt.input}).
+				Sequential(tesei.TransformJob[files.TextFile]{Transform: func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+					for k, v := range tt.meta {
+						msg.Metadata[k] = v
+					}
+					return msg, nil
+				}}).
+				Sequential(tt.job).
+				Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			if len(results) != len(tt.want) {
+				t.Fatalf("Expected %d results, got %d", len(tt.want), len(results))
+			}
+			for i, msg := range results {
+				if msg.Data.Name != tt.want[i] {
+					t.Errorf("Expected name %q, got %q", tt.want[i], msg.Data.Name)
+				}
+			}
+		})
+	}
+}
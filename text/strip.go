@@ -0,0 +1,134 @@
+package text
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// StripMarkdown is a job that converts markdown content to plain text.
+// Markdown syntax is removed: headings and list markers, emphasis, HTML tags, images and front-matter in YAML, TOML or JSON.
+// Links are replaced with their text, code blocks are replaced with their code.
+type StripMarkdown struct {
+	// KeepLinkURLs writes links as "text (url)" instead of just the text.
+	KeepLinkURLs bool
+	// DropCode removes fenced code blocks instead of keeping their code.
+	DropCode bool
+}
+
+var (
+	stripImagePattern     = regexp.MustCompile(`!\[[^\]]*\](?:\([^)]*\)|\[[^\]]*\])`)
+	stripLinkPattern      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	stripRefLinkPattern   = regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+	stripAutolinkPattern  = regexp.MustCompile(`<((?:https?|ftp|mailto):[^>\s]+)>`)
+	stripTagPattern       = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	stripBoldPattern      = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	stripItalicPattern    = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	stripUnderlinePattern = regexp.MustCompile(`(^|[^\w])_(\S(?:[^_]*?\S)?)_([^\w]|$)`)
+	stripStrikePattern    = regexp.MustCompile(`~~(.+?)~~`)
+	stripEscapePattern    = regexp.MustCompile(`\\([\\\x60*_{}\[\]()#+\-.!|>~])`)
+	stripHeadingPattern   = regexp.MustCompile(`^ {0,3}#{1,6}[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+	stripListPattern      = regexp.MustCompile(`^[ \t]*(?:[-*+]|\d{1,9}[.)])[ \t]+(?:\[[ xX]\][ \t]+)?`)
+	stripRulePattern      = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	stripTableRulePattern = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	stripInlineCode       = regexp.MustCompile("`+[^`]+`+")
+)
+
+func (s StripMarkdown) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	tesei.TransformStage(ctx, in, out, "StripMarkdown", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		msg.Data.Content = s.strip(msg.Data.Content)
+		return msg, nil
+	})
+}
+
+func (s StripMarkdown) strip(content string) string {
+	_, _, content, _ = detectFrontmatter(content)
+
+	var result []string
+	var fences files.FenceScanner
+	blank := true
+	add := func(line string) {
+		if strings.TrimSpace(line) == "" {
+			if !blank {
+				result = append(result, "")
+			}
+			blank = true
+			return
+		}
+		result = append(result, line)
+		blank = false
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		switch fences.Next(line) {
+		case files.OpeningFence, files.ClosingFence:
+			add("")
+			continue
+		case files.InsideFence:
+			if !s.DropCode {
+				add(line)
+			}
+			continue
+		}
+
+		if strings.Contains(line, "|") && stripTableRulePattern.MatchString(line) {
+			continue
+		}
+		if referenceDefPattern.MatchString(line) || stripRulePattern.MatchString(line) {
+			add("")
+			continue
+		}
+
+		line = quotePattern.ReplaceAllString(line, "")
+		if match := stripHeadingPattern.FindStringSubmatch(line); match != nil {
+			line = headingIDPattern.ReplaceAllString(match[1], "")
+		}
+		line = stripListPattern.ReplaceAllString(line, "")
+		if strings.HasPrefix(strings.TrimSpace(line), "|") {
+			cells := strings.Split(strings.Trim(strings.TrimSpace(line), "|"), "|")
+			for i := range cells {
+				cells[i] = strings.TrimSpace(cells[i])
+			}
+			line = strings.Join(cells, " ")
+		}
+
+		add(strings.TrimSpace(s.stripInline(line)))
+	}
+
+	return strings.TrimSpace(strings.Join(result, "\n"))
+}
+
+// stripInline removes inline markup, code spans are only unwrapped.
+func (s StripMarkdown) stripInline(line string) string {
+	var sb strings.Builder
+	last := 0
+	for _, match := range stripInlineCode.FindAllStringIndex(line, -1) {
+		sb.WriteString(s.stripText(line[last:match[0]]))
+		sb.WriteString(strings.TrimSpace(strings.Trim(line[match[0]:match[1]], "`")))
+		last = match[1]
+	}
+	sb.WriteString(s.stripText(line[last:]))
+	return sb.String()
+}
+
+func (s StripMarkdown) stripText(text string) string {
+	text = stripImagePattern.ReplaceAllString(text, "")
+	if s.KeepLinkURLs {
+		text = stripLinkPattern.ReplaceAllString(text, "$1 ($2)")
+	} else {
+		text = stripLinkPattern.ReplaceAllString(text, "$1")
+	}
+	text = stripRefLinkPattern.ReplaceAllString(text, "$1")
+	text = stripAutolinkPattern.ReplaceAllString(text, "$1")
+	text = stripTagPattern.ReplaceAllString(text, "")
+	text = stripBoldPattern.ReplaceAllString(text, "$2")
+	text = stripItalicPattern.ReplaceAllString(text, "$1")
+	text = stripUnderlinePattern.ReplaceAllString(text, "$1$2$3")
+	text = stripStrikePattern.ReplaceAllString(text, "$1")
+	text = stripEscapePattern.ReplaceAllString(text, "$1")
+	return text
+}
@@ -0,0 +1,74 @@
+package text
+
+import "testing"
+
+func TestStripMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		job      StripMarkdown
+		input    string
+		expected string
+	}{
+		{
+			name:     "Headings",
+			input:    "# Title\n\n## Section ##\n### Setup {#setup}\nText",
+			expected: "Title\n\nSection\nSetup\nText",
+		},
+		{
+			name:     "Emphasis",
+			input:    "Some **bold**, __strong__, *italic*, _em_ and ~~gone~~ text with snake_case_name and 2 * 3 * 4.",
+			expected: "Some bold, strong, italic, em and gone text with snake_case_name and 2 * 3 * 4.",
+		},
+		{
+			name:     "Link",
+			input:    "Read [the docs](https://example.com/docs \"Docs\") or [guide][g] at <https://example.com>.\n\n[g]: /guide",
+			expected: "Read the docs or guide at https://example.com.",
+		},
+		{
+			name:     "Link with URL",
+			job:      StripMarkdown{KeepLinkURLs: true},
+			input:    "Read [the docs](https://example.com/docs).",
+			expected: "Read the docs (https://example.com/docs).",
+		},
+		{
+			name:     "Image",
+			input:    "Before ![diagram](img/d.png) after\n\n![logo](logo.png)\n\nEnd",
+			expected: "Before  after\n\nEnd",
+		},
+		{
+			name:     "Code block",
+			input:    "Run:\n\n```go\nfmt.Println(\"*not bold*\")\n```\n\nUse `x_y *z*` inline.",
+			expected: "Run:\n\nfmt.Println(\"*not bold*\")\n\nUse x_y *z* inline.",
+		},
+		{
+			name:     "Code block dropped",
+			job:      StripMarkdown{DropCode: true},
+			input:    "Run:\n\n```go\nfmt.Println()\n```\n\nDone",
+			expected: "Run:\n\nDone",
+		},
+		{
+			name:     "Lists, quotes, rules and front-matter",
+			input:    "---\ntitle: X\n---\n> Quote <b>here</b>\n\n- one\n- [x] two\n1. three\n\n---\n\n| a | b |\n|---|:-:|\n| 1 | 2 |",
+			expected: "Quote here\n\none\ntwo\nthree\n\na b\n1 2",
+		},
+		{
+			name:     "TOML front-matter",
+			input:    "+++\ntitle = \"X\"\n+++\n# Title\n\nText",
+			expected: "Title\n\nText",
+		},
+		{
+			name:     "JSON front-matter",
+			input:    "{\"title\": \"X\"}\nText",
+			expected: "Text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.job.strip(tt.input)
+			if result != tt.expected {
+				t.Errorf("strip() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
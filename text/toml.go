@@ -0,0 +1,339 @@
+package text
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// orderedMap is a map which keeps the order of its keys, for front-matter conversion.
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: make(map[string]any)}
+}
+
+func (m *orderedMap) get(key string) (any, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+func (m *orderedMap) set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// plain converts the map, and the nested ones, to map[string]any.
+func (m *orderedMap) plain() map[string]any {
+	values := make(map[string]any, len(m.keys))
+	for _, key := range m.keys {
+		values[key] = plainValue(m.values[key])
+	}
+	return values
+}
+
+func plainValue(v any) any {
+	switch val := v.(type) {
+	case *orderedMap:
+		return val.plain()
+	case []any:
+		items := make([]any, len(val))
+		for i, item := range val {
+			items[i] = plainValue(item)
+		}
+		return items
+	}
+	return v
+}
+
+// parseTOML decodes a TOML document, keeping the keys in the order they are written.
+// Local dates, date-times and times are time.Time values in the locations named by the decoder,
+// see formatTime.
+func parseTOML(s string) (*orderedMap, error) {
+	var raw map[string]any
+	md, err := toml.Decode(s, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// keys of the tables in an array of tables share the path of the array
+	order := make(map[string]int)
+	for i, key := range md.Keys() {
+		path := strings.Join(key, "\x00")
+		if _, ok := order[path]; !ok {
+			order[path] = i
+		}
+	}
+	return fromTOMLTable(raw, "", order), nil
+}
+
+// fromTOMLTable sorts the keys by their position in the document,
+// the ones the decoder doesn't report, like keys of inline tables in arrays, go last by name.
+func fromTOMLTable(table map[string]any, prefix string, order map[string]int) *orderedMap {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		i, okA := order[prefix+a]
+		j, okB := order[prefix+b]
+		switch {
+		case okA && okB:
+			return i - j
+		case okA != okB:
+			if okA {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+
+	m := newOrderedMap()
+	for _, key := range keys {
+		m.set(key, fromTOMLValue(table[key], prefix+key+"\x00", order))
+	}
+	return m
+}
+
+func fromTOMLValue(v any, prefix string, order map[string]int) any {
+	switch val := v.(type) {
+	case map[string]any:
+		return fromTOMLTable(val, prefix, order)
+	case []map[string]any:
+		items := make([]any, len(val))
+		for i, item := range val {
+			items[i] = fromTOMLTable(item, prefix, order)
+		}
+		return items
+	case []any:
+		items := make([]any, len(val))
+		for i, item := range val {
+			items[i] = fromTOMLValue(item, prefix, order)
+		}
+		return items
+	}
+	return v
+}
+
+// formatTOML renders the map as a TOML document, nested maps become tables.
+// TOML has no null, so a nil value is an error.
+func formatTOML(m *orderedMap) (string, error) {
+	if key, ok := findNull(m, ""); ok {
+		return "", fmt.Errorf("%q is null, TOML has no null values", key)
+	}
+
+	var sb strings.Builder
+	writeTOMLTable(&sb, nil, m)
+	return sb.String(), nil
+}
+
+// findNull returns the dotted path of the first nil value.
+func findNull(v any, path string) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return path, true
+	case []any:
+		for i, item := range val {
+			if key, ok := findNull(item, fmt.Sprintf("%s[%d]", path, i)); ok {
+				return key, true
+			}
+		}
+	case *orderedMap:
+		for _, key := range val.keys {
+			sub := key
+			if path != "" {
+				sub = path + "." + key
+			}
+			if key, ok := findNull(val.values[key], sub); ok {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+func writeTOMLTable(sb *strings.Builder, path []string, m *orderedMap) {
+	// plain values go first, as they belong to the table header above them
+	for _, key := range m.keys {
+		value := m.values[key]
+		if isTable(value) || isTableArray(value) {
+			continue
+		}
+		sb.WriteString(formatTOMLKey(key) + " = " + formatTOMLValue(value) + "\n")
+	}
+
+	for _, key := range m.keys {
+		sub := append(path[:len(path):len(path)], key)
+		header := formatTOMLPath(sub)
+		switch value := m.values[key].(type) {
+		case *orderedMap:
+			if sb.Len() > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString("[" + header + "]\n")
+			writeTOMLTable(sb, sub, value)
+		case []any:
+			if !isTableArray(value) {
+				continue
+			}
+			for _, item := range value {
+				if sb.Len() > 0 {
+					sb.WriteString("\n")
+				}
+				sb.WriteString("[[" + header + "]]\n")
+				writeTOMLTable(sb, sub, item.(*orderedMap))
+			}
+		}
+	}
+}
+
+func isTable(v any) bool {
+	_, ok := v.(*orderedMap)
+	return ok
+}
+
+// isTableArray reports whether the value is a non-empty array of maps.
+func isTableArray(v any) bool {
+	items, ok := v.([]any)
+	if !ok || len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if !isTable(item) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatTOMLPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = formatTOMLKey(key)
+	}
+	return strings.Join(keys, ".")
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func formatTOMLKey(key string) string {
+	if key == "" {
+		return `""`
+	}
+	for i := 0; i < len(key); i++ {
+		if !isBareKeyChar(key[i]) {
+			return formatTOMLString(key)
+		}
+	}
+	return key
+}
+
+// formatTOMLValue renders a value inline, maps become inline tables.
+func formatTOMLValue(v any) string {
+	switch val := v.(type) {
+	case string:
+		return formatTOMLString(val)
+	case bool:
+		return strconv.FormatBool(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		switch {
+		case math.IsInf(val, 1):
+			return "inf"
+		case math.IsInf(val, -1):
+			return "-inf"
+		case math.IsNaN(val):
+			return "nan"
+		}
+		return formatFloat(val)
+	case time.Time:
+		return formatTime(val)
+	case []any:
+		items := make([]string, len(val))
+		for i, item := range val {
+			items[i] = formatTOMLValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *orderedMap:
+		items := make([]string, len(val.keys))
+		for i, key := range val.keys {
+			items[i] = formatTOMLKey(key) + " = " + formatTOMLValue(val.values[key])
+		}
+		if len(items) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(items, ", ") + " }"
+	}
+	return formatTOMLString(fmt.Sprint(v))
+}
+
+func formatTOMLString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// formatFloat renders a float which is read back as a float, "1.0" rather than "1".
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEn") {
+		s += ".0"
+	}
+	return s
+}
+
+// formatTime renders a date without a time of day as a plain date.
+// The local dates, date-times and times of TOML are rendered without an offset.
+func formatTime(t time.Time) string {
+	switch t.Location().String() {
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	}
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339Nano)
+}
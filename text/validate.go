@@ -0,0 +1,142 @@
+package text
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// CodeBlockError is reported for a fenced code block which fails to parse.
+type CodeBlockError struct {
+	// Index is the position of the block among the fenced blocks of the document, starting at 1.
+	Index int
+	// Lang is the declared language of the block.
+	Lang string
+	Err  error
+}
+
+func (e *CodeBlockError) Error() string {
+	return fmt.Sprintf("code block %d (%s): %v", e.Index, e.Lang, e.Err)
+}
+
+func (e *CodeBlockError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateCodeBlocks is a job that checks that fenced code blocks parse in their declared language.
+// Supported languages are Go and JSON, other blocks are skipped.
+// Failures are reported as the message error, joining a *CodeBlockError for each invalid block.
+type ValidateCodeBlocks struct{}
+
+func (v ValidateCodeBlocks) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	tesei.TransformStage(ctx, in, out, "ValidateCodeBlocks", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		var errs []error
+		for i, block := range findFencedBlocks(msg.Data.Content) {
+			validate, ok := codeValidators[strings.ToLower(block.lang)]
+			if !ok {
+				continue
+			}
+			if err := validate(block.code); err != nil {
+				errs = append(errs, &CodeBlockError{Index: i + 1, Lang: block.lang, Err: err})
+			}
+		}
+		return msg, errors.Join(errs...)
+	})
+}
+
+type fencedBlock struct {
+	lang string
+	code string
+}
+
+// findFencedBlocks returns the fenced code blocks of the content with the language of their info string.
+func findFencedBlocks(content string) []fencedBlock {
+	var blocks []fencedBlock
+	var code []string
+	var fences files.FenceScanner
+	lang := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		switch fences.Next(line) {
+		case files.OpeningFence:
+			code = nil
+			lang = ""
+			if fields := strings.Fields(line[len(fences.Match[0]):]); len(fields) > 0 {
+				lang = fields[0]
+			}
+		case files.InsideFence:
+			// strip the indentation of the fence from the code
+			indent := len(fences.Match[1])
+			code = append(code, line[min(indent, len(line)-len(strings.TrimLeft(line, " "))):])
+		case files.ClosingFence:
+			blocks = append(blocks, fencedBlock{lang: lang, code: strings.Join(code, "\n")})
+		}
+	}
+	return blocks
+}
+
+var codeValidators = map[string]func(string) error{
+	"go":     validateGo,
+	"golang": validateGo,
+	"json":   validateJSON,
+}
+
+// validateGo parses a Go snippet, which can be a whole file, a list of declarations or a list of statements.
+func validateGo(code string) error {
+	trimmed := skipComments(code)
+	var src string
+	switch {
+	case strings.HasPrefix(trimmed, "package "):
+		src = code
+	case startsWithAny(trimmed, "func ", "type ", "var ", "const ", "import ", "import("):
+		src = "package snippet\n" + code
+	default:
+		src = "package snippet\nfunc _() {\n" + code + "\n}"
+	}
+
+	_, err := parser.ParseFile(token.NewFileSet(), "", src, parser.AllErrors)
+	return err
+}
+
+// skipComments returns the code after the leading comments and whitespace, which don't tell what the snippet is.
+func skipComments(code string) string {
+	for {
+		code = strings.TrimSpace(code)
+		switch {
+		case strings.HasPrefix(code, "//"):
+			end := strings.IndexByte(code, '\n')
+			if end < 0 {
+				return ""
+			}
+			code = code[end:]
+		case strings.HasPrefix(code, "/*"):
+			end := strings.Index(code[2:], "*/")
+			if end < 0 {
+				return ""
+			}
+			code = code[2+end+2:]
+		default:
+			return code
+		}
+	}
+}
+
+func startsWithAny(s string, prefixes ...string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateJSON(code string) error {
+	var v any
+	return json.Unmarshal([]byte(code), &v)
+}
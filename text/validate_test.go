@@ -0,0 +1,86 @@
+package text
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+func TestValidateCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		invalid []int
+	}{
+		{
+			name:  "valid go",
+			input: "# Doc\n\n```go\npackage main\n\nfunc main() {}\n```\n\n```go\nx := 1\nfmt.Println(x)\n```\n\n```go\nfunc add(a, b int) int { return a + b }\n```\n",
+		},
+		{
+			name:  "leading comments",
+			input: "```go\n// Print the sum\nx := 1 + 2\nfmt.Println(x)\n```\n\n```go\n/* helper */\n// adds numbers\nfunc add(a, b int) int { return a + b }\n```\n\n```go\n// nothing yet\n```\n",
+		},
+		{
+			name:    "invalid go",
+			input:   "```go\nfunc main() {\n  x := \n}\n```\n\n```golang\nif true {\n```\n",
+			invalid: []int{1, 2},
+		},
+		{
+			name:    "json",
+			input:   "```json\n{\"a\": [1, 2]}\n```\n\n```json\n{\"a\": 1,}\n```\n",
+			invalid: []int{2},
+		},
+		{
+			name:  "unsupported and indented",
+			input: "```python\ndef (:\n```\n\n- item\n\n  ```go\n  x := 1\n  _ = x\n  ```\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []*tesei.Message[files.TextFile]
+			_, err := tesei.NewPipeline[files.TextFile]().
+				Sequential(files.Source{Files: []files.TextFile{{Name: "a.md", Content: tt.input}}}).
+				Sequential(ValidateCodeBlocks{}).
+				Sequential(tesei.Collect[files.TextFile]{Items: &results}).
+				Sequential(tesei.End[files.TextFile]{}).
+				Build().
+				Start(context.Background())
+			if err != nil {
+				t.Fatalf("Pipeline failed: %v", err)
+			}
+
+			msgErr := results[0].Error
+			if len(tt.invalid) == 0 {
+				if msgErr != nil {
+					t.Errorf("Expected no error, got %v", msgErr)
+				}
+				return
+			}
+
+			var joined interface{ Unwrap() []error }
+			if !errors.As(msgErr, &joined) {
+				t.Fatalf("Expected joined errors, got %v", msgErr)
+			}
+			var indexes []int
+			for _, err := range joined.Unwrap() {
+				var blockErr *CodeBlockError
+				if !errors.As(err, &blockErr) {
+					t.Fatalf("Expected CodeBlockError, got %v", err)
+				}
+				indexes = append(indexes, blockErr.Index)
+			}
+			if len(indexes) != len(tt.invalid) {
+				t.Fatalf("Expected invalid blocks %v, got %v (%v)", tt.invalid, indexes, msgErr)
+			}
+			for i := range indexes {
+				if indexes[i] != tt.invalid[i] {
+					t.Errorf("Expected invalid blocks %v, got %v", tt.invalid, indexes)
+				}
+			}
+		})
+	}
+}
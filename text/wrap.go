@@ -0,0 +1,83 @@
+package text
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+)
+
+// WrapText is a job that hard-wraps lines longer than Width at word boundaries.
+// Words longer than Width (like URLs) are never split, fenced code blocks are left as is.
+type WrapText struct {
+	// Width is the maximum line length. Defaults to 80.
+	Width int
+	// PreserveIndent keeps the indentation of the wrapped line on continuation lines.
+	// For list items the continuation is aligned with the item text.
+	PreserveIndent bool
+}
+
+func (w WrapText) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	tesei.TransformStage(ctx, in, out, "WrapText", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		msg.Data.Content = w.wrap(msg.Data.Content)
+		return msg, nil
+	})
+}
+
+func (w WrapText) wrap(content string) string {
+	width := w.Width
+	if width <= 0 {
+		width = 80
+	}
+
+	lines := strings.Split(content, "\n")
+	result := make([]string, 0, len(lines))
+	var fences files.FenceScanner
+
+	for _, line := range lines {
+		if fences.Next(line) != files.OutsideFence || utf8.RuneCountInString(line) <= width {
+			result = append(result, line)
+			continue
+		}
+
+		result = append(result, w.wrapLine(line, width)...)
+	}
+
+	return strings.Join(result, "\n")
+}
+
+func (w WrapText) wrapLine(line string, width int) []string {
+	text := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(text)]
+
+	hang := ""
+	if w.PreserveIndent {
+		hang = indent
+		if match := listItemPattern.FindString(line); match != "" {
+			hang = strings.Repeat(" ", len(match))
+		}
+	}
+
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{line}
+	}
+
+	var lines []string
+	current := indent + words[0]
+	size := utf8.RuneCountInString(current)
+	for _, word := range words[1:] {
+		wordSize := utf8.RuneCountInString(word)
+		if size+1+wordSize > width {
+			lines = append(lines, current)
+			current = hang + word
+			size = utf8.RuneCountInString(hang) + wordSize
+			continue
+		}
+		current += " " + word
+		size += 1 + wordSize
+	}
+
+	return append(lines, current)
+}
@@ -0,0 +1,54 @@
+package text
+
+import (
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		name     string
+		wrap     WrapText
+		input    string
+		expected string
+	}{
+		{
+			name:     "Prose is wrapped at word boundaries",
+			wrap:     WrapText{Width: 20},
+			input:    "The quick brown fox jumps over the lazy dog.\n\nShort line.",
+			expected: "The quick brown fox\njumps over the lazy\ndog.\n\nShort line.",
+		},
+		{
+			name:     "Indented list keeps hanging indent",
+			wrap:     WrapText{Width: 22, PreserveIndent: true},
+			input:    "  - first item with a long description",
+			expected: "  - first item with a\n    long description",
+		},
+		{
+			name:     "Indentation is dropped without PreserveIndent",
+			wrap:     WrapText{Width: 22},
+			input:    "  - first item with a long description",
+			expected: "  - first item with a\nlong description",
+		},
+		{
+			name:     "Long URL is not split",
+			wrap:     WrapText{Width: 20},
+			input:    "See https://example.com/a/very/long/path/to/page for details",
+			expected: "See\nhttps://example.com/a/very/long/path/to/page\nfor details",
+		},
+		{
+			name:     "Code block is untouched",
+			wrap:     WrapText{Width: 10},
+			input:    "```\nfmt.Println(\"a long line of code\")\n```",
+			expected: "```\nfmt.Println(\"a long line of code\")\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.wrap.wrap(tt.input)
+			if result != tt.expected {
+				t.Errorf("wrap() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
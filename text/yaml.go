@@ -0,0 +1,96 @@
+package text
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/mkozhukh/tesei"
+	"github.com/mkozhukh/tesei/files"
+	"gopkg.in/yaml.v3"
+)
+
+// NormalizeYAML is a job that parses the content as YAML and re-emits it with consistent formatting.
+// Comments are kept, though blank lines between entries are not.
+// Content which is not valid YAML gets an ErrYAML error and is left untouched.
+type NormalizeYAML struct {
+	// Indent is the number of spaces for each nesting level. Defaults to 2.
+	Indent int
+	// SortKeys sorts the keys of all mappings alphabetically. Comments move with their keys.
+	SortKeys bool
+}
+
+func (n NormalizeYAML) Run(ctx *tesei.Thread, in <-chan *tesei.Message[files.TextFile], out chan<- *tesei.Message[files.TextFile]) {
+	tesei.TransformStage(ctx, in, out, "NormalizeYAML", func(msg *tesei.Message[files.TextFile]) (*tesei.Message[files.TextFile], error) {
+		content, err := n.normalize(msg.Data.Content)
+		if err != nil {
+			return msg, fmt.Errorf("%w: %w", ErrYAML, err)
+		}
+		msg.Data.Content = content
+		return msg, nil
+	})
+}
+
+func (n NormalizeYAML) normalize(content string) (string, error) {
+	indent := n.Indent
+	if indent <= 0 {
+		indent = 2
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+
+	// a file can hold several documents separated by "---"
+	dec := yaml.NewDecoder(bytes.NewBufferString(content))
+	docs := 0
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		docs++
+		if n.SortKeys {
+			sortKeys(&doc)
+		}
+		if err := enc.Encode(&doc); err != nil {
+			return "", err
+		}
+	}
+
+	if docs == 0 {
+		return content, nil
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// sortKeys sorts the key-value pairs of all mappings under the node.
+func sortKeys(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		pairs := make([][2]*yaml.Node, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			pairs = append(pairs, [2]*yaml.Node{node.Content[i], node.Content[i+1]})
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return pairs[i][0].Value < pairs[j][0].Value
+		})
+		for i, pair := range pairs {
+			node.Content[2*i] = pair[0]
+			node.Content[2*i+1] = pair[1]
+		}
+	}
+
+	for _, child := range node.Content {
+		sortKeys(child)
+	}
+}
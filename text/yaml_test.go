@@ -0,0 +1,76 @@
+package text
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mkozhukh/tesei/files"
+	"github.com/mkozhukh/tesei/testutil"
+)
+
+func TestNormalizeYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		job  NormalizeYAML
+		in   string
+		want string
+	}{
+		{
+			name: "Reformat",
+			in:   "name:   app\nports:\n    - 80\n    - 443\nenv: {debug: true, level: 'info'}\n",
+			want: "name: app\nports:\n  - 80\n  - 443\nenv: {debug: true, level: 'info'}\n",
+		},
+		{
+			name: "Indent",
+			job:  NormalizeYAML{Indent: 4},
+			in:   "server:\n  host: localhost\n  port: 8080\n",
+			want: "server:\n    host: localhost\n    port: 8080\n",
+		},
+		{
+			name: "SortKeys",
+			job:  NormalizeYAML{SortKeys: true},
+			in:   "zeta: 1\nalpha:\n  c: 3\n  b: 2\nlist:\n  - y: 1\n    x: 2\n",
+			want: "alpha:\n  b: 2\n  c: 3\nlist:\n  - x: 2\n    y: 1\nzeta: 1\n",
+		},
+		{
+			name: "Comments",
+			job:  NormalizeYAML{SortKeys: true},
+			in:   "# b key\nb: 2 # second\na: 1 # first\n",
+			want: "a: 1 # first\n# b key\nb: 2 # second\n",
+		},
+		{
+			name: "Documents",
+			in:   "a:    1\n---\nb:    2\n",
+			want: "a: 1\n---\nb: 2\n",
+		},
+		{
+			name: "Empty",
+			in:   "",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := testutil.RunOne(t, files.Source{Files: []files.TextFile{{Name: "config.yaml", Content: tt.in}}}, tt.job)
+			if msg.Error != nil {
+				t.Fatalf("unexpected error: %v", msg.Error)
+			}
+			if msg.Data.Content != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", msg.Data.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeYAMLSyntaxError(t *testing.T) {
+	in := "name: app\n  ports: [80\n"
+	msg := testutil.RunOne(t, files.Source{Files: []files.TextFile{{Name: "config.yaml", Content: in}}}, NormalizeYAML{})
+
+	if !errors.Is(msg.Error, ErrYAML) {
+		t.Fatalf("expected ErrYAML, got %v", msg.Error)
+	}
+	if msg.Data.Content != in {
+		t.Errorf("content changed: %q", msg.Data.Content)
+	}
+}
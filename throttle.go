@@ -0,0 +1,54 @@
+package tesei
+
+import "time"
+
+// ThrottleJob is a job that passes at most Rate messages in any Per interval, keeping their order.
+// Up to Rate messages pass at once, then each next message waits until the message Rate positions
+// before it has passed Per ago. While waiting the job doesn't read its input, so the upstream stages block.
+type ThrottleJob[T any] struct {
+	// Rate is the number of messages per interval.
+	Rate int
+	// Per is the interval. Defaults to 1s.
+	Per time.Duration
+}
+
+func (t ThrottleJob[T]) Run(ctx *Thread, in <-chan *Message[T], out chan<- *Message[T]) {
+	defer close(out)
+
+	per := t.Per
+	if per <= 0 {
+		per = time.Second
+	}
+
+	// times of the last Rate messages, a ring with the oldest one at next
+	passed := make([]time.Time, max(t.Rate, 1))
+	next := 0
+	for {
+		if wait := time.Until(passed[next].Add(per)); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+			passed[next] = time.Now()
+			next = (next + 1) % len(passed)
+
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package tesei
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	items := make([]int, 30)
+	for i := range items {
+		items[i] = i
+	}
+
+	var times []time.Time
+	var results []*Message[int]
+	start := time.Now()
+	_, err := NewPipeline[int]().
+		Sequential(Slice[int]{Items: items}).
+		Throttle(10, 100*time.Millisecond).
+		Sequential(TransformJob[int]{
+			Transform: func(msg *Message[int]) (*Message[int], error) {
+				times = append(times, time.Now())
+				return msg, nil
+			},
+		}).
+		Sequential(Collect[int]{Items: &results}).
+		Sequential(End[int]{}).
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if len(results) != len(items) {
+		t.Fatalf("got %d messages, want %d", len(results), len(items))
+	}
+	for i, msg := range results {
+		if msg.Data != i {
+			t.Fatalf("message %d = %d, the order is not kept", i, msg.Data)
+		}
+	}
+
+	// 30 messages at 10 per 100ms: the first 10 pass at once, the rest in two more intervals
+	if elapsed < 195*time.Millisecond || elapsed > 400*time.Millisecond {
+		t.Errorf("Expected the run to take about 200ms, got %v", elapsed)
+	}
+	// no interval holds more than 10 messages
+	for i := 10; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-10]); gap < 95*time.Millisecond {
+			t.Errorf("Expected messages %d and %d to be 100ms apart, got %v", i-10, i, gap)
+		}
+	}
+}
+
+func TestThrottleCancel(t *testing.T) {
+	base, cancel := context.WithCancel(context.Background())
+	ctx := NewThread(base, 1)
+
+	in := make(chan *Message[int], 2)
+	out := make(chan *Message[int], 2)
+	in <- NewMessage(1)
+	in <- NewMessage(2)
+
+	done := make(chan struct{})
+	go func() {
+		ThrottleJob[int]{Rate: 1, Per: time.Hour}.Run(ctx, in, out)
+		close(done)
+	}()
+
+	<-out
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ThrottleJob didn't stop on cancellation")
+	}
+	if _, ok := <-out; ok {
+		t.Error("Expected the second message to be held back")
+	}
+}
@@ -0,0 +1,128 @@
+package tesei
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPipeline is reported by Validate for a misconfigured pipeline.
+var ErrInvalidPipeline = errors.New("invalid pipeline")
+
+// Validate checks the pipeline for misconfigurations which would fail or deadlock at runtime:
+// nil jobs, FanOut without workers, OrderedFanOut without a window, Parallel and Tee without jobs,
+// misconfigured jobs, like a Dedup without Seen, and, with WithRequiredEnd, a pipeline
+// which doesn't end with End (or Tee), so its output is never drained.
+// It returns all found problems joined, each wrapping ErrInvalidPipeline.
+func (p *Pipeline[T]) Validate() error {
+	errs := p.validateStages()
+	if p.requireEnd && len(p.stages) > 0 && !endsWithSink(p.stages[len(p.stages)-1]) {
+		errs = append(errs, fmt.Errorf("%w: the last stage is not End, the output will never be drained", ErrInvalidPipeline))
+	}
+	return errors.Join(errs...)
+}
+
+// WithRequiredEnd makes Validate report a pipeline which doesn't end with End (or Tee).
+// Set it when the Output of the executor isn't read, as the pipeline then blocks once the buffers are full.
+func (p *Pipeline[T]) WithRequiredEnd() *Pipeline[T] {
+	p.requireEnd = true
+	return p
+}
+
+// WithValidation makes the executor check the pipeline before running:
+// Start returns the errors of Validate, and Run reports the stage problems to the thread.
+func (p *Pipeline[T]) WithValidation() *Pipeline[T] {
+	p.validation = true
+	return p
+}
+
+// checker is implemented by jobs which can detect their own misconfiguration before the run.
+type checker interface {
+	check() error
+}
+
+func (p *Pipeline[T]) validateStages() []error {
+	var errs []error
+	fail := func(i int, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: stage %d: %s", ErrInvalidPipeline, i, fmt.Sprintf(format, args...)))
+	}
+	checkJob := func(i int, job Job[T]) {
+		if c, ok := job.(checker); ok {
+			if err := c.check(); err != nil {
+				fail(i, "%v", err)
+			}
+		}
+	}
+
+	for i, s := range p.stages {
+		switch st := s.(type) {
+		case *sequentialStage[T]:
+			if st.job == nil {
+				fail(i, "nil job")
+			}
+			checkJob(i, st.job)
+		case *fanOutStage[T]:
+			if st.job == nil {
+				fail(i, "nil job")
+			}
+			checkJob(i, st.job)
+			if st.count <= 0 {
+				fail(i, "fan-out with %d workers, at least 1 is required", st.count)
+			}
+		case *orderedFanOutStage[T]:
+			if st.job == nil {
+				fail(i, "nil job")
+			}
+			checkJob(i, st.job)
+			if st.count <= 0 {
+				fail(i, "fan-out with %d workers, at least 1 is required", st.count)
+			}
+			if st.window <= 0 {
+				fail(i, "ordered fan-out with a window of %d, at least 1 is required", st.window)
+			}
+		case *autoFanOutStage[T]:
+			if st.job == nil {
+				fail(i, "nil job")
+			}
+			checkJob(i, st.job)
+			if st.min < 0 {
+				fail(i, "auto fan-out with %d min workers", st.min)
+			}
+		case *parallelStage[T]:
+			if len(st.jobs) == 0 {
+				fail(i, "parallel stage without jobs")
+			}
+			for j, job := range st.jobs {
+				if job == nil {
+					fail(i, "nil job in parallel branch %d", j)
+				}
+				checkJob(i, job)
+			}
+		case *teeStage[T]:
+			if len(st.sinks) == 0 {
+				fail(i, "tee stage without sinks")
+			}
+			for j, job := range st.sinks {
+				if job == nil {
+					fail(i, "nil sink %d", j)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// endsWithSink checks that the stage consumes all messages, like End or a nested pipeline ending with End.
+func endsWithSink[T any](s stage[T]) bool {
+	switch st := s.(type) {
+	case *teeStage[T]:
+		return true
+	case *sequentialStage[T]:
+		switch job := st.job.(type) {
+		case End[T], *End[T]:
+			return true
+		case *executor[T]:
+			return len(job.stages) > 0 && endsWithSink(job.stages[len(job.stages)-1])
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package tesei_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mkozhukh/tesei"
+)
+
+func TestPipelineValidate(t *testing.T) {
+	job := &tesei.TransformJob[int]{Transform: func(msg *tesei.Message[int]) (*tesei.Message[int], error) {
+		return msg, nil
+	}}
+	nested := tesei.NewPipeline[int]().Sequential(job, tesei.End[int]{}).Build()
+
+	tests := []struct {
+		name     string
+		pipeline *tesei.Pipeline[int]
+		expected []string
+	}{
+		{
+			name:     "Valid",
+			pipeline: tesei.NewPipeline[int]().Sequential(tesei.Slice[int]{}).FanOut(job, 2).Sequential(tesei.End[int]{}),
+		},
+		{
+			name:     "Ends with a nested pipeline",
+			pipeline: tesei.NewPipeline[int]().Sequential(tesei.Slice[int]{}, nested),
+		},
+		{
+			name:     "Ends with Tee",
+			pipeline: tesei.NewPipeline[int]().Sequential(tesei.Slice[int]{}).Tee(tesei.End[int]{}),
+		},
+		{
+			name:     "Zero-count fan-out",
+			pipeline: tesei.NewPipeline[int]().Sequential(tesei.Slice[int]{}).FanOut(job, 0).Sequential(tesei.End[int]{}),
+			expected: []string{"stage 1: fan-out with 0 workers"},
+		},
+		{
+			name:     "Zero ordered window",
+			pipeline: tesei.NewPipeline[int]().WithOrderedWindow(0).Sequential(tesei.Slice[int]{}).OrderedFanOut(job, 2).Sequential(tesei.End[int]{}),
+			expected: []string{"stage 1: ordered fan-out with a window of 0"},
+		},
+		{
+			name:     "Empty parallel",
+			pipeline: tesei.NewPipeline[int]().Sequential(tesei.Slice[int]{}).Parallel().Sequential(tesei.End[int]{}),
+			expected: []string{"stage 1: parallel stage without jobs"},
+		},
+		{
+			name:     "Output read by the caller",
+			pipeline: tesei.NewPipeline[int]().Sequential(tesei.Slice[int]{}, job),
+		},
+		{
+			name:     "Missing End and nil job",
+			pipeline: tesei.NewPipeline[int]().WithRequiredEnd().Sequential(tesei.Slice[int]{}, nil, job),
+			expected: []string{"stage 1: nil job", "the last stage is not End"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pipeline.Validate()
+			if len(tt.expected) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tesei.ErrInvalidPipeline) {
+				t.Fatalf("Expected ErrInvalidPipeline, got %v", err)
+			}
+			for _, text := range tt.expected {
+				if !strings.Contains(err.Error(), text) {
+					t.Errorf("Expected %q in %q", text, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineWithValidation(t *testing.T) {
+	job := &tesei.TransformJob[int]{Transform: func(msg *tesei.Message[int]) (*tesei.Message[int], error) {
+		return msg, nil
+	}}
+
+	_, err := tesei.NewPipeline[int]().
+		Sequential(tesei.Slice[int]{Items: []int{1}}).
+		FanOut(job, 0).
+		Sequential(tesei.End[int]{}).
+		WithValidation().
+		Build().
+		Start(context.Background())
+	if !errors.Is(err, tesei.ErrInvalidPipeline) {
+		t.Errorf("Expected Start to fail validation, got %v", err)
+	}
+
+	// nested pipelines are checked without requiring End
+	nested := tesei.NewPipeline[int]().Sequential(job).WithValidation().Build()
+	_, err = tesei.NewPipeline[int]().
+		Sequential(tesei.Slice[int]{Items: []int{1}}, nested, tesei.End[int]{}).
+		WithValidation().
+		Build().
+		Start(context.Background())
+	if err != nil {
+		t.Errorf("Expected a valid pipeline, got %v", err)
+	}
+}